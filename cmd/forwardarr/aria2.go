@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/aria2"
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newAria2Connector returns the function that connects to aria2
+func newAria2Connector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	opts, err := aria2Options(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QbitNetworkInterface != "" || cfg.QbitInterfaceAddress != "" {
+		slog.Warn("TORRENT_CLIENT_NETWORK_INTERFACE and TORRENT_CLIENT_INTERFACE_ADDRESS are not supported by aria2, set its interface option instead")
+	}
+
+	return func() (torrent.Client, error) {
+		client, err := aria2.NewClient(cfg.QbitAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRetryPolicy(retryPolicy(cfg))
+		return client, nil
+	}, nil
+}
+
+// aria2Options returns the connection options of the aria2 client. The
+// rpc-secret of aria2 is the API key of the apikey auth method; the other
+// methods send no secret.
+func aria2Options(cfg *config.Config) (aria2.Options, error) {
+	auth, err := qbitAuth(cfg)
+	if err != nil {
+		return aria2.Options{}, err
+	}
+	transport, err := transportOptions(cfg, "aria2")
+	if err != nil {
+		return aria2.Options{}, err
+	}
+	return aria2.Options{Secret: auth.APIKey, Transport: transport}, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestAria2Options(t *testing.T) {
+	opts, err := aria2Options(&config.Config{QbitAuth: "apikey", QbitAPIKey: "s3cret"})
+	if err != nil {
+		t.Fatalf("aria2Options() error = %v", err)
+	}
+	if opts.Secret != "s3cret" {
+		t.Errorf("Secret = %q, want s3cret", opts.Secret)
+	}
+
+	opts, err = aria2Options(&config.Config{QbitUser: "admin", QbitPass: "adminadmin"})
+	if err != nil {
+		t.Fatalf("aria2Options() error = %v", err)
+	}
+	if opts.Secret != "" {
+		t.Errorf("Secret = %q, want none with password auth", opts.Secret)
+	}
+}
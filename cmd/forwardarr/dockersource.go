@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/docker"
+	"github.com/eslutz/forwardarr/internal/source"
+)
+
+// dockerSource returns the Docker source, or nil if DOCKER_SOURCE_CONTAINER
+// is not set. DOCKER_SOURCE_ENV and DOCKER_SOURCE_LABEL take precedence over
+// the command, which has a default.
+func dockerSource(cfg *config.Config) (*source.Docker, error) {
+	if cfg.DockerSourceContainer == "" {
+		return nil, nil
+	}
+	client, err := docker.NewClient(cfg.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+	opts := source.DockerOptions{Env: cfg.DockerSourceEnv, Label: cfg.DockerSourceLabel}
+	if opts.Env == "" && opts.Label == "" {
+		opts.Command = cfg.DockerSourceCommand
+	}
+	docker, err := source.NewDocker(client, cfg.DockerSourceContainer, opts)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("reading the forwarded port from a container through the Docker API",
+		"container", cfg.DockerSourceContainer,
+		"host", cfg.DockerHost,
+		"env", opts.Env,
+		"label", opts.Label,
+		"command", opts.Command,
+		"poll_interval", cfg.DockerSourcePollInterval,
+	)
+	return docker, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestDockerSource(t *testing.T) {
+	source, err := dockerSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("dockerSource() = %v, %v, want none without container", source, err)
+	}
+
+	for name, cfg := range map[string]config.Config{
+		"command": {DockerSourceContainer: "gluetun", DockerSourceCommand: []string{"cat", "/tmp/gluetun/forwarded_port"}},
+		"env":     {DockerSourceContainer: "gluetun", DockerHost: "tcp://docker-proxy:2375", DockerSourceEnv: "VPN_PORT", DockerSourceCommand: []string{"cat", "/tmp/gluetun/forwarded_port"}},
+	} {
+		if source, err := dockerSource(&cfg); err != nil || source == nil {
+			t.Errorf("dockerSource() with %s = %v, %v, want a source", name, source, err)
+		}
+	}
+
+	for name, cfg := range map[string]config.Config{
+		"host":          {DockerSourceContainer: "gluetun", DockerHost: "ssh://docker", DockerSourceEnv: "VPN_PORT"},
+		"env and label": {DockerSourceContainer: "gluetun", DockerSourceEnv: "VPN_PORT", DockerSourceLabel: "vpn.port"},
+		"no command":    {DockerSourceContainer: "gluetun"},
+	} {
+		if _, err := dockerSource(&cfg); err == nil {
+			t.Errorf("dockerSource() with invalid %s error = nil", name)
+		}
+	}
+}
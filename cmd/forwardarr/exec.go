@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/eslutz/forwardarr/internal/command"
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newExecConnector returns the function that returns the exec client. The
+// command is looked up right away, so that a missing command is reported
+// before connection attempts.
+func newExecConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	client, err := command.NewClient(cfg.ExecCommand, command.Options{Timeout: cfg.ExecTimeout})
+	if err != nil {
+		return nil, err
+	}
+	return func() (torrent.Client, error) {
+		return client, nil
+	}, nil
+}
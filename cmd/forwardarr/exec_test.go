@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestNewExecConnector(t *testing.T) {
+	connect, err := newExecConnector(&config.Config{ExecCommand: "true"})
+	if err != nil {
+		t.Fatalf("newExecConnector() error = %v", err)
+	}
+	client, err := connect()
+	if err != nil || client == nil {
+		t.Fatalf("connect() = %v, %v", client, err)
+	}
+	if err := client.SetPort(6000); err != nil {
+		t.Errorf("SetPort() error = %v", err)
+	}
+
+	if _, err := newExecConnector(&config.Config{ExecCommand: filepath.Join(t.TempDir(), "missing.sh")}); err == nil {
+		t.Error("newExecConnector() error = nil, want missing command")
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/source"
+)
+
+// execSource returns the exec source, or nil if EXEC_SOURCE_COMMAND is not
+// set. The command is looked up right away, so that a missing command is
+// reported at startup.
+func execSource(cfg *config.Config) (*source.Exec, error) {
+	if cfg.ExecSourceCommand == "" {
+		return nil, nil
+	}
+	exec, err := source.NewExec(cfg.ExecSourceCommand, source.ExecOptions{Timeout: cfg.ExecSourceTimeout})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("reading the forwarded port from a command",
+		"command", cfg.ExecSourceCommand,
+		"poll_interval", cfg.ExecSourcePollInterval,
+		"timeout", cfg.ExecSourceTimeout,
+	)
+	return exec, nil
+}
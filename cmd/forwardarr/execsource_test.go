@@ -0,0 +1,25 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestExecSource(t *testing.T) {
+	source, err := execSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("execSource() = %v, %v, want none without command", source, err)
+	}
+
+	source, err = execSource(&config.Config{ExecSourceCommand: "true"})
+	if err != nil || source == nil {
+		t.Errorf("execSource() = %v, %v, want a source", source, err)
+	}
+
+	_, err = execSource(&config.Config{ExecSourceCommand: filepath.Join(t.TempDir(), "port.sh")})
+	if err == nil {
+		t.Error("execSource() error = nil, want missing command")
+	}
+}
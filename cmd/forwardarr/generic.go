@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/generic"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newGenericConnector returns the function that returns the generic client.
+// The client sends no request until the first port, so it is created right
+// away, which reports invalid templates before connection attempts.
+func newGenericConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	opts, err := genericOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QbitEnforceStaticPort || cfg.QbitNetworkInterface != "" || cfg.QbitInterfaceAddress != "" {
+		slog.Warn("TORRENT_CLIENT_ENFORCE_STATIC_PORT, TORRENT_CLIENT_NETWORK_INTERFACE and TORRENT_CLIENT_INTERFACE_ADDRESS have no effect on the generic client")
+	}
+
+	client, err := generic.NewClient(cfg.QbitAddr, opts)
+	if err != nil {
+		return nil, err
+	}
+	client.SetRetryPolicy(retryPolicy(cfg))
+	return func() (torrent.Client, error) {
+		return client, nil
+	}, nil
+}
+
+// genericOptions returns the request options of the generic client. The
+// password method sends the credentials with basic authentication, apikey
+// sends the key as bearer token and bypass sends neither.
+func genericOptions(cfg *config.Config) (generic.Options, error) {
+	auth, err := qbitAuth(cfg)
+	if err != nil {
+		return generic.Options{}, err
+	}
+	transport, err := transportOptions(cfg, "generic target")
+	if err != nil {
+		return generic.Options{}, err
+	}
+	return generic.Options{
+		Method:      cfg.GenericMethod,
+		Body:        cfg.GenericBody,
+		ContentType: cfg.GenericContentType,
+		Headers:     cfg.GenericHeaders,
+		Username:    auth.Username,
+		Password:    auth.Password,
+		BearerToken: auth.APIKey,
+		Transport:   transport,
+	}, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestGenericOptions(t *testing.T) {
+	cfg := &config.Config{
+		QbitAuth:       "apikey",
+		QbitAPIKey:     "token",
+		GenericMethod:  "PUT",
+		GenericBody:    `{"port": {{.Port}}}`,
+		GenericHeaders: map[string]string{"X-Source": "forwardarr"},
+	}
+	opts, err := genericOptions(cfg)
+	if err != nil {
+		t.Fatalf("genericOptions() error = %v", err)
+	}
+	if opts.BearerToken != "token" || opts.Username != "" || opts.Method != "PUT" || opts.Headers["X-Source"] != "forwardarr" {
+		t.Errorf("genericOptions() = %+v", opts)
+	}
+
+	opts, err = genericOptions(&config.Config{QbitAuth: "bypass", QbitUser: "admin", QbitPass: "secret"})
+	if err != nil {
+		t.Fatalf("genericOptions() error = %v", err)
+	}
+	if opts.Username != "" || opts.Password != "" || opts.BearerToken != "" {
+		t.Errorf("genericOptions() = %+v, want no credentials with bypass", opts)
+	}
+}
+
+func TestNewGenericConnector_InvalidTemplate(t *testing.T) {
+	cfg := &config.Config{QbitAddr: "http://service/port", QbitAuth: "bypass", GenericBody: "{{.Port"}
+	if _, err := newGenericConnector(cfg); err == nil {
+		t.Error("newGenericConnector() error = nil, want invalid template")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/vpn"
+)
+
+// gluetunControl returns the client of the Gluetun control server that
+// replaces the port file, or nil if none is configured
+func gluetunControl(cfg *config.Config) (*vpn.ControlServer, error) {
+	if cfg.GluetunControlURL == "" {
+		return nil, nil
+	}
+	apiKey, err := secretValue(cfg.GluetunControlAPIKey, cfg.GluetunControlAPIKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Gluetun control server API key: %w", err)
+	}
+	slog.Info("reading the forwarded port from the Gluetun control server",
+		"url", cfg.GluetunControlURL,
+		"poll_interval", cfg.GluetunControlPollInterval,
+	)
+	return vpn.NewControlServer(cfg.GluetunControlURL, apiKey), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestGluetunControl(t *testing.T) {
+	control, err := gluetunControl(&config.Config{})
+	if err != nil || control != nil {
+		t.Errorf("gluetunControl() = %v, %v, want none without URL", control, err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "api_key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	control, err = gluetunControl(&config.Config{GluetunControlURL: "http://gluetun:8000", GluetunControlAPIKeyFile: keyFile})
+	if err != nil || control == nil {
+		t.Errorf("gluetunControl() = %v, %v, want a control server", control, err)
+	}
+
+	_, err = gluetunControl(&config.Config{GluetunControlURL: "http://gluetun:8000", GluetunControlAPIKeyFile: filepath.Join(t.TempDir(), "missing")})
+	if err == nil {
+		t.Error("gluetunControl() error = nil, want missing key file")
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/source"
+)
+
+// httpSource returns the HTTP source, or nil if HTTP_SOURCE_URL is not set
+func httpSource(cfg *config.Config) (*source.HTTP, error) {
+	if cfg.HTTPSourceURL == "" {
+		return nil, nil
+	}
+	http, err := source.NewHTTP(cfg.HTTPSourceURL, source.HTTPOptions{
+		Headers:  cfg.HTTPSourceHeaders,
+		JSONPath: cfg.HTTPSourceJSONPath,
+		Regex:    cfg.HTTPSourceRegex,
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("reading the forwarded port from an HTTP endpoint",
+		"url", cfg.HTTPSourceURL,
+		"poll_interval", cfg.HTTPSourcePollInterval,
+	)
+	return http, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestHTTPSource(t *testing.T) {
+	source, err := httpSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("httpSource() = %v, %v, want none without URL", source, err)
+	}
+
+	source, err = httpSource(&config.Config{HTTPSourceURL: "http://vpn:8080/port", HTTPSourceJSONPath: "$.port"})
+	if err != nil || source == nil {
+		t.Errorf("httpSource() = %v, %v, want a source", source, err)
+	}
+
+	_, err = httpSource(&config.Config{HTTPSourceURL: "http://vpn:8080/port", HTTPSourceRegex: `(\d+`})
+	if err == nil {
+		t.Error("httpSource() error = nil, want invalid regex")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/kubernetes"
+	"github.com/eslutz/forwardarr/internal/source"
+)
+
+// kubernetesSource returns the Kubernetes source, or nil if neither
+// KUBERNETES_SOURCE_CONFIGMAP nor KUBERNETES_SOURCE_POD is set. It fails
+// outside of a pod, which has no service account to use.
+func kubernetesSource(cfg *config.Config) (*source.Kubernetes, error) {
+	if cfg.KubernetesSourceConfigMap == "" && cfg.KubernetesSourcePod == "" {
+		return nil, nil
+	}
+	client, err := kubernetes.InCluster()
+	if err != nil {
+		return nil, err
+	}
+	return newKubernetesSource(cfg, client)
+}
+
+// newKubernetesSource creates the Kubernetes source with the client
+func newKubernetesSource(cfg *config.Config, client *kubernetes.Client) (*source.Kubernetes, error) {
+	opts := source.KubernetesOptions{
+		Namespace: cfg.KubernetesSourceNamespace,
+		ConfigMap: cfg.KubernetesSourceConfigMap,
+		Pod:       cfg.KubernetesSourcePod,
+		// Only used while the watch fails
+		PollInterval: cfg.KubernetesSourcePollInterval,
+	}
+	if opts.ConfigMap != "" {
+		opts.Key = cfg.KubernetesSourceKey
+	} else {
+		opts.Annotation = cfg.KubernetesSourceAnnotation
+	}
+	kubernetes, err := source.NewKubernetes(client, opts)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("watching the forwarded port through the Kubernetes API",
+		"namespace", cfg.KubernetesSourceNamespace,
+		"configmap", opts.ConfigMap,
+		"key", opts.Key,
+		"pod", opts.Pod,
+		"annotation", opts.Annotation,
+		"poll_interval", cfg.KubernetesSourcePollInterval,
+	)
+	return kubernetes, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/kubernetes"
+)
+
+func TestKubernetesSource(t *testing.T) {
+	source, err := kubernetesSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("kubernetesSource() = %v, %v, want none without ConfigMap or pod", source, err)
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := kubernetesSource(&config.Config{KubernetesSourceConfigMap: "gluetun", KubernetesSourceKey: "port"}); err == nil {
+		t.Error("kubernetesSource() outside of a pod error = nil")
+	}
+
+	client, err := kubernetes.NewClient("https://kubernetes.default.svc", kubernetes.Options{Namespace: "media"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, cfg := range map[string]config.Config{
+		"configmap": {KubernetesSourceConfigMap: "gluetun", KubernetesSourceKey: "port"},
+		"pod":       {KubernetesSourcePod: "qbittorrent-0", KubernetesSourceKey: "port", KubernetesSourceAnnotation: "gluetun/forwarded-port"},
+	} {
+		if source, err := newKubernetesSource(&cfg, client); err != nil || source == nil {
+			t.Errorf("newKubernetesSource() with %s = %v, %v, want a source", name, source, err)
+		}
+	}
+	if _, err := newKubernetesSource(&config.Config{KubernetesSourcePod: "qbittorrent-0", KubernetesSourceKey: "port"}, client); err == nil {
+		t.Error("newKubernetesSource() without annotation error = nil")
+	}
+}
@@ -10,11 +10,8 @@ import (
 	"time"
 
 	"github.com/eslutz/forwardarr/internal/config"
-	"github.com/eslutz/forwardarr/internal/qbit"
-	"github.com/eslutz/forwardarr/internal/server"
 	"github.com/eslutz/forwardarr/internal/sync"
-	"github.com/eslutz/forwardarr/internal/webhook"
-	_ "github.com/eslutz/forwardarr/pkg/version"
+	"github.com/eslutz/forwardarr/internal/torrent"
 )
 
 func main() {
@@ -26,84 +23,137 @@ func main() {
 
 	slog.Info("starting forwardarr",
 		"gluetun_port_file", cfg.GluetunPortFile,
-		"qbit_addr", cfg.QbitAddr,
+		"torrent_client", cfg.TorrentClient,
+		"torrent_client_address", torrentClientTarget(cfg),
+		"additional_torrent_clients", len(cfg.TorrentClients),
+		"qbit_auth", cfg.QbitAuth,
 		"startup_retry_delay", startupRetryDelay,
 		"startup_timeout", startupTimeout,
 		"startup_max_attempts", startupMaxAttempts,
 		"sync_interval", cfg.SyncInterval,
-		"metrics_port", cfg.MetricsPort,
+		"server_enabled", cfg.ServerEnabled,
+		"server_addresses", cfg.ServerAddresses,
 		"webhook_enabled", cfg.WebhookEnabled,
 	)
 
-	qbitClient, err := createQbitClientWithRetry(cfg, startupRetryDelay, startupTimeout, startupMaxAttempts)
+	qbitClient, err := createTorrentClients(cfg, startupRetryDelay, startupTimeout, startupMaxAttempts)
 	if err != nil {
-		slog.Error("failed to create qBittorrent client", "error", err)
+		slog.Error("failed to create torrent client", "torrent_client", cfg.TorrentClient, "error", err)
 		os.Exit(1)
 	}
 
-	var webhookClient *webhook.Client
-	if cfg.WebhookEnabled {
-		webhookClient = webhook.NewClient(
-			cfg.WebhookURL,
-			cfg.WebhookTimeout,
-			webhook.Template(cfg.WebhookTemplate),
-			cfg.WebhookEvents,
-		)
-		slog.Info("webhook notifications enabled",
-			"url", cfg.WebhookURL,
-			"timeout", cfg.WebhookTimeout,
-			"template", cfg.WebhookTemplate,
-			"events", cfg.WebhookEvents,
-		)
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		slog.Error("failed to configure webhooks", "error", err)
+		os.Exit(1)
 	}
 
-	watcher, err := sync.NewWatcher(cfg.GluetunPortFile, qbitClient, webhookClient, cfg.SyncInterval)
+	sources, err := newPortSources(cfg)
+	if err != nil {
+		slog.Error("failed to configure the port source", "error", err)
+		os.Exit(1)
+	}
+	// Other sources replace the port file, which is then not watched
+	watcher, err := sync.NewWatcher(sources.portFile, qbitClient, notifier, cfg.SyncInterval)
 	if err != nil {
 		slog.Error("failed to create file watcher", "error", err)
 		os.Exit(1)
 	}
+	watcher.SetPortFileFormat(sources.portFileFormat)
+	watcher.SetSourceHealth(sources.name, cfg.SourceUnhealthyFailures, cfg.SourceStaleAfter)
+	if sources.source != nil {
+		watcher.SetPortSource(sources.source, sources.pollInterval)
+	}
+	for _, mapper := range sources.mappers {
+		// Map the port before the first sync, which would otherwise find
+		// none and report the VPN down
+		if err := mapper.Refresh(); err != nil {
+			slog.Warn("initial port mapping failed, retrying in the background", "error", err)
+		}
+	}
+	history, err := sync.NewHistory(cfg.SyncHistorySize, cfg.SyncHistoryFile)
+	if err != nil {
+		slog.Error("failed to load sync history", "error", err)
+		os.Exit(1)
+	}
+	watcher.SetHistory(history)
+	reannounce, err := sync.ParseReannounce(cfg.Reannounce)
+	if err != nil {
+		slog.Error("invalid REANNOUNCE_ON_PORT_CHANGE", "error", err)
+		os.Exit(1)
+	}
+	watcher.SetReannounce(reannounce)
 
-	srv := server.NewServer(cfg.MetricsPort, qbitClient)
-
-	// Start HTTP server in goroutine
-	go func() {
-		if err := srv.Start(); err != nil {
-			slog.Error("http server failed", "error", err)
-			os.Exit(1)
+	srv, err := newServer(cfg, qbitClient)
+	if err != nil {
+		slog.Error("failed to configure http server", "error", err)
+		os.Exit(1)
+	}
+	reload := &reloader{watcher: watcher, server: srv, cfg: cfg, notifier: notifier}
+	if srv != nil {
+		srv.SetPortSyncer(watcher)
+		if notifier != nil {
+			srv.SetWebhooks(notifier)
 		}
-	}()
+		srv.SetConfigManager(reload)
+	}
 
-	// Setup graceful shutdown
+	// Setup graceful shutdown; notifications still in progress on shutdown
+	// are canceled
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	notifyStartup(ctx, notifier, qbitClient)
+
+	// Start HTTP server in goroutine
+	if srv != nil {
+		go func() {
+			if err := srv.Start(); err != nil {
+				slog.Error("http server failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	for _, mapper := range sources.mappers {
+		go mapper.Run(ctx)
+	}
+
 	// Start watcher in goroutine
 	watcherDone := make(chan error, 1)
 	go func() {
-		watcherDone <- watcher.Start()
+		watcherDone <- watcher.Start(ctx)
 	}()
 
-	// Wait for shutdown signal or watcher error
+	// Wait for shutdown signal or watcher error. The watcher also stops
+	// without error once it sees the signal.
 	select {
 	case <-ctx.Done():
-		slog.Info("received shutdown signal, gracefully stopping...")
-
-		// Give time for cleanup
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		if err := srv.Shutdown(shutdownCtx); err != nil {
-			slog.Error("server shutdown error", "error", err)
-		}
-
-		slog.Info("shutdown complete")
-
 	case err := <-watcherDone:
 		if err != nil {
 			slog.Error("watcher failed", "error", err)
+			notifyShutdown(reload.currentNotifier(), qbitClient)
 			os.Exit(1)
 		}
 	}
+
+	// Restore the default signal handling, so that a second signal stops
+	// immediately instead of waiting for the drain
+	stop()
+	slog.Info("received shutdown signal, gracefully stopping...", "drain_timeout", cfg.ShutdownTimeout)
+
+	// Let requests in progress, such as probes and webhook tests, finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if srv != nil {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("server shutdown error", "error", err)
+		}
+	}
+
+	notifyShutdown(reload.currentNotifier(), qbitClient)
+	slog.Info("shutdown complete")
 }
 
 func setupLogging(level string) {
@@ -128,23 +178,53 @@ func setupLogging(level string) {
 	slog.SetDefault(slog.New(handler))
 }
 
-func createQbitClientWithRetry(cfg *config.Config, retryDelay, startupTimeout time.Duration, maxAttempts int) (*qbit.Client, error) {
+// createTorrentClients connects to the configured torrent clients, each with
+// its own startup timeout. The options of all clients are validated before
+// connecting to any. Several clients are combined into a group that receives
+// every port.
+func createTorrentClients(cfg *config.Config, retryDelay, startupTimeout time.Duration, maxAttempts int) (torrent.Client, error) {
+	configs := torrentClientConfigs(cfg)
+	connectors := make([]func() (torrent.Client, error), len(configs))
+	for i, clientCfg := range configs {
+		connect, err := newTorrentConnector(clientCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s at %s: %w", torrentClientName(clientCfg), torrentClientTarget(clientCfg), err)
+		}
+		connectors[i] = connect
+	}
+
+	members := make([]torrent.Member, len(configs))
+	for i, clientCfg := range configs {
+		client, err := createTorrentClientWithRetry(clientCfg, connectors[i], retryDelay, startupTimeout, maxAttempts)
+		if err != nil {
+			return nil, err
+		}
+		members[i] = torrent.Member{Name: torrentClientName(clientCfg) + " at " + torrentClientTarget(clientCfg), Client: client}
+	}
+	if len(members) == 1 {
+		return members[0].Client, nil
+	}
+	return torrent.NewGroup(members...), nil
+}
+
+func createTorrentClientWithRetry(cfg *config.Config, connect func() (torrent.Client, error), retryDelay, startupTimeout time.Duration, maxAttempts int) (torrent.Client, error) {
 	startTime := time.Now()
 	deadline := startTime.Add(startupTimeout)
+	name := torrentClientName(cfg)
 
 	var lastErr error
 	attempt := 0
 	for time.Now().Before(deadline) {
 		attempt++
-		slog.Info("connecting to qBittorrent",
+		slog.Info("connecting to "+name,
 			"attempt", attempt,
 			"max_attempts", maxAttempts,
-			"qbit_addr", cfg.QbitAddr,
+			"address", torrentClientTarget(cfg),
 		)
 
-		client, err := qbit.NewClient(cfg.QbitAddr, cfg.QbitUser, cfg.QbitPass)
+		client, err := connect()
 		if err == nil {
-			slog.Info("connected to qBittorrent",
+			slog.Info("connected to "+name,
 				"attempt", attempt,
 				"elapsed", time.Since(startTime),
 			)
@@ -159,15 +239,15 @@ func createQbitClientWithRetry(cfg *config.Config, retryDelay, startupTimeout ti
 			sleep = exponentialBackoffDelay(attempt, retryDelay, remaining)
 		}
 
-		logMsg := "qBittorrent connection failed"
+		logMsg := name + " connection failed"
 		if shouldRetry {
-			logMsg = "qBittorrent connection failed, will retry"
+			logMsg = name + " connection failed, will retry"
 		}
 
 		slog.Warn(logMsg,
 			"attempt", attempt,
 			"max_attempts", maxAttempts,
-			"qbit_addr", cfg.QbitAddr,
+			"address", torrentClientTarget(cfg),
 			"retry_delay", sleep,
 			"remaining_timeout", remaining,
 			"error", err,
@@ -180,7 +260,7 @@ func createQbitClientWithRetry(cfg *config.Config, retryDelay, startupTimeout ti
 		time.Sleep(sleep)
 	}
 
-	return nil, fmt.Errorf("failed to connect to qBittorrent after %d attempts within %s: %w", attempt, startupTimeout, lastErr)
+	return nil, fmt.Errorf("failed to connect to %s after %d attempts within %s: %w", name, attempt, startupTimeout, lastErr)
 }
 
 func normalizeStartupSettings(cfg *config.Config) (time.Duration, time.Duration) {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// mapperPollInterval is how often the watcher reads the mapped port. Port
+// mappers renew their mappings on their own and keep the port in memory, so
+// polling them is cheap and picks up a new port right after a renewal.
+const mapperPollInterval = time.Second
+
+// portMapper is a port source that has the forwarded port mapped or
+// assigned itself, with NAT-PMP, UPnP or the API of PIA, and renews it while
+// Run is running. The MQTT and Kubernetes sources are ones as well: they
+// keep the port they receive while Run is running.
+type portMapper interface {
+	sync.PortSource
+	Refresh() error
+	Run(ctx context.Context)
+}
+
+// newPortMapper returns the configured port mapper, or nil if the port is
+// not mapped by Forwardarr. Only one source other than the port file can be
+// configured.
+func newPortMapper(cfg *config.Config) (portMapper, error) {
+	var sources []string
+	for name, set := range map[string]bool{
+		"GLUETUN_CONTROL_URL":         cfg.GluetunControlURL != "",
+		"NATPMP_GATEWAY":              cfg.NATPMPGateway != "",
+		"UPNP_INTERNAL_PORT":          cfg.UPnPInternalPort != 0,
+		"PIA_HOSTNAME":                cfg.PIAHostname != "",
+		"HTTP_SOURCE_URL":             cfg.HTTPSourceURL != "",
+		"EXEC_SOURCE_COMMAND":         cfg.ExecSourceCommand != "",
+		"DOCKER_SOURCE_CONTAINER":     cfg.DockerSourceContainer != "",
+		"KUBERNETES_SOURCE_CONFIGMAP": cfg.KubernetesSourceConfigMap != "",
+		"KUBERNETES_SOURCE_POD":       cfg.KubernetesSourcePod != "",
+		"MQTT_SOURCE_URL":             cfg.MQTTSourceURL != "",
+	} {
+		if set {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) > 1 {
+		slices.Sort(sources)
+		return nil, fmt.Errorf("only one port source can be configured, got %s", strings.Join(sources, ", "))
+	}
+
+	var (
+		mapper portMapper
+		err    error
+	)
+	switch {
+	case cfg.NATPMPGateway != "":
+		mapper, err = natpmpSource(cfg)
+	case cfg.UPnPInternalPort != 0:
+		mapper, err = upnpSource(cfg)
+	case cfg.PIAHostname != "":
+		mapper, err = piaSource(cfg)
+	case cfg.MQTTSourceURL != "":
+		mapper, err = mqttSource(cfg)
+	}
+	if err != nil {
+		// Not the typed nil of the failed source
+		return nil, err
+	}
+	return mapper, nil
+}
+
+// mapperName returns the name of the configured port mapper in
+// PORT_SOURCES
+func mapperName(cfg *config.Config) string {
+	switch {
+	case cfg.NATPMPGateway != "":
+		return "natpmp"
+	case cfg.UPnPInternalPort != 0:
+		return "upnp"
+	case cfg.PIAHostname != "":
+		return "pia"
+	case cfg.MQTTSourceURL != "":
+		return "mqtt"
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestNewPortMapper(t *testing.T) {
+	if mapper, err := newPortMapper(&config.Config{}); mapper != nil || err != nil {
+		t.Errorf("newPortMapper() = %v, %v, want none", mapper, err)
+	}
+	if mapper, err := newPortMapper(&config.Config{GluetunControlURL: "http://gluetun:8000"}); mapper != nil || err != nil {
+		t.Errorf("newPortMapper() = %v, %v, want none with the control server", mapper, err)
+	}
+	if mapper, err := newPortMapper(&config.Config{UPnPInternalPort: 6881, UPnPLease: time.Hour}); mapper == nil || err != nil {
+		t.Errorf("newPortMapper() = %v, %v, want the UPnP source", mapper, err)
+	}
+	if mapper, err := newPortMapper(&config.Config{UPnPInternalPort: 6881}); mapper != nil || err == nil {
+		t.Errorf("newPortMapper() = %v, %v, want the invalid lease rejected", mapper, err)
+	}
+	if mapper, err := newPortMapper(&config.Config{MQTTSourceURL: "mqtt://homeassistant:1883", MQTTSourceTopic: "vpn/port", MQTTSourceClientID: "forwardarr-source"}); mapper == nil || err != nil {
+		t.Errorf("newPortMapper() = %v, %v, want the MQTT source", mapper, err)
+	}
+
+	_, err := newPortMapper(&config.Config{UPnPInternalPort: 6881, UPnPLease: time.Hour, NATPMPGateway: "10.2.0.1", GluetunControlURL: "http://gluetun:8000"})
+	if err == nil || !strings.Contains(err.Error(), "GLUETUN_CONTROL_URL, NATPMP_GATEWAY, UPNP_INTERNAL_PORT") {
+		t.Errorf("newPortMapper() error = %v, want the sources listed", err)
+	}
+}
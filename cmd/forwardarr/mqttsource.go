@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/mqtt"
+	"github.com/eslutz/forwardarr/internal/source"
+)
+
+// mqttSource returns the source that receives the port over MQTT, or nil if
+// no broker is configured
+func mqttSource(cfg *config.Config) (*source.MQTT, error) {
+	if cfg.MQTTSourceURL == "" {
+		return nil, nil
+	}
+	password, err := secretValue(cfg.MQTTSourcePassword, cfg.MQTTSourcePasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MQTT source password: %w", err)
+	}
+	client, err := mqtt.NewClient(cfg.MQTTSourceURL, mqtt.Options{
+		Username: cfg.MQTTSourceUsername,
+		Password: password,
+		ClientID: cfg.MQTTSourceClientID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	mqtt, err := source.NewMQTT(client, cfg.MQTTSourceTopic)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("receiving the forwarded port over MQTT",
+		"broker", cfg.MQTTSourceURL,
+		"topic", cfg.MQTTSourceTopic,
+		"client_id", cfg.MQTTSourceClientID,
+	)
+	return mqtt, nil
+}
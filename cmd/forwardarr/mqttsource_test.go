@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestMQTTSource(t *testing.T) {
+	source, err := mqttSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("mqttSource() = %v, %v, want none without broker", source, err)
+	}
+
+	source, err = mqttSource(&config.Config{MQTTSourceURL: "mqtt://homeassistant:1883", MQTTSourceTopic: "vpn/port", MQTTSourceClientID: "forwardarr-source"})
+	if err != nil || source == nil {
+		t.Errorf("mqttSource() = %v, %v, want a source", source, err)
+	}
+
+	for name, cfg := range map[string]config.Config{
+		"URL":           {MQTTSourceURL: "http://homeassistant:1883", MQTTSourceTopic: "vpn/port", MQTTSourceClientID: "forwardarr-source"},
+		"topic":         {MQTTSourceURL: "mqtt://homeassistant:1883", MQTTSourceClientID: "forwardarr-source"},
+		"password file": {MQTTSourceURL: "mqtt://homeassistant:1883", MQTTSourceTopic: "vpn/port", MQTTSourceClientID: "forwardarr-source", MQTTSourcePasswordFile: filepath.Join(t.TempDir(), "missing")},
+	} {
+		if _, err := mqttSource(&cfg); err == nil {
+			t.Errorf("mqttSource() with invalid %s error = nil", name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/natpmp"
+)
+
+// natpmpSource returns the source that maps the forwarded port on the
+// NAT-PMP gateway of the VPN, or nil if no gateway is configured
+func natpmpSource(cfg *config.Config) (*natpmp.Source, error) {
+	if cfg.NATPMPGateway == "" {
+		return nil, nil
+	}
+	if cfg.NATPMPInternalPort < 1 || cfg.NATPMPInternalPort > 65535 {
+		return nil, fmt.Errorf("NATPMP_INTERNAL_PORT %d out of valid range", cfg.NATPMPInternalPort)
+	}
+	if cfg.NATPMPLifetime < 2*time.Second {
+		return nil, fmt.Errorf("NATPMP_LIFETIME must be at least 2 seconds, got %v", cfg.NATPMPLifetime)
+	}
+	client, err := natpmp.NewClient(cfg.NATPMPGateway)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("mapping the forwarded port with NAT-PMP",
+		"gateway", cfg.NATPMPGateway,
+		"internal_port", cfg.NATPMPInternalPort,
+		"lifetime", cfg.NATPMPLifetime,
+	)
+	return natpmp.NewSource(client, cfg.NATPMPInternalPort, cfg.NATPMPLifetime), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestNatpmpSource(t *testing.T) {
+	source, err := natpmpSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("natpmpSource() = %v, %v, want none without gateway", source, err)
+	}
+
+	source, err = natpmpSource(&config.Config{NATPMPGateway: "10.2.0.1", NATPMPInternalPort: 1, NATPMPLifetime: time.Minute})
+	if err != nil || source == nil {
+		t.Errorf("natpmpSource() = %v, %v, want a source", source, err)
+	}
+
+	for name, cfg := range map[string]config.Config{
+		"internal port": {NATPMPGateway: "10.2.0.1", NATPMPInternalPort: 0, NATPMPLifetime: time.Minute},
+		"lifetime":      {NATPMPGateway: "10.2.0.1", NATPMPInternalPort: 1, NATPMPLifetime: time.Second},
+		"gateway":       {NATPMPGateway: "vpn.example.com", NATPMPInternalPort: 1, NATPMPLifetime: time.Minute},
+	} {
+		if _, err := natpmpSource(&cfg); err == nil {
+			t.Errorf("natpmpSource() with invalid %s error = nil", name)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/torrent"
+	"github.com/eslutz/forwardarr/internal/vpn"
+	"github.com/eslutz/forwardarr/internal/webhook"
+	"github.com/eslutz/forwardarr/pkg/version"
+)
+
+// shutdownNotifyTimeout bounds how long shutdown waits for pending
+// notifications
+const shutdownNotifyTimeout = 10 * time.Second
+
+// newNotifier creates a dispatcher for all configured webhook targets. It
+// returns nil when webhooks are disabled.
+func newNotifier(cfg *config.Config) (*webhook.Dispatcher, error) {
+	if !cfg.WebhookEnabled {
+		return nil, nil
+	}
+
+	retry := webhook.RetryPolicy{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		BaseDelay:   cfg.WebhookRetryDelay,
+		MaxDelay:    cfg.WebhookRetryMaxDelay,
+	}
+
+	senders := make([]webhook.Sender, 0, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		target, err := webhookTarget(wh, retry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook %q: %w", wh.Name, err)
+		}
+		target.Proxy = cfg.WebhookProxy
+
+		sender, err := webhook.NewSender(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook %q: %w", wh.Name, err)
+		}
+		if wh.TLSInsecure {
+			slog.Warn("TLS certificate verification disabled for webhook", "name", wh.Name)
+		}
+		senders = append(senders, sender)
+
+		slog.Info("webhook target enabled",
+			"name", wh.Name,
+			"url", wh.URL,
+			"timeout", wh.Timeout,
+			"template", wh.Template,
+			"events", wh.Events,
+			"min_severity", wh.MinSeverity,
+			"signed", wh.Secret != "",
+			"custom_headers", len(wh.Headers),
+		)
+	}
+
+	routes := webhookRoutes(cfg.Webhooks)
+	for _, event := range slices.Sorted(maps.Keys(routes)) {
+		slog.Info("webhook route", "event", event, "targets", routes[event])
+	}
+
+	dispatcher := webhook.NewDispatcher(senders...)
+	dispatcher.SetInstance(newInstance(cfg))
+	if len(cfg.NotifyMessages) > 0 || len(cfg.NotifyTitles) > 0 {
+		messages, err := webhook.NewMessages(cfg.NotifyMessages, cfg.NotifyTitles)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification texts: %w", err)
+		}
+		dispatcher.SetMessages(messages)
+	}
+	if info := newVPNInfo(cfg); info != nil {
+		dispatcher.SetVPNInfo(info)
+	}
+	if cfg.WebhookQueueFile != "" {
+		dispatcher.SetQueue(webhook.NewQueue(cfg.WebhookQueueFile))
+	}
+	dispatcher.SetCircuitBreaker(cfg.WebhookBreakerThreshold, cfg.WebhookBreakerCooldown)
+	dispatcher.SetDeduplication(cfg.WebhookDedupWindow)
+	dispatcher.SetRateLimit(cfg.WebhookRateLimit)
+	dispatcher.SetBatching(cfg.WebhookBatchWindow)
+	dispatcher.Start(cfg.WebhookWorkers, cfg.WebhookBufferSize)
+
+	slog.Info("webhook notifications enabled",
+		"targets", len(senders),
+		"max_attempts", cfg.WebhookMaxAttempts,
+		"retry_delay", cfg.WebhookRetryDelay,
+		"retry_max_delay", cfg.WebhookRetryMaxDelay,
+		"queue_file", cfg.WebhookQueueFile,
+		"breaker_threshold", cfg.WebhookBreakerThreshold,
+		"breaker_cooldown", cfg.WebhookBreakerCooldown,
+		"dedup_window", cfg.WebhookDedupWindow,
+		"rate_limit", cfg.WebhookRateLimit,
+		"batch_window", cfg.WebhookBatchWindow,
+		"workers", cfg.WebhookWorkers,
+		"buffer_size", cfg.WebhookBufferSize,
+		"proxy", cfg.WebhookProxy != "",
+	)
+
+	return dispatcher, nil
+}
+
+// newInstance describes this instance for notification payloads
+func newInstance(cfg *config.Config) webhook.Instance {
+	hostname, err := os.Hostname()
+	if err != nil {
+		slog.Warn("failed to determine hostname for notifications", "error", err)
+	}
+	return webhook.Instance{
+		Name:     cfg.InstanceName,
+		Hostname: hostname,
+		Version:  version.Version,
+		Label:    cfg.InstanceLabel,
+	}
+}
+
+// newVPNInfo returns the lookup of the VPN details for port change
+// notifications, or nil if neither a provider nor a public IP source is
+// configured. A failed IP lookup is logged and leaves the IP out.
+func newVPNInfo(cfg *config.Config) func() webhook.VPNInfo {
+	lookup := vpn.NewIPLookup(cfg.VPNPublicIPFile, cfg.VPNPublicIPURL)
+	if lookup == nil && cfg.VPNProvider == "" {
+		return nil
+	}
+	return func() webhook.VPNInfo {
+		info := webhook.VPNInfo{Provider: cfg.VPNProvider}
+		if lookup == nil {
+			return info
+		}
+		ip, err := lookup.PublicIP()
+		if err != nil {
+			slog.Warn("failed to look up VPN public IP", "error", err)
+			return info
+		}
+		info.PublicIP = ip
+		return info
+	}
+}
+
+// webhookRoutes maps every event to the names of the targets subscribed to
+// it. Targets without an event filter receive all events and are listed
+// under "*".
+func webhookRoutes(webhooks []config.WebhookConfig) map[string][]string {
+	routes := make(map[string][]string)
+	for _, wh := range webhooks {
+		if len(wh.Events) == 0 {
+			routes["*"] = append(routes["*"], wh.Name)
+			continue
+		}
+		for _, event := range wh.Events {
+			routes[event] = append(routes[event], wh.Name)
+		}
+	}
+	return routes
+}
+
+// notifyStartup redelivers queued notifications and sends the startup event
+// in the background so that slow targets do not delay the first port sync
+func notifyStartup(ctx context.Context, notifier *webhook.Dispatcher, qbitClient torrent.Client) {
+	if notifier == nil {
+		return
+	}
+	go func() {
+		if err := notifier.Replay(ctx); err != nil {
+			slog.Warn("failed to replay queued webhook deliveries", "error", err)
+		}
+		if err := notifier.SendStartup(ctx, version.Version, currentPort(qbitClient)); err != nil {
+			slog.Warn("failed to send startup notification", "error", err)
+		}
+	}()
+}
+
+// notifyShutdown sends the shutdown event and waits up to
+// shutdownNotifyTimeout for it and all other pending notifications to be
+// delivered
+func notifyShutdown(notifier *webhook.Dispatcher, qbitClient torrent.Client) {
+	if notifier == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownNotifyTimeout)
+	defer cancel()
+	if err := notifier.SendShutdown(ctx, version.Version, currentPort(qbitClient)); err != nil {
+		slog.Warn("failed to send shutdown notification", "error", err)
+	}
+	if err := notifier.Close(ctx); err != nil {
+		slog.Warn("failed to flush webhook notifications", "error", err)
+	}
+}
+
+// currentPort returns the port set in the torrent client, or 0 if it cannot
+// be read
+func currentPort(qbitClient torrent.Client) int {
+	port, err := qbitClient.GetPort()
+	if err != nil {
+		slog.Debug("failed to read torrent client port for notification", "error", err)
+		return 0
+	}
+	return port
+}
+
+// webhookTarget converts a webhook configuration into a client target
+func webhookTarget(wh config.WebhookConfig, retry webhook.RetryPolicy) (webhook.Target, error) {
+	customTemplate := wh.CustomTemplate
+	if wh.CustomTemplateFile != "" {
+		data, err := os.ReadFile(wh.CustomTemplateFile)
+		if err != nil {
+			return webhook.Target{}, fmt.Errorf("failed to read custom template: %w", err)
+		}
+		customTemplate = string(data)
+	}
+
+	// Values read from files replace the inline ones in this copy of wh
+	secrets := []struct {
+		name  string
+		value *string
+		file  string
+	}{
+		{"URL", &wh.URL, wh.URLFile},
+		{"secret", &wh.Secret, wh.SecretFile},
+		{"bearer token", &wh.BearerToken, wh.BearerTokenFile},
+		{"basic auth password", &wh.BasicAuthPassword, wh.BasicAuthPasswordFile},
+		{"pushover token", &wh.PushoverToken, wh.PushoverTokenFile},
+		{"pushover user", &wh.PushoverUser, wh.PushoverUserFile},
+		{"ntfy token", &wh.NtfyToken, wh.NtfyTokenFile},
+		{"matrix access token", &wh.MatrixAccessToken, wh.MatrixAccessTokenFile},
+		{"pagerduty routing key", &wh.PagerDutyRoutingKey, wh.PagerDutyRoutingKeyFile},
+		{"email password", &wh.EmailPassword, wh.EmailPasswordFile},
+		{"mqtt password", &wh.MQTTPassword, wh.MQTTPasswordFile},
+		{"gotify token", &wh.GotifyToken, wh.GotifyTokenFile},
+	}
+	for _, secret := range secrets {
+		value, err := secretValue(*secret.value, secret.file)
+		if err != nil {
+			return webhook.Target{}, fmt.Errorf("failed to read %s: %w", secret.name, err)
+		}
+		*secret.value = value
+	}
+
+	return webhook.Target{
+		Name:           wh.Name,
+		URL:            wh.URL,
+		Template:       webhook.Template(wh.Template),
+		Timeout:        wh.Timeout,
+		Events:         wh.Events,
+		MinSeverity:    wh.MinSeverity,
+		Secret:         wh.Secret,
+		Headers:        wh.Headers,
+		Method:         wh.Method,
+		ContentType:    wh.ContentType,
+		FormFields:     wh.FormFields,
+		CustomTemplate: customTemplate,
+		Pushover: webhook.PushoverOptions{
+			Token:    wh.PushoverToken,
+			User:     wh.PushoverUser,
+			Priority: wh.PushoverPriority,
+		},
+		Ntfy: webhook.NtfyOptions{
+			Topic:    wh.NtfyTopic,
+			Priority: wh.NtfyPriority,
+			Tags:     wh.NtfyTags,
+			Token:    wh.NtfyToken,
+		},
+		Matrix: webhook.MatrixOptions{
+			RoomID:      wh.MatrixRoomID,
+			AccessToken: wh.MatrixAccessToken,
+		},
+		Apprise: webhook.AppriseOptions{
+			URLs: wh.AppriseURLs,
+			Tag:  wh.AppriseTag,
+		},
+		PagerDuty: webhook.PagerDutyOptions{
+			RoutingKey: wh.PagerDutyRoutingKey,
+			Severity:   wh.PagerDutySeverity,
+		},
+		Email: webhook.EmailOptions{
+			TLS:      wh.EmailTLS,
+			Username: wh.EmailUsername,
+			Password: wh.EmailPassword,
+			From:     wh.EmailFrom,
+			To:       wh.EmailTo,
+			HTML:     wh.EmailHTML,
+		},
+		MQTT: webhook.MQTTOptions{
+			Topic:    wh.MQTTTopic,
+			QoS:      wh.MQTTQoS,
+			Retain:   wh.MQTTRetain,
+			Username: wh.MQTTUsername,
+			Password: wh.MQTTPassword,
+			ClientID: wh.MQTTClientID,
+		},
+		TLS: webhook.TLSOptions{
+			CAFile:             wh.TLSCAFile,
+			CertFile:           wh.TLSCertFile,
+			KeyFile:            wh.TLSKeyFile,
+			InsecureSkipVerify: wh.TLSInsecure,
+		},
+		Auth: webhook.AuthOptions{
+			BearerToken: wh.BearerToken,
+			Username:    wh.BasicAuthUsername,
+			Password:    wh.BasicAuthPassword,
+		},
+		Discord: webhook.DiscordOptions{
+			Color:      wh.DiscordColor,
+			Title:      wh.DiscordTitle,
+			Username:   wh.DiscordUsername,
+			AvatarURL:  wh.DiscordAvatarURL,
+			Mention:    wh.DiscordMention,
+			ThreadID:   wh.DiscordThreadID,
+			ThreadName: wh.DiscordThreadName,
+		},
+		Slack: webhook.SlackOptions{
+			Channel:     wh.SlackChannel,
+			Username:    wh.SlackUsername,
+			IconEmoji:   wh.SlackIconEmoji,
+			Attachments: wh.SlackAttachments,
+		},
+		Gotify: webhook.GotifyOptions{
+			Token:         wh.GotifyToken,
+			TokenMode:     wh.GotifyTokenMode,
+			Priority:      wh.GotifyPriority,
+			ErrorPriority: wh.GotifyErrorPriority,
+		},
+		Retry: retry,
+	}, nil
+}
+
+// secretValue returns the contents of file without surrounding whitespace,
+// or value if no file is set. Files allow mounting secrets, e.g. Docker
+// secrets, instead of passing them as environment variables.
+func secretValue(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/webhook"
+)
+
+func TestNewNotifier_Disabled(t *testing.T) {
+	notifier, err := newNotifier(&config.Config{})
+	if err != nil {
+		t.Fatalf("newNotifier() error = %v, want nil", err)
+	}
+	if notifier != nil {
+		t.Error("newNotifier() = non-nil, want nil when webhooks are disabled")
+	}
+}
+
+func TestNewNotifier_Targets(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "json", URL: "http://example.com/a", Template: "json", Timeout: time.Second},
+			{Name: "custom", URL: "http://example.com/b", Template: "custom", Timeout: time.Second, CustomTemplate: "{{.NewPort}}"},
+		},
+	}
+
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		t.Fatalf("newNotifier() error = %v, want nil", err)
+	}
+	if got := len(notifier.Senders()); got != 2 {
+		t.Fatalf("len(Senders()) = %d, want 2", got)
+	}
+	if got := notifier.Senders()[1].Name(); got != "custom" {
+		t.Errorf("Senders()[1].Name() = %q, want %q", got, "custom")
+	}
+}
+
+func TestNewNotifier_UnknownEvent(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "pagerduty", URL: "http://example.com", Template: "json", Events: []string{"sync_eror"}},
+		},
+	}
+
+	if _, err := newNotifier(cfg); err == nil {
+		t.Error("newNotifier() error = nil, want error for unknown event")
+	}
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	routes := webhookRoutes([]config.WebhookConfig{
+		{Name: "discord", Events: []string{"port_changed"}},
+		{Name: "pagerduty", Events: []string{"sync_error", "sync_recovered"}},
+		{Name: "ntfy", Events: []string{"port_changed", "sync_error"}},
+		{Name: "archive"},
+	})
+
+	want := map[string][]string{
+		"port_changed":   {"discord", "ntfy"},
+		"sync_error":     {"pagerduty", "ntfy"},
+		"sync_recovered": {"pagerduty"},
+		"*":              {"archive"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("webhookRoutes() = %v, want %v", routes, want)
+	}
+}
+
+func TestNewVPNInfo(t *testing.T) {
+	if info := newVPNInfo(&config.Config{}); info != nil {
+		t.Error("newVPNInfo() = non-nil, want nil without provider and IP source")
+	}
+
+	ipFile := filepath.Join(t.TempDir(), "ip")
+	if err := os.WriteFile(ipFile, []byte("203.0.113.7\n"), 0o644); err != nil {
+		t.Fatalf("failed to write IP file: %v", err)
+	}
+	info := newVPNInfo(&config.Config{VPNProvider: "mullvad", VPNPublicIPFile: ipFile})
+	want := webhook.VPNInfo{PublicIP: "203.0.113.7", Provider: "mullvad"}
+	if got := info(); got != want {
+		t.Errorf("VPN info = %+v, want %+v", got, want)
+	}
+
+	if err := os.Remove(ipFile); err != nil {
+		t.Fatalf("failed to remove IP file: %v", err)
+	}
+	if got := info(); got != (webhook.VPNInfo{Provider: "mullvad"}) {
+		t.Errorf("VPN info without IP file = %+v, want provider only", got)
+	}
+}
+
+func TestNewNotifier_InvalidCustomTemplate(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "custom", URL: "http://example.com", Template: "custom", CustomTemplate: "{{.NewPort"},
+		},
+	}
+
+	if _, err := newNotifier(cfg); err == nil {
+		t.Error("newNotifier() error = nil, want error")
+	}
+}
+
+func TestNewNotifier_UnknownTemplate(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "telegram", URL: "https://api.telegram.org/bot123/sendMessage", Template: "telegram"},
+		},
+	}
+
+	// Unknown templates fall back to json
+	if notifier, err := newNotifier(cfg); err != nil || notifier == nil {
+		t.Errorf("newNotifier() = %v, %v, want a json notifier", notifier, err)
+	}
+}
+
+func TestNewNotifier_InvalidPushover(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "pushover", URL: "https://api.pushover.net/1/messages.json", Template: "pushover", PushoverUser: "user-key"},
+		},
+	}
+
+	if _, err := newNotifier(cfg); err == nil {
+		t.Error("newNotifier() error = nil, want error for missing Pushover token")
+	}
+}
+
+func TestNewNotifier_InvalidProxy(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		WebhookProxy:   "ftp://proxy.local",
+		Webhooks: []config.WebhookConfig{
+			{Name: "discord", URL: "https://discord.com/api/webhooks/1/a", Template: "discord"},
+		},
+	}
+
+	if _, err := newNotifier(cfg); err == nil {
+		t.Error("newNotifier() error = nil, want error for unsupported proxy scheme")
+	}
+}
+
+func TestNewNotifier_MissingCAFile(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "gotify", URL: "https://gotify.home.lan/message", Template: "gotify", TLSCAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		},
+	}
+
+	if _, err := newNotifier(cfg); err == nil {
+		t.Error("newNotifier() error = nil, want error for missing CA bundle")
+	}
+}
+
+func TestNewNotifier_EmailTarget(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{
+				Name:      "email",
+				URL:       "smtp://mail.example.com:587",
+				Template:  "email",
+				EmailFrom: "forwardarr@example.com",
+				EmailTo:   []string{"you@example.com"},
+			},
+		},
+	}
+
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		t.Fatalf("newNotifier() error = %v, want nil", err)
+	}
+	if _, ok := notifier.Senders()[0].(*webhook.EmailSender); !ok {
+		t.Errorf("Senders()[0] = %T, want *webhook.EmailSender", notifier.Senders()[0])
+	}
+}
+
+func TestNewNotifier_MQTTTarget(t *testing.T) {
+	cfg := &config.Config{
+		WebhookEnabled: true,
+		Webhooks: []config.WebhookConfig{
+			{Name: "mqtt", URL: "mqtt://broker:1883", Template: "mqtt", MQTTTopic: "home/forwardarr"},
+		},
+	}
+
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		t.Fatalf("newNotifier() error = %v, want nil", err)
+	}
+	if _, ok := notifier.Senders()[0].(*webhook.MQTTSender); !ok {
+		t.Errorf("Senders()[0] = %T, want *webhook.MQTTSender", notifier.Senders()[0])
+	}
+}
+
+func TestWebhookTarget_CustomTemplateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.tmpl")
+	if err := os.WriteFile(path, []byte("port={{.NewPort}}"), 0644); err != nil {
+		t.Fatalf("failed to write template file: %v", err)
+	}
+
+	target, err := webhookTarget(config.WebhookConfig{
+		Name:               "custom",
+		Template:           "custom",
+		CustomTemplate:     "ignored",
+		CustomTemplateFile: path,
+	}, webhook.RetryPolicy{})
+	if err != nil {
+		t.Fatalf("webhookTarget() error = %v, want nil", err)
+	}
+	if target.CustomTemplate != "port={{.NewPort}}" {
+		t.Errorf("target.CustomTemplate = %q, want file contents", target.CustomTemplate)
+	}
+}
+
+func TestWebhookTarget_MissingTemplateFile(t *testing.T) {
+	_, err := webhookTarget(config.WebhookConfig{
+		Name:               "custom",
+		Template:           "custom",
+		CustomTemplateFile: filepath.Join(t.TempDir(), "missing.tmpl"),
+	}, webhook.RetryPolicy{})
+	if err == nil {
+		t.Error("webhookTarget() error = nil, want error")
+	}
+}
+
+func TestWebhookTarget_AuthSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	target, err := webhookTarget(config.WebhookConfig{
+		Name:            "gotify",
+		BearerToken:     "ignored",
+		BearerTokenFile: tokenFile,
+	}, webhook.RetryPolicy{})
+	if err != nil {
+		t.Fatalf("webhookTarget() error = %v, want nil", err)
+	}
+	if target.Auth.BearerToken != "file-token" {
+		t.Errorf("target.Auth.BearerToken = %q, want trimmed file contents", target.Auth.BearerToken)
+	}
+
+	_, err = webhookTarget(config.WebhookConfig{
+		Name:                  "relay",
+		BasicAuthUsername:     "forwardarr",
+		BasicAuthPasswordFile: filepath.Join(dir, "missing"),
+	}, webhook.RetryPolicy{})
+	if err == nil {
+		t.Error("webhookTarget() error = nil, want error for missing password file")
+	}
+}
+
+func TestWebhookTarget_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret := func(name, value string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(value+"\n"), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	target, err := webhookTarget(config.WebhookConfig{
+		Name:                    "pagerduty",
+		URL:                     "https://example.com/ignored",
+		URLFile:                 writeSecret("url", "https://events.pagerduty.com/v2/enqueue"),
+		SecretFile:              writeSecret("secret", "hmac-key"),
+		PagerDutyRoutingKeyFile: writeSecret("routing_key", "R0UT1NG"),
+		GotifyTokenFile:         writeSecret("gotify", "app-token"),
+	}, webhook.RetryPolicy{})
+	if err != nil {
+		t.Fatalf("webhookTarget() error = %v, want nil", err)
+	}
+	if target.URL != "https://events.pagerduty.com/v2/enqueue" {
+		t.Errorf("target.URL = %q, want the URL from the file", target.URL)
+	}
+	if target.Secret != "hmac-key" {
+		t.Errorf("target.Secret = %q, want hmac-key", target.Secret)
+	}
+	if target.PagerDuty.RoutingKey != "R0UT1NG" || target.Gotify.Token != "app-token" {
+		t.Errorf("routing key = %q, gotify token = %q, want values from files", target.PagerDuty.RoutingKey, target.Gotify.Token)
+	}
+
+	_, err = webhookTarget(config.WebhookConfig{
+		Name:    "webhook",
+		URLFile: filepath.Join(dir, "missing"),
+	}, webhook.RetryPolicy{})
+	if err == nil {
+		t.Error("webhookTarget() error = nil, want error for missing URL file")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/pia"
+)
+
+// piaSource returns the source that has the port forwarded by the PIA
+// server, or nil if no server is configured
+func piaSource(cfg *config.Config) (*pia.Source, error) {
+	if cfg.PIAHostname == "" {
+		return nil, nil
+	}
+	password, err := secretValue(cfg.PIAPassword, cfg.PIAPasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PIA password: %w", err)
+	}
+	client, err := pia.NewClient(cfg.PIAHostname, cfg.PIAGateway, pia.Options{
+		Username:           cfg.PIAUser,
+		Password:           password,
+		Token:              cfg.PIAToken,
+		CAFile:             cfg.PIACAFile,
+		InsecureSkipVerify: cfg.PIATLSSkipVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PIATLSSkipVerify {
+		slog.Warn("PIA_TLS_SKIP_VERIFY is set, the certificate of the port forwarding API is not verified")
+	}
+	slog.Info("forwarding the port with the PIA API",
+		"hostname", cfg.PIAHostname,
+		"gateway", cfg.PIAGateway,
+	)
+	return pia.NewSource(client), nil
+}
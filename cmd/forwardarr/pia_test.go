@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestPiaSource(t *testing.T) {
+	source, err := piaSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("piaSource() = %v, %v, want none without hostname", source, err)
+	}
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		PIAHostname:      "ca-montreal.privacy.network",
+		PIAGateway:       "10.13.128.1",
+		PIAUser:          "p1234567",
+		PIAPasswordFile:  passwordFile,
+		PIATLSSkipVerify: true,
+	}
+	if source, err := piaSource(cfg); err != nil || source == nil {
+		t.Errorf("piaSource() = %v, %v, want a source", source, err)
+	}
+
+	cfg.PIAPasswordFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := piaSource(cfg); err == nil {
+		t.Error("piaSource() error = nil, want missing password file")
+	}
+	cfg.PIAPasswordFile, cfg.PIAGateway = "", ""
+	if _, err := piaSource(cfg); err == nil {
+		t.Error("piaSource() error = nil, want missing gateway")
+	}
+}
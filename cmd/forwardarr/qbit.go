@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newQbitConnector returns the function that connects to qBittorrent
+func newQbitConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	opts, err := qbitOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	prefs := qbitPortPreferences(cfg)
+
+	return func() (torrent.Client, error) {
+		client, err := qbit.NewClientWithOptions(cfg.QbitAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRetryPolicy(retryPolicy(cfg))
+		client.SetPortPreferences(prefs)
+		return client, nil
+	}, nil
+}
+
+// qbitOptions returns the connection options of the qBittorrent client
+func qbitOptions(cfg *config.Config) (qbit.Options, error) {
+	auth, err := qbitAuth(cfg)
+	if err != nil {
+		return qbit.Options{}, err
+	}
+	transport, err := transportOptions(cfg, "qBittorrent")
+	if err != nil {
+		return qbit.Options{}, err
+	}
+	return qbit.Options{Auth: auth, Transport: transport}, nil
+}
+
+// qbitPortPreferences returns the preferences written along with every port.
+// With QbitEnforceStaticPort, UPnP and the random port at startup are turned
+// off, since either would let qBittorrent override the forwarded port. The
+// network interface and address keep qBittorrent bound to the VPN.
+func qbitPortPreferences(cfg *config.Config) map[string]any {
+	prefs := make(map[string]any)
+	if cfg.QbitEnforceStaticPort {
+		prefs["upnp"] = false
+		prefs["random_port"] = false
+	}
+	if cfg.QbitNetworkInterface != "" {
+		prefs["current_network_interface"] = cfg.QbitNetworkInterface
+	}
+	if cfg.QbitInterfaceAddress != "" {
+		prefs["current_interface_address"] = cfg.QbitInterfaceAddress
+	}
+	if len(prefs) == 0 {
+		return nil
+	}
+	return prefs
+}
+
+// qbitAuth returns the credentials of the configured qBittorrent
+// authentication method
+func qbitAuth(cfg *config.Config) (qbit.Auth, error) {
+	method, err := qbit.ParseAuthMethod(cfg.QbitAuth)
+	if err != nil {
+		return qbit.Auth{}, err
+	}
+
+	auth := qbit.Auth{Method: method}
+	switch method {
+	case qbit.AuthPassword:
+		auth.Username, err = secretValue(cfg.QbitUser, cfg.QbitUserFile)
+		if err != nil {
+			return qbit.Auth{}, fmt.Errorf("failed to read qBittorrent username file: %w", err)
+		}
+		auth.Password, err = secretValue(cfg.QbitPass, cfg.QbitPassFile)
+		if err != nil {
+			return qbit.Auth{}, fmt.Errorf("failed to read qBittorrent password file: %w", err)
+		}
+	case qbit.AuthAPIKey:
+		auth.APIKey, err = secretValue(cfg.QbitAPIKey, cfg.QbitAPIKeyFile)
+		if err != nil {
+			return qbit.Auth{}, fmt.Errorf("failed to read qBittorrent api key file: %w", err)
+		}
+		if auth.APIKey == "" {
+			return qbit.Auth{}, errors.New("TORRENT_CLIENT_API_KEY or TORRENT_CLIENT_API_KEY_FILE is required with the apikey auth method")
+		}
+	}
+	return auth, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/qbit"
+)
+
+func TestQbitAuth(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "api_key")
+	userFile := filepath.Join(dir, "user")
+	passFile := filepath.Join(dir, "password")
+	for path, content := range map[string]string{keyFile: "file-key\n", userFile: "file-user\n", passFile: "file-secret\n"} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    qbit.Auth
+		wantErr bool
+	}{
+		{
+			name: "password",
+			cfg:  config.Config{QbitAuth: "password", QbitUser: "admin", QbitPass: "secret"},
+			want: qbit.Auth{Method: qbit.AuthPassword, Username: "admin", Password: "secret"},
+		},
+		{
+			name: "password files",
+			cfg:  config.Config{QbitAuth: "password", QbitUser: "admin", QbitPass: "secret", QbitUserFile: userFile, QbitPassFile: passFile},
+			want: qbit.Auth{Method: qbit.AuthPassword, Username: "file-user", Password: "file-secret"},
+		},
+		{name: "missing password file", cfg: config.Config{QbitAuth: "password", QbitPassFile: passFile + ".missing"}, wantErr: true},
+		{
+			name: "bypass ignores credentials",
+			cfg:  config.Config{QbitAuth: "bypass", QbitUser: "admin", QbitPass: "secret"},
+			want: qbit.Auth{Method: qbit.AuthBypass},
+		},
+		{
+			name: "api key",
+			cfg:  config.Config{QbitAuth: "apikey", QbitAPIKey: "key"},
+			want: qbit.Auth{Method: qbit.AuthAPIKey, APIKey: "key"},
+		},
+		{
+			name: "api key file",
+			cfg:  config.Config{QbitAuth: "apikey", QbitAPIKey: "key", QbitAPIKeyFile: keyFile},
+			want: qbit.Auth{Method: qbit.AuthAPIKey, APIKey: "file-key"},
+		},
+		{name: "missing api key", cfg: config.Config{QbitAuth: "apikey"}, wantErr: true},
+		{name: "missing api key file", cfg: config.Config{QbitAuth: "apikey", QbitAPIKeyFile: keyFile + ".missing"}, wantErr: true},
+		{name: "unknown method", cfg: config.Config{QbitAuth: "token"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := qbitAuth(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("qbitAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("qbitAuth() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQbitOptions(t *testing.T) {
+	opts, err := qbitOptions(&config.Config{QbitUser: "admin", QbitPass: "secret"})
+	if err != nil {
+		t.Fatalf("qbitOptions() error = %v", err)
+	}
+	if opts.Transport.TLS != nil || opts.Auth.Username != "admin" {
+		t.Errorf("qbitOptions() = %+v, want password auth with default TLS", opts)
+	}
+
+	opts, err = qbitOptions(&config.Config{QbitTimeout: 30 * time.Second, QbitKeepAlive: 60 * time.Second})
+	if err != nil {
+		t.Fatalf("qbitOptions() error = %v", err)
+	}
+	if opts.Transport.Timeout != 30*time.Second || opts.Transport.IdleTimeout != 60*time.Second || opts.Transport.DisableKeepAlives {
+		t.Errorf("qbitOptions() = %+v, want 30s timeout with 60s keep-alive", opts)
+	}
+
+	opts, err = qbitOptions(&config.Config{QbitTLSInsecure: true})
+	if err != nil {
+		t.Fatalf("qbitOptions() error = %v", err)
+	}
+	if opts.Transport.TLS == nil || !opts.Transport.TLS.InsecureSkipVerify {
+		t.Errorf("TLS = %+v, want InsecureSkipVerify", opts.Transport.TLS)
+	}
+
+	_, err = qbitOptions(&config.Config{QbitTLSCAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	if err == nil {
+		t.Error("qbitOptions() error = nil for missing CA bundle, want error")
+	}
+
+	opts, err = qbitOptions(&config.Config{QbitProxy: "socks5://proxy.local:1080"})
+	if err != nil {
+		t.Fatalf("qbitOptions() error = %v", err)
+	}
+	if opts.Transport.Proxy == nil || opts.Transport.Proxy.Host != "proxy.local:1080" {
+		t.Errorf("Proxy = %v, want socks5://proxy.local:1080", opts.Transport.Proxy)
+	}
+
+	_, err = qbitOptions(&config.Config{QbitProxy: "ftp://proxy.local"})
+	if err == nil {
+		t.Error("qbitOptions() error = nil for invalid proxy, want error")
+	}
+}
+
+func TestQbitPortPreferences(t *testing.T) {
+	if prefs := qbitPortPreferences(&config.Config{}); prefs != nil {
+		t.Errorf("qbitPortPreferences() = %v, want nil", prefs)
+	}
+
+	prefs := qbitPortPreferences(&config.Config{QbitEnforceStaticPort: true})
+	if len(prefs) != 2 || prefs["upnp"] != false || prefs["random_port"] != false {
+		t.Errorf("qbitPortPreferences() = %v, want upnp and random_port disabled", prefs)
+	}
+
+	prefs = qbitPortPreferences(&config.Config{QbitNetworkInterface: "tun0", QbitInterfaceAddress: "0.0.0.0"})
+	if len(prefs) != 2 || prefs["current_network_interface"] != "tun0" || prefs["current_interface_address"] != "0.0.0.0" {
+		t.Errorf("qbitPortPreferences() = %v, want interface tun0 and address 0.0.0.0", prefs)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	gosync "sync"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/server"
+	"github.com/eslutz/forwardarr/internal/sync"
+	"github.com/eslutz/forwardarr/internal/webhook"
+)
+
+// reloader reads the configuration again on /api/v1/config/reload and
+// applies what can change while running: the webhook targets, including the
+// secrets and templates read from files, and the API key. Other settings
+// take effect on the next start.
+type reloader struct {
+	watcher *sync.Watcher
+	// server is nil if the HTTP server is disabled
+	server *server.Server
+
+	mu       gosync.Mutex
+	cfg      *config.Config
+	notifier *webhook.Dispatcher
+}
+
+// Config returns the effective configuration with secrets redacted
+func (r *reloader) Config() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg.Redacted()
+}
+
+// Reload loads the configuration and replaces the notifier and API key. If
+// the new configuration is invalid, the current one stays in effect.
+func (r *reloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg := config.Load()
+	var apiKey string
+	if r.server != nil {
+		var err error
+		if apiKey, err = secretValue(cfg.APIKey, cfg.APIKeyFile); err != nil {
+			return fmt.Errorf("failed to read api key file: %w", err)
+		}
+	}
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to configure webhooks: %w", err)
+	}
+
+	r.watcher.SetNotifier(notifier)
+	if r.server != nil {
+		var webhooks server.Webhooks
+		if notifier != nil {
+			webhooks = notifier
+		}
+		r.server.SetWebhooks(webhooks)
+		r.server.SetAPIKey(apiKey)
+	}
+	previous := r.notifier
+	r.cfg, r.notifier = cfg, notifier
+
+	// Deliver what the previous notifier still has pending, without holding
+	// up the reload
+	if previous != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownNotifyTimeout)
+			defer cancel()
+			if err := previous.Close(ctx); err != nil {
+				slog.Warn("failed to flush webhook notifications of the previous config", "error", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// currentNotifier returns the notifier of the current configuration
+func (r *reloader) currentNotifier() *webhook.Dispatcher {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.notifier
+}
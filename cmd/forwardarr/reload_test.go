@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	urlFile := filepath.Join(dir, "webhook_url")
+	if err := os.WriteFile(urlFile, []byte("https://hooks.example.com/first\n"), 0o600); err != nil {
+		t.Fatalf("failed to write url file: %v", err)
+	}
+	os.Clearenv()
+	t.Setenv("WEBHOOK_URL_FILE", urlFile)
+
+	cfg := config.Load()
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		t.Fatalf("newNotifier() error = %v", err)
+	}
+	watcher, err := sync.NewWatcher(filepath.Join(dir, "forwarded_port"), nil, notifier, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	srv, err := newServer(&config.Config{ServerEnabled: true, MetricsPort: "9090"}, nil)
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	reload := &reloader{watcher: watcher, server: srv, cfg: cfg, notifier: notifier}
+
+	if err := reload.Reload(t.Context()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if reload.currentNotifier() == notifier {
+		t.Error("Reload() kept the previous notifier")
+	}
+	if got := reload.Config()["WEBHOOK_URL_FILE"]; got != urlFile {
+		t.Errorf("Config()[WEBHOOK_URL_FILE] = %q, want %q", got, urlFile)
+	}
+
+	// An unreadable secret file fails the reload and keeps the current config
+	current := reload.currentNotifier()
+	t.Setenv("API_KEY_FILE", filepath.Join(dir, "missing"))
+	if err := reload.Reload(t.Context()); err == nil {
+		t.Fatal("Reload() error = nil, want error for the missing api key file")
+	}
+	if reload.currentNotifier() != current {
+		t.Error("failed Reload() replaced the notifier")
+	}
+	if _, ok := reload.Config()["API_KEY_FILE"]; ok {
+		t.Error("failed Reload() replaced the config")
+	}
+}
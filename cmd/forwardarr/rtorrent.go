@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/rtorrent"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newRTorrentConnector returns the function that connects to rTorrent
+func newRTorrentConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	opts, err := rtorrentOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QbitNetworkInterface != "" {
+		slog.Warn("TORRENT_CLIENT_NETWORK_INTERFACE is not supported by rTorrent, set TORRENT_CLIENT_INTERFACE_ADDRESS to the address of the VPN interface instead")
+	}
+	settings := rtorrent.PortSettings{
+		StaticPort:  cfg.QbitEnforceStaticPort,
+		BindAddress: cfg.QbitInterfaceAddress,
+	}
+
+	return func() (torrent.Client, error) {
+		client, err := rtorrent.NewClient(cfg.QbitAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRetryPolicy(retryPolicy(cfg))
+		client.SetPortSettings(settings)
+		return client, nil
+	}, nil
+}
+
+// rtorrentOptions returns the connection options of the rTorrent client. The
+// credentials of the password method are sent to an HTTP endpoint with basic
+// authentication.
+func rtorrentOptions(cfg *config.Config) (rtorrent.Options, error) {
+	auth, err := qbitAuth(cfg)
+	if err != nil {
+		return rtorrent.Options{}, err
+	}
+	if auth.Method == qbit.AuthAPIKey {
+		return rtorrent.Options{}, errors.New("the apikey auth method is not supported by rTorrent")
+	}
+	transport, err := transportOptions(cfg, "rTorrent")
+	if err != nil {
+		return rtorrent.Options{}, err
+	}
+	return rtorrent.Options{Username: auth.Username, Password: auth.Password, Transport: transport}, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/server"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newServer creates the HTTP server from the configuration, or returns nil if
+// the server is disabled
+func newServer(cfg *config.Config, qbitClient torrent.Client) (*server.Server, error) {
+	if !cfg.ServerEnabled {
+		slog.Info("http server disabled")
+		return nil, nil
+	}
+
+	apiKey, err := secretValue(cfg.APIKey, cfg.APIKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api key file: %w", err)
+	}
+
+	srv := server.NewServer(cfg.MetricsPort, qbitClient)
+	if len(cfg.ServerAddresses) > 0 {
+		srv.SetAddresses(cfg.ServerAddresses)
+	}
+	srv.SetAPIKey(apiKey)
+	if cfg.AccessLog {
+		srv.SetAccessLog(max(cfg.AccessLogSample, 1))
+	}
+	srv.SetRateLimit(cfg.APIRateLimit, cfg.APIRateBurst)
+	srv.SetSyncStaleThreshold(cfg.SyncStaleThreshold)
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		srv.SetCORS(cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods)
+	}
+	if cfg.ServerTLSCertFile != "" || cfg.ServerTLSKeyFile != "" {
+		if err := srv.SetTLS(cfg.ServerTLSCertFile, cfg.ServerTLSKeyFile); err != nil {
+			return nil, fmt.Errorf("failed to configure https: %w", err)
+		}
+	}
+	if cfg.ServerTLSClientCAFile != "" {
+		if err := srv.SetClientCA(cfg.ServerTLSClientCAFile); err != nil {
+			return nil, fmt.Errorf("failed to configure client certificate verification: %w", err)
+		}
+	}
+	return srv, nil
+}
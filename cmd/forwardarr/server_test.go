@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestNewServer_Disabled(t *testing.T) {
+	srv, err := newServer(&config.Config{ServerEnabled: false}, nil)
+	if err != nil {
+		t.Fatalf("newServer() error = %v, want nil", err)
+	}
+	if srv != nil {
+		t.Error("newServer() = non-nil, want nil when the server is disabled")
+	}
+}
+
+func TestNewServer_Enabled(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api_key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("failed to write api key file: %v", err)
+	}
+
+	srv, err := newServer(&config.Config{
+		ServerEnabled:   true,
+		MetricsPort:     "9090",
+		ServerAddresses: []string{"127.0.0.1:9090"},
+		APIKeyFile:      keyFile,
+	}, nil)
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	if srv == nil {
+		t.Fatal("newServer() = nil, want a server")
+	}
+}
+
+func TestNewServer_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{name: "missing api key file", cfg: config.Config{APIKeyFile: "/nonexistent/api_key"}},
+		{name: "certificate without key", cfg: config.Config{ServerTLSCertFile: "/certs/tls.crt"}},
+		{name: "client ca without tls", cfg: config.Config{ServerTLSClientCAFile: "/certs/ca.crt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.ServerEnabled = true
+			if _, err := newServer(&tt.cfg, nil); err == nil {
+				t.Error("newServer() error = nil, want error")
+			}
+		})
+	}
+}
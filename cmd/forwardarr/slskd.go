@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/slskd"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newSlskdConnector returns the function that connects to slskd
+func newSlskdConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	opts, err := slskdOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QbitNetworkInterface != "" || cfg.QbitInterfaceAddress != "" {
+		slog.Warn("TORRENT_CLIENT_NETWORK_INTERFACE and TORRENT_CLIENT_INTERFACE_ADDRESS are not supported by slskd")
+	}
+	if cfg.QbitEnforceStaticPort {
+		slog.Warn("TORRENT_CLIENT_ENFORCE_STATIC_PORT has no effect on slskd, which has no random port or UPnP")
+	}
+
+	return func() (torrent.Client, error) {
+		client, err := slskd.NewClient(cfg.QbitAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRetryPolicy(retryPolicy(cfg))
+		return client, nil
+	}, nil
+}
+
+// slskdOptions returns the connection options of the slskd client. The
+// password method logs in to slskd, apikey sends the API key and bypass
+// sends no credentials.
+func slskdOptions(cfg *config.Config) (slskd.Options, error) {
+	auth, err := qbitAuth(cfg)
+	if err != nil {
+		return slskd.Options{}, err
+	}
+	transport, err := transportOptions(cfg, "slskd")
+	if err != nil {
+		return slskd.Options{}, err
+	}
+	return slskd.Options{
+		Username:  auth.Username,
+		Password:  auth.Password,
+		APIKey:    auth.APIKey,
+		Transport: transport,
+	}, nil
+}
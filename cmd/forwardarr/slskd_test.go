@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestSlskdOptions(t *testing.T) {
+	opts, err := slskdOptions(&config.Config{QbitUser: "slskd", QbitPass: "secret"})
+	if err != nil {
+		t.Fatalf("slskdOptions() error = %v", err)
+	}
+	if opts.Username != "slskd" || opts.Password != "secret" || opts.APIKey != "" {
+		t.Errorf("slskdOptions() = %+v, want slskd credentials", opts)
+	}
+
+	opts, err = slskdOptions(&config.Config{QbitAuth: "apikey", QbitAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("slskdOptions() error = %v", err)
+	}
+	if opts.APIKey != "key" || opts.Username != "" {
+		t.Errorf("slskdOptions() = %+v, want only the API key", opts)
+	}
+
+	opts, err = slskdOptions(&config.Config{QbitAuth: "bypass", QbitUser: "slskd", QbitPass: "secret"})
+	if err != nil {
+		t.Fatalf("slskdOptions() error = %v", err)
+	}
+	if opts.Username != "" || opts.Password != "" || opts.APIKey != "" {
+		t.Errorf("slskdOptions() = %+v, want no credentials with bypass", opts)
+	}
+}
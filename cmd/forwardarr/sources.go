@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// portSourceNames are the sources that can be listed in PORT_SOURCES
+var portSourceNames = []string{"gluetun_control", "file", "natpmp", "upnp", "pia", "http", "exec", "docker", "kubernetes", "mqtt", "static"}
+
+// portSources describes where the watcher reads the forwarded port from
+type portSources struct {
+	// portFile is watched when source is nil
+	portFile       string
+	portFileFormat sync.PortFileFormat
+	// source is polled every pollInterval; name names it in the source
+	// health
+	source       sync.PortSource
+	name         string
+	pollInterval time.Duration
+	// mappers renew their mappings while they run
+	mappers []portMapper
+}
+
+// newPortSources returns the configured port sources: the fallback through
+// PORT_SOURCES if set, otherwise the single source other than the port
+// file that is configured, or the port file
+func newPortSources(cfg *config.Config) (*portSources, error) {
+	format, err := sync.ParsePortFileFormat(cfg.GluetunPortFileFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GLUETUN_PORT_FILE_FORMAT: %w", err)
+	}
+	if len(cfg.PortSources) > 0 {
+		return newFallbackSources(cfg, format)
+	}
+
+	control, err := gluetunControl(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := newPortMapper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	http, err := httpSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exec, err := execSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	docker, err := dockerSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	kubernetes, err := kubernetesSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case control != nil:
+		return &portSources{source: control, name: "gluetun_control", pollInterval: cfg.GluetunControlPollInterval}, nil
+	case http != nil:
+		return &portSources{source: http, name: "http", pollInterval: cfg.HTTPSourcePollInterval}, nil
+	case exec != nil:
+		return &portSources{source: exec, name: "exec", pollInterval: cfg.ExecSourcePollInterval}, nil
+	case docker != nil:
+		return &portSources{source: docker, name: "docker", pollInterval: cfg.DockerSourcePollInterval}, nil
+	case kubernetes != nil:
+		return &portSources{source: kubernetes, name: "kubernetes", pollInterval: mapperPollInterval, mappers: []portMapper{kubernetes}}, nil
+	case mapper != nil:
+		return &portSources{source: mapper, name: mapperName(cfg), pollInterval: mapperPollInterval, mappers: []portMapper{mapper}}, nil
+	}
+	return &portSources{portFile: cfg.GluetunPortFile, name: "file", portFileFormat: format}, nil
+}
+
+// newFallbackSources builds the sources of PORT_SOURCES in order. They are
+// polled at the longest poll interval of the listed Gluetun control server,
+// HTTP, exec and Docker sources, so that none of them is asked more often
+// than configured, and every second without them.
+func newFallbackSources(cfg *config.Config, format sync.PortFileFormat) (*portSources, error) {
+	sources := &portSources{pollInterval: mapperPollInterval}
+	var named []sync.NamedSource
+	seen := make(map[string]bool)
+	for _, name := range cfg.PortSources {
+		if seen[name] {
+			return nil, fmt.Errorf("port source %q is listed twice in PORT_SOURCES", name)
+		}
+		seen[name] = true
+
+		var source sync.PortSource
+		var mapper portMapper
+		switch name {
+		case "gluetun_control":
+			control, err := gluetunControl(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if control == nil {
+				return nil, notConfigured(name, "GLUETUN_CONTROL_URL")
+			}
+			source = control
+			sources.pollInterval = max(sources.pollInterval, cfg.GluetunControlPollInterval)
+		case "file":
+			if cfg.GluetunPortFile == "" {
+				return nil, notConfigured(name, "GLUETUN_PORT_FILE")
+			}
+			source = sync.FileSource{Path: cfg.GluetunPortFile, Format: format}
+		case "natpmp":
+			natpmp, err := natpmpSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if natpmp == nil {
+				return nil, notConfigured(name, "NATPMP_GATEWAY")
+			}
+			source, mapper = natpmp, natpmp
+		case "upnp":
+			upnp, err := upnpSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if upnp == nil {
+				return nil, notConfigured(name, "UPNP_INTERNAL_PORT")
+			}
+			source, mapper = upnp, upnp
+		case "pia":
+			pia, err := piaSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if pia == nil {
+				return nil, notConfigured(name, "PIA_HOSTNAME")
+			}
+			source, mapper = pia, pia
+		case "http":
+			http, err := httpSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if http == nil {
+				return nil, notConfigured(name, "HTTP_SOURCE_URL")
+			}
+			source = http
+			sources.pollInterval = max(sources.pollInterval, cfg.HTTPSourcePollInterval)
+		case "exec":
+			exec, err := execSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if exec == nil {
+				return nil, notConfigured(name, "EXEC_SOURCE_COMMAND")
+			}
+			source = exec
+			sources.pollInterval = max(sources.pollInterval, cfg.ExecSourcePollInterval)
+		case "docker":
+			docker, err := dockerSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if docker == nil {
+				return nil, notConfigured(name, "DOCKER_SOURCE_CONTAINER")
+			}
+			source = docker
+			sources.pollInterval = max(sources.pollInterval, cfg.DockerSourcePollInterval)
+		case "kubernetes":
+			kubernetes, err := kubernetesSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if kubernetes == nil {
+				return nil, notConfigured(name, "KUBERNETES_SOURCE_CONFIGMAP or KUBERNETES_SOURCE_POD")
+			}
+			source, mapper = kubernetes, kubernetes
+		case "mqtt":
+			mqtt, err := mqttSource(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if mqtt == nil {
+				return nil, notConfigured(name, "MQTT_SOURCE_URL")
+			}
+			source, mapper = mqtt, mqtt
+		case "static":
+			if cfg.StaticPort < 1 || cfg.StaticPort > 65535 {
+				return nil, notConfigured(name, "STATIC_PORT")
+			}
+			source = sync.StaticPort(cfg.StaticPort)
+		default:
+			return nil, fmt.Errorf("unknown port source %q in PORT_SOURCES, expected one of %s", name, strings.Join(portSourceNames, ", "))
+		}
+		named = append(named, sync.NamedSource{Name: name, Source: source})
+		if mapper != nil {
+			sources.mappers = append(sources.mappers, mapper)
+		}
+	}
+
+	slog.Info("reading the forwarded port from the first available source", "sources", cfg.PortSources)
+	sources.source = sync.NewFallback(named...)
+	return sources, nil
+}
+
+// notConfigured is the error for a source of PORT_SOURCES whose variable is
+// not set
+func notConfigured(name, variable string) error {
+	return fmt.Errorf("port source %q is listed in PORT_SOURCES, but %s is not set", name, variable)
+}
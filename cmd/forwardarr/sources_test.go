@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+func TestNewPortSources(t *testing.T) {
+	sources, err := newPortSources(&config.Config{GluetunPortFile: "/tmp/gluetun/forwarded_port"})
+	if err != nil || sources.portFile != "/tmp/gluetun/forwarded_port" || sources.source != nil || sources.name != "file" {
+		t.Errorf("newPortSources() = %+v, %v, want the port file", sources, err)
+	}
+
+	sources, err = newPortSources(&config.Config{GluetunPortFile: "/tmp/gluetun/forwarded_port", GluetunPortFileFormat: "list"})
+	if err != nil || sources.portFileFormat != sync.PortFileList {
+		t.Errorf("newPortSources() = %+v, %v, want the list format", sources, err)
+	}
+	if _, err := newPortSources(&config.Config{GluetunPortFile: "/tmp/gluetun/forwarded_port", GluetunPortFileFormat: "yaml"}); err == nil {
+		t.Error("newPortSources() with unknown port file format error = nil")
+	}
+
+	sources, err = newPortSources(&config.Config{GluetunPortFile: "/tmp/gluetun/forwarded_port", GluetunControlURL: "http://gluetun:8000", GluetunControlPollInterval: 10 * time.Second})
+	if err != nil || sources.portFile != "" || sources.source == nil || sources.pollInterval != 10*time.Second {
+		t.Errorf("newPortSources() = %+v, %v, want the control server", sources, err)
+	}
+
+	sources, err = newPortSources(&config.Config{UPnPInternalPort: 6881, UPnPLease: time.Hour})
+	if err != nil || len(sources.mappers) != 1 || sources.pollInterval != mapperPollInterval || sources.name != "upnp" {
+		t.Errorf("newPortSources() = %+v, %v, want the UPnP mapper", sources, err)
+	}
+
+	sources, err = newPortSources(&config.Config{HTTPSourceURL: "http://vpn:8080/port", HTTPSourcePollInterval: 30 * time.Second})
+	if err != nil || sources.source == nil || len(sources.mappers) != 0 || sources.pollInterval != 30*time.Second || sources.name != "http" {
+		t.Errorf("newPortSources() = %+v, %v, want the HTTP source", sources, err)
+	}
+
+	sources, err = newPortSources(&config.Config{ExecSourceCommand: "/bin/sh", ExecSourcePollInterval: 20 * time.Second})
+	if err != nil || sources.source == nil || sources.pollInterval != 20*time.Second {
+		t.Errorf("newPortSources() = %+v, %v, want the exec source", sources, err)
+	}
+
+	sources, err = newPortSources(&config.Config{DockerSourceContainer: "gluetun", DockerSourceEnv: "VPN_PORT", DockerSourcePollInterval: 15 * time.Second})
+	if err != nil || sources.source == nil || sources.pollInterval != 15*time.Second {
+		t.Errorf("newPortSources() = %+v, %v, want the Docker source", sources, err)
+	}
+}
+
+func TestNewPortSources_Fallback(t *testing.T) {
+	cfg := &config.Config{
+		PortSources:                []string{"gluetun_control", "upnp", "http", "file", "static"},
+		GluetunPortFile:            "/tmp/gluetun/forwarded_port",
+		GluetunControlURL:          "http://gluetun:8000",
+		GluetunControlPollInterval: 10 * time.Second,
+		UPnPInternalPort:           6881,
+		UPnPLease:                  time.Hour,
+		StaticPort:                 51413,
+		HTTPSourceURL:              "http://vpn:8080/port",
+		HTTPSourcePollInterval:     5 * time.Second,
+	}
+	sources, err := newPortSources(cfg)
+	if err != nil {
+		t.Fatalf("newPortSources() error = %v", err)
+	}
+	if _, ok := sources.source.(*sync.Fallback); !ok || sources.portFile != "" {
+		t.Errorf("newPortSources() = %+v, want a fallback", sources)
+	}
+	if sources.pollInterval != 10*time.Second || len(sources.mappers) != 1 {
+		t.Errorf("pollInterval = %v, mappers = %d, want the interval of the control server and the UPnP mapper", sources.pollInterval, len(sources.mappers))
+	}
+
+	for name, test := range map[string]struct {
+		sources []string
+		want    string
+	}{
+		"unknown":        {[]string{"file", "gluetun"}, `unknown port source "gluetun"`},
+		"duplicate":      {[]string{"file", "file"}, "listed twice"},
+		"not configured": {[]string{"natpmp"}, "NATPMP_GATEWAY is not set"},
+		"static":         {[]string{"static"}, "STATIC_PORT is not set"},
+		"http":           {[]string{"http"}, "HTTP_SOURCE_URL is not set"},
+		"exec":           {[]string{"exec"}, "EXEC_SOURCE_COMMAND is not set"},
+		"docker":         {[]string{"docker"}, "DOCKER_SOURCE_CONTAINER is not set"},
+		"kubernetes":     {[]string{"kubernetes"}, "KUBERNETES_SOURCE_POD is not set"},
+		"mqtt":           {[]string{"mqtt"}, "MQTT_SOURCE_URL is not set"},
+	} {
+		_, err := newPortSources(&config.Config{PortSources: test.sources, GluetunPortFile: "/tmp/gluetun/forwarded_port"})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("newPortSources() with %s source error = %v, want %q", name, err, test.want)
+		}
+	}
+}
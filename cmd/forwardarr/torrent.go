@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// torrentClientType is a supported torrent client
+type torrentClientType struct {
+	// name is the display name, for log messages
+	name string
+	// connector validates the options of the client and returns the
+	// function that connects to it
+	connector func(cfg *config.Config) (func() (torrent.Client, error), error)
+}
+
+// torrentClientTypes are the supported torrent clients by
+// TORRENT_CLIENT_TYPE
+var torrentClientTypes = map[string]torrentClientType{
+	"qbittorrent":  {name: "qBittorrent", connector: newQbitConnector},
+	"transmission": {name: "Transmission", connector: newTransmissionConnector},
+	"rtorrent":     {name: "rTorrent", connector: newRTorrentConnector},
+	"aria2":        {name: "aria2", connector: newAria2Connector},
+	"slskd":        {name: "slskd", connector: newSlskdConnector},
+	"generic":      {name: "generic target", connector: newGenericConnector},
+	"exec":         {name: "exec target", connector: newExecConnector},
+}
+
+// lookupTorrentClientType returns the configured torrent client; an empty
+// type is qBittorrent
+func lookupTorrentClientType(cfg *config.Config) (torrentClientType, bool) {
+	name := cfg.TorrentClient
+	if name == "" {
+		name = "qbittorrent"
+	}
+	clientType, ok := torrentClientTypes[name]
+	return clientType, ok
+}
+
+// torrentClientName returns the display name of the configured torrent
+// client, for log messages
+func torrentClientName(cfg *config.Config) string {
+	if clientType, ok := lookupTorrentClientType(cfg); ok {
+		return clientType.name
+	}
+	return cfg.TorrentClient
+}
+
+// torrentClientTarget returns where the configured torrent client is, for
+// messages: its URL, or the command of the exec client
+func torrentClientTarget(cfg *config.Config) string {
+	if cfg.TorrentClient == "exec" {
+		return cfg.ExecCommand
+	}
+	return cfg.QbitAddr
+}
+
+// newTorrentConnector validates the options of the configured torrent client
+// and returns the function that connects to it. Invalid options are reported
+// here, before connection attempts are retried.
+func newTorrentConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	clientType, ok := lookupTorrentClientType(cfg)
+	if !ok {
+		names := slices.Sorted(maps.Keys(torrentClientTypes))
+		return nil, fmt.Errorf("unknown TORRENT_CLIENT_TYPE %q (expected one of %s)", cfg.TorrentClient, strings.Join(names, ", "))
+	}
+	return clientType.connector(cfg)
+}
+
+// torrentClientConfigs returns the configuration of every torrent client:
+// cfg for the first one, and a copy of it with the settings of each
+// additional client
+func torrentClientConfigs(cfg *config.Config) []*config.Config {
+	configs := []*config.Config{cfg}
+	for _, client := range cfg.TorrentClients {
+		clientCfg := *cfg
+		clientCfg.TorrentClient = client.Type
+		clientCfg.QbitAddr = client.Addr
+		clientCfg.QbitUser = client.User
+		clientCfg.QbitPass = client.Pass
+		clientCfg.QbitUserFile = client.UserFile
+		clientCfg.QbitPassFile = client.PassFile
+		clientCfg.QbitAuth = client.Auth
+		clientCfg.QbitAPIKey = client.APIKey
+		clientCfg.QbitAPIKeyFile = client.APIKeyFile
+		clientCfg.QbitTLSCAFile = client.TLSCAFile
+		clientCfg.QbitTLSCertFile = client.TLSCertFile
+		clientCfg.QbitTLSKeyFile = client.TLSKeyFile
+		clientCfg.QbitTLSInsecure = client.TLSInsecure
+		clientCfg.QbitEnforceStaticPort = client.EnforceStaticPort
+		clientCfg.QbitProxy = client.Proxy
+		clientCfg.QbitNetworkInterface = client.NetworkInterface
+		clientCfg.QbitInterfaceAddress = client.InterfaceAddress
+		clientCfg.GenericMethod = client.GenericMethod
+		clientCfg.GenericBody = client.GenericBody
+		clientCfg.GenericContentType = client.GenericContentType
+		clientCfg.GenericHeaders = client.GenericHeaders
+		clientCfg.ExecCommand = client.ExecCommand
+		clientCfg.ExecTimeout = client.ExecTimeout
+		clientCfg.TorrentClients = nil
+		configs = append(configs, &clientCfg)
+	}
+	return configs
+}
+
+// retryPolicy returns how failed requests to the torrent client are retried
+func retryPolicy(cfg *config.Config) torrent.RetryPolicy {
+	return torrent.RetryPolicy{
+		MaxAttempts: cfg.QbitMaxAttempts,
+		BaseDelay:   cfg.QbitRetryDelay,
+		MaxDelay:    cfg.QbitRetryMaxDelay,
+	}
+}
+
+// transportOptions returns the connection settings shared by all torrent
+// clients; name is the client in messages
+func transportOptions(cfg *config.Config, name string) (torrent.TransportOptions, error) {
+	tlsOptions := torrent.TLSOptions{
+		CAFile:             cfg.QbitTLSCAFile,
+		CertFile:           cfg.QbitTLSCertFile,
+		KeyFile:            cfg.QbitTLSKeyFile,
+		InsecureSkipVerify: cfg.QbitTLSInsecure,
+	}
+	if tlsOptions.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification disabled for " + name)
+	}
+	tlsConfig, err := tlsOptions.Config()
+	if err != nil {
+		return torrent.TransportOptions{}, fmt.Errorf("invalid %s TLS options: %w", name, err)
+	}
+	var proxy *url.URL
+	if cfg.QbitProxy != "" {
+		proxy, err = torrent.ParseProxyURL(cfg.QbitProxy)
+		if err != nil {
+			return torrent.TransportOptions{}, err
+		}
+	}
+
+	return torrent.TransportOptions{
+		TLS:               tlsConfig,
+		Timeout:           cfg.QbitTimeout,
+		DialTimeout:       cfg.QbitDialTimeout,
+		IdleTimeout:       cfg.QbitKeepAlive,
+		DisableKeepAlives: cfg.QbitKeepAlive == 0,
+		Proxy:             proxy,
+	}, nil
+}
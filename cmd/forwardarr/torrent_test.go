@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestNewTorrentConnector(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{name: "qbittorrent", cfg: config.Config{TorrentClient: "qbittorrent"}},
+		{name: "default", cfg: config.Config{}},
+		{name: "transmission", cfg: config.Config{TorrentClient: "transmission"}},
+		{name: "transmission with api key", cfg: config.Config{TorrentClient: "transmission", QbitAuth: "apikey", QbitAPIKey: "key"}, wantErr: true},
+		{name: "rtorrent", cfg: config.Config{TorrentClient: "rtorrent"}},
+		{name: "rtorrent with api key", cfg: config.Config{TorrentClient: "rtorrent", QbitAuth: "apikey", QbitAPIKey: "key"}, wantErr: true},
+		{name: "aria2", cfg: config.Config{TorrentClient: "aria2", QbitAuth: "apikey", QbitAPIKey: "secret"}},
+		{name: "slskd", cfg: config.Config{TorrentClient: "slskd", QbitAuth: "apikey", QbitAPIKey: "key"}},
+		{name: "generic", cfg: config.Config{TorrentClient: "generic", QbitAddr: "http://service/port/{{.Port}}"}},
+		{name: "unknown client", cfg: config.Config{TorrentClient: "deluge"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connect, err := newTorrentConnector(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newTorrentConnector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && connect == nil {
+				t.Error("newTorrentConnector() returned no connector")
+			}
+		})
+	}
+}
+
+func TestTorrentClientConfigs(t *testing.T) {
+	cfg := &config.Config{
+		TorrentClient: "qbittorrent",
+		QbitAddr:      "http://qbittorrent:8080",
+		QbitTimeout:   time.Second,
+		TorrentClients: []config.TorrentClientConfig{
+			{Type: "transmission", Addr: "http://transmission:9091", Auth: "bypass"},
+		},
+	}
+
+	configs := torrentClientConfigs(cfg)
+	if len(configs) != 2 {
+		t.Fatalf("torrentClientConfigs() returned %d configs, want 2", len(configs))
+	}
+	if configs[0] != cfg {
+		t.Error("first config is not the unnumbered client")
+	}
+	second := configs[1]
+	if second.TorrentClient != "transmission" || second.QbitAddr != "http://transmission:9091" || second.QbitAuth != "bypass" {
+		t.Errorf("additional client config = %+v", second)
+	}
+	if second.QbitTimeout != time.Second {
+		t.Errorf("QbitTimeout = %v, want the shared timeout", second.QbitTimeout)
+	}
+	if cfg.QbitAddr != "http://qbittorrent:8080" {
+		t.Errorf("first config changed to %q", cfg.QbitAddr)
+	}
+}
+
+func TestCreateTorrentClients_InvalidAdditionalClient(t *testing.T) {
+	cfg := &config.Config{
+		TorrentClients: []config.TorrentClientConfig{{Type: "deluge", Addr: "http://deluge:8112"}},
+	}
+	_, err := createTorrentClients(cfg, time.Millisecond, time.Millisecond, 1)
+	if err == nil || !strings.Contains(err.Error(), "deluge") {
+		t.Errorf("createTorrentClients() error = %v, want unknown type reported", err)
+	}
+}
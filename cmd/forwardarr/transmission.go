@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/torrent"
+	"github.com/eslutz/forwardarr/internal/transmission"
+)
+
+// newTransmissionConnector returns the function that connects to
+// Transmission
+func newTransmissionConnector(cfg *config.Config) (func() (torrent.Client, error), error) {
+	opts, err := transmissionOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QbitNetworkInterface != "" || cfg.QbitInterfaceAddress != "" {
+		slog.Warn("TORRENT_CLIENT_NETWORK_INTERFACE and TORRENT_CLIENT_INTERFACE_ADDRESS are not supported by Transmission, set bind-address-ipv4 in its settings.json instead")
+	}
+	args := transmissionPortArguments(cfg)
+
+	return func() (torrent.Client, error) {
+		client, err := transmission.NewClient(cfg.QbitAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRetryPolicy(retryPolicy(cfg))
+		client.SetPortArguments(args)
+		return client, nil
+	}, nil
+}
+
+// transmissionOptions returns the connection options of the Transmission
+// client. Transmission only knows basic authentication, so the password
+// method sends the credentials and bypass sends none.
+func transmissionOptions(cfg *config.Config) (transmission.Options, error) {
+	auth, err := qbitAuth(cfg)
+	if err != nil {
+		return transmission.Options{}, err
+	}
+	if auth.Method == qbit.AuthAPIKey {
+		return transmission.Options{}, errors.New("the apikey auth method is not supported by Transmission")
+	}
+	transport, err := transportOptions(cfg, "Transmission")
+	if err != nil {
+		return transmission.Options{}, err
+	}
+	return transmission.Options{Username: auth.Username, Password: auth.Password, Transport: transport}, nil
+}
+
+// transmissionPortArguments returns the session arguments written along with
+// every port. With QbitEnforceStaticPort, port forwarding by UPnP/NAT-PMP and
+// the random port at startup are turned off.
+func transmissionPortArguments(cfg *config.Config) map[string]any {
+	if !cfg.QbitEnforceStaticPort {
+		return nil
+	}
+	return map[string]any{
+		"port-forwarding-enabled":   false,
+		"peer-port-random-on-start": false,
+	}
+}
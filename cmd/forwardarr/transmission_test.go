@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestTransmissionOptions(t *testing.T) {
+	opts, err := transmissionOptions(&config.Config{QbitUser: "admin", QbitPass: "secret"})
+	if err != nil {
+		t.Fatalf("transmissionOptions() error = %v", err)
+	}
+	if opts.Username != "admin" || opts.Password != "secret" {
+		t.Errorf("transmissionOptions() = %+v, want admin credentials", opts)
+	}
+
+	opts, err = transmissionOptions(&config.Config{QbitAuth: "bypass", QbitUser: "admin", QbitPass: "secret"})
+	if err != nil {
+		t.Fatalf("transmissionOptions() error = %v", err)
+	}
+	if opts.Username != "" || opts.Password != "" {
+		t.Errorf("transmissionOptions() = %+v, want no credentials with bypass", opts)
+	}
+}
+
+func TestTransmissionPortArguments(t *testing.T) {
+	if args := transmissionPortArguments(&config.Config{}); args != nil {
+		t.Errorf("transmissionPortArguments() = %v, want nil", args)
+	}
+
+	args := transmissionPortArguments(&config.Config{QbitEnforceStaticPort: true})
+	if args["port-forwarding-enabled"] != false || args["peer-port-random-on-start"] != false {
+		t.Errorf("transmissionPortArguments() = %v, want port forwarding and random port disabled", args)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+	"github.com/eslutz/forwardarr/internal/upnp"
+)
+
+// upnpSource returns the source that maps the port on the router with UPnP,
+// or nil if no internal port is configured
+func upnpSource(cfg *config.Config) (*upnp.Source, error) {
+	if cfg.UPnPInternalPort == 0 {
+		return nil, nil
+	}
+	if cfg.UPnPInternalPort < 1 || cfg.UPnPInternalPort > 65535 {
+		return nil, fmt.Errorf("UPNP_INTERNAL_PORT %d out of valid range", cfg.UPnPInternalPort)
+	}
+	if cfg.UPnPLease < 2*time.Second {
+		return nil, fmt.Errorf("UPNP_LEASE must be at least 2 seconds, got %v", cfg.UPnPLease)
+	}
+	slog.Info("mapping the port on the router with UPnP",
+		"internal_port", cfg.UPnPInternalPort,
+		"lease", cfg.UPnPLease,
+		"description_url", cfg.UPnPDescriptionURL,
+	)
+	return upnp.NewSource(cfg.UPnPDescriptionURL, cfg.UPnPInternalPort, cfg.UPnPLease), nil
+}
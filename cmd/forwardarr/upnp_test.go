@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/config"
+)
+
+func TestUpnpSource(t *testing.T) {
+	source, err := upnpSource(&config.Config{})
+	if err != nil || source != nil {
+		t.Errorf("upnpSource() = %v, %v, want none without internal port", source, err)
+	}
+
+	source, err = upnpSource(&config.Config{UPnPInternalPort: 6881, UPnPLease: time.Hour})
+	if err != nil || source == nil {
+		t.Errorf("upnpSource() = %v, %v, want a source", source, err)
+	}
+
+	for name, cfg := range map[string]config.Config{
+		"internal port": {UPnPInternalPort: 70000, UPnPLease: time.Hour},
+		"lease":         {UPnPInternalPort: 6881, UPnPLease: 0},
+	} {
+		if _, err := upnpSource(&cfg); err == nil {
+			t.Errorf("upnpSource() with invalid %s error = nil", name)
+		}
+	}
+}
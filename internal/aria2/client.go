@@ -0,0 +1,236 @@
+package aria2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// rpcPath is where aria2 serves JSON-RPC
+const rpcPath = "/jsonrpc"
+
+// maxResponseSize limits a JSON-RPC response
+const maxResponseSize = 1 << 20
+
+// Client talks to the JSON-RPC interface of aria2. The BitTorrent listening
+// port and the DHT port are set to the forwarded port together.
+type Client struct {
+	rpcURL string
+	secret string
+	client *http.Client
+	retry  torrent.RetryPolicy
+}
+
+// Options configure how the client connects to aria2. Secret is the
+// rpc-secret of aria2, sent as "token:" parameter with every call; empty
+// sends none.
+type Options struct {
+	Secret    string
+	Transport torrent.TransportOptions
+}
+
+// ErrPortNotApplied means aria2 accepted a new listening port but kept the
+// previous one
+var ErrPortNotApplied = errors.New("aria2 did not apply the listening port")
+
+// errUnauthorized is the error aria2 returns for a missing or wrong secret
+const errUnauthorized = "Unauthorized"
+
+// NewClient creates a client for the aria2 at baseURL and checks that it
+// accepts requests. Without a path, baseURL is completed with /jsonrpc.
+func NewClient(baseURL string, opts Options) (*Client, error) {
+	rpcURL, err := rpcEndpoint(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		rpcURL: rpcURL,
+		secret: opts.Secret,
+		client: torrent.NewHTTPClient(opts.Transport),
+		retry:  torrent.DefaultRetryPolicy,
+	}
+
+	// The caller retries the initial request, e.g. until aria2 has started
+	if err := client.PingOnce(); err != nil {
+		return nil, fmt.Errorf("initial request failed: %w", err)
+	}
+	return client, nil
+}
+
+// rpcEndpoint returns the JSON-RPC URL for the URL of aria2
+func rpcEndpoint(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid aria2 URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid aria2 URL %q: scheme and host are required", baseURL)
+	}
+	if u.Path == "" {
+		u.Path = rpcPath
+	}
+	return u.String(), nil
+}
+
+// SetRetryPolicy changes how failed requests to aria2 are retried. It must be
+// called before the client is shared.
+func (c *Client) SetRetryPolicy(policy torrent.RetryPolicy) {
+	c.retry = policy
+}
+
+// GetPort returns the first BitTorrent listening port of aria2
+func (c *Client) GetPort() (int, error) {
+	var port int
+	err := c.retry.Run("get port", func() error {
+		var err error
+		port, err = c.listenPort()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// SetPort sets listen-port and dht-listen-port with changeGlobalOption, and
+// reads the options back to verify that aria2 applied the port. A port that
+// was not applied is retried like a failed request.
+func (c *Client) SetPort(port int) error {
+	options := map[string]string{
+		"listen-port":     strconv.Itoa(port),
+		"dht-listen-port": strconv.Itoa(port),
+	}
+	return c.retry.Run("set port", func() error {
+		if err := c.call("aria2.changeGlobalOption", nil, options); err != nil {
+			return fmt.Errorf("failed to change global options: %w", err)
+		}
+		applied, err := c.listenPort()
+		if err != nil {
+			return fmt.Errorf("failed to verify port: %w", err)
+		}
+		if applied != port {
+			return fmt.Errorf("%w: listen-port is %d instead of %d", ErrPortNotApplied, applied, port)
+		}
+		slog.Info("successfully updated aria2 listening port", "port", port)
+		return nil
+	})
+}
+
+// listenPort reads the first port of listen-port with a single attempt
+func (c *Client) listenPort() (int, error) {
+	var options map[string]string
+	if err := c.call("aria2.getGlobalOption", &options); err != nil {
+		return 0, fmt.Errorf("failed to get global options: %w", err)
+	}
+	// listen-port may be a list or range such as 6881-6999
+	value := options["listen-port"]
+	first, _, _ := strings.Cut(strings.Split(value, ",")[0], "-")
+	port, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, fmt.Errorf("invalid listen-port %q", value)
+	}
+	return port, nil
+}
+
+// Ping checks that aria2 is reachable, retrying failed attempts so that a
+// brief restart is not reported as an outage
+func (c *Client) Ping() error {
+	return c.retry.Run("ping", c.PingOnce)
+}
+
+// PingOnce checks that aria2 is reachable with a single attempt, for checks
+// that report the current state such as readiness probes
+func (c *Client) PingOnce() error {
+	if err := c.call("aria2.getVersion", nil); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      string `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// call invokes method with the secret token and params, and decodes the
+// result into result, unless it is nil
+func (c *Client) call(method string, result any, params ...any) error {
+	args := make([]any, 0, len(params)+1)
+	if c.secret != "" {
+		args = append(args, "token:"+c.secret)
+	}
+	args = append(args, params...)
+
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: "forwardarr", Method: method, Params: args})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closeResponseBody(resp)
+
+	// aria2 answers errors with 400 and the error in the body
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	var response rpcResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(data))
+	}
+	if response.Error != nil {
+		err := fmt.Errorf("%s failed: %s (code %d)", method, response.Error.Message, response.Error.Code)
+		if response.Error.Message == errUnauthorized {
+			return torrent.Permanent(err)
+		}
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(data))
+	}
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+func closeResponseBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+}
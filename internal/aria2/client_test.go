@@ -0,0 +1,152 @@
+package aria2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// fakeAria2 emulates the JSON-RPC interface of aria2 with an rpc-secret
+type fakeAria2 struct {
+	secret  string
+	options map[string]string
+	// ignorePort keeps changeGlobalOption from changing the options
+	ignorePort bool
+}
+
+func newFakeAria2(t *testing.T, fake *fakeAria2) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != rpcPath {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			ID     string            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		respond := func(status int, response map[string]any) {
+			response["id"] = req.ID
+			response["jsonrpc"] = "2.0"
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(response)
+		}
+		params := req.Params
+		if fake.secret != "" {
+			var token string
+			if len(params) > 0 {
+				_ = json.Unmarshal(params[0], &token)
+				params = params[1:]
+			}
+			if token != "token:"+fake.secret {
+				respond(http.StatusBadRequest, map[string]any{"error": map[string]any{"code": 1, "message": "Unauthorized"}})
+				return
+			}
+		}
+
+		switch req.Method {
+		case "aria2.getVersion":
+			respond(http.StatusOK, map[string]any{"result": map[string]any{"version": "1.37.0"}})
+		case "aria2.getGlobalOption":
+			respond(http.StatusOK, map[string]any{"result": fake.options})
+		case "aria2.changeGlobalOption":
+			var options map[string]string
+			if len(params) > 0 {
+				_ = json.Unmarshal(params[0], &options)
+			}
+			if !fake.ignorePort {
+				for key, value := range options {
+					fake.options[key] = value
+				}
+			}
+			respond(http.StatusOK, map[string]any{"result": "OK"})
+		default:
+			respond(http.StatusBadRequest, map[string]any{"error": map[string]any{"code": 1, "message": "No such method: " + req.Method}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	fake := &fakeAria2{secret: "s3cret", options: map[string]string{"listen-port": "6881-6999", "dht-listen-port": "6881-6999"}}
+	server := newFakeAria2(t, fake)
+
+	client, err := NewClient(server.URL, Options{Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	port, err := client.GetPort()
+	if err != nil {
+		t.Fatalf("GetPort() error = %v", err)
+	}
+	if port != 6881 {
+		t.Errorf("GetPort() = %d, want 6881", port)
+	}
+
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if fake.options["listen-port"] != "6000" || fake.options["dht-listen-port"] != "6000" {
+		t.Errorf("options = %v, want listen-port and dht-listen-port 6000", fake.options)
+	}
+}
+
+func TestNewClient_WrongSecret(t *testing.T) {
+	fake := &fakeAria2{secret: "s3cret"}
+	server := newFakeAria2(t, fake)
+
+	_, err := NewClient(server.URL, Options{Secret: "wrong"})
+	if err == nil {
+		t.Fatal("NewClient() error = nil, want unauthorized")
+	}
+	if !torrent.IsPermanent(err) {
+		t.Errorf("error %v is retried, want permanent", err)
+	}
+}
+
+func TestSetPort_NotApplied(t *testing.T) {
+	fake := &fakeAria2{options: map[string]string{"listen-port": "6881"}, ignorePort: true}
+	server := newFakeAria2(t, fake)
+
+	client, err := NewClient(server.URL, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	if err := client.SetPort(6000); !errors.Is(err, ErrPortNotApplied) {
+		t.Errorf("SetPort() error = %v, want ErrPortNotApplied", err)
+	}
+}
+
+func TestRPCEndpoint(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "http://localhost:6800", want: "http://localhost:6800/jsonrpc"},
+		{baseURL: "https://aria2.example/rpc/jsonrpc", want: "https://aria2.example/rpc/jsonrpc"},
+		{baseURL: "localhost:6800", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := rpcEndpoint(tt.baseURL)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("rpcEndpoint(%q) = %q, %v, want %q", tt.baseURL, got, err, tt.want)
+		}
+	}
+}
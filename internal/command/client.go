@@ -0,0 +1,178 @@
+// Package command keeps anything a script can reach in sync with the
+// forwarded port, by running a configured command for every new port, e.g.
+// to open the port in a firewall or restart a service.
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a run of the command when Options.Timeout is 0
+const DefaultTimeout = 30 * time.Second
+
+// maxOutput is how much of the output of a run is kept for logs and errors
+const maxOutput = 4096
+
+// waitDelay is how long a run may wait for its output after the command
+// exited or was killed, e.g. for a background process still holding the
+// output open
+const waitDelay = time.Second
+
+// Options configure how the command runs
+type Options struct {
+	// Timeout kills the command when it runs longer; 0 is DefaultTimeout
+	Timeout time.Duration
+}
+
+// Client runs a command for every new port, with the port and the previous
+// port as arguments and in FORWARDARR_PORT and FORWARDARR_PREVIOUS_PORT. The
+// previous port is 0 for the first run. The command is run directly, not
+// through a shell.
+//
+// Like a generic HTTP target, the command cannot be asked for its port, so
+// the client reports the port of the last successful run, and 0 before the
+// first one. A failed run is therefore repeated with the next sync.
+type Client struct {
+	path    string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	port int
+}
+
+// NewClient creates a client for the command, which is looked up in PATH
+// unless it contains a slash
+func NewClient(command string, opts Options) (*Client, error) {
+	if command == "" {
+		return nil, errors.New("no command configured")
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("command not found: %w", err)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{path: path, timeout: timeout}, nil
+}
+
+// GetPort returns the port of the last successful run, or 0 before the first
+// one
+func (c *Client) GetPort() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.port, nil
+}
+
+// SetPort runs the command for the port. A run fails when the command exits
+// with a non-zero status or exceeds the timeout; the error then includes the
+// output.
+func (c *Client) SetPort(port int) error {
+	c.mu.Lock()
+	previous := c.port
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	env := []string{
+		"FORWARDARR_PORT=" + strconv.Itoa(port),
+		"FORWARDARR_PREVIOUS_PORT=" + strconv.Itoa(previous),
+	}
+	output := NewOutput(maxOutput)
+
+	start := time.Now()
+	err := Run(ctx, c.path, []string{strconv.Itoa(port), strconv.Itoa(previous)}, env, output, output)
+	duration := time.Since(start)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("command timed out after %s, output: %s", c.timeout, output)
+	}
+	if err != nil {
+		return fmt.Errorf("command failed: %w, output: %s", err, output)
+	}
+
+	c.mu.Lock()
+	c.port = port
+	c.mu.Unlock()
+	slog.Info("successfully ran port command", "port", port, "previous_port", previous, "duration", duration, "output", output.String())
+	return nil
+}
+
+// Ping checks that the command can still be run
+func (c *Client) Ping() error {
+	return c.PingOnce()
+}
+
+// PingOnce checks that the command can still be run, e.g. that a mounted
+// script was not removed
+func (c *Client) PingOnce() error {
+	if _, err := exec.LookPath(c.path); err != nil {
+		return fmt.Errorf("command not found: %w", err)
+	}
+	return nil
+}
+
+// Run runs the command at path directly, not through a shell, with args and
+// the environment of Forwardarr extended by env. It writes the output of the
+// command to stdout and stderr, and kills the command once ctx is done.
+func Run(ctx context.Context, path string, args, env []string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	return cmd.Run()
+}
+
+// Output keeps the first limit bytes written to it and discards the rest.
+// It is safe for concurrent use, since a command may write stdout and stderr
+// to the same Output at the same time.
+type Output struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+// NewOutput creates an Output that keeps limit bytes
+func NewOutput(limit int) *Output {
+	return &Output{limit: limit}
+}
+
+func (b *Output) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room := b.limit - len(b.buf)
+	if len(p) > room {
+		b.buf = append(b.buf, p[:max(room, 0)]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
+
+// String returns the kept output without surrounding whitespace, marked if
+// output was discarded
+func (b *Output) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	output := strings.TrimSpace(string(b.buf))
+	if b.truncated {
+		output += " [truncated]"
+	}
+	return output
+}
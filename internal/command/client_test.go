@@ -0,0 +1,94 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/command/commandtest"
+)
+
+func TestClient_SetPort(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "calls")
+	script := commandtest.WriteScript(t, `echo "$1 $2 $FORWARDARR_PORT $FORWARDARR_PREVIOUS_PORT" >> `+log+"\necho applied\n")
+
+	client, err := NewClient(script, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if port, _ := client.GetPort(); port != 0 {
+		t.Errorf("GetPort() before the first run = %d, want 0", port)
+	}
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if err := client.SetPort(7000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if port, _ := client.GetPort(); port != 7000 {
+		t.Errorf("GetPort() = %d, want 7000", port)
+	}
+
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "6000 0 6000 0\n7000 6000 7000 6000\n"; string(calls) != want {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestClient_SetPortFailure(t *testing.T) {
+	client, err := NewClient(commandtest.WriteScript(t, "echo 'firewall rejected' >&2\nexit 3\n"), Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	err = client.SetPort(6000)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "firewall rejected") {
+		t.Errorf("SetPort() error = %v, want exit status and output", err)
+	}
+	if port, _ := client.GetPort(); port != 0 {
+		t.Errorf("GetPort() after a failure = %d, want 0", port)
+	}
+}
+
+func TestClient_SetPortTimeout(t *testing.T) {
+	client, err := NewClient(commandtest.WriteScript(t, "echo started\nexec sleep 5\n"), Options{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	start := time.Now()
+	err = client.SetPort(6000)
+	if err == nil || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "started") {
+		t.Errorf("SetPort() error = %v, want timeout with output", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("SetPort() took %v, want the command killed", elapsed)
+	}
+}
+
+func TestNewClient_NotFound(t *testing.T) {
+	if _, err := NewClient(filepath.Join(t.TempDir(), "missing.sh"), Options{}); err == nil {
+		t.Error("NewClient() error = nil, want missing command")
+	}
+	if _, err := NewClient("", Options{}); err == nil {
+		t.Error("NewClient() error = nil, want no command")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	b := NewOutput(5)
+	if n, _ := b.Write([]byte("abc")); n != 3 {
+		t.Errorf("Write() = %d, want 3", n)
+	}
+	if n, _ := b.Write([]byte("defg")); n != 4 {
+		t.Errorf("Write() = %d, want 4", n)
+	}
+	if got := b.String(); got != "abcde [truncated]" {
+		t.Errorf("String() = %q", got)
+	}
+}
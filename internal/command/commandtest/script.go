@@ -0,0 +1,18 @@
+// Package commandtest provides helpers for tests that run commands.
+package commandtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// WriteScript writes an executable shell script and returns its path
+func WriteScript(t testing.TB, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "port.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
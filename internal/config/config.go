@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -8,48 +10,680 @@ import (
 )
 
 type Config struct {
-	GluetunPortFile   string
-	QbitAddr          string
-	QbitUser          string
-	QbitPass          string
-	StartupRetryDelay time.Duration
-	StartupTimeout    time.Duration
-	SyncInterval      time.Duration
-	MetricsPort       string
-	LogLevel          string
-	WebhookURL        string
-	WebhookEnabled    bool
-	WebhookTimeout    time.Duration
-	WebhookTemplate   string
-	WebhookEvents     []string
+	GluetunPortFile      string
+	QbitAddr             string
+	QbitUser             string
+	QbitPass             string
+	StartupRetryDelay    time.Duration
+	StartupTimeout       time.Duration
+	SyncInterval         time.Duration
+	MetricsPort          string
+	LogLevel             string
+	Webhooks             []WebhookConfig
+	WebhookEnabled       bool
+	WebhookMaxAttempts   int
+	WebhookRetryDelay    time.Duration
+	WebhookRetryMaxDelay time.Duration
+	// WebhookQueueFile persists failed deliveries for later retry when set
+	WebhookQueueFile string
+	// WebhookBreakerThreshold consecutive failures suspend a target for
+	// WebhookBreakerCooldown; 0 disables the circuit breaker
+	WebhookBreakerThreshold int
+	WebhookBreakerCooldown  time.Duration
+	// WebhookDedupWindow suppresses repeated notifications with the same event
+	// and ports; WebhookRateLimit caps notifications per target and minute.
+	// 0 disables either.
+	WebhookDedupWindow time.Duration
+	WebhookRateLimit   int
+	// WebhookBatchWindow coalesces notifications sent within the window into
+	// one summary; 0 disables batching
+	WebhookBatchWindow time.Duration
+	// WebhookWorkers deliver notifications in the background from a buffer
+	// holding up to WebhookBufferSize pending notifications
+	WebhookWorkers    int
+	WebhookBufferSize int
+	// WebhookProxy is the proxy URL for HTTP webhook targets; the standard
+	// proxy environment variables apply when empty
+	WebhookProxy string
+	// InstanceName and InstanceLabel identify this instance in notifications
+	InstanceName  string
+	InstanceLabel string
+	// VPNProvider, and the public IP read from VPNPublicIPFile or requested
+	// from VPNPublicIPURL, are added to port change notifications
+	VPNProvider     string
+	VPNPublicIPFile string
+	VPNPublicIPURL  string
+	// NotifyMessages and NotifyTitles override the texts of notifications,
+	// keyed by event name
+	NotifyMessages map[string]string
+	NotifyTitles   map[string]string
+	// APIKey, or the contents of APIKeyFile, is required by the status and
+	// control endpoints when set
+	APIKey     string
+	APIKeyFile string
+	// ServerTLSCertFile and ServerTLSKeyFile enable HTTPS on the HTTP server;
+	// ServerTLSClientCAFile then requires client certificates signed by it
+	ServerTLSCertFile     string
+	ServerTLSKeyFile      string
+	ServerTLSClientCAFile string
+	// ServerEnabled runs the HTTP server on ServerAddresses, which default to
+	// all interfaces on MetricsPort
+	ServerEnabled   bool
+	ServerAddresses []string
+	// ShutdownTimeout bounds how long the HTTP server waits for requests in
+	// progress on shutdown
+	ShutdownTimeout time.Duration
+	// SyncHistorySize sync attempts are kept for /api/v1/history, and stored
+	// in SyncHistoryFile when set
+	SyncHistorySize int
+	SyncHistoryFile string
+	// AccessLog logs the requests to the HTTP server, one in every
+	// AccessLogSample unless they fail
+	AccessLog       bool
+	AccessLogSample int
+	// APIRateLimit requests per minute and client, after an initial burst of
+	// APIRateBurst, are allowed on the endpoints that trigger actions; 0
+	// disables the limit
+	APIRateLimit int
+	APIRateBurst int
+	// CORSAllowedOrigins may call the HTTP endpoints from browsers, with
+	// CORSAllowedMethods; no origins disables CORS
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	// SyncStaleThreshold fails the health check when no sync succeeded
+	// within it; 0 disables the check
+	SyncStaleThreshold time.Duration
+	// QbitMaxAttempts is the number of attempts of a qBittorrent request;
+	// the delay between them starts at QbitRetryDelay and doubles up to
+	// QbitRetryMaxDelay
+	QbitMaxAttempts   int
+	QbitRetryDelay    time.Duration
+	QbitRetryMaxDelay time.Duration
+	// Reannounce selects the torrents announced to their trackers after a
+	// port change: none, all or active
+	Reannounce string
+	// QbitAuth is the qBittorrent authentication method: password, bypass
+	// for clients in whitelisted subnets, or apikey with QbitAPIKey or the
+	// contents of QbitAPIKeyFile
+	QbitAuth       string
+	QbitAPIKey     string
+	QbitAPIKeyFile string
+	// QbitTLSCAFile, QbitTLSCertFile and QbitTLSKeyFile add a trusted CA
+	// bundle and a client certificate to HTTPS connections to qBittorrent;
+	// QbitTLSInsecure disables certificate verification
+	QbitTLSCAFile   string
+	QbitTLSCertFile string
+	QbitTLSKeyFile  string
+	QbitTLSInsecure bool
+	// QbitEnforceStaticPort disables UPnP and the random port in
+	// qBittorrent whenever a port is applied
+	QbitEnforceStaticPort bool
+	// QbitTimeout bounds a request to qBittorrent and QbitDialTimeout its
+	// connection; idle connections are reused for QbitKeepAlive, and not at
+	// all when it is 0
+	QbitTimeout     time.Duration
+	QbitDialTimeout time.Duration
+	QbitKeepAlive   time.Duration
+	// QbitProxy is the proxy URL for requests to qBittorrent; the standard
+	// proxy environment variables apply when empty
+	QbitProxy string
+	// QbitNetworkInterface and QbitInterfaceAddress bind qBittorrent to the
+	// VPN interface, and are written along with every port when set
+	QbitNetworkInterface string
+	QbitInterfaceAddress string
+	// QbitUserFile and QbitPassFile hold the qBittorrent credentials, e.g.
+	// Docker secrets, and take precedence over QbitUser and QbitPass
+	QbitUserFile string
+	QbitPassFile string
+	// TorrentClient is the kind of client at QbitAddr: qbittorrent,
+	// transmission, rtorrent, aria2, slskd for Soulseek, generic for any
+	// HTTP API, or exec for a command
+	TorrentClient string
+	// TorrentClients are additional clients that receive the same port, read
+	// from TORRENT_CLIENT_1_*, TORRENT_CLIENT_2_*, ...
+	TorrentClients []TorrentClientConfig
+	// GenericMethod, the GenericBody template, GenericContentType and
+	// GenericHeaders describe the request the generic client sends for
+	// every port
+	GenericMethod      string
+	GenericBody        string
+	GenericContentType string
+	GenericHeaders     map[string]string
+	// ExecCommand is run by the exec client for every port, and killed after
+	// ExecTimeout
+	ExecCommand string
+	ExecTimeout time.Duration
+	// GluetunPortFileFormat is the layout of GluetunPortFile: auto, plain,
+	// list or json
+	GluetunPortFileFormat string
+	// GluetunControlURL replaces the port file with the control server of
+	// Gluetun, polled every GluetunControlPollInterval with GluetunControlAPIKey
+	// or the contents of GluetunControlAPIKeyFile
+	GluetunControlURL          string
+	GluetunControlAPIKey       string
+	GluetunControlAPIKeyFile   string
+	GluetunControlPollInterval time.Duration
+	// NATPMPGateway replaces the port file with a port mapped by Forwardarr
+	// itself on the NAT-PMP gateway of the VPN, e.g. 10.2.0.1 for ProtonVPN.
+	// NATPMPInternalPort is mapped for NATPMPLifetime and renewed before
+	// the mapping expires.
+	NATPMPGateway      string
+	NATPMPInternalPort int
+	NATPMPLifetime     time.Duration
+	// UPnPInternalPort replaces the port file with a port mapped on the
+	// router with UPnP IGD for UPnPLease. The router is discovered on the
+	// local network unless UPnPDescriptionURL points at its description.
+	UPnPInternalPort   int
+	UPnPLease          time.Duration
+	UPnPDescriptionURL string
+	// PIAHostname replaces the port file with a port forwarded by the PIA
+	// server with the hostname, whose API is reached at PIAGateway. Tokens
+	// are generated from PIAUser and PIAPassword (or PIAPasswordFile), or
+	// PIAToken is used as is. PIACAFile is PIA's CA certificate.
+	PIAHostname      string
+	PIAGateway       string
+	PIAUser          string
+	PIAPassword      string
+	PIAPasswordFile  string
+	PIAToken         string
+	PIACAFile        string
+	PIATLSSkipVerify bool
+	// PortSources lists the sources of the forwarded port in order of
+	// priority, e.g. gluetun_control,file,static; the first one that reports
+	// a port is used. StaticPort is the port of the static source.
+	PortSources []string
+	StaticPort  int
+	// SourceUnhealthyFailures is the number of failed reads in a row, and
+	// SourceStaleAfter how long without a port, after which a port source is
+	// reported unhealthy; 0 disables either check
+	SourceUnhealthyFailures int
+	SourceStaleAfter        time.Duration
+	// HTTPSourceURL replaces the port file with the port in the response to
+	// a GET request of the URL with HTTPSourceHeaders, polled every
+	// HTTPSourcePollInterval. HTTPSourceJSONPath or HTTPSourceRegex select
+	// the port in the response; without either, the body is the port.
+	HTTPSourceURL          string
+	HTTPSourceHeaders      map[string]string
+	HTTPSourceJSONPath     string
+	HTTPSourceRegex        string
+	HTTPSourcePollInterval time.Duration
+	// ExecSourceCommand replaces the port file with the port the command
+	// prints, run every ExecSourcePollInterval and killed after
+	// ExecSourceTimeout
+	ExecSourceCommand      string
+	ExecSourceTimeout      time.Duration
+	ExecSourcePollInterval time.Duration
+	// DockerSourceContainer replaces the port file with the port in another
+	// container, read through the Docker API at DockerHost every
+	// DockerSourcePollInterval: from DockerSourceEnv or DockerSourceLabel if
+	// set, otherwise from the output of DockerSourceCommand run inside it
+	DockerSourceContainer    string
+	DockerHost               string
+	DockerSourceEnv          string
+	DockerSourceLabel        string
+	DockerSourceCommand      []string
+	DockerSourcePollInterval time.Duration
+	// KubernetesSourceConfigMap or KubernetesSourcePod replace the port file
+	// with the port in the KubernetesSourceKey of the ConfigMap, or in the
+	// KubernetesSourceAnnotation of the pod, watched with the service account
+	// of the pod and read every KubernetesSourcePollInterval while the watch
+	// fails. The namespace defaults to the one of the pod.
+	KubernetesSourceConfigMap    string
+	KubernetesSourceKey          string
+	KubernetesSourcePod          string
+	KubernetesSourceAnnotation   string
+	KubernetesSourceNamespace    string
+	KubernetesSourcePollInterval time.Duration
+	// MQTTSourceURL replaces the port file with the port last published to
+	// MQTTSourceTopic on the broker, e.g. mqtt://homeassistant:1883, with
+	// MQTTSourceUsername and MQTTSourcePassword (or MQTTSourcePasswordFile)
+	MQTTSourceURL          string
+	MQTTSourceTopic        string
+	MQTTSourceUsername     string
+	MQTTSourcePassword     string
+	MQTTSourcePasswordFile string
+	MQTTSourceClientID     string
+
+	// Values maps the variables read by Load to their effective values,
+	// including defaults; unset variables without a default are left out
+	Values map[string]string
+}
+
+// TorrentClientConfig describes an additional torrent client. The fields
+// match the Qbit* fields and TorrentClient of Config, which describe the
+// first client; the retry and timeout settings apply to all clients.
+type TorrentClientConfig struct {
+	Type              string
+	Addr              string
+	User              string
+	Pass              string
+	UserFile          string
+	PassFile          string
+	Auth              string
+	APIKey            string
+	APIKeyFile        string
+	TLSCAFile         string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSInsecure       bool
+	EnforceStaticPort bool
+	Proxy             string
+	NetworkInterface  string
+	InterfaceAddress  string
+
+	GenericMethod      string
+	GenericBody        string
+	GenericContentType string
+	GenericHeaders     map[string]string
+	ExecCommand        string
+	ExecTimeout        time.Duration
+}
+
+// WebhookConfig describes a single webhook destination
+type WebhookConfig struct {
+	Name     string
+	URL      string
+	Template string
+	Timeout  time.Duration
+	Events   []string
+	Secret   string
+	Headers  map[string]string
+	// Method and ContentType override the request of HTTP targets; empty
+	// values keep the template defaults
+	Method      string
+	ContentType string
+	FormFields  map[string]string
+	// MinSeverity drops events below info, warning or error
+	MinSeverity string
+	// CustomTemplate is an inline Go template for the "custom" template;
+	// CustomTemplateFile takes precedence when set.
+	CustomTemplate      string
+	CustomTemplateFile  string
+	PushoverToken       string
+	PushoverUser        string
+	PushoverPriority    int
+	NtfyTopic           string
+	NtfyPriority        string
+	NtfyTags            []string
+	NtfyToken           string
+	MatrixRoomID        string
+	MatrixAccessToken   string
+	AppriseURLs         []string
+	AppriseTag          string
+	PagerDutyRoutingKey string
+	PagerDutySeverity   string
+	EmailTLS            string
+	EmailUsername       string
+	EmailPassword       string
+	EmailFrom           string
+	EmailTo             []string
+	EmailHTML           bool
+	MQTTTopic           string
+	MQTTQoS             int
+	MQTTRetain          bool
+	MQTTUsername        string
+	MQTTPassword        string
+	MQTTClientID        string
+	TLSCAFile           string
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSInsecure         bool
+	// BearerTokenFile and BasicAuthPasswordFile take precedence over the
+	// inline secrets when set
+	BearerToken           string
+	BearerTokenFile       string
+	BasicAuthUsername     string
+	BasicAuthPassword     string
+	BasicAuthPasswordFile string
+	DiscordColor          string
+	DiscordTitle          string
+	DiscordUsername       string
+	DiscordAvatarURL      string
+	DiscordMention        string
+	DiscordThreadID       string
+	DiscordThreadName     string
+	SlackChannel          string
+	SlackUsername         string
+	SlackIconEmoji        string
+	SlackAttachments      bool
+	GotifyToken           string
+	GotifyTokenMode       string
+	GotifyPriority        int
+	GotifyErrorPriority   int
+
+	// The *File fields name files holding the URL or a secret of the target,
+	// e.g. Docker or Kubernetes secrets. They take precedence over the inline
+	// values and are read whenever the notifier is created.
+	URLFile                 string
+	SecretFile              string
+	PushoverTokenFile       string
+	PushoverUserFile        string
+	NtfyTokenFile           string
+	MatrixAccessTokenFile   string
+	PagerDutyRoutingKeyFile string
+	EmailPasswordFile       string
+	MQTTPasswordFile        string
+	GotifyTokenFile         string
 }
 
+// defaultGenericBody is the request body of the generic client
+const defaultGenericBody = `{"port": {{.Port}}}`
+
 func Load() *Config {
-	webhookURL := getEnv("WEBHOOK_URL", "")
-	webhookEvents := getEnv("WEBHOOK_EVENTS", "port_changed")
-	return &Config{
-		GluetunPortFile:   getEnv("GLUETUN_PORT_FILE", "/tmp/gluetun/forwarded_port"),
-		QbitAddr:          getEnv("TORRENT_CLIENT_URL", "http://localhost:8080"),
-		QbitUser:          getEnv("TORRENT_CLIENT_USER", "admin"),
-		QbitPass:          getEnv("TORRENT_CLIENT_PASSWORD", "adminadmin"),
-		StartupRetryDelay: getDurationEnv("STARTUP_RETRY_DELAY", 5*time.Second),
-		StartupTimeout:    getDurationEnv("STARTUP_TIMEOUT", 120*time.Second),
-		SyncInterval:      getDurationEnv("SYNC_INTERVAL", 5*time.Minute),
-		MetricsPort:       getEnv("METRICS_PORT", "9090"),
-		LogLevel:          getEnv("LOG_LEVEL", "info"),
-		WebhookURL:        webhookURL,
-		WebhookEnabled:    webhookURL != "",
-		WebhookTimeout:    getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
-		WebhookTemplate:   getEnv("WEBHOOK_TEMPLATE", "json"),
-		WebhookEvents:     parseEvents(webhookEvents),
+	l := &loader{values: make(map[string]string)}
+	webhooks := l.loadWebhooks()
+	metricsPort := l.getEnv("METRICS_PORT", "9090")
+	syncInterval := l.getDurationEnv("SYNC_INTERVAL", 5*time.Minute)
+	cfg := &Config{
+		GluetunPortFile:         l.getEnv("GLUETUN_PORT_FILE", "/tmp/gluetun/forwarded_port"),
+		QbitAddr:                l.getEnv("TORRENT_CLIENT_URL", "http://localhost:8080"),
+		QbitUser:                l.getEnv("TORRENT_CLIENT_USER", "admin"),
+		QbitPass:                l.getEnv("TORRENT_CLIENT_PASSWORD", "adminadmin"),
+		StartupRetryDelay:       l.getDurationEnv("STARTUP_RETRY_DELAY", 5*time.Second),
+		StartupTimeout:          l.getDurationEnv("STARTUP_TIMEOUT", 120*time.Second),
+		SyncInterval:            syncInterval,
+		MetricsPort:             metricsPort,
+		LogLevel:                l.getEnv("LOG_LEVEL", "info"),
+		Webhooks:                webhooks,
+		WebhookEnabled:          len(webhooks) > 0,
+		WebhookMaxAttempts:      l.getIntEnv("WEBHOOK_MAX_ATTEMPTS", 3),
+		WebhookRetryDelay:       l.getDurationEnv("WEBHOOK_RETRY_DELAY", 1*time.Second),
+		WebhookRetryMaxDelay:    l.getDurationEnv("WEBHOOK_RETRY_MAX_DELAY", 30*time.Second),
+		WebhookQueueFile:        l.getEnv("WEBHOOK_QUEUE_FILE", ""),
+		WebhookBreakerThreshold: l.getIntEnv("WEBHOOK_BREAKER_THRESHOLD", 5),
+		WebhookBreakerCooldown:  l.getDurationEnv("WEBHOOK_BREAKER_COOLDOWN", 5*time.Minute),
+		WebhookDedupWindow:      l.getDurationEnv("WEBHOOK_DEDUP_WINDOW", 0),
+		WebhookRateLimit:        l.getIntEnv("WEBHOOK_RATE_LIMIT", 0),
+		WebhookBatchWindow:      l.getDurationEnv("WEBHOOK_BATCH_WINDOW", 0),
+		WebhookWorkers:          l.getIntEnv("WEBHOOK_WORKERS", 1),
+		WebhookBufferSize:       l.getIntEnv("WEBHOOK_BUFFER_SIZE", 100),
+		WebhookProxy:            l.getEnv("WEBHOOK_PROXY", ""),
+		InstanceName:            l.getEnv("INSTANCE_NAME", ""),
+		InstanceLabel:           l.getEnv("INSTANCE_LABEL", ""),
+		VPNProvider:             l.getEnv("VPN_PROVIDER", ""),
+		VPNPublicIPFile:         l.getEnv("VPN_PUBLIC_IP_FILE", ""),
+		VPNPublicIPURL:          l.getEnv("VPN_PUBLIC_IP_URL", ""),
+		NotifyMessages:          l.getPrefixedEnv("NOTIFY_MESSAGE_"),
+		NotifyTitles:            l.getPrefixedEnv("NOTIFY_TITLE_"),
+		APIKey:                  l.getEnv("API_KEY", ""),
+		APIKeyFile:              l.getEnv("API_KEY_FILE", ""),
+		ServerTLSCertFile:       l.getEnv("SERVER_TLS_CERT_FILE", ""),
+		ServerTLSKeyFile:        l.getEnv("SERVER_TLS_KEY_FILE", ""),
+		ServerTLSClientCAFile:   l.getEnv("SERVER_TLS_CLIENT_CA_FILE", ""),
+		ShutdownTimeout:         l.getDurationEnv("SHUTDOWN_TIMEOUT", 10*time.Second),
+		SyncHistorySize:         l.getIntEnv("SYNC_HISTORY_SIZE", 1000),
+		SyncHistoryFile:         l.getEnv("SYNC_HISTORY_FILE", ""),
+		AccessLog:               l.getBoolEnv("ACCESS_LOG", false),
+		AccessLogSample:         l.getIntEnv("ACCESS_LOG_SAMPLE", 1),
+		APIRateLimit:            l.getIntEnv("API_RATE_LIMIT", 10),
+		APIRateBurst:            l.getIntEnv("API_RATE_BURST", 5),
+		CORSAllowedOrigins:      parseList(l.getEnv("CORS_ALLOWED_ORIGINS", "")),
+		CORSAllowedMethods:      parseList(l.getEnv("CORS_ALLOWED_METHODS", "GET,HEAD,POST")),
+		SyncStaleThreshold:      l.getDurationEnv("SYNC_STALE_THRESHOLD", 3*syncInterval),
+		QbitMaxAttempts:         l.getIntEnv("TORRENT_CLIENT_MAX_ATTEMPTS", 3),
+		QbitRetryDelay:          l.getDurationEnv("TORRENT_CLIENT_RETRY_DELAY", 2*time.Second),
+		QbitRetryMaxDelay:       l.getDurationEnv("TORRENT_CLIENT_RETRY_MAX_DELAY", 30*time.Second),
+		Reannounce:              l.getEnv("REANNOUNCE_ON_PORT_CHANGE", "none"),
+		QbitAuth:                l.getEnv("TORRENT_CLIENT_AUTH", "password"),
+		QbitAPIKey:              l.getEnv("TORRENT_CLIENT_API_KEY", ""),
+		QbitAPIKeyFile:          l.getEnv("TORRENT_CLIENT_API_KEY_FILE", ""),
+		QbitTLSCAFile:           l.getEnv("TORRENT_CLIENT_TLS_CA_FILE", ""),
+		QbitTLSCertFile:         l.getEnv("TORRENT_CLIENT_TLS_CERT_FILE", ""),
+		QbitTLSKeyFile:          l.getEnv("TORRENT_CLIENT_TLS_KEY_FILE", ""),
+		QbitTLSInsecure:         l.getBoolEnv("TORRENT_CLIENT_TLS_INSECURE_SKIP_VERIFY", false),
+		QbitEnforceStaticPort:   l.getBoolEnv("TORRENT_CLIENT_ENFORCE_STATIC_PORT", false),
+		QbitTimeout:             l.getDurationEnv("TORRENT_CLIENT_TIMEOUT", 10*time.Second),
+		QbitDialTimeout:         l.getDurationEnv("TORRENT_CLIENT_DIAL_TIMEOUT", 5*time.Second),
+		QbitKeepAlive:           l.getDurationEnv("TORRENT_CLIENT_KEEP_ALIVE", 90*time.Second),
+		QbitProxy:               l.getEnv("TORRENT_CLIENT_PROXY", ""),
+		QbitNetworkInterface:    l.getEnv("TORRENT_CLIENT_NETWORK_INTERFACE", ""),
+		QbitInterfaceAddress:    l.getEnv("TORRENT_CLIENT_INTERFACE_ADDRESS", ""),
+		QbitUserFile:            l.getEnv("TORRENT_CLIENT_USER_FILE", ""),
+		QbitPassFile:            l.getEnv("TORRENT_CLIENT_PASSWORD_FILE", ""),
+		TorrentClient:           l.getEnv("TORRENT_CLIENT_TYPE", "qbittorrent"),
+		TorrentClients:          l.loadTorrentClients(),
+		GenericMethod:           l.getEnv("TORRENT_CLIENT_GENERIC_METHOD", "POST"),
+		GenericBody:             l.getEnv("TORRENT_CLIENT_GENERIC_BODY", defaultGenericBody),
+		GenericContentType:      l.getEnv("TORRENT_CLIENT_GENERIC_CONTENT_TYPE", "application/json"),
+		GenericHeaders:          parseHeaders(l.getEnv("TORRENT_CLIENT_GENERIC_HEADERS", "")),
+		ExecCommand:             l.getEnv("TORRENT_CLIENT_EXEC_COMMAND", ""),
+		ExecTimeout:             l.getDurationEnv("TORRENT_CLIENT_EXEC_TIMEOUT", 30*time.Second),
+		ServerEnabled:           l.getBoolEnv("SERVER_ENABLED", true),
+		ServerAddresses:         parseAddresses(l.getEnv("SERVER_ADDRESSES", ""), metricsPort),
+
+		GluetunPortFileFormat: l.getEnv("GLUETUN_PORT_FILE_FORMAT", "auto"),
+
+		GluetunControlURL:          l.getEnv("GLUETUN_CONTROL_URL", ""),
+		GluetunControlAPIKey:       l.getEnv("GLUETUN_CONTROL_API_KEY", ""),
+		GluetunControlAPIKeyFile:   l.getEnv("GLUETUN_CONTROL_API_KEY_FILE", ""),
+		GluetunControlPollInterval: l.getDurationEnv("GLUETUN_CONTROL_POLL_INTERVAL", 10*time.Second),
+
+		NATPMPGateway:      l.getEnv("NATPMP_GATEWAY", ""),
+		NATPMPInternalPort: l.getIntEnv("NATPMP_INTERNAL_PORT", 1),
+		NATPMPLifetime:     l.getDurationEnv("NATPMP_LIFETIME", 60*time.Second),
+
+		UPnPInternalPort:   l.getIntEnv("UPNP_INTERNAL_PORT", 0),
+		UPnPLease:          l.getDurationEnv("UPNP_LEASE", time.Hour),
+		UPnPDescriptionURL: l.getEnv("UPNP_DESCRIPTION_URL", ""),
+
+		PIAHostname:      l.getEnv("PIA_HOSTNAME", ""),
+		PIAGateway:       l.getEnv("PIA_GATEWAY", ""),
+		PIAUser:          l.getEnv("PIA_USER", ""),
+		PIAPassword:      l.getEnv("PIA_PASSWORD", ""),
+		PIAPasswordFile:  l.getEnv("PIA_PASSWORD_FILE", ""),
+		PIAToken:         l.getEnv("PIA_TOKEN", ""),
+		PIACAFile:        l.getEnv("PIA_CA_FILE", ""),
+		PIATLSSkipVerify: l.getBoolEnv("PIA_TLS_SKIP_VERIFY", false),
+
+		PortSources: parseList(l.getEnv("PORT_SOURCES", "")),
+		StaticPort:  l.getIntEnv("STATIC_PORT", 0),
+
+		SourceUnhealthyFailures: l.getIntEnv("SOURCE_UNHEALTHY_FAILURES", 3),
+		SourceStaleAfter:        l.getDurationEnv("SOURCE_STALE_AFTER", 300*time.Second),
+
+		HTTPSourceURL:          l.getEnv("HTTP_SOURCE_URL", ""),
+		HTTPSourceHeaders:      parseHeaders(l.getEnv("HTTP_SOURCE_HEADERS", "")),
+		HTTPSourceJSONPath:     l.getEnv("HTTP_SOURCE_JSON_PATH", ""),
+		HTTPSourceRegex:        l.getEnv("HTTP_SOURCE_REGEX", ""),
+		HTTPSourcePollInterval: l.getDurationEnv("HTTP_SOURCE_POLL_INTERVAL", 10*time.Second),
+
+		ExecSourceCommand:      l.getEnv("EXEC_SOURCE_COMMAND", ""),
+		ExecSourceTimeout:      l.getDurationEnv("EXEC_SOURCE_TIMEOUT", 10*time.Second),
+		ExecSourcePollInterval: l.getDurationEnv("EXEC_SOURCE_POLL_INTERVAL", 10*time.Second),
+
+		DockerSourceContainer:    l.getEnv("DOCKER_SOURCE_CONTAINER", ""),
+		DockerHost:               l.getEnv("DOCKER_HOST", "unix:///var/run/docker.sock"),
+		DockerSourceEnv:          l.getEnv("DOCKER_SOURCE_ENV", ""),
+		DockerSourceLabel:        l.getEnv("DOCKER_SOURCE_LABEL", ""),
+		DockerSourceCommand:      strings.Fields(l.getEnv("DOCKER_SOURCE_COMMAND", "cat /tmp/gluetun/forwarded_port")),
+		DockerSourcePollInterval: l.getDurationEnv("DOCKER_SOURCE_POLL_INTERVAL", 10*time.Second),
+
+		KubernetesSourceConfigMap:    l.getEnv("KUBERNETES_SOURCE_CONFIGMAP", ""),
+		KubernetesSourceKey:          l.getEnv("KUBERNETES_SOURCE_KEY", "port"),
+		KubernetesSourcePod:          l.getEnv("KUBERNETES_SOURCE_POD", ""),
+		KubernetesSourceAnnotation:   l.getEnv("KUBERNETES_SOURCE_ANNOTATION", ""),
+		KubernetesSourceNamespace:    l.getEnv("KUBERNETES_SOURCE_NAMESPACE", ""),
+		KubernetesSourcePollInterval: l.getDurationEnv("KUBERNETES_SOURCE_POLL_INTERVAL", 10*time.Second),
+
+		MQTTSourceURL:          l.getEnv("MQTT_SOURCE_URL", ""),
+		MQTTSourceTopic:        l.getEnv("MQTT_SOURCE_TOPIC", "forwardarr/port"),
+		MQTTSourceUsername:     l.getEnv("MQTT_SOURCE_USERNAME", ""),
+		MQTTSourcePassword:     l.getEnv("MQTT_SOURCE_PASSWORD", ""),
+		MQTTSourcePasswordFile: l.getEnv("MQTT_SOURCE_PASSWORD_FILE", ""),
+		MQTTSourceClientID:     l.getEnv("MQTT_SOURCE_CLIENT_ID", "forwardarr-source"),
+	}
+	l.values["SERVER_ADDRESSES"] = strings.Join(cfg.ServerAddresses, ",")
+	cfg.Values = l.values
+	return cfg
+}
+
+// loadTorrentClients reads the additional torrent clients from
+// TORRENT_CLIENT_1_*, TORRENT_CLIENT_2_*, ... until a number without a URL
+// or, for exec clients, a command. Their settings default like the
+// unnumbered ones.
+func (l *loader) loadTorrentClients() []TorrentClientConfig {
+	var clients []TorrentClientConfig
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("TORRENT_CLIENT_%d_", i)
+		addr := l.getEnv(prefix+"URL", "")
+		if addr == "" && getEnv(prefix+"EXEC_COMMAND", "") == "" {
+			break
+		}
+		clients = append(clients, TorrentClientConfig{
+			Type:              l.getEnv(prefix+"TYPE", "qbittorrent"),
+			Addr:              addr,
+			User:              l.getEnv(prefix+"USER", "admin"),
+			Pass:              l.getEnv(prefix+"PASSWORD", "adminadmin"),
+			UserFile:          l.getEnv(prefix+"USER_FILE", ""),
+			PassFile:          l.getEnv(prefix+"PASSWORD_FILE", ""),
+			Auth:              l.getEnv(prefix+"AUTH", "password"),
+			APIKey:            l.getEnv(prefix+"API_KEY", ""),
+			APIKeyFile:        l.getEnv(prefix+"API_KEY_FILE", ""),
+			TLSCAFile:         l.getEnv(prefix+"TLS_CA_FILE", ""),
+			TLSCertFile:       l.getEnv(prefix+"TLS_CERT_FILE", ""),
+			TLSKeyFile:        l.getEnv(prefix+"TLS_KEY_FILE", ""),
+			TLSInsecure:       l.getBoolEnv(prefix+"TLS_INSECURE_SKIP_VERIFY", false),
+			EnforceStaticPort: l.getBoolEnv(prefix+"ENFORCE_STATIC_PORT", false),
+			Proxy:             l.getEnv(prefix+"PROXY", ""),
+			NetworkInterface:  l.getEnv(prefix+"NETWORK_INTERFACE", ""),
+			InterfaceAddress:  l.getEnv(prefix+"INTERFACE_ADDRESS", ""),
+
+			GenericMethod:      l.getEnv(prefix+"GENERIC_METHOD", "POST"),
+			GenericBody:        l.getEnv(prefix+"GENERIC_BODY", defaultGenericBody),
+			GenericContentType: l.getEnv(prefix+"GENERIC_CONTENT_TYPE", "application/json"),
+			GenericHeaders:     parseHeaders(l.getEnv(prefix+"GENERIC_HEADERS", "")),
+			ExecCommand:        l.getEnv(prefix+"EXEC_COMMAND", ""),
+			ExecTimeout:        l.getDurationEnv(prefix+"EXEC_TIMEOUT", 30*time.Second),
+		})
 	}
+	return clients
+}
+
+// loadWebhooks reads the webhook targets from the environment. The unnumbered
+// WEBHOOK_* variables configure the first target; additional targets use
+// WEBHOOK_1_*, WEBHOOK_2_*, ... and are read until a number without a URL
+// or URL file.
+func (l *loader) loadWebhooks() []WebhookConfig {
+	var webhooks []WebhookConfig
+	if webhook, ok := l.loadWebhook("WEBHOOK_", "webhook"); ok {
+		webhooks = append(webhooks, webhook)
+	}
+	for i := 1; ; i++ {
+		webhook, ok := l.loadWebhook(fmt.Sprintf("WEBHOOK_%d_", i), fmt.Sprintf("webhook_%d", i))
+		if !ok {
+			break
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+func (l *loader) loadWebhook(prefix, defaultName string) (WebhookConfig, bool) {
+	url := l.getEnv(prefix+"URL", "")
+	urlFile := l.getEnv(prefix+"URL_FILE", "")
+	if url == "" && urlFile == "" {
+		return WebhookConfig{}, false
+	}
+
+	// A minimum severity without an event list selects from all events
+	minSeverity := l.getEnv(prefix+"MIN_SEVERITY", "")
+	events := parseEvents(l.getEnv(prefix+"EVENTS", "port_changed"))
+	if minSeverity != "" && getEnv(prefix+"EVENTS", "") == "" {
+		events = nil
+	}
+
+	return WebhookConfig{
+		Name:     l.getEnv(prefix+"NAME", defaultName),
+		URL:      url,
+		Template: l.getEnv(prefix+"TEMPLATE", "json"),
+		Timeout:  l.getDurationEnv(prefix+"TIMEOUT", 10*time.Second),
+		Events:   events,
+		Secret:   l.getEnv(prefix+"SECRET", ""),
+		Headers:  parseHeaders(l.getEnv(prefix+"HEADERS", "")),
+
+		Method:      l.getEnv(prefix+"METHOD", ""),
+		ContentType: l.getEnv(prefix+"CONTENT_TYPE", ""),
+		FormFields:  parseFormFields(l.getEnv(prefix+"FORM_FIELDS", "")),
+		MinSeverity: minSeverity,
+
+		CustomTemplate:        l.getEnv(prefix+"CUSTOM_TEMPLATE", ""),
+		CustomTemplateFile:    l.getEnv(prefix+"CUSTOM_TEMPLATE_FILE", ""),
+		PushoverToken:         l.getEnv(prefix+"PUSHOVER_TOKEN", ""),
+		PushoverUser:          l.getEnv(prefix+"PUSHOVER_USER", ""),
+		PushoverPriority:      l.getIntEnv(prefix+"PUSHOVER_PRIORITY", 0),
+		NtfyTopic:             l.getEnv(prefix+"NTFY_TOPIC", ""),
+		NtfyPriority:          l.getEnv(prefix+"NTFY_PRIORITY", ""),
+		NtfyTags:              parseList(l.getEnv(prefix+"NTFY_TAGS", "")),
+		NtfyToken:             l.getEnv(prefix+"NTFY_TOKEN", ""),
+		MatrixRoomID:          l.getEnv(prefix+"MATRIX_ROOM_ID", ""),
+		MatrixAccessToken:     l.getEnv(prefix+"MATRIX_ACCESS_TOKEN", ""),
+		AppriseURLs:           parseList(l.getEnv(prefix+"APPRISE_URLS", "")),
+		AppriseTag:            l.getEnv(prefix+"APPRISE_TAG", ""),
+		PagerDutyRoutingKey:   l.getEnv(prefix+"PAGERDUTY_ROUTING_KEY", ""),
+		PagerDutySeverity:     l.getEnv(prefix+"PAGERDUTY_SEVERITY", ""),
+		EmailTLS:              l.getEnv(prefix+"EMAIL_TLS", ""),
+		EmailUsername:         l.getEnv(prefix+"EMAIL_USERNAME", ""),
+		EmailPassword:         l.getEnv(prefix+"EMAIL_PASSWORD", ""),
+		EmailFrom:             l.getEnv(prefix+"EMAIL_FROM", ""),
+		EmailTo:               parseList(l.getEnv(prefix+"EMAIL_TO", "")),
+		EmailHTML:             l.getBoolEnv(prefix+"EMAIL_HTML", false),
+		MQTTTopic:             l.getEnv(prefix+"MQTT_TOPIC", ""),
+		MQTTQoS:               l.getIntEnv(prefix+"MQTT_QOS", 0),
+		MQTTRetain:            l.getBoolEnv(prefix+"MQTT_RETAIN", false),
+		MQTTUsername:          l.getEnv(prefix+"MQTT_USERNAME", ""),
+		MQTTPassword:          l.getEnv(prefix+"MQTT_PASSWORD", ""),
+		MQTTClientID:          l.getEnv(prefix+"MQTT_CLIENT_ID", ""),
+		TLSCAFile:             l.getEnv(prefix+"TLS_CA_FILE", ""),
+		TLSCertFile:           l.getEnv(prefix+"TLS_CERT_FILE", ""),
+		TLSKeyFile:            l.getEnv(prefix+"TLS_KEY_FILE", ""),
+		TLSInsecure:           l.getBoolEnv(prefix+"TLS_INSECURE_SKIP_VERIFY", false),
+		BearerToken:           l.getEnv(prefix+"BEARER_TOKEN", ""),
+		BearerTokenFile:       l.getEnv(prefix+"BEARER_TOKEN_FILE", ""),
+		BasicAuthUsername:     l.getEnv(prefix+"BASIC_AUTH_USERNAME", ""),
+		BasicAuthPassword:     l.getEnv(prefix+"BASIC_AUTH_PASSWORD", ""),
+		BasicAuthPasswordFile: l.getEnv(prefix+"BASIC_AUTH_PASSWORD_FILE", ""),
+		DiscordColor:          l.getEnv(prefix+"DISCORD_COLOR", ""),
+		DiscordTitle:          l.getEnv(prefix+"DISCORD_TITLE", ""),
+		DiscordUsername:       l.getEnv(prefix+"DISCORD_USERNAME", ""),
+		DiscordAvatarURL:      l.getEnv(prefix+"DISCORD_AVATAR_URL", ""),
+		DiscordMention:        l.getEnv(prefix+"DISCORD_MENTION", ""),
+		DiscordThreadID:       l.getEnv(prefix+"DISCORD_THREAD_ID", ""),
+		DiscordThreadName:     l.getEnv(prefix+"DISCORD_THREAD_NAME", ""),
+		SlackChannel:          l.getEnv(prefix+"SLACK_CHANNEL", ""),
+		SlackUsername:         l.getEnv(prefix+"SLACK_USERNAME", ""),
+		SlackIconEmoji:        l.getEnv(prefix+"SLACK_ICON_EMOJI", ""),
+		SlackAttachments:      l.getBoolEnv(prefix+"SLACK_ATTACHMENTS", false),
+		GotifyToken:           l.getEnv(prefix+"GOTIFY_TOKEN", ""),
+		GotifyTokenMode:       l.getEnv(prefix+"GOTIFY_TOKEN_MODE", ""),
+		GotifyPriority:        l.getIntEnv(prefix+"GOTIFY_PRIORITY", 0),
+		GotifyErrorPriority:   l.getIntEnv(prefix+"GOTIFY_ERROR_PRIORITY", 0),
+
+		URLFile:                 urlFile,
+		SecretFile:              l.getEnv(prefix+"SECRET_FILE", ""),
+		PushoverTokenFile:       l.getEnv(prefix+"PUSHOVER_TOKEN_FILE", ""),
+		PushoverUserFile:        l.getEnv(prefix+"PUSHOVER_USER_FILE", ""),
+		NtfyTokenFile:           l.getEnv(prefix+"NTFY_TOKEN_FILE", ""),
+		MatrixAccessTokenFile:   l.getEnv(prefix+"MATRIX_ACCESS_TOKEN_FILE", ""),
+		PagerDutyRoutingKeyFile: l.getEnv(prefix+"PAGERDUTY_ROUTING_KEY_FILE", ""),
+		EmailPasswordFile:       l.getEnv(prefix+"EMAIL_PASSWORD_FILE", ""),
+		MQTTPasswordFile:        l.getEnv(prefix+"MQTT_PASSWORD_FILE", ""),
+		GotifyTokenFile:         l.getEnv(prefix+"GOTIFY_TOKEN_FILE", ""),
+	}, true
 }
 
 func parseEvents(events string) []string {
 	if events == "" {
 		return []string{"port_changed"}
 	}
-	parts := strings.Split(events, ",")
+	return parseList(events)
+}
+
+// parseList splits a comma-separated list, dropping empty entries
+func parseList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	parts := strings.Split(list, ",")
 	result := make([]string, 0, len(parts))
 	for _, part := range parts {
 		if trimmed := strings.TrimSpace(part); trimmed != "" {
@@ -59,6 +693,73 @@ func parseEvents(events string) []string {
 	return result
 }
 
+// parseAddresses parses a comma-separated list of listen addresses. Entries
+// without a port, such as 127.0.0.1 or [::1], listen on the default port; an
+// empty list listens on all interfaces.
+func parseAddresses(list, defaultPort string) []string {
+	entries := parseList(list)
+	if len(entries) == 0 {
+		return []string{net.JoinHostPort("", defaultPort)}
+	}
+	addresses := make([]string, len(entries))
+	for i, entry := range entries {
+		if _, _, err := net.SplitHostPort(entry); err == nil {
+			addresses[i] = entry
+			continue
+		}
+		host := strings.TrimSuffix(strings.TrimPrefix(entry, "["), "]")
+		addresses[i] = net.JoinHostPort(host, defaultPort)
+	}
+	return addresses
+}
+
+// parseHeaders parses a comma-separated list of "Name: value" pairs. Entries
+// without a colon or with an empty name are ignored.
+func parseHeaders(headers string) map[string]string {
+	return parsePairs(headers, ":")
+}
+
+// parseFormFields parses a comma-separated list of "key=field" pairs mapping
+// form keys to payload fields
+func parseFormFields(fields string) map[string]string {
+	return parsePairs(fields, "=")
+}
+
+// parsePairs parses a comma-separated list of name/value pairs split at sep
+func parsePairs(list, sep string) map[string]string {
+	if list == "" {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, part := range strings.Split(list, ",") {
+		name, value, ok := strings.Cut(part, sep)
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		result[name] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// getPrefixedEnv collects the non-empty variables whose name starts with
+// prefix, keyed by the rest of the name in lower case
+func getPrefixedEnv(prefix string) map[string]string {
+	var result map[string]string
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" || value == "" {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[strings.ToLower(name)] = value
+	}
+	return result
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -74,3 +775,21 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
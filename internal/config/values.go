@@ -0,0 +1,104 @@
+package config
+
+import (
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets in Redacted
+const redacted = "[redacted]"
+
+// loader reads variables like the get*Env functions and records their
+// effective values for Config.Values
+type loader struct {
+	values map[string]string
+}
+
+func (l *loader) record(key, value string) {
+	if value != "" {
+		l.values[key] = value
+	}
+}
+
+func (l *loader) getEnv(key, defaultValue string) string {
+	value := getEnv(key, defaultValue)
+	l.record(key, value)
+	return value
+}
+
+func (l *loader) getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := getDurationEnv(key, defaultValue)
+	l.record(key, strconv.Itoa(int(value/time.Second)))
+	return value
+}
+
+func (l *loader) getIntEnv(key string, defaultValue int) int {
+	value := getIntEnv(key, defaultValue)
+	l.record(key, strconv.Itoa(value))
+	return value
+}
+
+func (l *loader) getBoolEnv(key string, defaultValue bool) bool {
+	value := getBoolEnv(key, defaultValue)
+	l.record(key, strconv.FormatBool(value))
+	return value
+}
+
+func (l *loader) getPrefixedEnv(prefix string) map[string]string {
+	values := getPrefixedEnv(prefix)
+	for name, value := range values {
+		l.record(prefix+strings.ToUpper(name), value)
+	}
+	return values
+}
+
+// Redacted returns Values with secrets hidden, for display. Passwords, tokens
+// and keys are replaced entirely; URLs lose their password, and webhook URLs
+// also their path and query, which often embed a token.
+func (c *Config) Redacted() map[string]string {
+	values := maps.Clone(c.Values)
+	for key, value := range values {
+		switch {
+		case isSecretVariable(key):
+			values[key] = redacted
+		case strings.HasPrefix(key, "WEBHOOK_") && (strings.HasSuffix(key, "_URL") || strings.HasSuffix(key, "_URLS")):
+			values[key] = redactURLs(value, true)
+		case strings.HasSuffix(key, "_URL") || strings.HasSuffix(key, "_PROXY"):
+			values[key] = redactURLs(value, false)
+		}
+	}
+	return values
+}
+
+// isSecretVariable reports whether the variable holds a secret. Headers are
+// included since they usually carry credentials.
+func isSecretVariable(key string) bool {
+	for _, suffix := range []string{"PASSWORD", "TOKEN", "SECRET", "API_KEY", "ROUTING_KEY", "PUSHOVER_USER", "HEADERS"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURLs redacts a comma-separated list of URLs. Values that do not
+// parse as URLs with a host are redacted entirely.
+func redactURLs(list string, hidePath bool) string {
+	parts := strings.Split(list, ",")
+	for i, part := range parts {
+		u, err := url.Parse(strings.TrimSpace(part))
+		if err != nil || u.Host == "" {
+			parts[i] = redacted
+			continue
+		}
+		if hidePath && (u.Path != "" && u.Path != "/" || u.RawQuery != "") {
+			u.Path, u.RawPath, u.RawQuery = "/"+redacted, "/"+redacted, ""
+		}
+		u.Fragment = ""
+		parts[i] = u.Redacted()
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,233 @@
+// Package docker is a minimal client of the Docker Engine API, enough to
+// read the forwarded port from another container without a shared volume.
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultHost is the socket of the Docker daemon when DOCKER_HOST is unset
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// requestTimeout bounds a request to the Docker daemon, including the run of
+// an exec
+const requestTimeout = 10 * time.Second
+
+// maxResponseSize bounds the responses read from the daemon
+const maxResponseSize = 1 << 20
+
+// Client talks to the Docker daemon over its unix socket or plain TCP, e.g.
+// through a socket proxy. TLS is not supported.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient creates a client for the daemon at host, in the format of
+// DOCKER_HOST: unix:///var/run/docker.sock or tcp://docker-proxy:2375
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = DefaultHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker host %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid Docker host %q: no socket path", host)
+		}
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		// The host of the URL is ignored by the dialer
+		return &Client{baseURL: "http://docker", client: &http.Client{Transport: transport, Timeout: requestTimeout}}, nil
+	case "tcp", "http":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid Docker host %q: no address", host)
+		}
+		return &Client{baseURL: "http://" + u.Host, client: &http.Client{Timeout: requestTimeout}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Docker host %q, expected unix:// or tcp://", host)
+	}
+}
+
+// Container is the part of a container inspection the source reads
+type Container struct {
+	Name   string
+	Env    []string
+	Labels map[string]string
+}
+
+// StatusError is a response of the daemon with an error status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Docker API returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("Docker API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// Inspect returns the configuration of the container with the name or ID
+func (c *Client) Inspect(container string) (Container, error) {
+	var body struct {
+		Name   string `json:"Name"`
+		Config struct {
+			Env    []string          `json:"Env"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := c.do(http.MethodGet, "/containers/"+url.PathEscape(container)+"/json", nil, &body); err != nil {
+		return Container{}, err
+	}
+	return Container{
+		Name:   strings.TrimPrefix(body.Name, "/"),
+		Env:    body.Config.Env,
+		Labels: body.Config.Labels,
+	}, nil
+}
+
+// ExitError is a command run with Exec that exited with a non-zero status
+type ExitError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("command exited with status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("command exited with status %d: %s", e.ExitCode, e.Stderr)
+}
+
+// Exec runs the command in the container and returns its standard output.
+// A non-zero exit status is an *ExitError with the standard error.
+func (c *Client) Exec(container string, command []string) (string, error) {
+	if len(command) == 0 {
+		return "", errors.New("no command to run")
+	}
+	create := map[string]any{
+		"Cmd":          command,
+		"AttachStdout": true,
+		"AttachStderr": true,
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.do(http.MethodPost, "/containers/"+url.PathEscape(container)+"/exec", create, &created); err != nil {
+		return "", err
+	}
+
+	stream, err := c.request(http.MethodPost, "/exec/"+created.ID+"/start", map[string]any{"Detach": false, "Tty": false})
+	if err != nil {
+		return "", err
+	}
+	stdout, stderr, err := demultiplex(stream)
+	_ = stream.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read command output: %w", err)
+	}
+
+	var inspected struct {
+		Running  bool `json:"Running"`
+		ExitCode int  `json:"ExitCode"`
+	}
+	if err := c.do(http.MethodGet, "/exec/"+created.ID+"/json", nil, &inspected); err != nil {
+		return "", err
+	}
+	if inspected.ExitCode != 0 {
+		return "", &ExitError{ExitCode: inspected.ExitCode, Stderr: strings.TrimSpace(stderr)}
+	}
+	return stdout, nil
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(method, path string, in, out any) error {
+	body, err := c.request(method, path, in)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+	if err := json.NewDecoder(io.LimitReader(body, maxResponseSize)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Docker API response: %w", err)
+	}
+	return nil
+}
+
+// request sends a request with in as JSON body and returns the body of a
+// successful response, which the caller closes
+func (c *Client) request(method, path string, in any) (io.ReadCloser, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker API request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Docker API: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer func() { _ = resp.Body.Close() }()
+		var message struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&message)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: message.Message}
+	}
+	return resp.Body, nil
+}
+
+// demultiplex splits the output stream of an exec without TTY into stdout
+// and stderr. Each frame starts with a header of the stream type (1 for
+// stdout, 2 for stderr), three zero bytes and the big-endian frame size.
+func demultiplex(stream io.Reader) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	stream = io.LimitReader(stream, maxResponseSize)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(stream, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return stdout.String(), stderr.String(), nil
+			}
+			return "", "", err
+		}
+		out := &stdout
+		if header[0] == 2 {
+			out = &stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(out, stream, size); err != nil {
+			return "", "", err
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// frame encodes output of an exec stream
+func frame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+// fakeDaemon answers the requests of the client for the container gluetun.
+// The exec prints stdout and stderr and exits with exitCode.
+type fakeDaemon struct {
+	stdout, stderr string
+	exitCode       int
+	command        []string
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/gluetun/json":
+		_, _ = w.Write([]byte(`{"Name": "/gluetun", "Config": {"Env": ["PATH=/bin", "VPN_PORT=51413"], "Labels": {"vpn.port": "51414"}}}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/gluetun/exec":
+		var body struct {
+			Cmd []string `json:"Cmd"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		d.command = body.Cmd
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id": "abc"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/exec/abc/start":
+		_, _ = w.Write(frame(2, d.stderr))
+		_, _ = w.Write(frame(1, d.stdout))
+	case r.Method == http.MethodGet && r.URL.Path == "/exec/abc/json":
+		_ = json.NewEncoder(w).Encode(map[string]any{"Running": false, "ExitCode": d.exitCode})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "No such container: missing"}`))
+	}
+}
+
+// serveUnix serves the daemon on a unix socket and returns its host
+func serveUnix(t *testing.T, daemon http.Handler) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(daemon)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return "unix://" + socket
+}
+
+func TestClient_Inspect(t *testing.T) {
+	client, err := NewClient(serveUnix(t, &fakeDaemon{}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	container, err := client.Inspect("gluetun")
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	want := Container{Name: "gluetun", Env: []string{"PATH=/bin", "VPN_PORT=51413"}, Labels: map[string]string{"vpn.port": "51414"}}
+	if !reflect.DeepEqual(container, want) {
+		t.Errorf("Inspect() = %+v, want %+v", container, want)
+	}
+
+	_, err = client.Inspect("missing")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || !strings.Contains(err.Error(), "No such container") {
+		t.Errorf("Inspect() error = %v, want not found", err)
+	}
+}
+
+func TestClient_Exec(t *testing.T) {
+	daemon := &fakeDaemon{stdout: "51413\n", stderr: "warning\n"}
+	server := httptest.NewServer(daemon)
+	defer server.Close()
+	client, err := NewClient("tcp://" + strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	command := []string{"cat", "/tmp/gluetun/forwarded_port"}
+	output, err := client.Exec("gluetun", command)
+	if err != nil || output != "51413\n" {
+		t.Errorf("Exec() = %q, %v, want the stdout", output, err)
+	}
+	if !reflect.DeepEqual(daemon.command, command) {
+		t.Errorf("command = %q, want %q", daemon.command, command)
+	}
+
+	daemon.exitCode, daemon.stderr = 1, "cat: can't open '/tmp/gluetun/forwarded_port'\n"
+	_, err = client.Exec("gluetun", command)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 1 || !strings.Contains(err.Error(), "can't open") {
+		t.Errorf("Exec() error = %v, want exit status with stderr", err)
+	}
+}
+
+func TestNewClient_Invalid(t *testing.T) {
+	for _, host := range []string{"unix://", "tcp://", "ssh://user@docker", "npipe:////./pipe/docker_engine"} {
+		if _, err := NewClient(host); err == nil {
+			t.Errorf("NewClient(%q) error = nil", host)
+		}
+	}
+	if _, err := NewClient(""); err != nil {
+		t.Errorf("NewClient() with default host error = %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package generic keeps the port of any service with an HTTP API in sync, by
+// sending a request rendered from user-defined templates for every new port.
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// templateFuncs are available to the URL and body templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. {"port": {{json .Port}}}
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Data is passed to the URL and body templates
+type Data struct {
+	// Port is the port to apply
+	Port int
+	// PreviousPort is the port applied before, 0 for the first request
+	PreviousPort int
+}
+
+// Options describe the request sent for every port. Username and Password
+// are sent with basic authentication, and BearerToken as Authorization
+// header; Headers are applied last.
+type Options struct {
+	// Method is POST, PUT or PATCH; empty is POST
+	Method string
+	// Body is the template of the request body; empty sends no body
+	Body        string
+	ContentType string
+	Headers     map[string]string
+	Username    string
+	Password    string
+	BearerToken string
+	Transport   torrent.TransportOptions
+}
+
+// Client sends the port to a service with an HTTP API. The service is not
+// asked for its port, so the client reports the port it applied last, and 0
+// before the first request, which makes the watcher send every new port
+// once. There is no standard status endpoint either, so the pings always
+// succeed.
+type Client struct {
+	url    *template.Template
+	body   *template.Template
+	opts   Options
+	client *http.Client
+	retry  torrent.RetryPolicy
+
+	mu   sync.Mutex
+	port int
+}
+
+// NewClient creates a client for the URL template, which may contain the
+// port like the body, e.g. http://service/api/port/{{.Port}}
+func NewClient(rawURL string, opts Options) (*Client, error) {
+	switch opts.Method = strings.ToUpper(opts.Method); opts.Method {
+	case "":
+		opts.Method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("unsupported method %q (expected POST, PUT or PATCH)", opts.Method)
+	}
+
+	urlTemplate, err := parseTemplate("url", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		url:    urlTemplate,
+		opts:   opts,
+		client: torrent.NewHTTPClient(opts.Transport),
+		retry:  torrent.DefaultRetryPolicy,
+	}
+	if opts.Body != "" {
+		if client.body, err = parseTemplate("body", opts.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	// Render an example request, so that a template that cannot render or a
+	// URL without a host is reported at startup
+	if _, err := client.newRequest(Data{Port: 1}); err != nil {
+		return nil, torrent.Permanent(err)
+	}
+	return client, nil
+}
+
+// parseTemplate parses the URL or body template
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// SetRetryPolicy changes how failed requests are retried. It must be called
+// before the client is shared.
+func (c *Client) SetRetryPolicy(policy torrent.RetryPolicy) {
+	c.retry = policy
+}
+
+// GetPort returns the port applied last, or 0 before the first request
+func (c *Client) GetPort() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.port, nil
+}
+
+// SetPort sends the request for the port. A 2xx response applies it; other
+// client errors besides timeouts and rate limits are not retried, since the
+// same request would fail again.
+func (c *Client) SetPort(port int) error {
+	c.mu.Lock()
+	data := Data{Port: port, PreviousPort: c.port}
+	c.mu.Unlock()
+
+	err := c.retry.Run("set port", func() error {
+		req, err := c.newRequest(data)
+		if err != nil {
+			return torrent.Permanent(err)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+		defer closeResponseBody(resp)
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err = fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return torrent.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.port = port
+	c.mu.Unlock()
+	slog.Info("successfully sent port to generic target", "port", port)
+	return nil
+}
+
+// newRequest renders the request for the template data
+func (c *Client) newRequest(data Data) (*http.Request, error) {
+	var target bytes.Buffer
+	if err := c.url.Execute(&target, data); err != nil {
+		return nil, fmt.Errorf("failed to render url template: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(target.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("url must be an http or https URL with a host")
+	}
+
+	var body io.Reader
+	if c.body != nil {
+		var buf bytes.Buffer
+		if err := c.body.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render body template: %w", err)
+		}
+		body = &buf
+	}
+
+	req, err := http.NewRequest(c.opts.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.body != nil && c.opts.ContentType != "" {
+		req.Header.Set("Content-Type", c.opts.ContentType)
+	}
+	if c.opts.Username != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+	if c.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.BearerToken)
+	}
+	for name, value := range c.opts.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// Ping always succeeds, since the target has no status endpoint
+func (c *Client) Ping() error {
+	return nil
+}
+
+// PingOnce always succeeds, since the target has no status endpoint
+func (c *Client) PingOnce() error {
+	return nil
+}
+
+func closeResponseBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+}
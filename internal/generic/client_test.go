@@ -0,0 +1,154 @@
+package generic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// request is a request received by the test server
+type request struct {
+	method, path, body, contentType, auth, header string
+}
+
+func newTestServer(t *testing.T, status int, requests *[]request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*requests = append(*requests, request{
+			method:      r.Method,
+			path:        r.URL.Path,
+			body:        string(body),
+			contentType: r.Header.Get("Content-Type"),
+			auth:        r.Header.Get("Authorization"),
+			header:      r.Header.Get("X-Test"),
+		})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_SetPort(t *testing.T) {
+	var requests []request
+	server := newTestServer(t, http.StatusNoContent, &requests)
+
+	client, err := NewClient(server.URL+"/api/port/{{.Port}}", Options{
+		Method:      "put",
+		Body:        `{"port": {{.Port}}, "previous": {{.PreviousPort}}}`,
+		ContentType: "application/json",
+		Headers:     map[string]string{"X-Test": "yes"},
+		BearerToken: "token",
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if port, _ := client.GetPort(); port != 0 {
+		t.Errorf("GetPort() before the first request = %d, want 0", port)
+	}
+
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if err := client.SetPort(7000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if port, _ := client.GetPort(); port != 7000 {
+		t.Errorf("GetPort() = %d, want 7000", port)
+	}
+
+	want := request{
+		method:      http.MethodPut,
+		path:        "/api/port/7000",
+		body:        `{"port": 7000, "previous": 6000}`,
+		contentType: "application/json",
+		auth:        "Bearer token",
+		header:      "yes",
+	}
+	if len(requests) != 2 || requests[1] != want {
+		t.Errorf("requests = %+v, want second %+v", requests, want)
+	}
+}
+
+func TestClient_SetPortWithoutBody(t *testing.T) {
+	var requests []request
+	server := newTestServer(t, http.StatusOK, &requests)
+
+	client, err := NewClient(server.URL+"/port", Options{Username: "user", Password: "pass", ContentType: "application/json"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if len(requests) != 1 || requests[0].method != http.MethodPost || requests[0].body != "" || requests[0].contentType != "" {
+		t.Errorf("requests = %+v, want one POST without body", requests)
+	}
+	if requests[0].auth == "" {
+		t.Error("request without basic authentication")
+	}
+}
+
+func TestClient_SetPortErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantRequests  int
+		wantPermanent bool
+	}{
+		{name: "server error is retried", status: http.StatusBadGateway, wantRequests: 2},
+		{name: "rate limit is retried", status: http.StatusTooManyRequests, wantRequests: 2},
+		{name: "client error is permanent", status: http.StatusBadRequest, wantRequests: 1, wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []request
+			server := newTestServer(t, tt.status, &requests)
+			client, err := NewClient(server.URL, Options{})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+			err = client.SetPort(6000)
+			if err == nil {
+				t.Fatal("SetPort() error = nil")
+			}
+			if torrent.IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, !tt.wantPermanent, tt.wantPermanent)
+			}
+			if len(requests) != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", len(requests), tt.wantRequests)
+			}
+			if port, _ := client.GetPort(); port != 0 {
+				t.Errorf("GetPort() after a failure = %d, want 0", port)
+			}
+		})
+	}
+}
+
+func TestNewClient_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		opts Options
+	}{
+		{name: "method", url: "http://service/port", opts: Options{Method: "DELETE"}},
+		{name: "url template", url: "http://service/{{.Port", opts: Options{}},
+		{name: "unknown field", url: "http://service/port", opts: Options{Body: "{{.Unknown}}"}},
+		{name: "no host", url: "/port/{{.Port}}", opts: Options{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.url, tt.opts); err == nil {
+				t.Error("NewClient() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Package kubernetes is a minimal client of the Kubernetes API, enough to
+// read and watch the forwarded port that a Gluetun sidecar published in a
+// ConfigMap or a pod annotation.
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// requestTimeout bounds a request to the API server
+const requestTimeout = 10 * time.Second
+
+// maxResponseSize bounds the responses read from the API server
+const maxResponseSize = 4 << 20
+
+// Options configure how the client authenticates against the API server
+type Options struct {
+	// TokenFile holds the bearer token. It is read for every request, since
+	// the kubelet rotates projected tokens.
+	TokenFile string
+	// CAFile is the CA certificate of the API server
+	CAFile string
+	// Namespace is the namespace of the pod, used when none is given
+	Namespace string
+}
+
+// Client reads and watches objects of the API server
+type Client struct {
+	baseURL   string
+	tokenFile string
+	namespace string
+	client    *http.Client
+	// watchClient has no timeout, since watches stay open until the API
+	// server ends them
+	watchClient *http.Client
+}
+
+// NewClient creates a client for the API server at baseURL
+func NewClient(baseURL string, opts Options) (*Client, error) {
+	tlsConfig, err := torrent.TLSOptions{CAFile: opts.CAFile}.Config()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		tokenFile:   opts.TokenFile,
+		namespace:   opts.Namespace,
+		client:      &http.Client{Transport: transport, Timeout: requestTimeout},
+		watchClient: &http.Client{Transport: transport},
+	}, nil
+}
+
+// InCluster creates a client with the service account of the pod Forwardarr
+// runs in, like the in-cluster configuration of client-go
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes pod, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace of the service account: %w", err)
+	}
+	return NewClient("https://"+net.JoinHostPort(host, port), Options{
+		TokenFile: filepath.Join(serviceAccountDir, "token"),
+		CAFile:    filepath.Join(serviceAccountDir, "ca.crt"),
+		Namespace: strings.TrimSpace(string(namespace)),
+	})
+}
+
+// Namespace returns the namespace of the pod, or an empty string if unknown
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// StatusError is a response of the API server with an error status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Kubernetes API returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("Kubernetes API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// configMap and pod hold the parts of the objects the client reads
+type configMap struct {
+	Data map[string]string `json:"data"`
+}
+
+type pod struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// ConfigMapData returns the data of the ConfigMap
+func (c *Client) ConfigMapData(namespace, name string) (map[string]string, error) {
+	var body configMap
+	if err := c.get(configMapsPath(namespace)+"/"+url.PathEscape(name), &body); err != nil {
+		return nil, err
+	}
+	return body.Data, nil
+}
+
+// PodAnnotations returns the annotations of the pod
+func (c *Client) PodAnnotations(namespace, name string) (map[string]string, error) {
+	var body pod
+	if err := c.get(podsPath(namespace)+"/"+url.PathEscape(name), &body); err != nil {
+		return nil, err
+	}
+	return body.Metadata.Annotations, nil
+}
+
+// WatchConfigMapData calls fn with the data of the ConfigMap, first with the
+// current data and then with every change, until ctx is done or the watch
+// fails. A deleted ConfigMap is reported with exists false. The watch
+// returns nil when the API server ends it, which it does every few minutes.
+func (c *Client) WatchConfigMapData(ctx context.Context, namespace, name string, fn func(data map[string]string, exists bool)) error {
+	return c.watch(ctx, configMapsPath(namespace), name, func(object json.RawMessage, exists bool) error {
+		var body configMap
+		if err := json.Unmarshal(object, &body); err != nil {
+			return fmt.Errorf("failed to decode Kubernetes watch event: %w", err)
+		}
+		fn(body.Data, exists)
+		return nil
+	})
+}
+
+// WatchPodAnnotations calls fn with the annotations of the pod, like
+// WatchConfigMapData
+func (c *Client) WatchPodAnnotations(ctx context.Context, namespace, name string, fn func(annotations map[string]string, exists bool)) error {
+	return c.watch(ctx, podsPath(namespace), name, func(object json.RawMessage, exists bool) error {
+		var body pod
+		if err := json.Unmarshal(object, &body); err != nil {
+			return fmt.Errorf("failed to decode Kubernetes watch event: %w", err)
+		}
+		fn(body.Metadata.Annotations, exists)
+		return nil
+	})
+}
+
+func configMapsPath(namespace string) string {
+	return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/configmaps"
+}
+
+func podsPath(namespace string) string {
+	return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+}
+
+// get requests the object at path and decodes it into out
+func (c *Client) get(path string, out any) error {
+	req, err := c.newRequest(context.Background(), path)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Kubernetes API: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body := io.LimitReader(resp.Body, maxResponseSize)
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Kubernetes API response: %w", err)
+	}
+	return nil
+}
+
+// watch watches the object named name in the collection at path and calls
+// fn with the object of every event
+func (c *Client) watch(ctx context.Context, path, name string, fn func(object json.RawMessage, exists bool) error) error {
+	query := url.Values{"watch": {"1"}, "fieldSelector": {"metadata.name=" + name}}
+	req, err := c.newRequest(ctx, path+"?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	resp, err := c.watchClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Kubernetes API: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode, io.LimitReader(resp.Body, maxResponseSize))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read Kubernetes watch: %w", err)
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			err = fn(event.Object, true)
+		case "DELETED":
+			err = fn(event.Object, false)
+		case "ERROR":
+			// The object is a Status, e.g. 410 Gone once the watch expired
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(event.Object, &status)
+			err = &StatusError{StatusCode: status.Code, Message: status.Message}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newRequest creates a GET request of path with the service account token
+func (c *Client) newRequest(ctx context.Context, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes API request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return req, nil
+}
+
+// statusError returns the error of a response with the status code and
+// body
+func statusError(code int, body io.Reader) error {
+	var status struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(body).Decode(&status)
+	return &StatusError{StatusCode: code, Message: status.Message}
+}
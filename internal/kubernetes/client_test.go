@@ -0,0 +1,150 @@
+package kubernetes
+
+import (
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeAPIServer serves the ConfigMap default/gluetun and the pod
+// default/qbittorrent to requests with the token, and writes the service
+// account files of a pod to a new directory
+func fakeAPIServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"kind": "Status", "message": "Unauthorized"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/default/configmaps/gluetun":
+			_, _ = w.Write([]byte(`{"kind": "ConfigMap", "data": {"port": "51413"}}`))
+		case "/api/v1/namespaces/default/pods/qbittorrent":
+			_, _ = w.Write([]byte(`{"kind": "Pod", "metadata": {"annotations": {"gluetun/port": "51414"}}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"kind": "Status", "message": "configmaps \"other\" is forbidden"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	for name, data := range map[string][]byte{"token": []byte("token\n"), "ca.crt": ca, "namespace": []byte("default")} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return server, dir
+}
+
+func TestInCluster(t *testing.T) {
+	server, dir := fakeAPIServer(t)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := serviceAccountDir
+	serviceAccountDir = dir
+	t.Cleanup(func() { serviceAccountDir = original })
+	t.Setenv("KUBERNETES_SERVICE_HOST", u.Hostname())
+	t.Setenv("KUBERNETES_SERVICE_PORT", u.Port())
+
+	client, err := InCluster()
+	if err != nil {
+		t.Fatalf("InCluster() error = %v", err)
+	}
+	if client.Namespace() != "default" {
+		t.Errorf("Namespace() = %q, want default", client.Namespace())
+	}
+
+	data, err := client.ConfigMapData("default", "gluetun")
+	if err != nil || !reflect.DeepEqual(data, map[string]string{"port": "51413"}) {
+		t.Errorf("ConfigMapData() = %v, %v", data, err)
+	}
+	annotations, err := client.PodAnnotations("default", "qbittorrent")
+	if err != nil || !reflect.DeepEqual(annotations, map[string]string{"gluetun/port": "51414"}) {
+		t.Errorf("PodAnnotations() = %v, %v", annotations, err)
+	}
+
+	_, err = client.ConfigMapData("default", "other")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("ConfigMapData() error = %v, want forbidden", err)
+	}
+}
+
+func TestInCluster_NotInPod(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	if _, err := InCluster(); err == nil {
+		t.Error("InCluster() error = nil, want not in a pod")
+	}
+}
+
+func TestClient_Unauthorized(t *testing.T) {
+	server, dir := fakeAPIServer(t)
+	client, err := NewClient(server.URL, Options{CAFile: filepath.Join(dir, "ca.crt")})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	_, err = client.ConfigMapData("default", "gluetun")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ConfigMapData() error = %v, want unauthorized", err)
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "1" || r.URL.Query().Get("fieldSelector") != "metadata.name=gluetun" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/default/configmaps":
+			_, _ = w.Write([]byte(`{"type": "ADDED", "object": {"data": {"port": "51413"}}}
+{"type": "MODIFIED", "object": {"data": {"port": "51414"}}}
+{"type": "DELETED", "object": {"data": {"port": "51414"}}}
+`))
+		case "/api/v1/namespaces/default/pods":
+			_, _ = w.Write([]byte(`{"type": "ADDED", "object": {"metadata": {"annotations": {"gluetun/port": "51415"}}}}
+{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}
+`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(server.URL, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	var events []string
+	err = client.WatchConfigMapData(t.Context(), "default", "gluetun", func(data map[string]string, exists bool) {
+		events = append(events, data["port"]+" "+strconv.FormatBool(exists))
+	})
+	if err != nil || !reflect.DeepEqual(events, []string{"51413 true", "51414 true", "51414 false"}) {
+		t.Errorf("WatchConfigMapData() = %v, events %v", err, events)
+	}
+
+	events = nil
+	err = client.WatchPodAnnotations(t.Context(), "default", "gluetun", func(annotations map[string]string, exists bool) {
+		events = append(events, annotations["gluetun/port"]+" "+strconv.FormatBool(exists))
+	})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusGone {
+		t.Errorf("WatchPodAnnotations() error = %v, want gone", err)
+	}
+	if !reflect.DeepEqual(events, []string{"51415 true"}) {
+		t.Errorf("WatchPodAnnotations() events = %v", events)
+	}
+}
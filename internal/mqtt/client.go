@@ -0,0 +1,343 @@
+// Package mqtt is a minimal MQTT 3.1.1 client that subscribes to a topic,
+// enough to receive the forwarded port from publishers such as Home
+// Assistant, and publishes single messages, such as the MQTT notifications.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/mqtt/packet"
+)
+
+// DefaultKeepAlive is the keep-alive interval when Options.KeepAlive is 0
+const DefaultKeepAlive = 60 * time.Second
+
+// DefaultTimeout is the timeout when Options.Timeout is 0
+const DefaultTimeout = 10 * time.Second
+
+// ServerUnavailable is the CONNACK return code of a broker that is
+// temporarily unable to accept connections
+const ServerUnavailable = 3
+
+// ConnectError is returned when the broker refuses the connection
+type ConnectError struct {
+	Code byte
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("MQTT broker refused connection: return code %d", e.Code)
+}
+
+// Options configure the connection to the broker
+type Options struct {
+	Username string
+	Password string
+	// ClientID identifies the connection to the broker; it must differ from
+	// the ID of other clients, such as the MQTT webhook of Forwardarr
+	ClientID string
+	// TLS configures mqtts:// brokers; nil uses the system roots
+	TLS *tls.Config
+	// KeepAlive is announced to the broker. The subscription pings the
+	// broker at half the interval and gives up when nothing arrives for one
+	// and a half intervals.
+	KeepAlive time.Duration
+	// Timeout bounds connecting and subscribing, and each publish
+	Timeout time.Duration
+}
+
+// Client connects to a broker
+type Client struct {
+	addr string
+	tls  *tls.Config
+	opts Options
+}
+
+// NewClient creates a client for the broker at brokerURL, mqtt://host:port
+// or mqtts://host:port for TLS
+func NewClient(brokerURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker URL: %w", err)
+	}
+	if (u.Scheme != "mqtt" && u.Scheme != "mqtts") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid MQTT broker URL %q: expected mqtt://host:port or mqtts://host:port", brokerURL)
+	}
+	if opts.ClientID == "" {
+		return nil, errors.New("MQTT client ID is required")
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	port := u.Port()
+	if port == "" {
+		port = "1883"
+		if u.Scheme == "mqtts" {
+			port = "8883"
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if u.Scheme == "mqtts" {
+		tlsConfig = &tls.Config{}
+		if opts.TLS != nil {
+			tlsConfig = opts.TLS.Clone()
+		}
+		tlsConfig.ServerName = u.Hostname()
+	}
+	return &Client{addr: net.JoinHostPort(u.Hostname(), port), tls: tlsConfig, opts: opts}, nil
+}
+
+// Addr returns the host:port of the broker
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Message is a message published to the subscribed topic
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Subscription is a connection subscribed to a topic
+type Subscription struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	keepAlive time.Duration
+
+	// mu serializes writes of pings and the disconnect
+	mu   sync.Mutex
+	stop chan struct{}
+	once sync.Once
+}
+
+// Subscribe connects to the broker with a clean session and subscribes to
+// the topic, which may contain wildcards, with QoS 0. Retained messages of
+// the topic are delivered first.
+func (c *Client) Subscribe(ctx context.Context, topic string) (*Subscription, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Retained messages may arrive right after the SUBACK, so the reader of
+	// the handshake is kept for the subscription
+	r := bufio.NewReader(conn)
+	if err := c.handshake(conn, r, topic); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	s := &Subscription{conn: conn, r: r, keepAlive: c.opts.KeepAlive, stop: make(chan struct{})}
+	go s.ping()
+	return s, nil
+}
+
+// Publish connects to the broker, publishes a single message with QoS 0 or
+// 1 and disconnects. With QoS 1 it waits for the broker to acknowledge the
+// message.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return fmt.Errorf("unsupported MQTT QoS %d: must be 0 or 1", qos)
+	}
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			slog.Debug("failed to close MQTT connection", "error", err)
+		}
+	}()
+	// Closing the connection aborts the exchange once ctx is done
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	r := bufio.NewReader(conn)
+	if err := c.connect(conn, r); err != nil {
+		return err
+	}
+
+	const packetID = 1
+	header := byte(packet.Publish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := packet.AppendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, payload...)
+	if _, err := conn.Write(packet.Encode(header, body)); err != nil {
+		return fmt.Errorf("failed to send MQTT PUBLISH: %w", err)
+	}
+	if qos > 0 {
+		header, data, err := packet.Read(r)
+		if err != nil {
+			return fmt.Errorf("failed to read MQTT PUBACK: %w", err)
+		}
+		if header>>4 != packet.PubAck || len(data) != 2 || binary.BigEndian.Uint16(data) != packetID {
+			return fmt.Errorf("unexpected MQTT packet type %d, want PUBACK", header>>4)
+		}
+	}
+
+	if _, err := conn.Write([]byte{packet.Disconnect << 4, 0}); err != nil {
+		slog.Debug("failed to send MQTT DISCONNECT", "broker", c.addr, "error", err)
+	}
+	return nil
+}
+
+// dial opens the connection to the broker, over TLS for mqtts://
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.opts.Timeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+	return conn, nil
+}
+
+// connect sets the deadline of the exchange, sends CONNECT and checks the
+// CONNACK
+func (c *Client) connect(conn net.Conn, r *bufio.Reader) error {
+	if err := conn.SetDeadline(time.Now().Add(c.opts.Timeout)); err != nil {
+		return fmt.Errorf("failed to set MQTT deadline: %w", err)
+	}
+
+	if _, err := conn.Write(c.connectPacket()); err != nil {
+		return fmt.Errorf("failed to send MQTT CONNECT: %w", err)
+	}
+	header, data, err := packet.Read(r)
+	if err != nil {
+		return fmt.Errorf("failed to read MQTT CONNACK: %w", err)
+	}
+	if header>>4 != packet.ConnAck || len(data) != 2 {
+		return fmt.Errorf("unexpected MQTT packet type %d, want CONNACK", header>>4)
+	}
+	if data[1] != 0 {
+		return &ConnectError{Code: data[1]}
+	}
+	return nil
+}
+
+// handshake sends CONNECT and SUBSCRIBE and checks the acknowledgements
+func (c *Client) handshake(conn net.Conn, r *bufio.Reader, topic string) error {
+	if err := c.connect(conn, r); err != nil {
+		return err
+	}
+
+	const packetID = 1
+	var body []byte
+	body = binary.BigEndian.AppendUint16(body, packetID)
+	body = packet.AppendString(body, topic)
+	body = append(body, 0)
+	if _, err := conn.Write(packet.Encode(packet.Subscribe<<4|0x02, body)); err != nil {
+		return fmt.Errorf("failed to send MQTT SUBSCRIBE: %w", err)
+	}
+	header, data, err := packet.Read(r)
+	if err != nil {
+		return fmt.Errorf("failed to read MQTT SUBACK: %w", err)
+	}
+	if header>>4 != packet.SubAck || len(data) != 3 || binary.BigEndian.Uint16(data) != packetID {
+		return fmt.Errorf("unexpected MQTT packet type %d, want SUBACK", header>>4)
+	}
+	if data[2] == 0x80 {
+		return fmt.Errorf("MQTT broker rejected the subscription to %q", topic)
+	}
+	return conn.SetDeadline(time.Time{})
+}
+
+// connectPacket builds a CONNECT packet with a clean session
+func (c *Client) connectPacket() []byte {
+	flags := byte(0x02)
+	if c.opts.Username != "" {
+		flags |= 0x80
+		if c.opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+
+	var body []byte
+	body = packet.AppendString(body, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.opts.KeepAlive/time.Second))
+	body = packet.AppendString(body, c.opts.ClientID)
+	if flags&0x80 != 0 {
+		body = packet.AppendString(body, c.opts.Username)
+	}
+	if flags&0x40 != 0 {
+		body = packet.AppendString(body, c.opts.Password)
+	}
+	return packet.Encode(packet.Connect<<4, body)
+}
+
+// Next waits for the next message. It fails when the connection is lost,
+// including when the broker stopped answering pings.
+func (s *Subscription) Next() (Message, error) {
+	for {
+		if err := s.conn.SetReadDeadline(time.Now().Add(s.keepAlive * 3 / 2)); err != nil {
+			return Message{}, err
+		}
+		header, body, err := packet.Read(s.r)
+		if err != nil {
+			return Message{}, err
+		}
+		switch header >> 4 {
+		case packet.Publish:
+			topic, _, payload, err := packet.ParsePublish(header, body)
+			if err != nil {
+				return Message{}, err
+			}
+			return Message{Topic: topic, Payload: payload, Retain: header&0x01 != 0}, nil
+		case packet.PingResp:
+		default:
+			slog.Debug("ignoring MQTT packet", "type", header>>4)
+		}
+	}
+}
+
+// Close disconnects from the broker
+func (s *Subscription) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	s.mu.Lock()
+	_, _ = s.conn.Write([]byte{packet.Disconnect << 4, 0})
+	s.mu.Unlock()
+	return s.conn.Close()
+}
+
+// ping sends PINGREQ at half the keep-alive interval until Close
+func (s *Subscription) ping() {
+	ticker := time.NewTicker(s.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		_, err := s.conn.Write([]byte{packet.PingReq << 4, 0})
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/mqtt/mqtttest"
+)
+
+func TestSubscription(t *testing.T) {
+	broker := mqtttest.NewBroker(t, mqtttest.Config{Retained: "51413"})
+	client, err := NewClient(broker.URL(), Options{Username: "forwardarr", Password: "secret", ClientID: "forwardarr-source"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	sub, err := client.Subscribe(context.Background(), "vpn/+/port")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer func() { _ = sub.Close() }()
+
+	message, err := sub.Next()
+	if err != nil || message.Topic != "vpn/+/port" || string(message.Payload) != "51413" || !message.Retain {
+		t.Errorf("Next() = %+v, %v, want the retained message", message, err)
+	}
+	broker.Publish(`{"port": 51414}`)
+	message, err = sub.Next()
+	if err != nil || string(message.Payload) != `{"port": 51414}` || message.Retain {
+		t.Errorf("Next() = %+v, %v, want the published message", message, err)
+	}
+
+	clientID, username, password := broker.Connect()
+	if clientID != "forwardarr-source" || username != "forwardarr" || password != "secret" || broker.Topic() != "vpn/+/port" {
+		t.Errorf("broker got client %q, user %q, password %q, topic %q", clientID, username, password, broker.Topic())
+	}
+}
+
+func TestSubscription_Ping(t *testing.T) {
+	broker := mqtttest.NewBroker(t, mqtttest.Config{})
+	client, err := NewClient(broker.URL(), Options{ClientID: "forwardarr-source", KeepAlive: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	sub, err := client.Subscribe(context.Background(), "vpn/port")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	go func() {
+		time.Sleep(time.Second)
+		broker.Publish("51413")
+	}()
+	// The pings keep the connection alive past the read deadline
+	if message, err := sub.Next(); err != nil || string(message.Payload) != "51413" {
+		t.Errorf("Next() = %+v, %v, want the message", message, err)
+	}
+	_ = sub.Close()
+	if _, err := sub.Next(); err == nil {
+		t.Error("Next() after Close() error = nil")
+	}
+
+	if pings := broker.Pings(); pings < 2 {
+		t.Errorf("pings = %d, want at least 2", pings)
+	}
+}
+
+func TestSubscribe_Refused(t *testing.T) {
+	for name, test := range map[string]struct {
+		connAck, subAck byte
+		want            string
+	}{
+		"credentials":  {connAck: 5, want: "return code 5"},
+		"subscription": {subAck: 0x80, want: "rejected the subscription"},
+	} {
+		broker := mqtttest.NewBroker(t, mqtttest.Config{ConnAck: test.connAck, SubAck: test.subAck})
+		client, err := NewClient(broker.URL(), Options{ClientID: "forwardarr-source"})
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		if _, err := client.Subscribe(context.Background(), "vpn/port"); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Subscribe() with refused %s error = %v, want %q", name, err, test.want)
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	for _, qos := range []byte{0, 1} {
+		broker := mqtttest.NewBroker(t, mqtttest.Config{})
+		client, err := NewClient(broker.URL(), Options{Username: "forwardarr", ClientID: "forwardarr"})
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		if err := client.Publish(context.Background(), "forwardarr/events", []byte("51413"), qos, qos == 1); err != nil {
+			t.Fatalf("Publish() with QoS %d error = %v", qos, err)
+		}
+
+		select {
+		case message := <-broker.Received():
+			if message.Topic != "forwardarr/events" || string(message.Payload) != "51413" || message.QoS != qos || message.Retain != (qos == 1) {
+				t.Errorf("broker got %+v, want the message with QoS %d", message, qos)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for publish")
+		}
+		if clientID, username, _ := broker.Connect(); clientID != "forwardarr" || username != "forwardarr" {
+			t.Errorf("broker got client %q, user %q", clientID, username)
+		}
+	}
+}
+
+func TestPublish_Refused(t *testing.T) {
+	broker := mqtttest.NewBroker(t, mqtttest.Config{ConnAck: 5})
+	client, err := NewClient(broker.URL(), Options{ClientID: "forwardarr"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	err = client.Publish(context.Background(), "forwardarr/events", []byte("51413"), 0, false)
+	var connectErr *ConnectError
+	if !errors.As(err, &connectErr) || connectErr.Code != 5 {
+		t.Errorf("Publish() error = %v, want return code 5", err)
+	}
+	if err := client.Publish(context.Background(), "forwardarr/events", nil, 2, false); err == nil {
+		t.Error("Publish() with QoS 2 error = nil")
+	}
+}
+
+func TestNewClient_Invalid(t *testing.T) {
+	for _, broker := range []string{"http://broker:1883", "mqtt://", "broker:1883"} {
+		if _, err := NewClient(broker, Options{ClientID: "forwardarr-source"}); err == nil {
+			t.Errorf("NewClient(%q) error = nil", broker)
+		}
+	}
+	if _, err := NewClient("mqtt://broker", Options{}); err == nil {
+		t.Error("NewClient() without client ID error = nil")
+	}
+	client, err := NewClient("mqtts://broker", Options{ClientID: "forwardarr-source"})
+	if err != nil || client.addr != "broker:8883" || client.tls == nil || client.tls.ServerName != "broker" {
+		t.Errorf("NewClient() = %+v, %v, want TLS on port 8883", client, err)
+	}
+}
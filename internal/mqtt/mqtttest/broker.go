@@ -0,0 +1,234 @@
+// Package mqtttest provides an MQTT broker for tests of the MQTT client and
+// its users.
+package mqtttest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/mqtt/packet"
+)
+
+// Config sets how the broker answers
+type Config struct {
+	// ConnAck and SubAck are the return codes of CONNACK and SUBACK
+	ConnAck byte
+	SubAck  byte
+	// Retained is sent to subscribers right after the SUBACK if set
+	Retained string
+}
+
+// Message is a PUBLISH received by the broker
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Broker accepts connections, acknowledges CONNECT and SUBSCRIBE with the
+// configured return codes, answers pings, forwards the payloads of Publish
+// to subscribers and records the messages published by clients
+type Broker struct {
+	config   Config
+	listener net.Listener
+	outgoing chan string
+	conns    chan net.Conn
+	received chan Message
+
+	mu       sync.Mutex
+	clientID string
+	username string
+	password string
+	topic    string
+	pings    int
+}
+
+// NewBroker starts a broker that stops when the test ends
+func NewBroker(t testing.TB, config Config) *Broker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Broker{
+		config:   config,
+		listener: listener,
+		outgoing: make(chan string, 10),
+		conns:    make(chan net.Conn, 10),
+		received: make(chan Message, 10),
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.conns <- conn
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+// URL returns the mqtt:// URL of the broker
+func (b *Broker) URL() string {
+	return "mqtt://" + b.listener.Addr().String()
+}
+
+// Addr returns the host:port of the broker
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Publish sends payload to a subscriber on the subscribed topic
+func (b *Broker) Publish(payload string) {
+	b.outgoing <- payload
+}
+
+// Received returns the messages published by clients
+func (b *Broker) Received() <-chan Message {
+	return b.received
+}
+
+// DropConnection waits for the next connection not dropped yet and closes it
+func (b *Broker) DropConnection() {
+	_ = (<-b.conns).Close()
+}
+
+// Close stops accepting connections
+func (b *Broker) Close() {
+	_ = b.listener.Close()
+}
+
+// Connect returns the client ID and credentials of the last CONNECT
+func (b *Broker) Connect() (clientID, username, password string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.clientID, b.username, b.password
+}
+
+// Topic returns the topic of the last SUBSCRIBE
+func (b *Broker) Topic() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.topic
+}
+
+// Pings returns the number of PINGREQ received
+func (b *Broker) Pings() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pings
+}
+
+func (b *Broker) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+
+	header, body, err := packet.Read(r)
+	if err != nil || header>>4 != packet.Connect {
+		return
+	}
+	b.parseConnect(body)
+	if _, err := conn.Write(packet.Encode(packet.ConnAck<<4, []byte{0, b.config.ConnAck})); err != nil || b.config.ConnAck != 0 {
+		return
+	}
+
+	for {
+		header, body, err := packet.Read(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case packet.Subscribe:
+			if !b.subscribe(conn, body) {
+				return
+			}
+		case packet.Publish:
+			topic, id, payload, err := packet.ParsePublish(header, body)
+			if err != nil {
+				return
+			}
+			qos := header >> 1 & 0x03
+			if qos > 0 {
+				if _, err := conn.Write(packet.Encode(packet.PubAck<<4, binary.BigEndian.AppendUint16(nil, id))); err != nil {
+					return
+				}
+			}
+			b.received <- Message{Topic: topic, Payload: payload, QoS: qos, Retain: header&0x01 != 0}
+		case packet.PingReq:
+			b.mu.Lock()
+			b.pings++
+			b.mu.Unlock()
+			if _, err := conn.Write([]byte{packet.PingResp << 4, 0}); err != nil {
+				return
+			}
+		case packet.Disconnect:
+			return
+		}
+	}
+}
+
+// subscribe acknowledges a SUBSCRIBE, sends the retained message and starts
+// forwarding the payloads of Publish
+func (b *Broker) subscribe(conn net.Conn, body []byte) bool {
+	n := int(binary.BigEndian.Uint16(body[2:]))
+	topic := string(body[4 : 4+n])
+	b.mu.Lock()
+	b.topic = topic
+	b.mu.Unlock()
+	// The SUBACK and the retained message are written at once, like brokers
+	// often do
+	out := packet.Encode(packet.SubAck<<4, []byte{body[0], body[1], b.config.SubAck})
+	if b.config.Retained != "" {
+		out = append(out, publishPacket(topic, b.config.Retained, true)...)
+	}
+	if _, err := conn.Write(out); err != nil {
+		return false
+	}
+
+	go func() {
+		for payload := range b.outgoing {
+			if _, err := conn.Write(publishPacket(topic, payload, false)); err != nil {
+				return
+			}
+		}
+	}()
+	return true
+}
+
+func (b *Broker) parseConnect(body []byte) {
+	// Protocol name (6 bytes), level, flags, keep alive
+	flags := body[7]
+	rest := body[10:]
+	next := func() string {
+		n := int(binary.BigEndian.Uint16(rest))
+		s := string(rest[2 : 2+n])
+		rest = rest[2+n:]
+		return s
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clientID = next()
+	b.username, b.password = "", ""
+	if flags&0x80 != 0 {
+		b.username = next()
+	}
+	if flags&0x40 != 0 {
+		b.password = next()
+	}
+}
+
+// publishPacket builds a QoS 0 PUBLISH packet
+func publishPacket(topic, payload string, retain bool) []byte {
+	header := byte(packet.Publish << 4)
+	if retain {
+		header |= 0x01
+	}
+	return packet.Encode(header, append(packet.AppendString(nil, topic), payload...))
+}
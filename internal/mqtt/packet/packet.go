@@ -0,0 +1,99 @@
+// Package packet encodes and decodes the MQTT 3.1.1 control packets used by
+// the MQTT client and its test broker.
+package packet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, the upper four bits of the fixed header
+const (
+	Connect    = 1
+	ConnAck    = 2
+	Publish    = 3
+	PubAck     = 4
+	Subscribe  = 8
+	SubAck     = 9
+	PingReq    = 12
+	PingResp   = 13
+	Disconnect = 14
+)
+
+// Encode prefixes body with the fixed header and remaining length
+func Encode(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// AppendString appends a length-prefixed UTF-8 string
+func AppendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// Read reads a control packet and returns its fixed header byte and body
+func Read(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed MQTT remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// ParsePublish returns the topic, packet identifier (0 for QoS 0) and
+// payload of a PUBLISH packet with the fixed header byte header
+func ParsePublish(header byte, body []byte) (string, uint16, []byte, error) {
+	if len(body) < 2 {
+		return "", 0, nil, errors.New("malformed MQTT PUBLISH")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	rest := body[2:]
+	if len(rest) < n {
+		return "", 0, nil, errors.New("malformed MQTT PUBLISH topic")
+	}
+	topic, rest := string(rest[:n]), rest[n:]
+	var id uint16
+	if qos := header >> 1 & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return "", 0, nil, errors.New("malformed MQTT PUBLISH packet identifier")
+		}
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	return topic, id, rest, nil
+}
@@ -0,0 +1,48 @@
+package packet
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestRemainingLength(t *testing.T) {
+	encoded := Encode(Publish<<4, make([]byte, 321))
+
+	// 321 = 65 + 2*128 encodes as 0xC1 0x02
+	if encoded[1] != 0xC1 || encoded[2] != 0x02 {
+		t.Errorf("remaining length = % x, want c1 02", encoded[1:3])
+	}
+
+	header, body, err := Read(bufio.NewReader(bytes.NewReader(encoded)))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if header != Publish<<4 || len(body) != 321 {
+		t.Errorf("Read() = %x with %d bytes, want PUBLISH with 321", header, len(body))
+	}
+
+	if _, _, err := Read(bufio.NewReader(bytes.NewReader([]byte{Publish << 4, 0xff, 0xff, 0xff, 0xff}))); err == nil {
+		t.Error("Read() of a malformed remaining length error = nil")
+	}
+}
+
+func TestParsePublish(t *testing.T) {
+	body := append(AppendString(nil, "vpn/port"), 0, 7)
+	body = append(body, "51413"...)
+	topic, id, payload, err := ParsePublish(Publish<<4|0x02, body)
+	if err != nil || topic != "vpn/port" || id != 7 || string(payload) != "51413" {
+		t.Errorf("ParsePublish() = %q, %d, %q, %v, want QoS 1 message", topic, id, payload, err)
+	}
+
+	topic, id, payload, err = ParsePublish(Publish<<4, append(AppendString(nil, "vpn/port"), "51413"...))
+	if err != nil || topic != "vpn/port" || id != 0 || string(payload) != "51413" {
+		t.Errorf("ParsePublish() = %q, %d, %q, %v, want QoS 0 message", topic, id, payload, err)
+	}
+
+	for name, body := range map[string][]byte{"empty": nil, "topic": {0, 9, 'v'}, "identifier": AppendString(nil, "vpn/port")} {
+		if _, _, _, err := ParsePublish(Publish<<4|0x02, body); err == nil {
+			t.Errorf("ParsePublish() of malformed %s error = nil", name)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package natpmp requests port mappings from a NAT-PMP gateway (RFC 6886),
+// such as the one ProtonVPN runs at 10.2.0.1, and keeps them renewed.
+package natpmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultPort is the port NAT-PMP gateways listen on
+const DefaultPort = 5351
+
+// Protocol is the transport protocol of a mapping
+type Protocol byte
+
+// Protocols of a mapping, by their opcode in a request
+const (
+	UDP Protocol = 1
+	TCP Protocol = 2
+)
+
+func (p Protocol) String() string {
+	if p == TCP {
+		return "tcp"
+	}
+	return "udp"
+}
+
+// Retransmission of requests: the first response is awaited for
+// initialTimeout, which doubles with every attempt. RFC 6886 retries up to 9
+// times, over a minute; a VPN gateway that does not answer within a few
+// seconds is treated as down, and the mapping retried later.
+const (
+	initialTimeout = 250 * time.Millisecond
+	maxAttempts    = 5
+)
+
+// resultCodes describe the result codes of responses other than success
+var resultCodes = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// ResultError is a response of the gateway that refused a mapping
+type ResultError struct {
+	Code uint16
+}
+
+func (e *ResultError) Error() string {
+	if description, ok := resultCodes[e.Code]; ok {
+		return fmt.Sprintf("gateway refused the mapping: %s (%d)", description, e.Code)
+	}
+	return fmt.Sprintf("gateway refused the mapping with result code %d", e.Code)
+}
+
+// Mapping is a port mapping granted by the gateway for Lifetime
+type Mapping struct {
+	Protocol     Protocol
+	InternalPort int
+	ExternalPort int
+	Lifetime     time.Duration
+}
+
+// Client sends mapping requests to a NAT-PMP gateway
+type Client struct {
+	gateway string
+}
+
+// NewClient creates a client for the gateway, an IP address with an optional
+// port that defaults to DefaultPort
+func NewClient(gateway string) (*Client, error) {
+	host, port, err := net.SplitHostPort(gateway)
+	if err != nil {
+		host, port = gateway, fmt.Sprint(DefaultPort)
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("invalid NAT-PMP gateway %q: expected an IP address", gateway)
+	}
+	return &Client{gateway: net.JoinHostPort(host, port)}, nil
+}
+
+// Map requests a mapping of internalPort for lifetime. externalPort is the
+// suggested external port; 0 lets the gateway choose. A lifetime of 0
+// deletes the mapping.
+func (c *Client) Map(protocol Protocol, internalPort, externalPort int, lifetime time.Duration) (Mapping, error) {
+	request := make([]byte, 12)
+	request[1] = byte(protocol)
+	binary.BigEndian.PutUint16(request[4:], uint16(internalPort))
+	binary.BigEndian.PutUint16(request[6:], uint16(externalPort))
+	binary.BigEndian.PutUint32(request[8:], uint32(lifetime/time.Second))
+
+	response, err := c.exchange(request, 128+byte(protocol))
+	if err != nil {
+		return Mapping{}, err
+	}
+	if code := binary.BigEndian.Uint16(response[2:]); code != 0 {
+		return Mapping{}, &ResultError{Code: code}
+	}
+	return Mapping{
+		Protocol:     protocol,
+		InternalPort: int(binary.BigEndian.Uint16(response[8:])),
+		ExternalPort: int(binary.BigEndian.Uint16(response[10:])),
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(response[12:])) * time.Second,
+	}, nil
+}
+
+// exchange sends the request until a response with the opcode arrives,
+// doubling the timeout with every attempt
+func (c *Client) exchange(request []byte, opcode byte) ([]byte, error) {
+	conn, err := net.Dial("udp", c.gateway)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach NAT-PMP gateway: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	timeout := initialTimeout
+	response := make([]byte, 16)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return nil, fmt.Errorf("failed to send NAT-PMP request: %w", err)
+		}
+		deadline := time.Now().Add(timeout)
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		// Responses to earlier attempts or of another opcode are skipped
+		for {
+			n, err := conn.Read(response)
+			if errors.Is(err, net.ErrClosed) {
+				return nil, err
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			if err != nil {
+				// e.g. ICMP port unreachable while the tunnel comes up
+				return nil, fmt.Errorf("failed to read NAT-PMP response: %w", err)
+			}
+			if n >= 16 && response[0] == 0 && response[1] == opcode {
+				return response, nil
+			}
+		}
+		timeout *= 2
+	}
+	return nil, fmt.Errorf("no response from NAT-PMP gateway %s after %d attempts", c.gateway, maxAttempts)
+}
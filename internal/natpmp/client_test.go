@@ -0,0 +1,152 @@
+package natpmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGateway answers mapping requests like ProtonVPN: the first request
+// gets nextPort, later ones keep the suggested port
+type fakeGateway struct {
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	nextPort int
+	result   uint16
+	drop     int // requests to ignore before answering
+	requests []request
+}
+
+type request struct {
+	protocol               Protocol
+	internalPort, external int
+	lifetime               uint32
+}
+
+func newFakeGateway(t *testing.T) *fakeGateway {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &fakeGateway{conn: conn, nextPort: 45678}
+	t.Cleanup(func() { _ = conn.Close() })
+	go g.serve()
+	return g
+}
+
+func (g *fakeGateway) addr() string {
+	return g.conn.LocalAddr().String()
+}
+
+func (g *fakeGateway) serve() {
+	buf := make([]byte, 64)
+	for {
+		n, from, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if n < 12 {
+			continue
+		}
+		req := request{
+			protocol:     Protocol(buf[1]),
+			internalPort: int(binary.BigEndian.Uint16(buf[4:])),
+			external:     int(binary.BigEndian.Uint16(buf[6:])),
+			lifetime:     binary.BigEndian.Uint32(buf[8:]),
+		}
+
+		g.mu.Lock()
+		g.requests = append(g.requests, req)
+		if g.drop > 0 {
+			g.drop--
+			g.mu.Unlock()
+			continue
+		}
+		port := req.external
+		if port == 0 {
+			port = g.nextPort
+		}
+		response := make([]byte, 16)
+		response[1] = 128 + buf[1]
+		binary.BigEndian.PutUint16(response[2:], g.result)
+		binary.BigEndian.PutUint16(response[8:], uint16(req.internalPort))
+		binary.BigEndian.PutUint16(response[10:], uint16(port))
+		binary.BigEndian.PutUint32(response[12:], req.lifetime)
+		g.mu.Unlock()
+
+		_, _ = g.conn.WriteToUDP(response, from)
+	}
+}
+
+func (g *fakeGateway) received() []request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]request(nil), g.requests...)
+}
+
+func TestClient_Map(t *testing.T) {
+	gateway := newFakeGateway(t)
+	client, err := NewClient(gateway.addr())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	mapping, err := client.Map(TCP, 1, 0, time.Minute)
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	want := Mapping{Protocol: TCP, InternalPort: 1, ExternalPort: 45678, Lifetime: time.Minute}
+	if mapping != want {
+		t.Errorf("Map() = %+v, want %+v", mapping, want)
+	}
+	if got := gateway.received(); len(got) != 1 || got[0] != (request{protocol: TCP, internalPort: 1, lifetime: 60}) {
+		t.Errorf("requests = %+v", got)
+	}
+}
+
+func TestClient_MapRetransmits(t *testing.T) {
+	gateway := newFakeGateway(t)
+	gateway.mu.Lock()
+	gateway.drop = 2
+	gateway.mu.Unlock()
+	client, _ := NewClient(gateway.addr())
+
+	if _, err := client.Map(UDP, 1, 0, time.Minute); err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if got := len(gateway.received()); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestClient_MapRefused(t *testing.T) {
+	gateway := newFakeGateway(t)
+	gateway.mu.Lock()
+	gateway.result = 2
+	gateway.mu.Unlock()
+	client, _ := NewClient(gateway.addr())
+
+	_, err := client.Map(UDP, 1, 0, time.Minute)
+	var resultErr *ResultError
+	if !errors.As(err, &resultErr) || resultErr.Code != 2 {
+		t.Fatalf("Map() error = %v, want result code 2", err)
+	}
+	if got := err.Error(); got != "gateway refused the mapping: not authorized (2)" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("10.2.0.1")
+	if err != nil || client.gateway != "10.2.0.1:5351" {
+		t.Errorf("NewClient() = %+v, %v, want the default port", client, err)
+	}
+	if _, err := NewClient("vpn.example.com"); err == nil {
+		t.Error("NewClient() error = nil, want a host name rejected")
+	}
+}
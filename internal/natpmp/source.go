@@ -0,0 +1,120 @@
+package natpmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// retryDelay is how long the source waits after a failed renewal
+const retryDelay = 5 * time.Second
+
+// Source maps a port for both UDP and TCP and renews the mappings before
+// they expire, providing the external port as forwarded port. ProtonVPN
+// chooses the external port on the first request, and keeps it as long as
+// the mappings are renewed; the TCP mapping requests the port of the UDP
+// mapping.
+type Source struct {
+	client       *Client
+	internalPort int
+	lifetime     time.Duration
+
+	mu      sync.Mutex
+	port    int
+	expires time.Time
+	err     error
+}
+
+// NewSource creates a source mapping internalPort for lifetime, e.g. 1 and
+// 60 seconds for ProtonVPN
+func NewSource(client *Client, internalPort int, lifetime time.Duration) *Source {
+	return &Source{client: client, internalPort: internalPort, lifetime: lifetime}
+}
+
+// ForwardedPort returns the external port of the mappings, or 0 before the
+// first mapping. Once the mappings expired without renewal, it returns the
+// error of the last attempt.
+func (s *Source) ForwardedPort() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.port != 0 && time.Now().Before(s.expires) {
+		return s.port, nil
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return 0, nil
+}
+
+// Refresh requests or renews the mappings
+func (s *Source) Refresh() error {
+	s.mu.Lock()
+	suggested := s.port
+	s.mu.Unlock()
+
+	udp, err := s.client.Map(UDP, s.internalPort, suggested, s.lifetime)
+	if err == nil {
+		var tcp Mapping
+		tcp, err = s.client.Map(TCP, s.internalPort, udp.ExternalPort, s.lifetime)
+		if err == nil && tcp.ExternalPort != udp.ExternalPort {
+			slog.Warn("NAT-PMP gateway mapped different ports for UDP and TCP, using the UDP port",
+				"udp_port", udp.ExternalPort,
+				"tcp_port", tcp.ExternalPort,
+			)
+		}
+		if err == nil && udp.ExternalPort == 0 {
+			err = errors.New("gateway mapped no external port")
+		}
+		if err == nil {
+			s.mu.Lock()
+			if udp.ExternalPort != s.port {
+				slog.Info("NAT-PMP mapped port", "port", udp.ExternalPort, "previous_port", s.port, "lifetime", udp.Lifetime)
+			}
+			s.port = udp.ExternalPort
+			s.expires = time.Now().Add(min(udp.Lifetime, tcp.Lifetime))
+			s.err = nil
+			s.mu.Unlock()
+			return nil
+		}
+	}
+
+	err = fmt.Errorf("failed to map port: %w", err)
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+// Run renews the mappings at half their lifetime until ctx is done, and
+// retries failed renewals every few seconds. The first mapping is requested
+// right away unless Refresh already succeeded.
+func (s *Source) Run(ctx context.Context) {
+	for {
+		delay := s.nextRenewal()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err := s.Refresh(); err != nil {
+			slog.Warn("NAT-PMP renewal failed, retrying", "retry_delay", retryDelay, "error", err)
+		}
+	}
+}
+
+// nextRenewal returns how long to wait before the next renewal: half the
+// remaining lifetime, or retryDelay after a failure
+func (s *Source) nextRenewal() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.port == 0 && s.err == nil {
+		return 0
+	}
+	if s.err != nil {
+		return retryDelay
+	}
+	return max(time.Until(s.expires)/2, 0)
+}
@@ -0,0 +1,112 @@
+package natpmp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSource_Refresh(t *testing.T) {
+	gateway := newFakeGateway(t)
+	client, _ := NewClient(gateway.addr())
+	source := NewSource(client, 1, time.Minute)
+
+	if port, err := source.ForwardedPort(); port != 0 || err != nil {
+		t.Errorf("ForwardedPort() before mapping = %d, %v, want 0", port, err)
+	}
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if port, err := source.ForwardedPort(); port != 45678 || err != nil {
+		t.Errorf("ForwardedPort() = %d, %v, want 45678", port, err)
+	}
+
+	// Renewals keep the port the gateway chose
+	gateway.mu.Lock()
+	gateway.nextPort = 50000
+	gateway.mu.Unlock()
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if port, _ := source.ForwardedPort(); port != 45678 {
+		t.Errorf("ForwardedPort() after renewal = %d, want 45678", port)
+	}
+
+	want := []request{
+		{protocol: UDP, internalPort: 1, lifetime: 60},
+		{protocol: TCP, internalPort: 1, external: 45678, lifetime: 60},
+		{protocol: UDP, internalPort: 1, external: 45678, lifetime: 60},
+		{protocol: TCP, internalPort: 1, external: 45678, lifetime: 60},
+	}
+	got := gateway.received()
+	if len(got) != len(want) {
+		t.Fatalf("requests = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSource_Expired(t *testing.T) {
+	gateway := newFakeGateway(t)
+	client, _ := NewClient(gateway.addr())
+	source := NewSource(client, 1, time.Minute)
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// A failed renewal keeps the port until the mappings expire
+	gateway.mu.Lock()
+	gateway.result = 3
+	gateway.mu.Unlock()
+	if err := source.Refresh(); err == nil {
+		t.Fatal("Refresh() error = nil, want refused mapping")
+	}
+	if port, err := source.ForwardedPort(); port != 45678 || err != nil {
+		t.Errorf("ForwardedPort() after failed renewal = %d, %v, want 45678", port, err)
+	}
+	if got := source.nextRenewal(); got != retryDelay {
+		t.Errorf("nextRenewal() = %v, want %v", got, retryDelay)
+	}
+
+	source.mu.Lock()
+	source.expires = time.Now()
+	source.mu.Unlock()
+	if port, err := source.ForwardedPort(); port != 0 || err == nil {
+		t.Errorf("ForwardedPort() after expiry = %d, %v, want the renewal error", port, err)
+	}
+}
+
+func TestSource_Run(t *testing.T) {
+	gateway := newFakeGateway(t)
+	client, _ := NewClient(gateway.addr())
+	source := NewSource(client, 1, 2*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		source.Run(ctx)
+		close(done)
+	}()
+
+	// The first mapping is requested right away and renewed after a second
+	deadline := time.Now().Add(3 * time.Second)
+	for len(gateway.received()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := len(gateway.received()); got < 4 {
+		t.Errorf("requests = %d, want a mapping and a renewal", got)
+	}
+	if port, _ := source.ForwardedPort(); port != 45678 {
+		t.Errorf("ForwardedPort() = %d, want 45678", port)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not stop after cancel")
+	}
+}
@@ -0,0 +1,232 @@
+// Package pia forwards a port with the port forwarding API of Private
+// Internet Access, which runs on the gateway of every PIA server that
+// supports port forwarding.
+package pia
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// apiPort is the port of the port forwarding API on the gateway
+const apiPort = "19999"
+
+// requestTimeout bounds a request to PIA
+const requestTimeout = 10 * time.Second
+
+// maxResponseSize bounds responses read from PIA
+const maxResponseSize = 64 << 10
+
+// tokenURL issues tokens for the account credentials
+var tokenURL = "https://www.privateinternetaccess.com/api/client/v2/token"
+
+// Options configure the client. A token is generated from Username and
+// Password whenever a signature is requested, since tokens expire after a
+// day; Token is used as is when no credentials are set. PIA signs the API
+// certificates with its own CA, whose certificate (ca.rsa.4096.crt of PIA's
+// manual-connections scripts) must be trusted with CAFile.
+type Options struct {
+	Username string
+	Password string
+	Token    string
+
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+// Client talks to the port forwarding API of a PIA server
+type Client struct {
+	hostname string
+	opts     Options
+	api      *http.Client
+	auth     *http.Client
+}
+
+// NewClient creates a client for the server with the hostname, e.g.
+// ca-montreal.privacy.network, whose API is reached at the gateway of the
+// VPN connection. The gateway is an IP address with an optional port.
+func NewClient(hostname, gateway string, opts Options) (*Client, error) {
+	if hostname == "" {
+		return nil, errors.New("PIA server hostname is required")
+	}
+	if opts.Username == "" && opts.Token == "" {
+		return nil, errors.New("PIA credentials or a token are required")
+	}
+	if opts.CAFile == "" && !opts.InsecureSkipVerify {
+		return nil, errors.New("PIA CA certificate is required to verify the port forwarding API")
+	}
+	host, port, err := net.SplitHostPort(gateway)
+	if err != nil {
+		host, port = gateway, apiPort
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("invalid PIA gateway %q: expected an IP address", gateway)
+	}
+	address := net.JoinHostPort(host, port)
+
+	tlsConfig, err := torrent.TLSOptions{CAFile: opts.CAFile, InsecureSkipVerify: opts.InsecureSkipVerify}.Config()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = hostname
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	transport := &http.Transport{
+		// The hostname is only used for verification; the API is
+		// reached through the tunnel at the gateway
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		TLSClientConfig: tlsConfig,
+	}
+	return &Client{
+		hostname: hostname,
+		opts:     opts,
+		api:      &http.Client{Timeout: requestTimeout, Transport: transport},
+		auth:     &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Signature is a signed port assignment, which binds the port until
+// ExpiresAt, about two months after it was issued
+type Signature struct {
+	Payload   string
+	Signature string
+	Port      int
+	ExpiresAt time.Time
+}
+
+// GetSignature requests a port assignment, generating a token first if
+// credentials are set
+func (c *Client) GetSignature() (Signature, error) {
+	token, err := c.token()
+	if err != nil {
+		return Signature{}, err
+	}
+	var body struct {
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := c.get("getSignature", url.Values{"token": {token}}, &body); err != nil {
+		return Signature{}, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(body.Payload)
+	if err != nil {
+		return Signature{}, fmt.Errorf("failed to decode signature payload: %w", err)
+	}
+	var payload struct {
+		Port      int       `json:"port"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(decoded, &payload); err != nil {
+		return Signature{}, fmt.Errorf("failed to decode signature payload: %w", err)
+	}
+	if payload.Port < 1 || payload.Port > 65535 {
+		return Signature{}, fmt.Errorf("signature assigned port %d out of valid range", payload.Port)
+	}
+	return Signature{
+		Payload:   body.Payload,
+		Signature: body.Signature,
+		Port:      payload.Port,
+		ExpiresAt: payload.ExpiresAt,
+	}, nil
+}
+
+// BindPort binds or keeps binding the port of the signature, which PIA
+// releases when it is not bound again within about 15 minutes
+func (c *Client) BindPort(sig Signature) error {
+	return c.get("bindPort", url.Values{"payload": {sig.Payload}, "signature": {sig.Signature}}, nil)
+}
+
+// get calls the endpoint of the API and decodes the response into v, failing
+// unless PIA reports the status OK
+func (c *Client) get(endpoint string, query url.Values, v any) error {
+	u := url.URL{
+		Scheme:   "https",
+		Host:     net.JoinHostPort(c.hostname, apiPort),
+		Path:     "/" + endpoint,
+		RawQuery: query.Encode(),
+	}
+	resp, err := c.api.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to reach PIA port forwarding API: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", endpoint, err)
+	}
+
+	var status struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("%s returned status %d with an invalid response: %w", endpoint, resp.StatusCode, err)
+	}
+	if status.Status != "OK" {
+		return &StatusError{Endpoint: endpoint, Status: status.Status, Message: status.Message}
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", endpoint, err)
+		}
+	}
+	return nil
+}
+
+// StatusError is a response of the API other than OK, e.g. for an expired
+// token or signature
+type StatusError struct {
+	Endpoint string
+	Status   string
+	Message  string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s failed with status %s: %s", e.Endpoint, e.Status, e.Message)
+	}
+	return fmt.Sprintf("%s failed with status %s", e.Endpoint, e.Status)
+}
+
+// token returns a fresh token for the credentials, or the configured token
+func (c *Client) token() (string, error) {
+	if c.opts.Username == "" {
+		return c.opts.Token, nil
+	}
+	form := url.Values{"username": {c.opts.Username}, "password": {c.opts.Password}}
+	resp, err := c.auth.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to request PIA token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", errors.New("PIA rejected the credentials, check PIA_USER and PIA_PASSWORD")
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("PIA token request returned status %d", resp.StatusCode)
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode PIA token: %w", err)
+	}
+	if body.Token == "" {
+		return "", errors.New("PIA returned an empty token")
+	}
+	return body.Token, nil
+}
@@ -0,0 +1,161 @@
+package pia
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePIA serves the port forwarding API and the token endpoint
+type fakePIA struct {
+	api   *httptest.Server
+	token *httptest.Server
+	ca    string
+
+	mu         sync.Mutex
+	port       int
+	expiresAt  time.Time
+	signatures int
+	binds      []string // payloads bound
+	rejectBind bool
+	tokens     int
+}
+
+func newFakePIA(t *testing.T) *fakePIA {
+	t.Helper()
+	f := &fakePIA{port: 47000, expiresAt: time.Now().Add(60 * 24 * time.Hour).UTC().Truncate(time.Second)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/getSignature", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Host != "example.com:19999" || r.URL.Query().Get("token") != "fresh-token" {
+			fmt.Fprint(w, `{"status":"ERROR","message":"invalid token"}`)
+			return
+		}
+		f.signatures++
+		payload, _ := json.Marshal(map[string]any{"token": "x", "port": f.port, "expires_at": f.expiresAt})
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"status":    "OK",
+			"payload":   base64.StdEncoding.EncodeToString(payload),
+			"signature": "sig",
+		})
+	})
+	mux.HandleFunc("/bindPort", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.rejectBind || r.URL.Query().Get("signature") != "sig" {
+			fmt.Fprint(w, `{"status":"ERROR","message":"payload expired"}`)
+			return
+		}
+		f.binds = append(f.binds, r.URL.Query().Get("payload"))
+		fmt.Fprint(w, `{"status":"OK","message":"port scheduled for add"}`)
+	})
+	f.api = httptest.NewTLSServer(mux)
+	t.Cleanup(f.api.Close)
+
+	f.token = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("username") != "p1234567" || r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.mu.Lock()
+		f.tokens++
+		f.mu.Unlock()
+		fmt.Fprint(w, `{"token":"fresh-token"}`)
+	}))
+	t.Cleanup(f.token.Close)
+	previous := tokenURL
+	tokenURL = f.token.URL
+	t.Cleanup(func() { tokenURL = previous })
+
+	f.ca = filepath.Join(t.TempDir(), "ca.crt")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.api.Certificate().Raw})
+	if err := os.WriteFile(f.ca, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// client returns a client for the fake API; the certificate of the test
+// server is valid for example.com
+func (f *fakePIA) client(t *testing.T, opts Options) *Client {
+	t.Helper()
+	if opts.CAFile == "" {
+		opts.CAFile = f.ca
+	}
+	client, err := NewClient("example.com", f.api.Listener.Addr().String(), opts)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestClient_GetSignatureAndBind(t *testing.T) {
+	f := newFakePIA(t)
+	client := f.client(t, Options{Username: "p1234567", Password: "secret"})
+
+	sig, err := client.GetSignature()
+	if err != nil {
+		t.Fatalf("GetSignature() error = %v", err)
+	}
+	if sig.Port != 47000 || !sig.ExpiresAt.Equal(f.expiresAt) || sig.Signature != "sig" {
+		t.Errorf("GetSignature() = %+v", sig)
+	}
+	if err := client.BindPort(sig); err != nil {
+		t.Fatalf("BindPort() error = %v", err)
+	}
+	if len(f.binds) != 1 || f.binds[0] != sig.Payload {
+		t.Errorf("binds = %q, want the payload", f.binds)
+	}
+}
+
+func TestClient_StaticToken(t *testing.T) {
+	f := newFakePIA(t)
+	client := f.client(t, Options{Token: "fresh-token"})
+	if _, err := client.GetSignature(); err != nil {
+		t.Fatalf("GetSignature() error = %v", err)
+	}
+	if f.tokens != 0 {
+		t.Errorf("tokens requested = %d, want the static token used", f.tokens)
+	}
+
+	client = f.client(t, Options{Token: "stale-token"})
+	_, err := client.GetSignature()
+	if err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("GetSignature() error = %v, want the message of PIA", err)
+	}
+}
+
+func TestClient_RejectedCredentials(t *testing.T) {
+	f := newFakePIA(t)
+	client := f.client(t, Options{Username: "p1234567", Password: "wrong"})
+	if _, err := client.GetSignature(); err == nil || !strings.Contains(err.Error(), "rejected the credentials") {
+		t.Errorf("GetSignature() error = %v, want rejected credentials", err)
+	}
+}
+
+func TestNewClient_Invalid(t *testing.T) {
+	for name, args := range map[string]struct {
+		hostname, gateway string
+		opts              Options
+	}{
+		"hostname":    {"", "10.0.0.1", Options{Token: "t", InsecureSkipVerify: true}},
+		"credentials": {"example.com", "10.0.0.1", Options{InsecureSkipVerify: true}},
+		"CA":          {"example.com", "10.0.0.1", Options{Token: "t"}},
+		"gateway":     {"example.com", "gateway.example.com", Options{Token: "t", InsecureSkipVerify: true}},
+	} {
+		if _, err := NewClient(args.hostname, args.gateway, args.opts); err == nil {
+			t.Errorf("NewClient() with invalid %s error = nil", name)
+		}
+	}
+}
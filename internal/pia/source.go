@@ -0,0 +1,136 @@
+package pia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// keepAliveInterval is how often the port is bound again; PIA releases ports
+// that were not bound for longer
+const keepAliveInterval = 15 * time.Minute
+
+// retryDelay is how long the source waits after a failed keep-alive
+const retryDelay = 30 * time.Second
+
+// signatureRenewal is how long before its expiry a signature is replaced.
+// A new signature usually assigns a new port.
+const signatureRenewal = 24 * time.Hour
+
+// Source keeps a port forwarded by PIA and provides it as forwarded port.
+// It requests a signature, binds its port, and binds it again every 15
+// minutes. Signatures are replaced shortly before they expire, or when PIA
+// rejects binding them, e.g. after the server restarted.
+type Source struct {
+	client *Client
+
+	mu    sync.Mutex
+	sig   *Signature
+	bound bool
+	err   error
+}
+
+// NewSource creates a source forwarding a port with the client
+func NewSource(client *Client) *Source {
+	return &Source{client: client}
+}
+
+// ForwardedPort returns the forwarded port, or 0 until its first bind and
+// after its signature expired, with the error of the last attempt
+func (s *Source) ForwardedPort() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sig != nil && s.bound && time.Now().Before(s.sig.ExpiresAt) {
+		return s.sig.Port, nil
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return 0, nil
+}
+
+// Refresh binds the port, requesting a new signature first if there is none
+// or it is about to expire
+func (s *Source) Refresh() error {
+	err := s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = fmt.Errorf("failed to forward port: %w", err)
+		return s.err
+	}
+	s.err = nil
+	return nil
+}
+
+func (s *Source) refresh() error {
+	s.mu.Lock()
+	sig := s.sig
+	s.mu.Unlock()
+
+	if sig == nil || time.Until(sig.ExpiresAt) < signatureRenewal {
+		next, err := s.client.GetSignature()
+		if err != nil {
+			return err
+		}
+		slog.Info("PIA assigned port", "port", next.Port, "expires_at", next.ExpiresAt)
+		sig = &next
+		s.mu.Lock()
+		s.sig, s.bound = sig, false
+		s.mu.Unlock()
+	}
+
+	err := s.client.BindPort(*sig)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		// The signature is no longer accepted; the next attempt requests a
+		// new one
+		s.mu.Lock()
+		s.sig, s.bound = nil, false
+		s.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if !s.bound {
+		slog.Info("PIA port bound", "port", sig.Port)
+	}
+	s.bound = true
+	s.mu.Unlock()
+	return nil
+}
+
+// Run keeps the port bound until ctx is done, retrying failed attempts
+// sooner. The first signature is requested right away unless Refresh
+// already succeeded.
+func (s *Source) Run(ctx context.Context) {
+	for {
+		delay := s.nextRefresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err := s.Refresh(); err != nil {
+			slog.Warn("PIA port forwarding failed, retrying", "retry_delay", retryDelay, "error", err)
+		}
+	}
+}
+
+// nextRefresh returns how long to wait before the next attempt
+func (s *Source) nextRefresh() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.err != nil:
+		return retryDelay
+	case !s.bound:
+		return 0
+	default:
+		return keepAliveInterval
+	}
+}
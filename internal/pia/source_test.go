@@ -0,0 +1,81 @@
+package pia
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSource_Refresh(t *testing.T) {
+	f := newFakePIA(t)
+	source := NewSource(f.client(t, Options{Username: "p1234567", Password: "secret"}))
+
+	if port, err := source.ForwardedPort(); port != 0 || err != nil {
+		t.Errorf("ForwardedPort() before binding = %d, %v, want 0", port, err)
+	}
+	if got := source.nextRefresh(); got != 0 {
+		t.Errorf("nextRefresh() before binding = %v, want 0", got)
+	}
+	for range 2 {
+		if err := source.Refresh(); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+	}
+	if port, err := source.ForwardedPort(); port != 47000 || err != nil {
+		t.Errorf("ForwardedPort() = %d, %v, want 47000", port, err)
+	}
+	// The signature is reused for the keep-alive
+	if f.signatures != 1 || f.tokens != 1 || len(f.binds) != 2 {
+		t.Errorf("signatures, tokens, binds = %d, %d, %d, want 1, 1, 2", f.signatures, f.tokens, len(f.binds))
+	}
+	if got := source.nextRefresh(); got != keepAliveInterval {
+		t.Errorf("nextRefresh() = %v, want %v", got, keepAliveInterval)
+	}
+}
+
+func TestSource_RejectedBind(t *testing.T) {
+	f := newFakePIA(t)
+	source := NewSource(f.client(t, Options{Token: "fresh-token"}))
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	f.mu.Lock()
+	f.rejectBind = true
+	f.mu.Unlock()
+	if err := source.Refresh(); err == nil {
+		t.Fatal("Refresh() error = nil, want rejected bind")
+	}
+	if port, err := source.ForwardedPort(); port != 0 || err == nil {
+		t.Errorf("ForwardedPort() = %d, %v, want the error", port, err)
+	}
+	if got := source.nextRefresh(); got != retryDelay {
+		t.Errorf("nextRefresh() = %v, want %v", got, retryDelay)
+	}
+
+	// The next attempt gets a new signature with a new port
+	f.mu.Lock()
+	f.rejectBind = false
+	f.port = 48000
+	f.mu.Unlock()
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if port, _ := source.ForwardedPort(); port != 48000 {
+		t.Errorf("ForwardedPort() = %d, want 48000", port)
+	}
+}
+
+func TestSource_ExpiringSignature(t *testing.T) {
+	f := newFakePIA(t)
+	f.expiresAt = time.Now().Add(time.Hour)
+	source := NewSource(f.client(t, Options{Token: "fresh-token"}))
+
+	for range 2 {
+		if err := source.Refresh(); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+	}
+	if f.signatures != 2 {
+		t.Errorf("signatures = %d, want a new one for the expiring signature", f.signatures)
+	}
+}
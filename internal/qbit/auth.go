@@ -0,0 +1,79 @@
+package qbit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// AuthMethod selects how the client authenticates with qBittorrent
+type AuthMethod int
+
+const (
+	// AuthPassword logs in with the WebUI username and password and keeps
+	// the session cookie
+	AuthPassword AuthMethod = iota
+	// AuthBypass sends requests without credentials, for clients in a subnet
+	// whitelisted by "Bypass authentication for clients in whitelisted IP
+	// subnets"
+	AuthBypass
+	// AuthAPIKey sends the WebUI API key of qBittorrent 5.x as bearer token
+	AuthAPIKey
+)
+
+// ParseAuthMethod parses an authentication method; an empty name is password
+func ParseAuthMethod(name string) (AuthMethod, error) {
+	switch name {
+	case "", "password":
+		return AuthPassword, nil
+	case "bypass":
+		return AuthBypass, nil
+	case "apikey":
+		return AuthAPIKey, nil
+	}
+	return AuthPassword, fmt.Errorf("unknown auth method %q (expected password, bypass or apikey)", name)
+}
+
+func (m AuthMethod) String() string {
+	switch m {
+	case AuthBypass:
+		return "bypass"
+	case AuthAPIKey:
+		return "apikey"
+	default:
+		return "password"
+	}
+}
+
+// Auth holds the credentials of the authentication method. Username and
+// Password are used by AuthPassword, APIKey by AuthAPIKey.
+type Auth struct {
+	Method   AuthMethod
+	Username string
+	Password string
+	APIKey   string
+}
+
+// errAccessDenied means qBittorrent rejected a request that carries no
+// session to renew
+var errAccessDenied = errors.New("qBittorrent denied access")
+
+// authorize adds the credentials of the authentication method to req
+func (a Auth) authorize(req *http.Request) {
+	if a.Method == AuthAPIKey {
+		req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	}
+}
+
+// deniedError explains a 403 to a request without session, which logging in
+// again cannot fix
+func (a Auth) deniedError() error {
+	switch a.Method {
+	case AuthAPIKey:
+		return torrent.Permanent(fmt.Errorf("%w: the API key was rejected", errAccessDenied))
+	default:
+		return torrent.Permanent(fmt.Errorf("%w: this host is not in a subnet that bypasses authentication", errAccessDenied))
+	}
+}
@@ -0,0 +1,133 @@
+package qbit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+func TestParseAuthMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    AuthMethod
+		wantErr bool
+	}{
+		{name: "", want: AuthPassword},
+		{name: "password", want: AuthPassword},
+		{name: "bypass", want: AuthBypass},
+		{name: "apikey", want: AuthAPIKey},
+		{name: "token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAuthMethod(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAuthMethod(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAuthMethod(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newAuthServer fakes a qBittorrent that accepts requests for which allow
+// returns true, and counts the login attempts
+func newAuthServer(t *testing.T, allow func(r *http.Request) bool) (*httptest.Server, *int) {
+	t.Helper()
+	logins := new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/auth/login" {
+			*logins++
+			_, _ = w.Write([]byte("Ok."))
+			return
+		}
+		if !allow(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/app/version":
+			_, _ = w.Write([]byte("v5.2.0"))
+		case "/api/v2/app/preferences":
+			_, _ = w.Write([]byte(`{"listen_port":6881}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, logins
+}
+
+func TestClient_Bypass(t *testing.T) {
+	server, logins := newAuthServer(t, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == ""
+	})
+
+	client, err := NewClientWithOptions(server.URL, Options{Auth: Auth{Method: AuthBypass}})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v, want nil", err)
+	}
+	port, err := client.GetPort()
+	if err != nil || port != 6881 {
+		t.Errorf("GetPort() = %d, %v, want 6881, nil", port, err)
+	}
+	if err := client.Login(); err != nil {
+		t.Errorf("Login() error = %v, want nil", err)
+	}
+	if *logins != 0 {
+		t.Errorf("login attempts = %d, want 0", *logins)
+	}
+}
+
+func TestClient_APIKey(t *testing.T) {
+	server, logins := newAuthServer(t, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer qbt_key"
+	})
+
+	client, err := NewClientWithOptions(server.URL, Options{Auth: Auth{Method: AuthAPIKey, APIKey: "qbt_key"}})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v, want nil", err)
+	}
+	port, err := client.GetPort()
+	if err != nil || port != 6881 {
+		t.Errorf("GetPort() = %d, %v, want 6881, nil", port, err)
+	}
+	if *logins != 0 {
+		t.Errorf("login attempts = %d, want 0", *logins)
+	}
+}
+
+func TestClient_AccessDenied(t *testing.T) {
+	tests := []struct {
+		name string
+		auth Auth
+	}{
+		{name: "bypass", auth: Auth{Method: AuthBypass}},
+		{name: "api key", auth: Auth{Method: AuthAPIKey, APIKey: "wrong"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server, logins := newAuthServer(t, func(r *http.Request) bool {
+				requests++
+				return false
+			})
+
+			_, err := NewClientWithOptions(server.URL, Options{Auth: tt.auth})
+			if !errors.Is(err, errAccessDenied) {
+				t.Fatalf("NewClientWithOptions() error = %v, want access denied", err)
+			}
+			if !torrent.IsPermanent(err) {
+				t.Errorf("error %v is retried, want permanent", err)
+			}
+			if requests != 1 || *logins != 0 {
+				t.Errorf("requests = %d, logins = %d, want 1 request without login", requests, *logins)
+			}
+		})
+	}
+}
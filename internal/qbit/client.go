@@ -2,61 +2,115 @@ package qbit
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
-	"time"
+	"sync"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
 )
 
+// Client talks to the qBittorrent WebUI API. With password authentication,
+// the session cookie of the login is kept in a cookie jar and reused by all
+// requests; when qBittorrent rejects it with 403, e.g. after a restart, the
+// client logs in again.
 type Client struct {
 	baseURL string
-	user    string
-	pass    string
+	auth    Auth
 	client  *http.Client
+	retry   torrent.RetryPolicy
+	// portPreferences are written along with every listening port
+	portPreferences map[string]any
+
+	// sessionMu serializes logins. session counts them, so that concurrent
+	// requests rejected with the same session log in again only once.
+	sessionMu sync.Mutex
+	session   uint64
 }
 
 type Preferences struct {
 	ListenPort int `json:"listen_port"`
 }
 
-const (
-	defaultHTTPTimeout   = 10 * time.Second
-	requestRetryAttempts = 3
-)
-
-var requestRetryDelay = 2 * time.Second
+// Options configure how the client connects to qBittorrent
+type Options struct {
+	Auth      Auth
+	Transport torrent.TransportOptions
+}
 
+// NewClient creates a client that logs in with the username and password
 func NewClient(baseURL, user, pass string) (*Client, error) {
+	return NewClientWithOptions(baseURL, Options{Auth: Auth{Method: AuthPassword, Username: user, Password: pass}})
+}
+
+// NewClientWithOptions creates a client that connects and authenticates as
+// set in opts. It logs in or, for authentication methods without login,
+// checks that qBittorrent accepts requests.
+func NewClientWithOptions(baseURL string, opts Options) (*Client, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
 	}
 
+	httpClient := torrent.NewHTTPClient(opts.Transport)
+	httpClient.Jar = jar
+
+	auth := opts.Auth
 	client := &Client{
 		baseURL: strings.TrimRight(baseURL, "/"),
-		user:    user,
-		pass:    pass,
-		client: &http.Client{
-			Jar:     jar,
-			Timeout: defaultHTTPTimeout,
-		},
+		auth:    auth,
+		client:  httpClient,
+		retry:   torrent.DefaultRetryPolicy,
 	}
 
-	if err := client.Login(); err != nil {
+	// The caller retries the initial login, e.g. until qBittorrent has started
+	if auth.Method != AuthPassword {
+		if err := client.PingOnce(); err != nil {
+			return nil, fmt.Errorf("initial request failed: %w", err)
+		}
+		return client, nil
+	}
+	if err := client.login(); err != nil {
 		return nil, fmt.Errorf("initial login failed: %w", err)
 	}
 
 	return client, nil
 }
 
+// SetRetryPolicy changes how failed requests to qBittorrent are retried. It
+// must be called before the client is shared.
+func (c *Client) SetRetryPolicy(policy torrent.RetryPolicy) {
+	c.retry = policy
+}
+
+// Login authenticates with qBittorrent, retrying failed attempts. Rejected
+// credentials are not retried, since qBittorrent bans clients after a few
+// failed logins. Authentication methods without login have nothing to do.
 func (c *Client) Login() error {
+	if c.auth.Method != AuthPassword {
+		return nil
+	}
+	return c.retry.Run("login", c.login)
+}
+
+// login makes a single login attempt
+func (c *Client) login() error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.loginLocked()
+}
+
+// loginLocked makes a single login attempt with c.sessionMu held
+func (c *Client) loginLocked() error {
 	data := url.Values{}
-	data.Set("username", c.user)
-	data.Set("password", c.pass)
+	data.Set("username", c.auth.Username)
+	data.Set("password", c.auth.Password)
 
 	resp, err := c.client.PostForm(c.baseURL+"/api/v2/auth/login", data)
 	if err != nil {
@@ -66,44 +120,74 @@ func (c *Client) Login() error {
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK || string(body) != "Ok." {
-		return fmt.Errorf("login failed: status %d, body: %s", resp.StatusCode, string(body))
+		err := fmt.Errorf("login failed: status %d, body: %s", resp.StatusCode, string(body))
+		// qBittorrent answers 200 with "Fails." to wrong credentials and 403
+		// to banned clients; anything else may be a server still starting
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusForbidden {
+			return torrent.Permanent(err)
+		}
+		return err
 	}
 
+	c.session++
 	slog.Debug("successfully authenticated with qBittorrent")
 	return nil
 }
 
+// currentSession returns the number of the current login
+func (c *Client) currentSession() uint64 {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.session
+}
+
+// relogin logs in again after a request with the given session was rejected,
+// unless another request has already done so
+func (c *Client) relogin(session uint64) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.session != session {
+		return nil
+	}
+	slog.Warn("received 403 from qBittorrent, re-authenticating...")
+	return c.loginLocked()
+}
+
 func (c *Client) GetPort() (int, error) {
-	var lastErr error
-	for attempt := 1; attempt <= requestRetryAttempts; attempt++ {
+	var port int
+	err := c.retry.Run("get port", func() error {
 		resp, err := c.doGet(c.baseURL + "/api/v2/app/preferences")
 		if err != nil {
-			lastErr = fmt.Errorf("failed to get preferences: %w", err)
-		} else {
-			port, decodeErr := decodePreferences(resp)
-			if decodeErr == nil {
-				return port, nil
-			}
-			lastErr = decodeErr
-		}
-
-		if attempt < requestRetryAttempts {
-			slog.Warn("get port failed, retrying",
-				"attempt", attempt,
-				"max_attempts", requestRetryAttempts,
-				"error", lastErr,
-			)
-			time.Sleep(requestRetryDelay)
+			return fmt.Errorf("failed to get preferences: %w", err)
 		}
+		port, err = decodePreferences(resp)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
+	return port, nil
+}
+
+// ErrPortNotApplied means qBittorrent accepted a new listening port but kept
+// the previous one
+var ErrPortNotApplied = errors.New("qBittorrent did not apply the listening port")
 
-	return 0, fmt.Errorf("failed to get preferences after %d attempts: %w", requestRetryAttempts, lastErr)
+// SetPortPreferences sets preferences that are written along with every
+// listening port, e.g. to keep qBittorrent from changing the port itself. It
+// must be called before the client is shared.
+func (c *Client) SetPortPreferences(prefs map[string]any) {
+	c.portPreferences = prefs
 }
 
+// SetPort sets the listening port, together with the preferences of
+// SetPortPreferences, and reads the preferences back to verify that
+// qBittorrent applied it. A port that was not applied is retried like a
+// failed request.
 func (c *Client) SetPort(port int) error {
-	prefsJSON := map[string]int{
-		"listen_port": port,
-	}
+	prefsJSON := make(map[string]any, len(c.portPreferences)+1)
+	maps.Copy(prefsJSON, c.portPreferences)
+	prefsJSON["listen_port"] = port
 
 	jsonBytes, err := json.Marshal(prefsJSON)
 	if err != nil {
@@ -113,41 +197,134 @@ func (c *Client) SetPort(port int) error {
 	data := url.Values{}
 	data.Set("json", string(jsonBytes))
 
-	var lastErr error
-	for attempt := 1; attempt <= requestRetryAttempts; attempt++ {
+	return c.retry.Run("set port", func() error {
 		resp, err := c.doPostForm(c.baseURL+"/api/v2/app/setPreferences", data)
 		if err != nil {
-			lastErr = fmt.Errorf("failed to set preferences: %w", err)
-		} else {
-			body, readErr := io.ReadAll(resp.Body)
-			closeResponseBody(resp)
-
-			if resp.StatusCode == http.StatusOK {
-				slog.Info("successfully updated qBittorrent listening port", "port", port)
-				return nil
-			}
+			return fmt.Errorf("failed to set preferences: %w", err)
+		}
+		body, readErr := io.ReadAll(resp.Body)
+		closeResponseBody(resp)
 
-			if readErr != nil {
-				lastErr = fmt.Errorf("unexpected status code: %d, body read error: %w", resp.StatusCode, readErr)
-			} else {
-				lastErr = fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+		if resp.StatusCode == http.StatusOK {
+			if err := c.verifyPort(port); err != nil {
+				return err
 			}
+			slog.Info("successfully updated qBittorrent listening port", "port", port)
+			return nil
 		}
+		if readErr != nil {
+			return fmt.Errorf("unexpected status code: %d, body read error: %w", resp.StatusCode, readErr)
+		}
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	})
+}
+
+// verifyPort reads the preferences back to check that the port was applied
+func (c *Client) verifyPort(port int) error {
+	resp, err := c.doGet(c.baseURL + "/api/v2/app/preferences")
+	if err != nil {
+		return fmt.Errorf("failed to verify port: %w", err)
+	}
+	applied, err := decodePreferences(resp)
+	if err != nil {
+		return fmt.Errorf("failed to verify port: %w", err)
+	}
+	if applied != port {
+		return fmt.Errorf("%w: listen_port is %d instead of %d", ErrPortNotApplied, applied, port)
+	}
+	return nil
+}
+
+// ConnectionStatus returns the connection status of qBittorrent, one of the
+// torrent.Connection constants. It makes a single attempt, since the status is
+// checked periodically anyway.
+func (c *Client) ConnectionStatus() (string, error) {
+	resp, err := c.doGet(c.baseURL + "/api/v2/transfer/info")
+	if err != nil {
+		return "", fmt.Errorf("failed to get transfer info: %w", err)
+	}
+	defer closeResponseBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var info struct {
+		ConnectionStatus string `json:"connection_status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to decode transfer info: %w", err)
+	}
+	return info.ConnectionStatus, nil
+}
 
-		if attempt < requestRetryAttempts {
-			slog.Warn("set port failed, retrying",
-				"attempt", attempt,
-				"max_attempts", requestRetryAttempts,
-				"error", lastErr,
-			)
-			time.Sleep(requestRetryDelay)
+// Reannounce makes qBittorrent announce torrents to their trackers now, e.g.
+// so that they learn a new listening port. With activeOnly, only the active
+// torrents are announced; otherwise all of them.
+func (c *Client) Reannounce(activeOnly bool) error {
+	hashes := "all"
+	if activeOnly {
+		active, err := c.activeTorrents()
+		if err != nil {
+			return err
+		}
+		if len(active) == 0 {
+			return nil
 		}
+		hashes = strings.Join(active, "|")
 	}
 
-	return fmt.Errorf("failed to set qBittorrent port after %d attempts: %w", requestRetryAttempts, lastErr)
+	data := url.Values{}
+	data.Set("hashes", hashes)
+	return c.retry.Run("reannounce", func() error {
+		resp, err := c.doPostForm(c.baseURL+"/api/v2/torrents/reannounce", data)
+		if err != nil {
+			return fmt.Errorf("failed to reannounce torrents: %w", err)
+		}
+		defer closeResponseBody(resp)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		return nil
+	})
 }
 
+// activeTorrents returns the hashes of the torrents that are transferring
+func (c *Client) activeTorrents() ([]string, error) {
+	var hashes []string
+	err := c.retry.Run("list active torrents", func() error {
+		resp, err := c.doGet(c.baseURL + "/api/v2/torrents/info?filter=active")
+		if err != nil {
+			return fmt.Errorf("failed to list torrents: %w", err)
+		}
+		defer closeResponseBody(resp)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+
+		var torrents []struct {
+			Hash string `json:"hash"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
+			return fmt.Errorf("failed to decode torrents: %w", err)
+		}
+		hashes = make([]string, 0, len(torrents))
+		for _, torrent := range torrents {
+			hashes = append(hashes, torrent.Hash)
+		}
+		return nil
+	})
+	return hashes, err
+}
+
+// Ping checks that qBittorrent is reachable, retrying failed attempts so that
+// a brief restart is not reported as an outage
 func (c *Client) Ping() error {
+	return c.retry.Run("ping", c.PingOnce)
+}
+
+// PingOnce checks that qBittorrent is reachable with a single attempt, for
+// checks that report the current state such as readiness probes
+func (c *Client) PingOnce() error {
 	resp, err := c.doGet(c.baseURL + "/api/v2/app/version")
 	if err != nil {
 		return fmt.Errorf("ping failed: %w", err)
@@ -162,26 +339,38 @@ func (c *Client) Ping() error {
 }
 
 func (c *Client) doGet(path string) (*http.Response, error) {
-	resp, err := c.client.Get(path)
-	if err != nil {
-		return nil, err
-	}
+	return c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, path, nil)
+	})
+}
 
-	if resp.StatusCode != http.StatusForbidden {
-		return resp, nil
-	}
+func (c *Client) doPostForm(path string, data url.Values) (*http.Response, error) {
+	return c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+}
 
-	closeResponseBody(resp)
-	slog.Warn("received 403 from qBittorrent, re-authenticating...")
-	if err := c.Login(); err != nil {
-		return nil, fmt.Errorf("re-authentication failed: %w", err)
+// do sends the request built by newRequest with the current session, logging
+// in again and resending it once if qBittorrent rejects the session. Without
+// password authentication there is no session to renew, so a rejection fails
+// the request.
+func (c *Client) do(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		c.auth.authorize(req)
+		return c.client.Do(req)
 	}
 
-	return c.client.Get(path)
-}
-
-func (c *Client) doPostForm(path string, data url.Values) (*http.Response, error) {
-	resp, err := c.client.PostForm(path, data)
+	session := c.currentSession()
+	resp, err := send()
 	if err != nil {
 		return nil, err
 	}
@@ -191,12 +380,14 @@ func (c *Client) doPostForm(path string, data url.Values) (*http.Response, error
 	}
 
 	closeResponseBody(resp)
-	slog.Warn("received 403 from qBittorrent, re-authenticating...")
-	if err := c.Login(); err != nil {
+	if c.auth.Method != AuthPassword {
+		return nil, c.auth.deniedError()
+	}
+	if err := c.relogin(session); err != nil {
 		return nil, fmt.Errorf("re-authentication failed: %w", err)
 	}
 
-	return c.client.PostForm(path, data)
+	return send()
 }
 
 func decodePreferences(resp *http.Response) (int, error) {
@@ -2,11 +2,17 @@ package qbit
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
 )
 
 func TestNewClient_Success(t *testing.T) {
@@ -69,8 +75,7 @@ func TestLogin_Failure(t *testing.T) {
 	jar, _ := cookiejar.New(nil)
 	client := &Client{
 		baseURL: server.URL,
-		user:    "admin",
-		pass:    "admin",
+		auth:    Auth{Username: "admin", Password: "admin"},
 		client: &http.Client{
 			Jar: jar,
 		},
@@ -150,9 +155,6 @@ func TestGetPort_Reauthentication(t *testing.T) {
 }
 
 func TestGetPort_RetryOnServerError(t *testing.T) {
-	origDelay := requestRetryDelay
-	requestRetryDelay = 10 * time.Millisecond
-	defer func() { requestRetryDelay = origDelay }()
 	callCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/api/v2/auth/login" {
@@ -176,6 +178,7 @@ func TestGetPort_RetryOnServerError(t *testing.T) {
 	defer server.Close()
 
 	client, _ := NewClient(server.URL, "admin", "admin")
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond})
 	port, err := client.GetPort()
 	if err != nil {
 		t.Fatalf("GetPort() error = %v, want nil", err)
@@ -222,6 +225,10 @@ func TestSetPort_Success(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if r.URL.Path == "/api/v2/app/preferences" {
+			_ = json.NewEncoder(w).Encode(Preferences{ListenPort: receivedPort})
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer server.Close()
@@ -254,6 +261,10 @@ func TestSetPort_Reauthentication(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if r.URL.Path == "/api/v2/app/preferences" {
+			_ = json.NewEncoder(w).Encode(Preferences{ListenPort: 8888})
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer server.Close()
@@ -269,10 +280,6 @@ func TestSetPort_Reauthentication(t *testing.T) {
 }
 
 func TestSetPort_RetryOnServerError(t *testing.T) {
-	origDelay := requestRetryDelay
-	requestRetryDelay = 10 * time.Millisecond
-	defer func() { requestRetryDelay = origDelay }()
-
 	callCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/api/v2/auth/login" {
@@ -289,11 +296,16 @@ func TestSetPort_RetryOnServerError(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if r.URL.Path == "/api/v2/app/preferences" {
+			_ = json.NewEncoder(w).Encode(Preferences{ListenPort: 7777})
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer server.Close()
 
 	client, _ := NewClient(server.URL, "admin", "admin")
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond})
 	err := client.SetPort(7777)
 	if err != nil {
 		t.Fatalf("SetPort() error = %v, want nil", err)
@@ -327,6 +339,7 @@ func TestPing_Success(t *testing.T) {
 }
 
 func TestPing_Failure(t *testing.T) {
+	versionCalls := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/api/v2/auth/login" {
 			w.WriteHeader(http.StatusOK)
@@ -334,6 +347,7 @@ func TestPing_Failure(t *testing.T) {
 			return
 		}
 		if r.URL.Path == "/api/v2/app/version" {
+			versionCalls++
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -342,10 +356,22 @@ func TestPing_Failure(t *testing.T) {
 	defer server.Close()
 
 	client, _ := NewClient(server.URL, "admin", "admin")
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
 	err := client.Ping()
 	if err == nil {
 		t.Error("Ping() error = nil, want error")
 	}
+	if versionCalls != 3 {
+		t.Errorf("Ping() attempts = %d, want 3", versionCalls)
+	}
+
+	versionCalls = 0
+	if err := client.PingOnce(); err == nil {
+		t.Error("PingOnce() error = nil, want error")
+	}
+	if versionCalls != 1 {
+		t.Errorf("PingOnce() attempts = %d, want 1", versionCalls)
+	}
 }
 
 func TestPing_Reauthentication(t *testing.T) {
@@ -393,8 +419,281 @@ func TestPing_ReauthenticationFailure(t *testing.T) {
 	defer server.Close()
 
 	client, _ := NewClient(server.URL, "admin", "admin")
-	err := client.Ping()
+	err := client.PingOnce()
+	if err == nil {
+		t.Error("PingOnce() error = nil, want error when repeated 403")
+	}
+}
+
+func TestClient_ReusesSession(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	// validSID is the session qBittorrent accepts; resetting it simulates a
+	// qBittorrent restart
+	validSID := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/api/v2/auth/login" {
+			logins++
+			validSID = strconv.Itoa(logins)
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: validSID, Path: "/"})
+			_, _ = w.Write([]byte("Ok."))
+			return
+		}
+		if cookie, err := r.Cookie("SID"); err != nil || cookie.Value != validSID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("v4.6.0"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "admin", "admin")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	for range 3 {
+		if err := client.PingOnce(); err != nil {
+			t.Fatalf("PingOnce() error = %v", err)
+		}
+	}
+	if logins != 1 {
+		t.Fatalf("logins = %d, want the session of the initial login reused", logins)
+	}
+
+	mu.Lock()
+	validSID = ""
+	mu.Unlock()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			if err := client.PingOnce(); err != nil {
+				t.Errorf("PingOnce() error = %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if logins != 2 {
+		t.Errorf("logins = %d, want a single re-login for the concurrent requests", logins)
+	}
+}
+
+func TestSetPort_NotApplied(t *testing.T) {
+	setCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/app/setPreferences":
+			setCalls++
+		case "/api/v2/app/preferences":
+			// qBittorrent keeps the old port until the second attempt
+			port := 6881
+			if setCalls > 1 {
+				port = 7777
+			}
+			_ = json.NewEncoder(w).Encode(Preferences{ListenPort: port})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "admin", "admin")
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	if err := client.SetPort(7777); err != nil {
+		t.Fatalf("SetPort() error = %v, want nil after the retry", err)
+	}
+	if setCalls != 2 {
+		t.Errorf("SetPort() call count = %d, want 2", setCalls)
+	}
+
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 1})
+	err := client.SetPort(9999)
+	if !errors.Is(err, ErrPortNotApplied) {
+		t.Errorf("SetPort() error = %v, want ErrPortNotApplied", err)
+	}
+}
+
+func TestReannounce(t *testing.T) {
+	tests := []struct {
+		name       string
+		activeOnly bool
+		active     []string
+		want       string
+	}{
+		{name: "all torrents", want: "all"},
+		{name: "active torrents", activeOnly: true, active: []string{"abc", "def"}, want: "abc|def"},
+		{name: "no active torrents", activeOnly: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reannounced string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v2/auth/login":
+					_, _ = w.Write([]byte("Ok."))
+				case "/api/v2/torrents/info":
+					if filter := r.URL.Query().Get("filter"); filter != "active" {
+						t.Errorf("filter = %q, want active", filter)
+					}
+					torrents := []map[string]string{}
+					for _, hash := range tt.active {
+						torrents = append(torrents, map[string]string{"hash": hash, "name": "torrent " + hash})
+					}
+					_ = json.NewEncoder(w).Encode(torrents)
+				case "/api/v2/torrents/reannounce":
+					if r.Method != http.MethodPost {
+						t.Errorf("method = %s, want POST", r.Method)
+					}
+					reannounced = r.FormValue("hashes")
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			client, _ := NewClient(server.URL, "admin", "admin")
+			if err := client.Reannounce(tt.activeOnly); err != nil {
+				t.Fatalf("Reannounce() error = %v, want nil", err)
+			}
+			if reannounced != tt.want {
+				t.Errorf("reannounced hashes = %q, want %q", reannounced, tt.want)
+			}
+		})
+	}
+}
+
+func TestReannounce_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/auth/login" {
+			_, _ = w.Write([]byte("Ok."))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "admin", "admin")
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	if err := client.Reannounce(false); err == nil {
+		t.Error("Reannounce() error = nil, want error")
+	}
+	if err := client.Reannounce(true); err == nil {
+		t.Error("Reannounce(active) error = nil, want error")
+	}
+}
+
+func TestSetPort_PortPreferences(t *testing.T) {
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/app/setPreferences":
+			if err := json.Unmarshal([]byte(r.FormValue("json")), &received); err != nil {
+				t.Errorf("json.Unmarshal error: %v", err)
+			}
+		case "/api/v2/app/preferences":
+			_ = json.NewEncoder(w).Encode(received)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "admin", "admin")
+	client.SetPortPreferences(map[string]any{"upnp": false, "random_port": false})
+	if err := client.SetPort(9999); err != nil {
+		t.Fatalf("SetPort() error = %v, want nil", err)
+	}
+
+	want := map[string]any{"listen_port": float64(9999), "upnp": false, "random_port": false}
+	if len(received) != len(want) {
+		t.Fatalf("preferences = %v, want %v", received, want)
+	}
+	for key, value := range want {
+		if received[key] != value {
+			t.Errorf("preferences[%q] = %v, want %v", key, received[key], value)
+		}
+	}
+}
+
+func TestConnectionStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/transfer/info":
+			_, _ = w.Write([]byte(`{"connection_status":"firewalled","dht_nodes":120}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "admin", "admin")
+	status, err := client.ConnectionStatus()
+	if err != nil {
+		t.Fatalf("ConnectionStatus() error = %v, want nil", err)
+	}
+	if status != torrent.ConnectionFirewalled {
+		t.Errorf("ConnectionStatus() = %q, want %q", status, torrent.ConnectionFirewalled)
+	}
+}
+
+func TestLogin_RetriesUnavailableServer(t *testing.T) {
+	loginAttempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loginAttempts++
+		if loginAttempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("Ok."))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "admin", "admin")
 	if err == nil {
-		t.Error("Ping() error = nil, want error when repeated 403")
+		t.Fatal("NewClient() error = nil, want the failed initial login")
+	}
+
+	client = &Client{baseURL: server.URL, client: server.Client(), retry: torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	loginAttempts = 0
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if loginAttempts != 2 {
+		t.Errorf("Login() attempts = %d, want 2", loginAttempts)
+	}
+}
+
+func TestNewClientWithOptions_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the target
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write([]byte("Ok."))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := torrent.ParseProxyURL(proxy.URL)
+	if err != nil {
+		t.Fatalf("ParseProxyURL() error = %v", err)
+	}
+	client, err := NewClientWithOptions("http://qbittorrent.internal:8080", Options{Transport: torrent.TransportOptions{Proxy: proxyURL}})
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() error = %v", err)
+	}
+	if err := client.PingOnce(); err != nil {
+		t.Fatalf("PingOnce() error = %v", err)
+	}
+
+	want := []string{"http://qbittorrent.internal:8080/api/v2/auth/login", "http://qbittorrent.internal:8080/api/v2/app/version"}
+	if !slices.Equal(proxied, want) {
+		t.Errorf("proxied requests = %v, want %v", proxied, want)
 	}
 }
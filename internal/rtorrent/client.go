@@ -0,0 +1,275 @@
+package rtorrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// Client talks to rTorrent over XML-RPC, either directly on its SCGI socket
+// or through the HTTP endpoint of a web server or ruTorrent, usually /RPC2
+type Client struct {
+	post     func(body []byte) ([]byte, error)
+	retry    torrent.RetryPolicy
+	settings PortSettings
+}
+
+// Options configure how the client connects to rTorrent. Username and
+// Password are sent with basic authentication to an HTTP endpoint; the
+// SCGI socket has no authentication. Of Transport, SCGI only uses the
+// timeouts.
+type Options struct {
+	Username  string
+	Password  string
+	Transport torrent.TransportOptions
+}
+
+// PortSettings are applied along with every port
+type PortSettings struct {
+	// StaticPort turns off network.port_random, which would pick another
+	// port from the range at startup
+	StaticPort bool
+	// BindAddress is written to network.bind_address to make rTorrent
+	// listen on the new port; empty keeps the current address
+	BindAddress string
+}
+
+// ErrPortNotApplied means rTorrent accepted a new port range but kept the
+// previous one
+var ErrPortNotApplied = errors.New("rTorrent did not apply the port range")
+
+// NewClient creates a client for rTorrent and checks that it accepts
+// requests. rawURL is scgi://host:port or scgi:///path/to/socket for the
+// SCGI socket, or the http(s) URL of an XML-RPC endpoint.
+func NewClient(rawURL string, opts Options) (*Client, error) {
+	post, err := newPoster(rawURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{post: post, retry: torrent.DefaultRetryPolicy}
+
+	// The caller retries the initial request, e.g. until rTorrent has started
+	if err := client.PingOnce(); err != nil {
+		return nil, fmt.Errorf("initial request failed: %w", err)
+	}
+	return client, nil
+}
+
+// newPoster returns the function that sends XML-RPC calls to rawURL
+func newPoster(rawURL string, opts Options) (func([]byte) ([]byte, error), error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rTorrent URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "scgi":
+		scgi := &scgiTransport{
+			network:     "tcp",
+			address:     u.Host,
+			timeout:     opts.Transport.Timeout,
+			dialTimeout: opts.Transport.DialTimeout,
+		}
+		if scgi.timeout <= 0 {
+			scgi.timeout = torrent.DefaultTimeout
+		}
+		if scgi.dialTimeout <= 0 {
+			scgi.dialTimeout = torrent.DefaultDialTimeout
+		}
+		if u.Host == "" {
+			if u.Path == "" {
+				return nil, fmt.Errorf("invalid rTorrent URL %q: missing host or socket path", rawURL)
+			}
+			scgi.network, scgi.address = "unix", u.Path
+		}
+		return scgi.post, nil
+	case "http", "https":
+		transport := &httpTransport{
+			url:      u.String(),
+			username: opts.Username,
+			password: opts.Password,
+			client:   torrent.NewHTTPClient(opts.Transport),
+		}
+		return transport.post, nil
+	}
+	return nil, fmt.Errorf("invalid rTorrent URL %q: scheme must be scgi, http or https", rawURL)
+}
+
+// SetRetryPolicy changes how failed requests to rTorrent are retried. It
+// must be called before the client is shared.
+func (c *Client) SetRetryPolicy(policy torrent.RetryPolicy) {
+	c.retry = policy
+}
+
+// SetPortSettings sets what is applied along with every port. It must be
+// called before the client is shared.
+func (c *Client) SetPortSettings(settings PortSettings) {
+	c.settings = settings
+}
+
+// GetPort returns the first port of network.port_range
+func (c *Client) GetPort() (int, error) {
+	var port int
+	err := c.retry.Run("get port", func() error {
+		var err error
+		port, err = c.portRange()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// SetPort sets network.port_range to the single port, rebinds the listening
+// socket by writing network.bind_address, and reads the range back to
+// verify that rTorrent applied it. A port that was not applied is retried
+// like a failed request.
+func (c *Client) SetPort(port int) error {
+	portRange := fmt.Sprintf("%d-%d", port, port)
+	return c.retry.Run("set port", func() error {
+		if c.settings.StaticPort {
+			if _, err := c.call("network.port_random.set", "", 0); err != nil {
+				return fmt.Errorf("failed to disable random port: %w", err)
+			}
+		}
+		if _, err := c.call("network.port_range.set", "", portRange); err != nil {
+			return fmt.Errorf("failed to set port range: %w", err)
+		}
+		if err := c.rebind(); err != nil {
+			return err
+		}
+
+		applied, err := c.portRange()
+		if err != nil {
+			return fmt.Errorf("failed to verify port: %w", err)
+		}
+		if applied != port {
+			return fmt.Errorf("%w: network.port_range starts at %d instead of %d", ErrPortNotApplied, applied, port)
+		}
+		slog.Info("successfully updated rTorrent port range", "port", port)
+		return nil
+	})
+}
+
+// rebind writes the bind address, which makes rTorrent open its listening
+// socket again on the port range
+func (c *Client) rebind() error {
+	address := c.settings.BindAddress
+	if address == "" {
+		current, err := c.call("network.bind_address")
+		if err != nil {
+			return fmt.Errorf("failed to get bind address: %w", err)
+		}
+		address = current
+	}
+	if address == "" {
+		address = "0.0.0.0"
+	}
+	if _, err := c.call("network.bind_address.set", "", address); err != nil {
+		return fmt.Errorf("failed to rebind listening socket: %w", err)
+	}
+	return nil
+}
+
+// portRange reads the first port of network.port_range with a single attempt
+func (c *Client) portRange() (int, error) {
+	value, err := c.call("network.port_range")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get port range: %w", err)
+	}
+	return parsePortRange(value)
+}
+
+// parsePortRange returns the first port of a range such as "6000-6010"
+func parsePortRange(value string) (int, error) {
+	first, _, _ := strings.Cut(value, "-")
+	port, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port range %q", value)
+	}
+	return port, nil
+}
+
+// Ping checks that rTorrent is reachable, retrying failed attempts so that a
+// brief restart is not reported as an outage
+func (c *Client) Ping() error {
+	return c.retry.Run("ping", c.PingOnce)
+}
+
+// PingOnce checks that rTorrent is reachable with a single attempt, for
+// checks that report the current state such as readiness probes
+func (c *Client) PingOnce() error {
+	if _, err := c.call("system.client_version"); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+// call invokes an XML-RPC method and returns its result as text
+func (c *Client) call(method string, params ...any) (string, error) {
+	body, err := encodeCall(method, params...)
+	if err != nil {
+		return "", err
+	}
+	response, err := c.post(body)
+	if err != nil {
+		return "", err
+	}
+	return decodeResponse(response)
+}
+
+// httpTransport sends XML-RPC calls to an HTTP endpoint
+type httpTransport struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func (t *httpTransport) post(body []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeResponseBody(resp)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(data))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, torrent.Permanent(err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, nil
+}
+
+func closeResponseBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+}
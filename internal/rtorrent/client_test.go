@@ -0,0 +1,261 @@
+package rtorrent
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// fakeRTorrent emulates the XML-RPC commands of rTorrent used by the client
+type fakeRTorrent struct {
+	mu          sync.Mutex
+	portRange   string
+	bindAddress string
+	// ignorePort keeps network.port_range.set from changing portRange
+	ignorePort bool
+	calls      []string
+}
+
+// handle answers an XML-RPC method call
+func (f *fakeRTorrent) handle(body []byte) string {
+	var call struct {
+		Method string     `xml:"methodName"`
+		Params []xmlValue `xml:"params>param>value"`
+	}
+	if err := xml.Unmarshal(body, &call); err != nil {
+		return fault(-503, err.Error())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call.Method)
+	param := func(i int) string {
+		if i < len(call.Params) {
+			return call.Params[i].text()
+		}
+		return ""
+	}
+
+	switch call.Method {
+	case "system.client_version":
+		return result("<string>0.9.8</string>")
+	case "network.port_range":
+		return result("<string>" + f.portRange + "</string>")
+	case "network.port_range.set":
+		if !f.ignorePort {
+			f.portRange = param(1)
+		}
+		return result("<i8>0</i8>")
+	case "network.bind_address":
+		return result("<string>" + f.bindAddress + "</string>")
+	case "network.bind_address.set":
+		f.bindAddress = param(1)
+		return result("<i8>0</i8>")
+	case "network.port_random.set":
+		return result("<i8>0</i8>")
+	}
+	return fault(-506, "Method '"+call.Method+"' not defined")
+}
+
+func result(value string) string {
+	return xml.Header + "<methodResponse><params><param><value>" + value + "</value></param></params></methodResponse>"
+}
+
+func fault(code int, message string) string {
+	return xml.Header + "<methodResponse><fault><value><struct>" +
+		"<member><name>faultCode</name><value><i4>" + strconv.Itoa(code) + "</i4></value></member>" +
+		"<member><name>faultString</name><value><string>" + message + "</string></value></member>" +
+		"</struct></value></fault></methodResponse>"
+}
+
+// serveSCGI serves the fake on an SCGI socket and returns its URL
+func serveSCGI(t *testing.T, fake *fakeRTorrent) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				body, err := readSCGIRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				response := fake.handle(body)
+				_, _ = fmt.Fprintf(conn, "Status: 200 OK\r\nContent-Type: text/xml\r\nContent-Length: %d\r\n\r\n%s", len(response), response)
+			}()
+		}
+	}()
+	return "scgi://" + listener.Addr().String()
+}
+
+// readSCGIRequest reads the body of an SCGI request
+func readSCGIRequest(r *bufio.Reader) ([]byte, error) {
+	size, err := r.ReadString(':')
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSuffix(size, ":"))
+	if err != nil {
+		return nil, err
+	}
+	headers := make([]byte, length+1)
+	if _, err := io.ReadFull(r, headers); err != nil {
+		return nil, err
+	}
+	fields := strings.Split(string(headers[:length]), "\x00")
+	if len(fields) < 2 || fields[0] != "CONTENT_LENGTH" {
+		return nil, errors.New("CONTENT_LENGTH is not the first header")
+	}
+	contentLength, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, contentLength)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
+
+func TestClient_SCGI(t *testing.T) {
+	fake := &fakeRTorrent{portRange: "51413-51413", bindAddress: "10.2.0.2"}
+	client, err := NewClient(serveSCGI(t, fake), Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	port, err := client.GetPort()
+	if err != nil {
+		t.Fatalf("GetPort() error = %v", err)
+	}
+	if port != 51413 {
+		t.Errorf("GetPort() = %d, want 51413", port)
+	}
+
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if fake.portRange != "6000-6000" || fake.bindAddress != "10.2.0.2" {
+		t.Errorf("port range = %q, bind address = %q, want 6000-6000 rebound to 10.2.0.2", fake.portRange, fake.bindAddress)
+	}
+	if !slices.Contains(fake.calls, "network.bind_address.set") {
+		t.Errorf("calls = %v, want a rebind", fake.calls)
+	}
+}
+
+func TestClient_HTTP(t *testing.T) {
+	fake := &fakeRTorrent{portRange: "51413-51413"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(fake.handle(body)))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL+"/RPC2", Options{Username: "admin", Password: "wrong"})
+	if !torrent.IsPermanent(err) {
+		t.Errorf("NewClient() error = %v, want permanent error for rejected credentials", err)
+	}
+
+	client, err := NewClient(server.URL+"/RPC2", Options{Username: "admin", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetPortSettings(PortSettings{StaticPort: true, BindAddress: "10.2.0.2"})
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if fake.bindAddress != "10.2.0.2" || !slices.Contains(fake.calls, "network.port_random.set") {
+		t.Errorf("bind address = %q, calls = %v, want 10.2.0.2 and random port disabled", fake.bindAddress, fake.calls)
+	}
+}
+
+func TestSetPort_NotApplied(t *testing.T) {
+	fake := &fakeRTorrent{portRange: "51413-51413", ignorePort: true}
+	client, err := NewClient(serveSCGI(t, fake), Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	if err := client.SetPort(6000); !errors.Is(err, ErrPortNotApplied) {
+		t.Errorf("SetPort() error = %v, want ErrPortNotApplied", err)
+	}
+}
+
+func TestCall_Fault(t *testing.T) {
+	fake := &fakeRTorrent{}
+	client, err := NewClient(serveSCGI(t, fake), Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	_, err = client.call("network.unknown")
+	var faultErr *FaultError
+	if !errors.As(err, &faultErr) || faultErr.Code != "-506" {
+		t.Errorf("call() error = %v, want fault -506", err)
+	}
+}
+
+func TestNewPoster(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "scgi://localhost:5000"},
+		{url: "scgi:///run/rtorrent/rpc.sock"},
+		{url: "https://seedbox.example/RPC2"},
+		{url: "scgi://", wantErr: true},
+		{url: "ftp://seedbox.example", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := newPoster(tt.url, Options{})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newPoster(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "6000-6000", want: 6000},
+		{value: "6881-6999", want: 6881},
+		{value: "51413", want: 51413},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePortRange(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parsePortRange(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package rtorrent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseSize limits a response read from the SCGI socket
+const maxResponseSize = 1 << 20
+
+// scgiTransport sends XML-RPC calls to the SCGI socket of rTorrent, set with
+// network.scgi.open_port or network.scgi.open_local
+type scgiTransport struct {
+	network     string
+	address     string
+	timeout     time.Duration
+	dialTimeout time.Duration
+}
+
+// post sends body as an SCGI request and returns the body of the response
+func (t *scgiTransport) post(body []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(t.network, t.address, t.dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SCGI socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if err := conn.SetDeadline(time.Now().Add(t.timeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(encodeSCGI(body)); err != nil {
+		return nil, fmt.Errorf("failed to send SCGI request: %w", err)
+	}
+	response, err := io.ReadAll(io.LimitReader(conn, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SCGI response: %w", err)
+	}
+	return decodeSCGI(response)
+}
+
+// encodeSCGI frames body as an SCGI request: a netstring of the headers,
+// CONTENT_LENGTH first, followed by the body
+func encodeSCGI(body []byte) []byte {
+	headers := "CONTENT_LENGTH\x00" + strconv.Itoa(len(body)) + "\x00SCGI\x001\x00"
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Itoa(len(headers)) + ":" + headers + ",")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// decodeSCGI returns the body of an SCGI response, which starts with CGI
+// headers such as "Status: 200 OK"
+func decodeSCGI(response []byte) ([]byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(response))
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCGI response: %w", err)
+	}
+	if status := headers.Get("Status"); status != "" && !strings.HasPrefix(status, "200") {
+		return nil, fmt.Errorf("unexpected SCGI status: %s", status)
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,105 @@
+package rtorrent
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// encodeCall encodes an XML-RPC method call. Parameters are strings or ints,
+// the only types the commands used here take.
+func encodeCall(method string, params ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<methodCall><methodName>")
+	if err := xml.EscapeText(&buf, []byte(method)); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</methodName><params>")
+	for _, param := range params {
+		buf.WriteString("<param><value>")
+		switch v := param.(type) {
+		case string:
+			buf.WriteString("<string>")
+			if err := xml.EscapeText(&buf, []byte(v)); err != nil {
+				return nil, err
+			}
+			buf.WriteString("</string>")
+		case int:
+			buf.WriteString("<i8>" + strconv.Itoa(v) + "</i8>")
+		default:
+			return nil, fmt.Errorf("unsupported XML-RPC parameter type %T", param)
+		}
+		buf.WriteString("</value></param>")
+	}
+	buf.WriteString("</params></methodCall>")
+	return buf.Bytes(), nil
+}
+
+// xmlValue is an XML-RPC value. Only scalars and the struct of a fault are
+// decoded; a value without a type element is a string.
+type xmlValue struct {
+	String  *string     `xml:"string"`
+	Int     *string     `xml:"int"`
+	I4      *string     `xml:"i4"`
+	I8      *string     `xml:"i8"`
+	Members []xmlMember `xml:"struct>member"`
+	Text    string      `xml:",chardata"`
+}
+
+type xmlMember struct {
+	Name  string   `xml:"name"`
+	Value xmlValue `xml:"value"`
+}
+
+// text returns a scalar value as text
+func (v xmlValue) text() string {
+	for _, s := range []*string{v.String, v.Int, v.I4, v.I8} {
+		if s != nil {
+			return strings.TrimSpace(*s)
+		}
+	}
+	return strings.TrimSpace(v.Text)
+}
+
+// member returns the value of the struct member name
+func (v xmlValue) member(name string) string {
+	for _, m := range v.Members {
+		if m.Name == name {
+			return m.Value.text()
+		}
+	}
+	return ""
+}
+
+type methodResponse struct {
+	Params []xmlValue `xml:"params>param>value"`
+	Fault  *xmlValue  `xml:"fault>value"`
+}
+
+// FaultError is an XML-RPC fault returned by rTorrent
+type FaultError struct {
+	Code    string
+	Message string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("XML-RPC fault %s: %s", e.Code, e.Message)
+}
+
+// decodeResponse returns the result of an XML-RPC method response as text
+func decodeResponse(data []byte) (string, error) {
+	var response methodResponse
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return "", fmt.Errorf("failed to decode XML-RPC response: %w", err)
+	}
+	if response.Fault != nil {
+		return "", &FaultError{Code: response.Fault.member("faultCode"), Message: response.Fault.member("faultString")}
+	}
+	if len(response.Params) == 0 {
+		return "", nil
+	}
+	return response.Params[0].text(), nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+)
+
+// apiKeyHeader and apiKeyParam carry the API key, as in Sonarr, Radarr and
+// the other *arr applications
+const (
+	apiKeyHeader = "X-Api-Key"
+	apiKeyParam  = "apikey"
+)
+
+// protect applies the configured authentication to a status or control
+// endpoint
+func (s *Server) protect(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireClientCert(s.requireAPIKey(next))
+}
+
+// requireClientCert rejects requests without a verified client certificate
+// if a client CA is configured. The TLS handshake has already verified any
+// certificate presented against the CA.
+func (s *Server) requireClientCert(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.clientCAs != nil && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			slog.Warn("rejected request without client certificate", "path", r.URL.Path, "remote", r.RemoteAddr)
+			writeProblem(w, r, problemUnauthorized, "Client certificate required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAPIKey rejects requests without the configured API key. All
+// requests pass if no key is set.
+func (s *Server) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := s.currentAPIKey()
+		if apiKey == "" {
+			next(w, r)
+			return
+		}
+
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			key = r.URL.Query().Get(apiKeyParam)
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			slog.Warn("rejected request without valid api key", "path", r.URL.Path, "remote", r.RemoteAddr)
+			writeProblem(w, r, problemUnauthorized, "Missing or invalid API key")
+			return
+		}
+		next(w, r)
+	}
+}
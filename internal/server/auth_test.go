@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		header     string
+		target     string
+		wantStatus int
+	}{
+		{name: "no key configured", target: "/status", wantStatus: http.StatusOK},
+		{name: "header", apiKey: "secret", header: "secret", target: "/status", wantStatus: http.StatusOK},
+		{name: "query parameter", apiKey: "secret", target: "/status?apikey=secret", wantStatus: http.StatusOK},
+		{name: "missing", apiKey: "secret", target: "/status", wantStatus: http.StatusUnauthorized},
+		{name: "wrong header", apiKey: "secret", header: "guess", target: "/status?apikey=secret", wantStatus: http.StatusUnauthorized},
+		{name: "wrong query parameter", apiKey: "secret", target: "/status?apikey=guess", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			server.SetAPIKey(tt.apiKey)
+			handler := server.requireAPIKey(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Api-Key", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// configHandler reports the effective configuration as a JSON object of
+// environment variables, with secrets redacted
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	if s.config == nil {
+		writeProblem(w, r, problemNotConfigured, "Configuration not available")
+		return
+	}
+	writeConfig(w, s.config.Config())
+}
+
+// configReloadHandler reloads the configuration and responds with the new
+// one. If it is invalid, the current configuration stays in effect and the
+// response is 422 with the error.
+func (s *Server) configReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.config == nil {
+		writeProblem(w, r, problemNotConfigured, "Configuration not available")
+		return
+	}
+
+	if err := s.config.Reload(r.Context()); err != nil {
+		slog.Error("config reload failed", "error", err)
+		writeProblem(w, r, problemInvalidConfig, "Config reload failed: "+err.Error())
+		return
+	}
+	slog.Info("config reloaded")
+	writeConfig(w, s.config.Config())
+}
+
+func writeConfig(w http.ResponseWriter, config map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(config); err != nil {
+		slog.Error("failed to encode config response", "error", err)
+	}
+}
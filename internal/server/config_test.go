@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeConfig returns a fixed configuration and counts reloads, failing them
+// with err
+type fakeConfig struct {
+	values   map[string]string
+	reloaded int
+	err      error
+}
+
+func (f *fakeConfig) Config() map[string]string {
+	return f.values
+}
+
+func (f *fakeConfig) Reload(ctx context.Context) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.reloaded++
+	return nil
+}
+
+func TestConfigHandler(t *testing.T) {
+	server := &Server{}
+	server.SetConfigManager(&fakeConfig{values: map[string]string{"SYNC_INTERVAL": "300", "API_KEY": "[redacted]"}})
+
+	w := httptest.NewRecorder()
+	server.configHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/config", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("configHandler() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var values map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&values); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if values["SYNC_INTERVAL"] != "300" || values["API_KEY"] != "[redacted]" {
+		t.Errorf("response = %v, want the configuration", values)
+	}
+}
+
+func TestConfigHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		config     ConfigManager
+		wantStatus int
+	}{
+		{name: "wrong method", method: http.MethodPost, config: &fakeConfig{}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "not available", method: http.MethodGet, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			server.SetConfigManager(tt.config)
+
+			w := httptest.NewRecorder()
+			server.configHandler(w, httptest.NewRequest(tt.method, "/api/v1/config", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("configHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestConfigReloadHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		config       *fakeConfig
+		wantStatus   int
+		wantReloaded int
+	}{
+		{name: "reloads", method: http.MethodPost, config: &fakeConfig{}, wantStatus: http.StatusOK, wantReloaded: 1},
+		{name: "invalid config", method: http.MethodPost, config: &fakeConfig{err: errors.New("invalid webhook")}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "wrong method", method: http.MethodGet, config: &fakeConfig{}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "not available", method: http.MethodPost, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			if tt.config != nil {
+				server.SetConfigManager(tt.config)
+			}
+
+			w := httptest.NewRecorder()
+			server.configReloadHandler(w, httptest.NewRequest(tt.method, "/api/v1/config/reload", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("configReloadHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.config != nil && tt.config.reloaded != tt.wantReloaded {
+				t.Errorf("Reload() called %d times, want %d", tt.config.reloaded, tt.wantReloaded)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long browsers may cache the answer to a preflight
+// request, in seconds
+const corsMaxAge = "600"
+
+// defaultCORSMethods are allowed when SetCORS is given no methods
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// SetCORS allows web pages from the given origins, or any origin for "*", to
+// call the endpoints with the given methods. Preflight requests are answered
+// without authentication; the API key header is among the allowed headers.
+func (s *Server) SetCORS(origins, methods []string) {
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	s.corsOrigins = origins
+	s.corsMethods = make([]string, len(methods))
+	for i, method := range methods {
+		s.corsMethods[i] = strings.ToUpper(method)
+	}
+}
+
+// cors adds the CORS headers for allowed origins and answers their
+// preflight requests
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !s.corsAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if slices.Contains(s.corsOrigins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(s.corsMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", apiKeyHeader+", Content-Type")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsAllowed reports whether the origin is allowed, ignoring case
+func (s *Server) corsAllowed(origin string) bool {
+	for _, allowed := range s.corsOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+	}{
+		{name: "allowed origin", origins: []string{"https://home.example.com"}, method: http.MethodGet, origin: "https://home.example.com", wantStatus: http.StatusOK, wantOrigin: "https://home.example.com"},
+		{name: "trailing slash and case", origins: []string{"https://Home.example.com/"}, method: http.MethodGet, origin: "https://home.example.com", wantStatus: http.StatusOK, wantOrigin: "https://home.example.com"},
+		{name: "any origin", origins: []string{"*"}, method: http.MethodGet, origin: "https://home.example.com", wantStatus: http.StatusOK, wantOrigin: "*"},
+		{name: "other origin", origins: []string{"https://home.example.com"}, method: http.MethodGet, origin: "https://evil.example.com", wantStatus: http.StatusOK},
+		{name: "same origin", origins: []string{"https://home.example.com"}, method: http.MethodGet, wantStatus: http.StatusOK},
+		{
+			name:        "preflight",
+			origins:     []string{"https://home.example.com"},
+			method:      http.MethodOptions,
+			origin:      "https://home.example.com",
+			preflight:   true,
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "https://home.example.com",
+			wantMethods: "GET, POST",
+		},
+		{name: "preflight of other origin", origins: []string{"https://home.example.com"}, method: http.MethodOptions, origin: "https://evil.example.com", preflight: true, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			server.SetCORS(tt.origins, []string{"get", "post"})
+			handler := server.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(tt.method, "/status", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if tt.preflight && tt.wantOrigin != "" && w.Header().Get("Access-Control-Allow-Headers") != "X-Api-Key, Content-Type" {
+				t.Errorf("Access-Control-Allow-Headers = %q, want the API key header", w.Header().Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+func TestSetCORS_DefaultMethods(t *testing.T) {
+	server := &Server{}
+	server.SetCORS([]string{"*"}, nil)
+	handler := server.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/sync", nil)
+	req.Header.Set("Origin", "https://home.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, HEAD, POST" {
+		t.Errorf("Access-Control-Allow-Methods = %q, want GET, HEAD, POST", got)
+	}
+}
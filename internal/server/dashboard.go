@@ -0,0 +1,25 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardHTML is a single-page UI built on /status, /sync and
+// /webhook/test, so that Forwardarr can be inspected and driven from a
+// browser
+//
+//go:embed ui/index.html
+var dashboardHTML []byte
+
+// dashboardHandler serves the dashboard at the root path
+func (s *Server) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(dashboardHTML)
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	server := &Server{}
+
+	w := httptest.NewRecorder()
+	server.dashboardHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("dashboardHandler() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+	for _, endpoint := range []string{`"status"`, `"sync"`, `"webhook/test"`} {
+		if !strings.Contains(w.Body.String(), endpoint) {
+			t.Errorf("dashboard does not use the %s endpoint", endpoint)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	server.dashboardHandler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("dashboardHandler() POST status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
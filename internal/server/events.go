@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// eventKeepAlive is the interval of the comments sent on idle event streams,
+// so that proxies do not close them
+const eventKeepAlive = 30 * time.Second
+
+// Event types of the event stream
+const (
+	eventStatus      = "status"
+	eventSync        = "sync"
+	eventPortChanged = "port_changed"
+)
+
+// streamEvent is an event of the event streams. It is also the format of
+// WebSocket messages.
+type streamEvent struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// recordEvents returns the events of a sync attempt: a sync event, followed
+// by a port_changed event if the sync changed the port
+func recordEvents(record sync.SyncRecord) []streamEvent {
+	events := []streamEvent{{Event: eventSync, Data: record}}
+	if record.OldPort != 0 {
+		events = append(events, streamEvent{Event: eventPortChanged, Data: portChangedEvent{
+			Time:    record.Time,
+			OldPort: record.OldPort,
+			NewPort: record.Port,
+		}})
+	}
+	return events
+}
+
+// portChangedEvent is the data of a port_changed event
+type portChangedEvent struct {
+	Time    time.Time `json:"time"`
+	OldPort int       `json:"old_port"`
+	NewPort int       `json:"new_port"`
+}
+
+// eventsHandler streams the state of the port sync as Server-Sent Events: a
+// status event with the current state on connect, a sync event for every
+// sync attempt and a port_changed event when a sync changed the port
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+
+	records, unsubscribe := s.syncer.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+
+	if err := writeEvent(w, eventStatus, s.syncer.Status()); err != nil {
+		slog.Debug("failed to write event", "error", err)
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		slog.Warn("event stream not supported by response writer", "error", err)
+		return
+	}
+
+	var stopped <-chan struct{}
+	if s.streams != nil {
+		stopped = s.streams.Done()
+	}
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stopped:
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case record, ok := <-records:
+			if !ok {
+				return
+			}
+			for _, event := range recordEvents(record) {
+				if err = writeEvent(w, event.Event, event.Data); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			slog.Debug("event stream closed", "error", err)
+			return
+		}
+	}
+}
+
+// writeEvent writes one Server-Sent Event with the JSON encoded data
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+	return err
+}
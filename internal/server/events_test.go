@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// readEvent reads the next event of a Server-Sent Events stream, skipping
+// comments
+func readEvent(t *testing.T, reader *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	syncer := &fakeSyncer{
+		status:  sync.Status{CurrentPort: 8080},
+		records: make(chan sync.SyncRecord, 2),
+	}
+	server := &Server{}
+	server.SetPortSyncer(syncer)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.eventsHandler))
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/v1/events error = %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	event, data := readEvent(t, reader)
+	var status sync.Status
+	if err := json.Unmarshal([]byte(data), &status); event != eventStatus || err != nil || status.CurrentPort != 8080 {
+		t.Fatalf("first event = %s %s, want the current status", event, data)
+	}
+
+	syncer.records <- sync.SyncRecord{Time: time.Now(), Result: sync.SyncResultSuccess, Port: 9090, OldPort: 8080}
+	if event, data = readEvent(t, reader); event != eventSync || !strings.Contains(data, `"port":9090`) {
+		t.Errorf("event = %s %s, want the sync record", event, data)
+	}
+	var changed portChangedEvent
+	event, data = readEvent(t, reader)
+	if err := json.Unmarshal([]byte(data), &changed); event != eventPortChanged || err != nil || changed.OldPort != 8080 || changed.NewPort != 9090 {
+		t.Errorf("event = %s %s, want the port change from 8080 to 9090", event, data)
+	}
+}
+
+func TestEventsHandler_Unavailable(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		syncer     PortSyncer
+		wantStatus int
+	}{
+		{name: "no watcher", method: http.MethodGet, wantStatus: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodPost, syncer: &fakeSyncer{}, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{syncer: tt.syncer}
+			w := httptest.NewRecorder()
+			server.eventsHandler(w, httptest.NewRequest(tt.method, "/api/v1/events", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("eventsHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEventsHandler_EndsOnShutdown(t *testing.T) {
+	server := NewServer("0", nil)
+	server.SetPortSyncer(&fakeSyncer{records: make(chan sync.SyncRecord)})
+	httpServer := httptest.NewServer(http.HandlerFunc(server.eventsHandler))
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatalf("GET /api/v1/events error = %v", err)
+	}
+	defer resp.Body.Close()
+	readEvent(t, bufio.NewReader(resp.Body))
+
+	if err := server.Shutdown(t.Context()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_, _ = bufio.NewReader(resp.Body).ReadString(0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event stream still open after Shutdown")
+	}
+}
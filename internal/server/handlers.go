@@ -2,25 +2,51 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/eslutz/forwardarr/internal/sync"
+	"github.com/eslutz/forwardarr/internal/webhook"
 	"github.com/eslutz/forwardarr/pkg/version"
 )
 
+// healthHandler is the liveness probe, which also fails if the port sync
+// is stale. With verbose=1 it reports the health of every component instead.
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	if !s.isRunning {
+	if verboseRequested(r) {
+		s.detailedHealthHandler(w, r)
+		return
+	}
+	if !s.isRunning.Load() {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = w.Write([]byte("Service not running"))
 		return
 	}
+	if s.syncer != nil && s.syncStale(s.syncer.Status(), time.Now()) {
+		slog.Warn("health check failed: port sync is stale", "threshold", s.syncStaleAfter)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Port sync stale"))
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("OK"))
 }
 
+// readyHandler reports whether qBittorrent is reachable. A stopping server is
+// never ready, so that no new work is routed to it.
 func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
-	if err := s.qbitClient.Ping(); err != nil {
+	if !s.isRunning.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Service stopping"))
+		return
+	}
+	if err := s.pingQbit(); err != nil {
 		slog.Warn("readiness check failed", "error", err)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = w.Write([]byte("qBittorrent not reachable"))
@@ -31,24 +57,136 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("Ready"))
 }
 
+// statusHandler reports the version, uptime and qBittorrent reachability,
+// together with the state of the port sync if available
 func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 	status := struct {
 		Status               string `json:"status"`
 		Version              string `json:"version"`
+		UptimeSeconds        int64  `json:"uptime_seconds"`
 		QBittorrentReachable bool   `json:"qbittorrent_reachable"`
+		// Sync is omitted when no watcher is attached
+		Sync *sync.Status `json:"sync,omitempty"`
 	}{
 		Status:               "running",
 		Version:              version.Version,
-		QBittorrentReachable: s.qbitClient.Ping() == nil,
+		UptimeSeconds:        int64(time.Since(s.started).Seconds()),
+		QBittorrentReachable: s.pingQbit() == nil,
 	}
 
-	if !s.isRunning {
+	if !s.isRunning.Load() {
 		status.Status = "stopping"
 	}
+	if s.syncer != nil {
+		syncStatus := s.syncer.Status()
+		status.Sync = &syncStatus
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(status); err != nil {
 		slog.Error("failed to encode status response", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "Failed to encode the status")
+	}
+}
+
+// versionHandler reports the details of the running build
+func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(version.Build()); err != nil {
+		slog.Error("failed to encode version response", "error", err)
+	}
+}
+
+// portHandler returns the current port as plain text, for scripts. It
+// responds with 503 until the port has been synced.
+func (s *Server) portHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+	port := s.syncer.Status().CurrentPort
+	if port == 0 {
+		writeProblem(w, r, problemNotSynced, "The port has not been synced since the start")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(strconv.Itoa(port) + "\n"))
+}
+
+// syncHandler asks the watcher to sync the port now. The sync runs in the
+// background; its outcome is reported by /status.
+func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+
+	s.syncer.TriggerSync()
+	slog.Info("port sync requested")
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("Sync requested"))
+}
+
+// webhookTestResult is the outcome of a test notification for one target
+type webhookTestResult struct {
+	Target  string `json:"target"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// webhookTestHandler sends a test notification to the target named by the
+// "target" query parameter, or to all targets. It responds with 502 if any
+// delivery failed.
+func (s *Server) webhookTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	webhooks := s.currentWebhooks()
+	if webhooks == nil {
+		writeProblem(w, r, problemNotConfigured, "Webhooks not configured")
+		return
+	}
+
+	target := r.URL.Query().Get("target")
+	results := webhooks.SendTest(r.Context(), target)
+	if len(results) == 0 {
+		writeProblem(w, r, problemNotFound, fmt.Sprintf("Unknown webhook target %q", target))
+		return
+	}
+
+	response := struct {
+		Results []webhookTestResult `json:"results"`
+	}{}
+	code := http.StatusOK
+	for _, name := range slices.Sorted(maps.Keys(results)) {
+		result := webhookTestResult{Target: name, Success: results[name] == nil}
+		if err := results[name]; err != nil {
+			// The error may quote the URL, and with it the token, of the target
+			result.Error = webhook.RedactError(err)
+			code = http.StatusBadGateway
+		}
+		response.Results = append(response.Results, result)
+	}
+	slog.Info("sent webhook test notification", "target", target, "targets", len(results), "status", code)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("failed to encode webhook test response", "error", err)
 	}
 }
@@ -1,18 +1,26 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/sync"
+	"github.com/eslutz/forwardarr/internal/webhook"
+	"github.com/eslutz/forwardarr/pkg/version"
 )
 
 func TestHealthHandler_Running(t *testing.T) {
-	server := &Server{
-		isRunning: true,
-	}
+	server := &Server{}
+	server.SetRunning(true)
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -28,9 +36,7 @@ func TestHealthHandler_Running(t *testing.T) {
 }
 
 func TestHealthHandler_NotRunning(t *testing.T) {
-	server := &Server{
-		isRunning: false,
-	}
+	server := &Server{}
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -59,9 +65,8 @@ func TestReadyHandler_Success(t *testing.T) {
 	defer qbitServer.Close()
 
 	client, _ := qbit.NewClient(qbitServer.URL, "admin", "admin")
-	server := &Server{
-		qbitClient: client,
-	}
+	server := &Server{qbitClient: client}
+	server.SetRunning(true)
 
 	req := httptest.NewRequest("GET", "/ready", nil)
 	w := httptest.NewRecorder()
@@ -89,9 +94,8 @@ func TestReadyHandler_Failure(t *testing.T) {
 	defer qbitServer.Close()
 
 	client, _ := qbit.NewClient(qbitServer.URL, "admin", "admin")
-	server := &Server{
-		qbitClient: client,
-	}
+	server := &Server{qbitClient: client}
+	server.SetRunning(true)
 
 	req := httptest.NewRequest("GET", "/ready", nil)
 	w := httptest.NewRecorder()
@@ -119,10 +123,8 @@ func TestStatusHandler_Running(t *testing.T) {
 	defer qbitServer.Close()
 
 	client, _ := qbit.NewClient(qbitServer.URL, "admin", "admin")
-	server := &Server{
-		qbitClient: client,
-		isRunning:  true,
-	}
+	server := &Server{qbitClient: client}
+	server.SetRunning(true)
 
 	req := httptest.NewRequest("GET", "/status", nil)
 	w := httptest.NewRecorder()
@@ -152,6 +154,118 @@ func TestStatusHandler_Running(t *testing.T) {
 	}
 }
 
+// fakeSyncer returns a fixed sync status, counts sync requests, streams
+// the records sent on records and answers history queries from history
+type fakeSyncer struct {
+	status    sync.Status
+	triggered int
+	pushed    int
+	records   chan sync.SyncRecord
+	history   *sync.History
+	query     sync.HistoryQuery
+}
+
+func (f *fakeSyncer) Status() sync.Status {
+	return f.status
+}
+
+func (f *fakeSyncer) TriggerSync() {
+	f.triggered++
+}
+
+func (f *fakeSyncer) PushPort(port int) error {
+	if port < 1 || port > 65535 {
+		return errors.New("port out of valid range")
+	}
+	f.pushed = port
+	f.triggered++
+	return nil
+}
+
+func (f *fakeSyncer) Pause() bool {
+	if f.status.Paused {
+		return false
+	}
+	f.status.Paused = true
+	return true
+}
+
+func (f *fakeSyncer) Resume() bool {
+	if !f.status.Paused {
+		return false
+	}
+	f.status.Paused = false
+	f.triggered++
+	return true
+}
+
+func (f *fakeSyncer) Subscribe() (<-chan sync.SyncRecord, func()) {
+	return f.records, func() {}
+}
+
+func (f *fakeSyncer) History(query sync.HistoryQuery) sync.HistoryPage {
+	f.query = query
+	if f.history == nil {
+		return sync.HistoryPage{Records: []sync.SyncRecord{}}
+	}
+	return f.history.Query(query)
+}
+
+func TestStatusHandler_SyncStatus(t *testing.T) {
+	qbitServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Ok."))
+	}))
+	defer qbitServer.Close()
+
+	client, _ := qbit.NewClient(qbitServer.URL, "admin", "admin")
+	changed := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := &Server{
+		qbitClient: client,
+		started:    time.Now().Add(-time.Hour),
+	}
+	server.SetRunning(true)
+	server.SetPortSyncer(&fakeSyncer{status: sync.Status{
+		CurrentPort:    9090,
+		PreviousPort:   8080,
+		LastChange:     changed,
+		LastSync:       changed,
+		LastSyncResult: sync.SyncResultSuccess,
+		SourceHealthy:  true,
+	}})
+
+	w := httptest.NewRecorder()
+	server.statusHandler(w, httptest.NewRequest("GET", "/status", nil))
+
+	var status struct {
+		UptimeSeconds int64          `json:"uptime_seconds"`
+		Sync          map[string]any `json:"sync"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status response: %v", err)
+	}
+
+	if status.UptimeSeconds < 3600 {
+		t.Errorf("uptime_seconds = %d, want at least 3600", status.UptimeSeconds)
+	}
+	want := map[string]any{
+		"current_port":              float64(9090),
+		"previous_port":             float64(8080),
+		"last_change":               "2025-01-02T03:04:05Z",
+		"last_sync_result":          "success",
+		"consecutive_sync_failures": float64(0),
+		"source_healthy":            true,
+	}
+	for key, value := range want {
+		if status.Sync[key] != value {
+			t.Errorf("sync.%s = %v, want %v", key, status.Sync[key], value)
+		}
+	}
+	if _, ok := status.Sync["source_down_since"]; ok {
+		t.Error("sync.source_down_since is set for a healthy source, want omitted")
+	}
+}
+
 func TestStatusHandler_Stopping(t *testing.T) {
 	qbitServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/api/v2/auth/login" {
@@ -163,10 +277,7 @@ func TestStatusHandler_Stopping(t *testing.T) {
 	defer qbitServer.Close()
 
 	client, _ := qbit.NewClient(qbitServer.URL, "admin", "admin")
-	server := &Server{
-		qbitClient: client,
-		isRunning:  false,
-	}
+	server := &Server{qbitClient: client}
 
 	req := httptest.NewRequest("GET", "/status", nil)
 	w := httptest.NewRecorder()
@@ -209,10 +320,10 @@ func TestNewServer(t *testing.T) {
 	if server == nil {
 		t.Fatal("NewServer() returned nil")
 	}
-	if server.port != "9090" {
-		t.Errorf("server.port = %q, want %q", server.port, "9090")
+	if len(server.addresses) != 1 || server.addresses[0] != ":9090" {
+		t.Errorf("server.addresses = %v, want [:9090]", server.addresses)
 	}
-	if !server.isRunning {
+	if !server.isRunning.Load() {
 		t.Error("server.isRunning = false, want true")
 	}
 	if server.qbitClient != client {
@@ -221,15 +332,290 @@ func TestNewServer(t *testing.T) {
 }
 
 func TestSetRunning(t *testing.T) {
-	server := &Server{isRunning: true}
+	server := &Server{}
+	server.SetRunning(true)
 
 	server.SetRunning(false)
-	if server.isRunning {
+	if server.isRunning.Load() {
 		t.Error("SetRunning(false) did not update isRunning")
 	}
 
 	server.SetRunning(true)
-	if !server.isRunning {
+	if !server.isRunning.Load() {
 		t.Error("SetRunning(true) did not update isRunning")
 	}
 }
+
+// fakeWebhooks returns the configured results for every target
+type fakeWebhooks struct {
+	results  map[string]error
+	statuses []webhook.TargetStatus
+}
+
+func (f *fakeWebhooks) TargetStatus() []webhook.TargetStatus {
+	return f.statuses
+}
+
+func (f *fakeWebhooks) SendTest(ctx context.Context, name string) map[string]error {
+	if name == "" {
+		return f.results
+	}
+	if err, ok := f.results[name]; ok {
+		return map[string]error{name: err}
+	}
+	return nil
+}
+
+func TestWebhookTestHandler(t *testing.T) {
+	tester := &fakeWebhooks{results: map[string]error{
+		"discord": nil,
+		"gotify":  errors.New("webhook returned non-2xx status: 401"),
+	}}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		tester     Webhooks
+		wantStatus int
+		wantCount  int
+	}{
+		{name: "single target", method: http.MethodPost, target: "discord", tester: tester, wantStatus: http.StatusOK, wantCount: 1},
+		{name: "failing target", method: http.MethodPost, target: "gotify", tester: tester, wantStatus: http.StatusBadGateway, wantCount: 1},
+		{name: "all targets", method: http.MethodPost, tester: tester, wantStatus: http.StatusBadGateway, wantCount: 2},
+		{name: "unknown target", method: http.MethodPost, target: "slack", tester: tester, wantStatus: http.StatusNotFound},
+		{name: "webhooks disabled", method: http.MethodPost, wantStatus: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, tester: tester, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{webhooks: tt.tester}
+
+			req := httptest.NewRequest(tt.method, "/webhook/test?target="+tt.target, nil)
+			w := httptest.NewRecorder()
+
+			server.webhookTestHandler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("webhookTestHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+
+			var response struct {
+				Results []webhookTestResult `json:"results"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode webhook test response: %v", err)
+			}
+			if len(response.Results) != tt.wantCount {
+				t.Fatalf("len(results) = %d, want %d", len(response.Results), tt.wantCount)
+			}
+			for _, result := range response.Results {
+				if result.Success != (result.Target == "discord") {
+					t.Errorf("result %+v has unexpected success", result)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookTestHandler_RedactsURL(t *testing.T) {
+	const token = "https://discord.com/api/webhooks/123/secret-token"
+	tester := &fakeWebhooks{results: map[string]error{
+		"discord": &url.Error{Op: "Post", URL: token, Err: errors.New("connection refused")},
+	}}
+	server := &Server{webhooks: tester}
+
+	w := httptest.NewRecorder()
+	server.webhookTestHandler(w, httptest.NewRequest(http.MethodPost, "/webhook/test", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("webhookTestHandler() status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "secret-token") {
+		t.Errorf("response leaks the webhook URL: %s", body)
+	}
+	if !strings.Contains(body, "https://discord.com/[redacted]") || !strings.Contains(body, "connection refused") {
+		t.Errorf("response = %s, want the redacted error", body)
+	}
+}
+
+func TestSyncHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		syncer        *fakeSyncer
+		wantStatus    int
+		wantTriggered int
+	}{
+		{name: "triggers sync", method: http.MethodPost, syncer: &fakeSyncer{}, wantStatus: http.StatusAccepted, wantTriggered: 1},
+		{name: "wrong method", method: http.MethodGet, syncer: &fakeSyncer{}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no watcher", method: http.MethodPost, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			if tt.syncer != nil {
+				server.SetPortSyncer(tt.syncer)
+			}
+
+			w := httptest.NewRecorder()
+			server.syncHandler(w, httptest.NewRequest(tt.method, "/sync", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("syncHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.syncer != nil && tt.syncer.triggered != tt.wantTriggered {
+				t.Errorf("TriggerSync() called %d times, want %d", tt.syncer.triggered, tt.wantTriggered)
+			}
+		})
+	}
+}
+
+func TestReadyHandler_Stopping(t *testing.T) {
+	server := &Server{}
+
+	w := httptest.NewRecorder()
+	server.readyHandler(w, httptest.NewRequest("GET", "/ready", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyHandler() status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	server := NewServer("0", nil)
+	done := make(chan error, 1)
+	go func() { done <- server.Start() }()
+
+	if err := server.Shutdown(t.Context()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start() error = %v after Shutdown, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start() did not return after Shutdown")
+	}
+	if server.isRunning.Load() {
+		t.Error("server still reports running after Shutdown")
+	}
+}
+
+// freeAddress returns a local address that is free to listen on
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	return addr
+}
+
+func TestServerStart_MultipleAddresses(t *testing.T) {
+	addresses := []string{freeAddress(t), freeAddress(t)}
+	server := NewServer("0", nil)
+	server.SetAddresses(addresses)
+	done := make(chan error, 1)
+	go func() { done <- server.Start() }()
+
+	for _, addr := range addresses {
+		var resp *http.Response
+		var err error
+		for range 50 {
+			if resp, err = http.Get("http://" + addr + "/health"); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("GET /health on %s error = %v", addr, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /health on %s status = %d, want %d", addr, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	if err := server.Shutdown(t.Context()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Start() error = %v after Shutdown, want nil", err)
+	}
+}
+
+func TestServerStart_AddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	server := NewServer("0", nil)
+	server.SetAddresses([]string{freeAddress(t), listener.Addr().String()})
+	if err := server.Start(); err == nil {
+		t.Error("Start() error = nil, want error for the address in use")
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	server := &Server{}
+
+	w := httptest.NewRecorder()
+	server.versionHandler(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("versionHandler() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var build version.BuildInfo
+	if err := json.NewDecoder(w.Body).Decode(&build); err != nil {
+		t.Fatalf("failed to decode version response: %v", err)
+	}
+	if build.Version != version.Version || build.GoVersion == "" || build.Commit == "" {
+		t.Errorf("versionHandler() = %+v, want the build details", build)
+	}
+}
+
+func TestPortHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		syncer     *fakeSyncer
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "current port", method: http.MethodGet, syncer: &fakeSyncer{status: sync.Status{CurrentPort: 54321}}, wantStatus: http.StatusOK, wantBody: "54321\n"},
+		{name: "not synced yet", method: http.MethodGet, syncer: &fakeSyncer{}, wantStatus: http.StatusServiceUnavailable},
+		{name: "wrong method", method: http.MethodPost, syncer: &fakeSyncer{}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no watcher", method: http.MethodGet, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			if tt.syncer != nil {
+				server.SetPortSyncer(tt.syncer)
+			}
+
+			w := httptest.NewRecorder()
+			server.portHandler(w, httptest.NewRequest(tt.method, "/port", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("portHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("portHandler() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
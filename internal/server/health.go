@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+	"github.com/eslutz/forwardarr/internal/torrent"
+	"github.com/eslutz/forwardarr/internal/webhook"
+)
+
+// Component health states. A degraded component still works but needs
+// attention; a component that is down keeps the port from being synced.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthDown     = "down"
+	healthStopping = "stopping"
+)
+
+// componentHealth is the state of one component in the detailed health check
+type componentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Since is the start of the outage of a component that is down
+	Since time.Time `json:"since,omitzero"`
+}
+
+// syncLoopHealth is the state of the sync loop
+type syncLoopHealth struct {
+	componentHealth
+	LastSuccess time.Time `json:"last_successful_sync,omitzero"`
+	// LastSuccessAge is omitted until the first successful sync
+	LastSuccessAge      *int64 `json:"last_successful_sync_age_seconds,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// qbitHealth is the state of qBittorrent. Reachable is not enough: with a
+// firewalled or disconnected listener, peers cannot use the forwarded port.
+type qbitHealth struct {
+	componentHealth
+	ConnectionStatus string `json:"connection_status,omitempty"`
+}
+
+// webhooksHealth is the state of the webhook targets, which is degraded if
+// any target is unhealthy
+type webhooksHealth struct {
+	componentHealth
+	Targets []webhook.TargetStatus `json:"targets"`
+}
+
+// detailedHealth is the response of /healthz?verbose=1
+type detailedHealth struct {
+	Status     string `json:"status"`
+	Components struct {
+		PortSource  *componentHealth `json:"port_source,omitempty"`
+		QBittorrent qbitHealth       `json:"qbittorrent"`
+		SyncLoop    *syncLoopHealth  `json:"sync_loop,omitempty"`
+		Webhooks    *webhooksHealth  `json:"webhooks,omitempty"`
+	} `json:"components"`
+}
+
+// verboseRequested reports whether the request asks for the detailed health
+// check with verbose=1 or verbose=true
+func verboseRequested(r *http.Request) bool {
+	verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose"))
+	return verbose
+}
+
+// detailedHealthHandler reports the health of every component. It responds
+// with 503 if a component is down or the server is stopping, and with 200
+// otherwise, including while webhook targets are failing.
+func (s *Server) detailedHealthHandler(w http.ResponseWriter, r *http.Request) {
+	health := s.detailedHealth(time.Now())
+
+	code := http.StatusOK
+	if health.Status == healthDown || health.Status == healthStopping {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		slog.Error("failed to encode health response", "error", err)
+	}
+}
+
+// detailedHealth collects the state of the components at now
+func (s *Server) detailedHealth(now time.Time) detailedHealth {
+	var health detailedHealth
+	components := &health.Components
+
+	components.QBittorrent.Status = healthOK
+	pingErr := s.pingQbit()
+	if pingErr != nil {
+		components.QBittorrent.componentHealth = componentHealth{Status: healthDown, Error: pingErr.Error()}
+	}
+
+	if s.syncer != nil {
+		status := s.syncer.Status()
+
+		components.PortSource = &componentHealth{Status: healthOK}
+		if !status.SourceHealthy {
+			components.PortSource = &componentHealth{
+				Status: healthDown,
+				Error:  status.SourceError,
+				Since:  status.SourceDownSince,
+			}
+		}
+		components.QBittorrent.Since = status.QbitDownSince
+		components.QBittorrent.ConnectionStatus = status.ConnectionStatus
+		if pingErr == nil {
+			switch {
+			case status.ConnectionStatus == torrent.ConnectionDisconnected:
+				components.QBittorrent.Status = healthDown
+				components.QBittorrent.Error = "qBittorrent reports no network connection"
+			case status.Firewalled:
+				components.QBittorrent.Status = healthDegraded
+				components.QBittorrent.Error = fmt.Sprintf("qBittorrent is firewalled, peers cannot reach port %d", status.CurrentPort)
+				components.QBittorrent.Since = status.FirewalledSince
+			}
+		}
+
+		loop := &syncLoopHealth{
+			componentHealth:     componentHealth{Status: healthOK},
+			LastSuccess:         status.LastSuccess,
+			ConsecutiveFailures: status.SyncFailures,
+		}
+		if !status.LastSuccess.IsZero() {
+			age := int64(now.Sub(status.LastSuccess).Seconds())
+			loop.LastSuccessAge = &age
+		}
+		switch {
+		case !status.Running:
+			loop.Status = healthDown
+			loop.Error = "sync loop not running"
+		case s.syncStale(status, now):
+			loop.Status = healthDown
+			loop.Error = fmt.Sprintf("no successful sync within %s", s.syncStaleAfter)
+		case status.SyncFailures > 0:
+			loop.Status = healthDegraded
+			loop.Error = status.LastSyncError
+		}
+		components.SyncLoop = loop
+	}
+
+	if targets := s.currentWebhooks(); targets != nil {
+		webhooks := &webhooksHealth{
+			componentHealth: componentHealth{Status: healthOK},
+			Targets:         targets.TargetStatus(),
+		}
+		for _, target := range webhooks.Targets {
+			if !target.Healthy {
+				webhooks.Status = healthDegraded
+			}
+		}
+		components.Webhooks = webhooks
+	}
+
+	health.Status = healthOK
+	for _, status := range health.componentStatuses() {
+		switch {
+		case status == healthDown:
+			health.Status = healthDown
+		case status == healthDegraded && health.Status == healthOK:
+			health.Status = healthDegraded
+		}
+	}
+	if !s.isRunning.Load() {
+		health.Status = healthStopping
+	}
+	return health
+}
+
+// syncStale reports whether the last successful sync, or the start of the
+// server if there was none, is older than the threshold of
+// SetSyncStaleThreshold. A paused sync is never stale.
+func (s *Server) syncStale(status sync.Status, now time.Time) bool {
+	if s.syncStaleAfter <= 0 || status.Paused {
+		return false
+	}
+	last := status.LastSuccess
+	if last.IsZero() {
+		last = s.started
+	}
+	return now.Sub(last) > s.syncStaleAfter
+}
+
+// SetSyncStaleThreshold makes the health checks fail when no sync succeeded
+// within threshold, e.g. because the sync loop is stuck. 0 disables the
+// check.
+func (s *Server) SetSyncStaleThreshold(threshold time.Duration) {
+	s.syncStaleAfter = threshold
+}
+
+// componentStatuses returns the states of the reported components
+func (h *detailedHealth) componentStatuses() []string {
+	statuses := []string{h.Components.QBittorrent.Status}
+	if h.Components.PortSource != nil {
+		statuses = append(statuses, h.Components.PortSource.Status)
+	}
+	if h.Components.SyncLoop != nil {
+		statuses = append(statuses, h.Components.SyncLoop.Status)
+	}
+	if h.Components.Webhooks != nil {
+		statuses = append(statuses, h.Components.Webhooks.Status)
+	}
+	return statuses
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/sync"
+	"github.com/eslutz/forwardarr/internal/webhook"
+)
+
+// newHealthTestServer returns a server whose qBittorrent answers pings
+// according to qbitUp
+func newHealthTestServer(t *testing.T, qbitUp *bool) *Server {
+	t.Helper()
+	qbitServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/auth/login" {
+			_, _ = w.Write([]byte("Ok."))
+			return
+		}
+		if !*qbitUp {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("v4.5.0"))
+	}))
+	t.Cleanup(qbitServer.Close)
+
+	client, err := qbit.NewClient(qbitServer.URL, "admin", "admin")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	server := &Server{qbitClient: client}
+	server.SetRunning(true)
+	return server
+}
+
+func TestHealthHandler_Verbose(t *testing.T) {
+	lastSuccess := time.Now().Add(-90 * time.Second)
+	healthySync := sync.Status{SourceHealthy: true, Running: true, LastSuccess: lastSuccess}
+
+	tests := []struct {
+		name       string
+		qbitUp     bool
+		sync       sync.Status
+		targets    []webhook.TargetStatus
+		wantStatus string
+		wantCode   int
+	}{
+		{
+			name:       "healthy",
+			qbitUp:     true,
+			sync:       healthySync,
+			targets:    []webhook.TargetStatus{{Name: "discord", Healthy: true}},
+			wantStatus: healthOK,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "failing webhook",
+			qbitUp:     true,
+			sync:       healthySync,
+			targets:    []webhook.TargetStatus{{Name: "discord", Healthy: true}, {Name: "gotify", LastError: "timeout"}},
+			wantStatus: healthDegraded,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "qbittorrent down",
+			sync:       healthySync,
+			wantStatus: healthDown,
+			wantCode:   http.StatusServiceUnavailable,
+		},
+		{
+			name:       "port source down",
+			qbitUp:     true,
+			sync:       sync.Status{SourceError: "port file empty", SourceDownSince: lastSuccess, Running: true},
+			wantStatus: healthDown,
+			wantCode:   http.StatusServiceUnavailable,
+		},
+		{
+			name:       "qbittorrent firewalled",
+			qbitUp:     true,
+			sync:       sync.Status{SourceHealthy: true, Running: true, LastSuccess: lastSuccess, ConnectionStatus: "firewalled", Firewalled: true},
+			wantStatus: healthDegraded,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "qbittorrent firewalled within grace period",
+			qbitUp:     true,
+			sync:       sync.Status{SourceHealthy: true, Running: true, LastSuccess: lastSuccess, ConnectionStatus: "firewalled"},
+			wantStatus: healthOK,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "qbittorrent disconnected",
+			qbitUp:     true,
+			sync:       sync.Status{SourceHealthy: true, Running: true, LastSuccess: lastSuccess, ConnectionStatus: "disconnected"},
+			wantStatus: healthDown,
+			wantCode:   http.StatusServiceUnavailable,
+		},
+		{
+			name:       "sync loop stopped",
+			qbitUp:     true,
+			sync:       sync.Status{SourceHealthy: true},
+			wantStatus: healthDown,
+			wantCode:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newHealthTestServer(t, &tt.qbitUp)
+			server.SetPortSyncer(&fakeSyncer{status: tt.sync})
+			server.SetWebhooks(&fakeWebhooks{statuses: tt.targets})
+
+			w := httptest.NewRecorder()
+			server.healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz?verbose=1", nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("healthHandler() status = %d, want %d", w.Code, tt.wantCode)
+			}
+			var health detailedHealth
+			if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+				t.Fatalf("failed to decode health response: %v", err)
+			}
+			if health.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q (components %+v)", health.Status, tt.wantStatus, health.Components)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_VerboseDetails(t *testing.T) {
+	qbitUp := true
+	server := newHealthTestServer(t, &qbitUp)
+	server.SetPortSyncer(&fakeSyncer{status: sync.Status{
+		SourceHealthy: true,
+		Running:       true,
+		LastSuccess:   time.Now().Add(-90 * time.Second),
+		SyncFailures:  2,
+		LastSyncError: "qbittorrent timeout",
+	}})
+
+	w := httptest.NewRecorder()
+	server.healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz?verbose=true", nil))
+
+	var health struct {
+		Status     string                    `json:"status"`
+		Components map[string]map[string]any `json:"components"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("failed to decode health response: %v", err)
+	}
+	loop := health.Components["sync_loop"]
+	if loop["status"] != healthDegraded || loop["error"] != "qbittorrent timeout" || loop["consecutive_failures"] != float64(2) {
+		t.Errorf("sync_loop = %v, want degraded with 2 failures", loop)
+	}
+	if age, _ := loop["last_successful_sync_age_seconds"].(float64); age < 90 || age > 100 {
+		t.Errorf("last_successful_sync_age_seconds = %v, want about 90", loop["last_successful_sync_age_seconds"])
+	}
+	if _, ok := health.Components["webhooks"]; ok {
+		t.Error("webhooks reported without configured targets")
+	}
+}
+
+func TestHealthHandler_VerboseStopping(t *testing.T) {
+	qbitUp := true
+	server := newHealthTestServer(t, &qbitUp)
+	server.SetRunning(false)
+
+	w := httptest.NewRecorder()
+	server.healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz?verbose=1", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("healthHandler() status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHealthHandler_SyncStale(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		started   time.Time
+		sync      sync.Status
+		wantCode  int
+	}{
+		{name: "fresh", threshold: 15 * time.Minute, sync: sync.Status{Running: true, LastSuccess: now.Add(-5 * time.Minute)}, wantCode: http.StatusOK},
+		{name: "stale", threshold: 15 * time.Minute, sync: sync.Status{Running: true, LastSuccess: now.Add(-20 * time.Minute)}, wantCode: http.StatusServiceUnavailable},
+		{name: "disabled", sync: sync.Status{Running: true, LastSuccess: now.Add(-20 * time.Minute)}, wantCode: http.StatusOK},
+		{name: "paused", threshold: 15 * time.Minute, sync: sync.Status{Running: true, Paused: true, LastSuccess: now.Add(-20 * time.Minute)}, wantCode: http.StatusOK},
+		{name: "no sync since recent start", threshold: 15 * time.Minute, started: now.Add(-time.Minute), sync: sync.Status{Running: true}, wantCode: http.StatusOK},
+		{name: "no sync since start", threshold: 15 * time.Minute, started: now.Add(-time.Hour), sync: sync.Status{Running: true}, wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{started: tt.started, syncer: &fakeSyncer{status: tt.sync}}
+			server.SetRunning(true)
+			server.SetSyncStaleThreshold(tt.threshold)
+
+			w := httptest.NewRecorder()
+			server.healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("healthHandler() status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_VerboseSyncStale(t *testing.T) {
+	qbitUp := true
+	server := newHealthTestServer(t, &qbitUp)
+	server.SetPortSyncer(&fakeSyncer{status: sync.Status{SourceHealthy: true, Running: true, LastSuccess: time.Now().Add(-time.Hour)}})
+	server.SetSyncStaleThreshold(15 * time.Minute)
+
+	health := server.detailedHealth(time.Now())
+
+	if health.Status != healthDown || health.Components.SyncLoop.Status != healthDown {
+		t.Fatalf("health = %s with sync loop %s, want down", health.Status, health.Components.SyncLoop.Status)
+	}
+	if want := "no successful sync within 15m0s"; health.Components.SyncLoop.Error != want {
+		t.Errorf("sync loop error = %q, want %q", health.Components.SyncLoop.Error, want)
+	}
+}
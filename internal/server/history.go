@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// Page sizes of /api/v1/history
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// historyHandler returns the recorded sync attempts, newest first. The query
+// parameters select them:
+//
+//	limit, offset  page through the attempts (default limit 50, at most 500)
+//	since, until   RFC 3339 times bounding the attempts, inclusively
+//	result         only attempts with this result (success, failed, skipped,
+//	               paused)
+//	changes        only attempts that changed the port, if true
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.syncer.History(query)); err != nil {
+		slog.Error("failed to encode history response", "error", err)
+	}
+}
+
+// parseHistoryQuery reads the query parameters of historyHandler
+func parseHistoryQuery(values url.Values) (sync.HistoryQuery, error) {
+	var query sync.HistoryQuery
+	var err error
+	if query.Limit, err = parseHistoryInt(values, "limit", defaultHistoryLimit); err != nil {
+		return query, err
+	}
+	if query.Limit < 1 || query.Limit > maxHistoryLimit {
+		return query, fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+	}
+	if query.Offset, err = parseHistoryInt(values, "offset", 0); err != nil {
+		return query, err
+	}
+	if query.Offset < 0 {
+		return query, errors.New("offset must not be negative")
+	}
+	if query.Since, err = parseHistoryTime(values, "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseHistoryTime(values, "until"); err != nil {
+		return query, err
+	}
+
+	query.Result = values.Get("result")
+	results := []string{sync.SyncResultSuccess, sync.SyncResultFailed, sync.SyncResultSkipped, sync.SyncResultPaused}
+	if query.Result != "" && !slices.Contains(results, query.Result) {
+		return query, fmt.Errorf("unknown result %q (available: success, failed, skipped, paused)", query.Result)
+	}
+	if value := values.Get("changes"); value != "" {
+		if query.Changes, err = strconv.ParseBool(value); err != nil {
+			return query, fmt.Errorf("invalid changes %q: must be true or false", value)
+		}
+	}
+	return query, nil
+}
+
+func parseHistoryInt(values url.Values, name string, fallback int) (int, error) {
+	value := values.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be an integer", name, value)
+	}
+	return n, nil
+}
+
+func parseHistoryTime(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: must be an RFC 3339 time", name, value)
+	}
+	return t, nil
+}
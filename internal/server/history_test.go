@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	history, err := sync.NewHistory(10, "")
+	if err != nil {
+		t.Fatalf("NewHistory() error = %v", err)
+	}
+	changed := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	history.Add(sync.SyncRecord{Time: changed, Result: sync.SyncResultSuccess, Port: 9090, OldPort: 8080})
+	history.Add(sync.SyncRecord{Time: changed.Add(time.Minute), Result: sync.SyncResultSuccess, Port: 9090})
+	syncer := &fakeSyncer{history: history}
+	server := &Server{}
+	server.SetPortSyncer(syncer)
+
+	w := httptest.NewRecorder()
+	server.historyHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/history?changes=true&since=2025-01-02T00:00:00Z&limit=5", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("historyHandler() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	want := sync.HistoryQuery{Since: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Changes: true, Limit: 5}
+	if syncer.query != want {
+		t.Errorf("query = %+v, want %+v", syncer.query, want)
+	}
+	var page sync.HistoryPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if page.Total != 1 || page.Limit != 5 || len(page.Records) != 1 || page.Records[0].OldPort != 8080 {
+		t.Errorf("response = %+v, want the single port change", page)
+	}
+}
+
+func TestHistoryHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		syncer     *fakeSyncer
+		wantStatus int
+	}{
+		{name: "defaults", method: http.MethodGet, target: "/api/v1/history", syncer: &fakeSyncer{}, wantStatus: http.StatusOK},
+		{name: "wrong method", method: http.MethodPost, target: "/api/v1/history", syncer: &fakeSyncer{}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no watcher", method: http.MethodGet, target: "/api/v1/history", wantStatus: http.StatusNotFound},
+		{name: "limit too large", method: http.MethodGet, target: "/api/v1/history?limit=501", syncer: &fakeSyncer{}, wantStatus: http.StatusBadRequest},
+		{name: "negative offset", method: http.MethodGet, target: "/api/v1/history?offset=-1", syncer: &fakeSyncer{}, wantStatus: http.StatusBadRequest},
+		{name: "invalid time", method: http.MethodGet, target: "/api/v1/history?since=yesterday", syncer: &fakeSyncer{}, wantStatus: http.StatusBadRequest},
+		{name: "unknown result", method: http.MethodGet, target: "/api/v1/history?result=maybe", syncer: &fakeSyncer{}, wantStatus: http.StatusBadRequest},
+		{name: "invalid changes", method: http.MethodGet, target: "/api/v1/history?changes=some", syncer: &fakeSyncer{}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			if tt.syncer != nil {
+				server.SetPortSyncer(tt.syncer)
+			}
+
+			w := httptest.NewRecorder()
+			server.historyHandler(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("historyHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// SetAccessLog logs one in every requests handled, and all requests that
+// fail with a 4xx or 5xx status. 0 disables the access log.
+func (s *Server) SetAccessLog(every int) {
+	s.accessLogEvery = every
+}
+
+// logRequests logs the requests passed to next as configured by SetAccessLog.
+// The query string is left out since it may carry the API key.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		sampled := s.accessLogCount.Add(1)%uint64(s.accessLogEvery) == 0
+		if !sampled && status < http.StatusBadRequest {
+			return
+		}
+		slog.Info("http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", recorder.bytes,
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr,
+		)
+	})
+}
+
+// statusRecorder records the status and size of a response. Flushing goes
+// through Unwrap; Hijack is implemented to record the protocol switch of
+// WebSocket connections.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// logBuffer collects log output written from the server's goroutines
+type logBuffer struct {
+	mu  gosync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func (b *logBuffer) String() string {
+	return string(b.Bytes())
+}
+
+func (b *logBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// captureLogs sends the default logger's output to the returned buffer for
+// the rest of the test
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+	logs := &logBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logs
+}
+
+func TestLogRequests(t *testing.T) {
+	logs := captureLogs(t)
+	server := &Server{}
+	server.SetAccessLog(1)
+	handler := server.logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("Sync requested"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/sync?apikey=secret", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode log entry %q: %v", logs.String(), err)
+	}
+	want := map[string]any{"msg": "http request", "method": "POST", "path": "/sync", "status": 202.0, "bytes": 14.0, "remote": req.RemoteAddr}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("log %s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if strings.Contains(logs.String(), "secret") {
+		t.Error("access log contains the query string")
+	}
+}
+
+func TestLogRequests_Sampling(t *testing.T) {
+	logs := captureLogs(t)
+	server := &Server{}
+	server.SetAccessLog(3)
+	status := http.StatusOK
+	handler := server.logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	for range 6 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	}
+	if got := strings.Count(logs.String(), "\n"); got != 2 {
+		t.Errorf("logged %d of 6 requests, want 2", got)
+	}
+
+	logs.Reset()
+	status = http.StatusUnauthorized
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/status", nil))
+	if !strings.Contains(logs.String(), `"status":401`) {
+		t.Errorf("failed request not logged despite sampling, log = %q", logs.String())
+	}
+}
+
+func TestStatusRecorder_DefaultStatus(t *testing.T) {
+	logs := captureLogs(t)
+	server := &Server{}
+	server.SetAccessLog(1)
+	handler := server.logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if !strings.Contains(logs.String(), `"status":200`) {
+		t.Errorf("log = %q, want status 200 for a handler that writes nothing", logs.String())
+	}
+}
+
+func TestLogRequests_WebSocket(t *testing.T) {
+	logs := captureLogs(t)
+	syncer := &fakeSyncer{records: make(chan sync.SyncRecord)}
+	server := &Server{syncer: syncer}
+	server.SetAccessLog(1)
+	httpServer := httptest.NewServer(server.logRequests(http.HandlerFunc(server.websocketHandler)))
+	defer httpServer.Close()
+
+	client := dialWebSocket(t, httpServer.URL, "")
+	if event, _ := client.receiveEvent(); event != eventStatus {
+		t.Fatalf("first message = %s, want the status", event)
+	}
+	close(syncer.records)
+	client.receive()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(logs.String(), `"status":101`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("log = %q, want the websocket connection with status 101", logs.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "forwardarr_http_requests_total",
+		Help: "Total number of HTTP requests by route and status code",
+	}, []string{"route", "code"})
+
+	httpErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "forwardarr_http_request_errors_total",
+		Help: "Total number of HTTP requests answered with a 4xx or 5xx status by route",
+	}, []string{"route"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "forwardarr_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+)
+
+// instrument records the metrics of the requests handled by mux. Requests
+// are labeled with the mux pattern they matched rather than their path, so
+// that unknown paths cannot create new series.
+func instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// ServeMux sets the pattern on the request it routed
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(route, strconv.Itoa(status)).Inc()
+		if status >= http.StatusBadRequest {
+			httpErrors.WithLabelValues(route).Inc()
+		}
+		httpDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
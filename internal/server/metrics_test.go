@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/instrumented/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("/instrumented/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	})
+	handler := instrument(mux)
+
+	okRequests := httpRequests.WithLabelValues("/instrumented/{name}", "200")
+	failedRequests := httpRequests.WithLabelValues("/instrumented/fail", "404")
+	baselineOK := testutil.ToFloat64(okRequests)
+	baselineFailed := testutil.ToFloat64(failedRequests)
+	baselineErrors := testutil.ToFloat64(httpErrors.WithLabelValues("/instrumented/fail"))
+
+	for _, path := range []string{"/instrumented/a", "/instrumented/b", "/instrumented/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(okRequests); got != baselineOK+2 {
+		t.Errorf("requests of the pattern = %v, want %v", got, baselineOK+2)
+	}
+	if got := testutil.ToFloat64(failedRequests); got != baselineFailed+1 {
+		t.Errorf("failed requests = %v, want %v", got, baselineFailed+1)
+	}
+	if got := testutil.ToFloat64(httpErrors.WithLabelValues("/instrumented/fail")); got != baselineErrors+1 {
+		t.Errorf("errors = %v, want %v", got, baselineErrors+1)
+	}
+	if got := testutil.CollectAndCount(httpDuration, "forwardarr_http_request_duration_seconds"); got < 2 {
+		t.Errorf("duration series = %d, want one per route", got)
+	}
+}
+
+func TestInstrument_Unmatched(t *testing.T) {
+	handler := instrument(http.NewServeMux())
+	unmatched := httpRequests.WithLabelValues("unmatched", "404")
+	baseline := testutil.ToFloat64(unmatched)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/random/path", nil))
+
+	if got := testutil.ToFloat64(unmatched); got != baseline+1 {
+		t.Errorf("unmatched requests = %v, want %v", got, baseline+1)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// pauseHandler pauses the port sync for maintenance, e.g. while qBittorrent
+// is upgraded. Syncs keep reading the port file but leave qBittorrent
+// unchanged until resumeHandler is called.
+func (s *Server) pauseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+
+	if !s.syncer.Pause() {
+		_, _ = w.Write([]byte("Sync already paused"))
+		return
+	}
+	slog.Info("port sync pause requested", "remote", r.RemoteAddr)
+	_, _ = w.Write([]byte("Sync paused"))
+}
+
+// resumeHandler ends the pause of pauseHandler and syncs the port right away
+func (s *Server) resumeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+
+	if !s.syncer.Resume() {
+		_, _ = w.Write([]byte("Sync not paused"))
+		return
+	}
+	slog.Info("port sync resume requested", "remote", r.RemoteAddr)
+	_, _ = w.Write([]byte("Sync resumed"))
+}
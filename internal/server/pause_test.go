@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPauseAndResumeHandlers(t *testing.T) {
+	syncer := &fakeSyncer{}
+	server := &Server{}
+	server.SetPortSyncer(syncer)
+
+	steps := []struct {
+		handler    http.HandlerFunc
+		wantBody   string
+		wantPaused bool
+	}{
+		{handler: server.resumeHandler, wantBody: "Sync not paused"},
+		{handler: server.pauseHandler, wantBody: "Sync paused", wantPaused: true},
+		{handler: server.pauseHandler, wantBody: "Sync already paused", wantPaused: true},
+		{handler: server.resumeHandler, wantBody: "Sync resumed"},
+	}
+	for i, step := range steps {
+		w := httptest.NewRecorder()
+		step.handler(w, httptest.NewRequest(http.MethodPost, "/api/v1/pause", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != step.wantBody {
+			t.Errorf("step %d: response = %d %q, want 200 %q", i, w.Code, w.Body.String(), step.wantBody)
+		}
+		if syncer.status.Paused != step.wantPaused {
+			t.Errorf("step %d: paused = %v, want %v", i, syncer.status.Paused, step.wantPaused)
+		}
+	}
+	if syncer.triggered != 1 {
+		t.Errorf("TriggerSync() called %d times, want 1 on resume", syncer.triggered)
+	}
+}
+
+func TestPauseAndResumeHandlers_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		syncer     *fakeSyncer
+		wantStatus int
+	}{
+		{name: "wrong method", method: http.MethodGet, syncer: &fakeSyncer{}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no watcher", method: http.MethodPost, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			if tt.syncer != nil {
+				server.SetPortSyncer(tt.syncer)
+			}
+
+			for name, handler := range map[string]http.HandlerFunc{"pauseHandler": server.pauseHandler, "resumeHandler": server.resumeHandler} {
+				w := httptest.NewRecorder()
+				handler(w, httptest.NewRequest(tt.method, "/api/v1/pause", nil))
+				if w.Code != tt.wantStatus {
+					t.Errorf("%s() status = %d, want %d", name, w.Code, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
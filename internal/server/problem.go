@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// problemTypeBase is the URI of the documentation of the problem types, to
+// which their name is appended as fragment
+const problemTypeBase = "https://github.com/eslutz/forwardarr/blob/main/docs/problems.md#"
+
+// problemType is a kind of error response. Every occurrence of a type has
+// the same title and status; the detail describes the occurrence.
+type problemType struct {
+	name   string
+	title  string
+	status int
+}
+
+var (
+	problemInvalidRequest   = problemType{"invalid-request", "Invalid request", http.StatusBadRequest}
+	problemUnauthorized     = problemType{"unauthorized", "Unauthorized", http.StatusUnauthorized}
+	problemNotFound         = problemType{"not-found", "Not found", http.StatusNotFound}
+	problemNotConfigured    = problemType{"not-configured", "Feature not configured", http.StatusNotFound}
+	problemMethodNotAllowed = problemType{"method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed}
+	problemInvalidConfig    = problemType{"invalid-config", "Invalid configuration", http.StatusUnprocessableEntity}
+	problemUpgradeRequired  = problemType{"upgrade-required", "WebSocket upgrade required", http.StatusUpgradeRequired}
+	problemRateLimited      = problemType{"rate-limited", "Too many requests", http.StatusTooManyRequests}
+	problemInternal         = problemType{"internal-error", "Internal server error", http.StatusInternalServerError}
+	problemNotSynced        = problemType{"not-synced", "Port not synced yet", http.StatusServiceUnavailable}
+)
+
+// problem is a problem details object of RFC 9457, formerly RFC 7807
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// writeProblem responds with an application/problem+json error of the type.
+// Detail explains this occurrence to the client.
+func writeProblem(w http.ResponseWriter, r *http.Request, kind problemType, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(kind.status)
+	err := json.NewEncoder(w).Encode(problem{
+		Type:     problemTypeBase + kind.name,
+		Title:    kind.title,
+		Status:   kind.status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+	if err != nil {
+		slog.Debug("failed to write problem response", "type", kind.name, "error", err)
+	}
+}
+
+// methodNotAllowed responds with 405 to a request whose method the endpoint
+// does not support; allow lists the supported methods
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeProblem(w, r, problemMethodNotAllowed, fmt.Sprintf("%s is not supported by %s (allowed: %s)", r.Method, r.URL.Path, allow))
+}
+
+// notFoundHandler answers requests for paths without an endpoint
+func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemNotFound, fmt.Sprintf("no endpoint at %s", r.URL.Path))
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeProblem checks that the response is a problem of the type and
+// returns it
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder, kind problemType) problem {
+	t.Helper()
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json", got)
+	}
+	var body problem
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	if w.Code != kind.status || body.Status != kind.status {
+		t.Errorf("status = %d, body status = %d, want %d", w.Code, body.Status, kind.status)
+	}
+	if body.Type != problemTypeBase+kind.name || body.Title != kind.title {
+		t.Errorf("problem = %s %q, want %s %q", body.Type, body.Title, problemTypeBase+kind.name, kind.title)
+	}
+	return body
+}
+
+func TestWriteProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeProblem(w, httptest.NewRequest(http.MethodPost, "/api/v1/port?apikey=secret", nil), problemInvalidRequest, "Invalid port")
+
+	body := decodeProblem(t, w, problemInvalidRequest)
+	if body.Detail != "Invalid port" {
+		t.Errorf("detail = %q, want %q", body.Detail, "Invalid port")
+	}
+	if body.Instance != "/api/v1/port" {
+		t.Errorf("instance = %q, want the path without query", body.Instance)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	server := &Server{}
+	w := httptest.NewRecorder()
+	server.syncHandler(w, httptest.NewRequest(http.MethodGet, "/sync", nil))
+
+	body := decodeProblem(t, w, problemMethodNotAllowed)
+	if got := w.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow = %q, want POST", got)
+	}
+	if !strings.Contains(body.Detail, "GET") {
+		t.Errorf("detail = %q, want the rejected method", body.Detail)
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiKey  string
+		syncer  *fakeSyncer
+		handler func(s *Server) http.HandlerFunc
+		target  string
+		kind    problemType
+	}{
+		{name: "unknown path", handler: func(s *Server) http.HandlerFunc { return s.notFoundHandler }, target: "/nothing", kind: problemNotFound},
+		{name: "no watcher", handler: func(s *Server) http.HandlerFunc { return s.portHandler }, target: "/port", kind: problemNotConfigured},
+		{name: "not synced", syncer: &fakeSyncer{}, handler: func(s *Server) http.HandlerFunc { return s.portHandler }, target: "/port", kind: problemNotSynced},
+		{name: "invalid query", syncer: &fakeSyncer{}, handler: func(s *Server) http.HandlerFunc { return s.historyHandler }, target: "/api/v1/history?limit=x", kind: problemInvalidRequest},
+		{name: "missing api key", apiKey: "secret", handler: func(s *Server) http.HandlerFunc { return s.protect(s.statusHandler) }, target: "/status", kind: problemUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			server.SetAPIKey(tt.apiKey)
+			if tt.syncer != nil {
+				server.SetPortSyncer(tt.syncer)
+			}
+
+			w := httptest.NewRecorder()
+			tt.handler(server)(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			decodeProblem(t, w, tt.kind)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxPushBody limits the body of /api/v1/port, which only carries a port
+const maxPushBody = 1024
+
+// pushPortRequest is the JSON body of /api/v1/port. NewPort accepts the json
+// webhook of another Forwardarr.
+type pushPortRequest struct {
+	Port    int `json:"port"`
+	NewPort int `json:"new_port"`
+}
+
+// pushPortHandler syncs a port pushed by an external system, e.g. the up
+// command of Gluetun or the json webhook of another Forwardarr, instead of
+// the port in the port file. The body is either JSON with "port" or
+// "new_port", or the port as plain text; of a comma-separated list of ports,
+// as sent by Gluetun, the first is used.
+func (s *Server) pushPortHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushBody))
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	port, err := parsePushedPort(body)
+	if err == nil {
+		err = s.syncer.PushPort(port)
+	}
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequest, "Invalid port: "+err.Error())
+		return
+	}
+
+	slog.Info("port push received", "port", port, "remote", r.RemoteAddr)
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("Sync requested"))
+}
+
+// parsePushedPort reads the port from a JSON or plain text body
+func parsePushedPort(body []byte) (int, error) {
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return 0, errors.New("empty request body")
+	}
+
+	if strings.HasPrefix(text, "{") {
+		var request pushPortRequest
+		if err := json.Unmarshal([]byte(text), &request); err != nil {
+			return 0, fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		if request.Port != 0 {
+			return request.Port, nil
+		}
+		if request.NewPort != 0 {
+			return request.NewPort, nil
+		}
+		return 0, errors.New(`JSON body has no "port" or "new_port"`)
+	}
+
+	first, _, _ := strings.Cut(text, ",")
+	port, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", first)
+	}
+	return port, nil
+}
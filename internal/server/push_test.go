@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushPortHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		noSyncer   bool
+		wantStatus int
+		wantPort   int
+	}{
+		{name: "plain text", method: http.MethodPost, body: "9090\n", wantStatus: http.StatusAccepted, wantPort: 9090},
+		{name: "gluetun ports", method: http.MethodPost, body: "9090,9091", wantStatus: http.StatusAccepted, wantPort: 9090},
+		{name: "json port", method: http.MethodPost, body: `{"port": 9090}`, wantStatus: http.StatusAccepted, wantPort: 9090},
+		{name: "forwardarr webhook", method: http.MethodPost, body: `{"event": "port_changed", "old_port": 8080, "new_port": 9090}`, wantStatus: http.StatusAccepted, wantPort: 9090},
+		{name: "empty body", method: http.MethodPost, wantStatus: http.StatusBadRequest},
+		{name: "not a number", method: http.MethodPost, body: "port", wantStatus: http.StatusBadRequest},
+		{name: "json without port", method: http.MethodPost, body: `{"event": "startup"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid json", method: http.MethodPost, body: `{"port": "9090"}`, wantStatus: http.StatusBadRequest},
+		{name: "out of range", method: http.MethodPost, body: "70000", wantStatus: http.StatusBadRequest},
+		{name: "body too large", method: http.MethodPost, body: strings.Repeat(" ", maxPushBody) + "9090", wantStatus: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no watcher", method: http.MethodPost, body: "9090", noSyncer: true, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syncer := &fakeSyncer{}
+			server := &Server{}
+			if !tt.noSyncer {
+				server.SetPortSyncer(syncer)
+			}
+
+			w := httptest.NewRecorder()
+			server.pushPortHandler(w, httptest.NewRequest(tt.method, "/api/v1/port", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("pushPortHandler() status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if syncer.pushed != tt.wantPort {
+				t.Errorf("pushed port = %d, want %d", syncer.pushed, tt.wantPort)
+			}
+		})
+	}
+}
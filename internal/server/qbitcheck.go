@@ -0,0 +1,33 @@
+package server
+
+import (
+	gosync "sync"
+	"time"
+)
+
+// qbitCheckTTL is how long the result of a qBittorrent ping is reused, so
+// that frequent probes do not flood qBittorrent with requests
+const qbitCheckTTL = 5 * time.Second
+
+// qbitCheck caches the last qBittorrent ping. Its mutex is held during the
+// ping, so that concurrent probes wait for a single ping instead of each
+// sending their own.
+type qbitCheck struct {
+	mu      gosync.Mutex
+	checked time.Time
+	err     error
+}
+
+// pingQbit reports whether qBittorrent is reachable, pinging it at most once
+// per qbitCheckTTL
+func (s *Server) pingQbit() error {
+	s.qbitCheck.mu.Lock()
+	defer s.qbitCheck.mu.Unlock()
+
+	if !s.qbitCheck.checked.IsZero() && time.Since(s.qbitCheck.checked) < qbitCheckTTL {
+		return s.qbitCheck.err
+	}
+	s.qbitCheck.err = s.qbitClient.PingOnce()
+	s.qbitCheck.checked = time.Now()
+	return s.qbitCheck.err
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	gosync "sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+)
+
+func TestPingQbit_Cached(t *testing.T) {
+	var pings atomic.Int32
+	qbitServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/app/version") {
+			pings.Add(1)
+			// Slow enough for the probes below to overlap
+			time.Sleep(20 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte("Ok."))
+	}))
+	defer qbitServer.Close()
+
+	client, err := qbit.NewClient(qbitServer.URL, "admin", "admin")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	server := &Server{qbitClient: client}
+	server.SetRunning(true)
+
+	var wg gosync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			w := httptest.NewRecorder()
+			server.readyHandler(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("readyHandler() status = %d, want %d", w.Code, http.StatusOK)
+			}
+		})
+	}
+	wg.Wait()
+	if got := pings.Load(); got != 1 {
+		t.Errorf("qBittorrent pinged %d times by concurrent probes, want 1", got)
+	}
+
+	// Expire the cached result
+	server.qbitCheck.checked = time.Now().Add(-qbitCheckTTL)
+	if err := server.pingQbit(); err != nil {
+		t.Fatalf("pingQbit() error = %v", err)
+	}
+	if got := pings.Load(); got != 2 {
+		t.Errorf("qBittorrent pinged %d times after the cache expired, want 2", got)
+	}
+}
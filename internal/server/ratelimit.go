@@ -0,0 +1,109 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	gosync "sync"
+	"time"
+)
+
+// clientLimiter keeps a token bucket per client. A bucket holds up to burst
+// tokens and refills at perMinute tokens per minute; every request takes a
+// token.
+type clientLimiter struct {
+	perMinute int
+	burst     int
+
+	mu      gosync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// allow takes a token from the client's bucket at now. If the bucket is
+// empty, it returns false and the time until the next token.
+func (l *clientLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rate := float64(l.perMinute) / float64(time.Minute)
+	// Full buckets are dropped, so that the map only holds recent clients
+	for key, bucket := range l.buckets {
+		if bucket.level(now, rate, l.burst) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		if l.buckets == nil {
+			l.buckets = make(map[string]*tokenBucket)
+		}
+		bucket = &tokenBucket{tokens: float64(l.burst), updated: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = bucket.level(now, rate, l.burst)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate)
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// level returns the tokens in the bucket at now
+func (b *tokenBucket) level(now time.Time, rate float64, burst int) float64 {
+	return min(b.tokens+float64(now.Sub(b.updated))*rate, float64(burst))
+}
+
+// SetRateLimit limits every client to perMinute requests per minute, after
+// an initial burst, on the endpoints that trigger actions. A limit of 0 or
+// less disables rate limiting.
+func (s *Server) SetRateLimit(perMinute, burst int) {
+	if perMinute <= 0 {
+		s.limiter = nil
+		return
+	}
+	s.limiter = &clientLimiter{perMinute: perMinute, burst: max(burst, 1)}
+}
+
+// rateLimit rejects requests of clients that exceed the rate limit with 429.
+// Clients are told apart by their API key if one is required, so that all
+// users of the key share its limit, and by IP address otherwise.
+func (s *Server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next(w, r)
+			return
+		}
+
+		client := s.rateLimitClient(r)
+		if ok, retry := s.limiter.allow(client, time.Now()); !ok {
+			slog.Warn("rejected request over rate limit", "path", r.URL.Path, "remote", r.RemoteAddr)
+			seconds := strconv.Itoa(int(math.Ceil(retry.Seconds())))
+			w.Header().Set("Retry-After", seconds)
+			writeProblem(w, r, problemRateLimited, "Rate limit exceeded, retry after "+seconds+" seconds")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimitClient identifies the client of a request that passed
+// requireAPIKey
+func (s *Server) rateLimitClient(r *http.Request) string {
+	if s.currentAPIKey() != "" {
+		return "api key"
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
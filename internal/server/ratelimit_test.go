@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientLimiter(t *testing.T) {
+	limiter := &clientLimiter{perMinute: 6, burst: 2}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for i := range 2 {
+		if ok, _ := limiter.allow("10.0.0.1", now); !ok {
+			t.Fatalf("request %d within the burst rejected", i+1)
+		}
+	}
+	ok, retry := limiter.allow("10.0.0.1", now)
+	if ok {
+		t.Fatal("request beyond the burst allowed")
+	}
+	if retry != 10*time.Second {
+		t.Errorf("retry after %v, want 10s", retry)
+	}
+	if ok, _ := limiter.allow("10.0.0.2", now); !ok {
+		t.Error("request of another client rejected")
+	}
+
+	// One token per 10 seconds
+	if ok, _ := limiter.allow("10.0.0.1", now.Add(10*time.Second)); !ok {
+		t.Error("request after the refill rejected")
+	}
+	if ok, _ := limiter.allow("10.0.0.1", now.Add(15*time.Second)); ok {
+		t.Error("request before the next refill allowed")
+	}
+
+	// Buckets that refilled completely are dropped
+	limiter.allow("10.0.0.3", now.Add(time.Hour))
+	if len(limiter.buckets) != 1 {
+		t.Errorf("limiter keeps %d buckets, want only the latest client", len(limiter.buckets))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiKey  string
+		remotes []string
+		want    []int
+	}{
+		{
+			name:    "per address",
+			remotes: []string{"10.0.0.1:1000", "10.0.0.1:1001", "10.0.0.2:1000"},
+			want:    []int{http.StatusAccepted, http.StatusTooManyRequests, http.StatusAccepted},
+		},
+		{
+			name:    "shared api key",
+			apiKey:  "secret",
+			remotes: []string{"10.0.0.1:1000", "10.0.0.2:1000"},
+			want:    []int{http.StatusAccepted, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{}
+			server.SetAPIKey(tt.apiKey)
+			server.SetRateLimit(1, 1)
+			handler := server.rateLimit(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})
+
+			for i, remote := range tt.remotes {
+				req := httptest.NewRequest(http.MethodPost, "/sync", nil)
+				req.RemoteAddr = remote
+				w := httptest.NewRecorder()
+				handler(w, req)
+
+				if w.Code != tt.want[i] {
+					t.Errorf("request %d from %s status = %d, want %d", i+1, remote, w.Code, tt.want[i])
+				}
+				if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+					t.Errorf("Retry-After = %q, want 60", w.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	server := &Server{}
+	server.SetRateLimit(0, 5)
+	handler := server.rateLimit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for range 20 {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/sync", nil))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d without a rate limit", w.Code, http.StatusAccepted)
+		}
+	}
+}
@@ -2,54 +2,316 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	gosync "sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
-	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/sync"
+	"github.com/eslutz/forwardarr/internal/torrent"
+	"github.com/eslutz/forwardarr/internal/webhook"
 )
 
 type Server struct {
-	port       string
-	qbitClient *qbit.Client
-	isRunning  bool
+	addresses  []string
+	qbitClient torrent.Client
+	qbitCheck  qbitCheck
+	isRunning  atomic.Bool
 	server     *http.Server
+	syncer     PortSyncer
+	config     ConfigManager
+	started    time.Time
+	// syncStaleAfter fails the health checks when no sync succeeded within
+	// it; 0 disables the check
+	syncStaleAfter time.Duration
+	// accessLogEvery samples the access log, which accessLogCount counts
+	// requests for; 0 disables it
+	accessLogEvery int
+	accessLogCount atomic.Uint64
+	// limiter rate limits the endpoints that trigger actions when set
+	limiter *clientLimiter
+	// corsOrigins may call the endpoints from browsers with corsMethods
+	corsOrigins []string
+	corsMethods []string
+
+	// mu guards the settings a config reload replaces while the server is
+	// running: webhooks and apiKey, which is required by the status and
+	// control endpoints when set
+	mu       gosync.RWMutex
+	webhooks Webhooks
+	apiKey   string
+	// certs serves HTTPS when set; clientCAs then verifies the client
+	// certificates required by the status and control endpoints
+	certs     *certReloader
+	clientCAs *x509.CertPool
+
+	// streams is canceled on shutdown to end open event streams, which would
+	// otherwise keep the server from shutting down
+	streams     context.Context
+	stopStreams context.CancelFunc
+}
+
+// PortSyncer reports the state of the port sync shown by /status, runs a
+// sync on demand, streams the sync attempts to /api/v1/events and /ws and
+// lists the past ones on /api/v1/history. PushPort syncs a port pushed to
+// /api/v1/port instead of the one in the port file. Pause and Resume
+// switch the maintenance mode of /api/v1/pause and /api/v1/resume and
+// report whether they changed it.
+type PortSyncer interface {
+	Status() sync.Status
+	TriggerSync()
+	PushPort(port int) error
+	Pause() bool
+	Resume() bool
+	Subscribe() (records <-chan sync.SyncRecord, unsubscribe func())
+	History(query sync.HistoryQuery) sync.HistoryPage
+}
+
+// Webhooks are the configured webhook targets. SendTest sends test
+// notifications to the named target, or to all targets if name is empty, and
+// returns the result per target; the deliveries are canceled with ctx, e.g.
+// when the client disconnects. TargetStatus reports the delivery health of
+// the targets for /healthz.
+type Webhooks interface {
+	SendTest(ctx context.Context, name string) map[string]error
+	TargetStatus() []webhook.TargetStatus
+}
+
+// ConfigManager shows the effective configuration on /api/v1/config, with
+// secrets redacted, and reloads it on /api/v1/config/reload. Reload returns
+// an error, and keeps the current configuration, if the new one is invalid.
+type ConfigManager interface {
+	Config() map[string]string
+	Reload(ctx context.Context) error
 }
 
-func NewServer(port string, qbitClient *qbit.Client) *Server {
-	return &Server{
-		port:       port,
+// NewServer creates a server listening on all interfaces on the given port
+func NewServer(port string, qbitClient torrent.Client) *Server {
+	streams, stopStreams := context.WithCancel(context.Background())
+	s := &Server{
+		addresses:  []string{":" + port},
 		qbitClient: qbitClient,
-		isRunning:  true,
+		// The server is created up front so that Shutdown is safe to call
+		// while Start is still setting up
+		server:      &http.Server{},
+		started:     time.Now(),
+		streams:     streams,
+		stopStreams: stopStreams,
 	}
+	s.isRunning.Store(true)
+	return s
 }
 
+// Start serves the endpoints on all addresses, or on the sockets passed by
+// systemd socket activation, until Shutdown, and then returns nil. It fails
+// if any address cannot be listened on.
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 
+	// Probes, metrics, build details and the dashboard page stay open; the dashboard
+	// passes the API key on to the endpoints it calls, and browsers present
+	// their client certificate on every request
 	mux.HandleFunc("/health", s.healthHandler)
+	mux.HandleFunc("/healthz", s.healthHandler)
 	mux.HandleFunc("/ready", s.readyHandler)
-	mux.HandleFunc("/status", s.statusHandler)
+	mux.HandleFunc("/version", s.versionHandler)
+	mux.HandleFunc("/status", s.protect(s.statusHandler))
+	mux.HandleFunc("/port", s.protect(s.portHandler))
+	mux.HandleFunc("/sync", s.protect(s.rateLimit(s.syncHandler)))
+	mux.HandleFunc("/webhook/test", s.protect(s.rateLimit(s.webhookTestHandler)))
+	mux.HandleFunc("/api/v1/events", s.protect(s.eventsHandler))
+	mux.HandleFunc("/api/v1/history", s.protect(s.historyHandler))
+	mux.HandleFunc("/api/v1/port", s.protect(s.rateLimit(s.pushPortHandler)))
+	mux.HandleFunc("/api/v1/pause", s.protect(s.rateLimit(s.pauseHandler)))
+	mux.HandleFunc("/api/v1/resume", s.protect(s.rateLimit(s.resumeHandler)))
+	mux.HandleFunc("/api/v1/config", s.protect(s.configHandler))
+	mux.HandleFunc("/api/v1/config/reload", s.protect(s.rateLimit(s.configReloadHandler)))
+	mux.HandleFunc("/ws", s.protect(s.websocketHandler))
+	mux.HandleFunc("/{$}", s.dashboardHandler)
+	mux.HandleFunc("/", s.notFoundHandler)
 	mux.Handle("/metrics", promhttp.Handler())
 
-	addr := ":" + s.port
-	s.server = &http.Server{
-		Addr:    addr,
-		Handler: mux,
+	handler := instrument(mux)
+	if len(s.corsOrigins) > 0 {
+		handler = s.cors(handler)
+	}
+	if s.accessLogEvery > 0 {
+		handler = s.logRequests(handler)
+	}
+	s.server.Handler = handler
+	if s.certs != nil {
+		s.server.TLSConfig = s.tlsConfig()
 	}
 
-	slog.Info("starting http server", "address", addr)
-	return s.server.ListenAndServe()
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func() { errs <- s.serve(listener) }()
+	}
+	for range listeners {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen returns the sockets passed by systemd socket activation, if any,
+// or listens on the configured addresses
+func (s *Server) listen() ([]net.Listener, error) {
+	listeners, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		slog.Info("using sockets from systemd socket activation, ignoring the configured addresses", "sockets", len(listeners))
+		return listeners, nil
+	}
+
+	listeners = make([]net.Listener, 0, len(s.addresses))
+	for _, addr := range s.addresses {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// serve accepts connections on the listener until the server is shut down
+func (s *Server) serve(listener net.Listener) error {
+	if s.certs != nil {
+		slog.Info("starting https server", "address", listener.Addr().String())
+		return s.server.ServeTLS(listener, "", "")
+	}
+	slog.Info("starting http server", "address", listener.Addr().String())
+	return s.server.Serve(listener)
+}
+
+// tlsConfig returns the TLS configuration of the HTTPS server
+func (s *Server) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: s.certs.GetCertificate,
+	}
+	if s.clientCAs != nil {
+		// Certificates are only required by the protected endpoints, so that
+		// probes can connect without one
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = s.clientCAs
+	}
+	return config
 }
 
+// Shutdown stops accepting connections and waits until ctx is done for the
+// requests in progress to finish. The server reports itself as stopping to
+// probes and /status in the meantime; event streams end right away.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.SetRunning(false)
+	if s.stopStreams != nil {
+		s.stopStreams()
+	}
 	if s.server != nil {
 		return s.server.Shutdown(ctx)
 	}
 	return nil
 }
 
+// SetAPIKey requires the key on the status and control endpoints. An empty
+// key leaves them open. It is safe to call while the server is running.
+func (s *Server) SetAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+func (s *Server) currentAPIKey() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apiKey
+}
+
+// SetAddresses replaces the addresses the server listens on, each given as
+// host:port
+func (s *Server) SetAddresses(addresses []string) {
+	s.addresses = addresses
+}
+
+// SetTLS serves HTTPS with the certificate and key in the given PEM files.
+// The files are reloaded when they change.
+func (s *Server) SetTLS(certFile, keyFile string) error {
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("tls requires both a certificate and a key file")
+	}
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	s.certs = certs
+	return nil
+}
+
+// SetClientCA requires client certificates signed by a CA in the given PEM
+// file on the status and control endpoints. It requires SetTLS.
+func (s *Server) SetClientCA(caFile string) error {
+	if s.certs == nil {
+		return fmt.Errorf("client certificate verification requires tls")
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in client ca file %s", caFile)
+	}
+	s.clientCAs = pool
+	return nil
+}
+
+// SetWebhooks enables the webhook test endpoint and adds the webhook targets
+// to the detailed health check. It is safe to call while the server is
+// running.
+func (s *Server) SetWebhooks(webhooks Webhooks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks = webhooks
+}
+
+func (s *Server) currentWebhooks() Webhooks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.webhooks
+}
+
+// SetConfigManager enables the config endpoints
+func (s *Server) SetConfigManager(config ConfigManager) {
+	s.config = config
+}
+
+// SetPortSyncer adds the state of the port sync to the status endpoint and
+// enables the sync endpoint
+func (s *Server) SetPortSyncer(syncer PortSyncer) {
+	s.syncer = syncer
+}
+
 func (s *Server) SetRunning(running bool) {
-	s.isRunning = running
+	s.isRunning.Store(running)
 }
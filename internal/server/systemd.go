@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation (SD_LISTEN_FDS_START)
+const listenFDsStart = 3
+
+// systemdListeners returns the sockets passed by systemd socket activation,
+// or nil if the process was not socket activated. Like sd_listen_fds, it
+// unsets the variables of the protocol so that child processes do not
+// mistake the sockets for theirs.
+func systemdListeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	return socketListeners(os.Getenv, listenFDsStart)
+}
+
+// socketListeners implements systemdListeners for the environment getenv
+// and sockets starting at the file descriptor start
+func socketListeners(getenv func(string) string, start int) ([]net.Listener, error) {
+	pid, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q from systemd", getenv("LISTEN_FDS"))
+	}
+	var names []string
+	if value := getenv("LISTEN_FDNAMES"); value != "" {
+		names = strings.Split(value, ":")
+	}
+
+	listeners := make([]net.Listener, 0, count)
+	for i := range count {
+		fd := start + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// FileListener uses a duplicate of the descriptor, so the original
+		// is closed either way
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to use socket %s from systemd: %w", name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestSocketListeners(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	file, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("failed to get socket file: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	// socketListeners takes over the descriptor it is passed, as it would
+	// the one from systemd
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("failed to duplicate socket: %v", err)
+	}
+	env := map[string]string{
+		"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS":     "1",
+		"LISTEN_FDNAMES": "forwardarr.socket",
+	}
+
+	listeners, err := socketListeners(func(key string) string { return env[key] }, fd)
+	if err != nil {
+		t.Fatalf("socketListeners() error = %v", err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("socketListeners() returned %d listeners, want 1", len(listeners))
+	}
+	defer func() { _ = listeners[0].Close() }()
+	if listeners[0].Addr().String() != listener.Addr().String() {
+		t.Errorf("listener address = %s, want %s", listeners[0].Addr(), listener.Addr())
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})}
+	go func() { _ = server.Serve(listeners[0]) }()
+	defer func() { _ = server.Close() }()
+	resp, err := http.Get("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("request to inherited socket failed: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestSocketListeners_NotActivated(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "no variables", env: map[string]string{}},
+		{name: "other process", env: map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid() + 1), "LISTEN_FDS": "1"}},
+		{name: "no sockets", env: map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "0"}},
+		{name: "invalid count", env: map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "many"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listeners, err := socketListeners(func(key string) string { return tt.env[key] }, listenFDsStart)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("socketListeners() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(listeners) != 0 {
+				t.Errorf("socketListeners() returned %d listeners, want none", len(listeners))
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	gosync "sync"
+	"time"
+)
+
+// certReloader serves the certificate of a cert/key file pair and reloads it
+// when either file changes, so renewed certificates are picked up without a
+// restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      gosync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// newCertReloader loads the certificate, failing if it cannot be used
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(certMod, keyMod); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// modTimes returns the modification times of the certificate and key files
+func (c *certReloader) modTimes() (certMod, keyMod time.Time, err error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to read tls certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to read tls key: %w", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// load reads the key pair and records the modification times it was read
+// at. It must be called with c.mu held or before c is shared.
+func (c *certReloader) load(certMod, keyMod time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls key pair: %w", err)
+	}
+	c.cert = &cert
+	c.certMod = certMod
+	c.keyMod = keyMod
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A changed key pair
+// that fails to load, e.g. because only one of the files has been replaced
+// yet, keeps the previous certificate in use.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		slog.Warn("failed to check tls certificate for changes", "error", err)
+		return c.cert, nil
+	}
+	if certMod.Equal(c.certMod) && keyMod.Equal(c.keyMod) {
+		return c.cert, nil
+	}
+
+	if err := c.load(certMod, keyMod); err != nil {
+		slog.Warn("failed to reload tls certificate, keeping the previous one", "error", err)
+		return c.cert, nil
+	}
+	slog.Info("reloaded tls certificate", "cert_file", c.certFile)
+	return c.cert, nil
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for the common name and its
+// key to certFile and keyFile
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+}
+
+// servedCommonName returns the common name of the certificate the reloader
+// currently serves
+func servedCommonName(t *testing.T, certs *certReloader) string {
+	t.Helper()
+	cert, err := certs.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse served certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile, "first")
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	if got := servedCommonName(t, certs); got != "first" {
+		t.Fatalf("served certificate = %q, want first", got)
+	}
+
+	writeTestCert(t, certFile, keyFile, "renewed")
+	// Make sure the change is visible on file systems with coarse timestamps
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	_ = os.Chtimes(keyFile, later, later)
+	if got := servedCommonName(t, certs); got != "renewed" {
+		t.Errorf("served certificate = %q after renewal, want renewed", got)
+	}
+
+	// A broken key pair keeps the previous certificate
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	evenLater := later.Add(time.Minute)
+	_ = os.Chtimes(keyFile, evenLater, evenLater)
+	if got := servedCommonName(t, certs); got != "renewed" {
+		t.Errorf("served certificate = %q after a broken update, want renewed", got)
+	}
+}
+
+func TestSetTLS_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile, "forwardarr")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+	}{
+		{name: "missing key", certFile: certFile},
+		{name: "missing file", certFile: certFile, keyFile: filepath.Join(dir, "missing.key")},
+		{name: "swapped files", certFile: keyFile, keyFile: certFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&Server{}).SetTLS(tt.certFile, tt.keyFile); err == nil {
+				t.Error("SetTLS() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := filepath.Join(dir, "server.crt")
+	serverKey := filepath.Join(dir, "server.key")
+	clientCert := filepath.Join(dir, "client.crt")
+	clientKey := filepath.Join(dir, "client.key")
+	otherCert := filepath.Join(dir, "other.crt")
+	otherKey := filepath.Join(dir, "other.key")
+	writeTestCert(t, serverCert, serverKey, "forwardarr")
+	// The self-signed client certificate acts as its own CA
+	writeTestCert(t, clientCert, clientKey, "client")
+	writeTestCert(t, otherCert, otherKey, "other")
+
+	server := &Server{}
+	server.SetRunning(true)
+	if err := server.SetTLS(serverCert, serverKey); err != nil {
+		t.Fatalf("SetTLS() error = %v", err)
+	}
+	if err := server.SetClientCA(clientCert); err != nil {
+		t.Fatalf("SetClientCA() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", server.healthHandler)
+	mux.HandleFunc("/sync", server.protect(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	httpServer := httptest.NewUnstartedServer(mux)
+	httpServer.TLS = server.tlsConfig()
+	httpServer.StartTLS()
+	defer httpServer.Close()
+
+	request := func(path, certFile, keyFile string) int {
+		t.Helper()
+		config := &tls.Config{InsecureSkipVerify: true}
+		if certFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				t.Fatalf("failed to load client certificate: %v", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, err := client.Post(httpServer.URL+path, "", nil)
+		if err != nil {
+			// Certificates from another CA fail the handshake
+			return 0
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := request("/sync", clientCert, clientKey); got != http.StatusAccepted {
+		t.Errorf("request with client certificate = %d, want %d", got, http.StatusAccepted)
+	}
+	if got := request("/sync", "", ""); got != http.StatusUnauthorized {
+		t.Errorf("request without client certificate = %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := request("/sync", otherCert, otherKey); got == http.StatusAccepted {
+		t.Error("request with certificate of another CA was accepted")
+	}
+	if got := request("/health", "", ""); got != http.StatusOK {
+		t.Errorf("health check without client certificate = %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestSetClientCA_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile, "forwardarr")
+
+	if err := (&Server{}).SetClientCA(certFile); err == nil {
+		t.Error("SetClientCA() without tls error = nil, want error")
+	}
+
+	server := &Server{}
+	if err := server.SetTLS(certFile, keyFile); err != nil {
+		t.Fatalf("SetTLS() error = %v", err)
+	}
+	if err := server.SetClientCA(keyFile); err == nil {
+		t.Error("SetClientCA() with a key file error = nil, want error")
+	}
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+)
+
+// websocketGUID is appended to the client key to compute the accept key of
+// the handshake (RFC 6455, section 1.3)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal      = 1000
+	wsCloseGoingAway   = 1001
+	wsCloseUnsupported = 1003
+	wsCloseTooBig      = 1009
+)
+
+// wsMaxMessage limits the size of client messages, which only ever carry a
+// subscription
+const wsMaxMessage = 4096
+
+// wsWriteTimeout bounds every write, so that a stalled client cannot block
+// the stream
+const wsWriteTimeout = 10 * time.Second
+
+// streamEventNames are the events clients can subscribe to
+var streamEventNames = []string{eventStatus, eventSync, eventPortChanged}
+
+var errMessageTooBig = errors.New("websocket message too big")
+
+// wsConn is a server-side WebSocket connection. Only the subset of RFC 6455
+// needed for the event stream is implemented: no extensions or subprotocols.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// writeMu serializes writes from the stream and the reader, which
+	// answers pings
+	writeMu gosync.Mutex
+}
+
+// upgradeWebSocket performs the opening handshake and takes over the
+// connection. On failure, an error response has been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return nil, errors.New("websocket handshake requires GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		writeProblem(w, r, problemUpgradeRequired, "Connect with a WebSocket client")
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeProblem(w, r, problemInvalidRequest, "Unsupported WebSocket version, only 13 is supported")
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		writeProblem(w, r, problemInvalidRequest, "Invalid Sec-WebSocket-Key")
+		return nil, errors.New("invalid websocket key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeProblem(w, r, problemInternal, "Failed to take over the connection")
+		return nil, fmt.Errorf("failed to take over connection: %w", err)
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := rw.WriteString(response); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to complete websocket handshake: %w", err)
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// headerContains reports whether the comma-separated header contains the
+// token, ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for part := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame writes a single unmasked frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// writeJSON sends the value as a text message
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode websocket message: %w", err)
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// close sends a close frame with the code and closes the connection
+func (c *wsConn) close(code uint16, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	_ = c.writeFrame(wsOpClose, append(payload, reason...))
+	_ = c.conn.Close()
+}
+
+// readFrame reads a single frame from the client, whose frames are always
+// masked
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket frame uses unsupported extension bits")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("websocket client frame is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessage {
+		return false, 0, nil, errMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// readMessage returns the next data or close message from the client,
+// reassembling fragmented messages and answering pings on the way
+func (c *wsConn) readMessage() (opcode byte, payload []byte, err error) {
+	var message []byte
+	var messageOp byte
+	for {
+		fin, op, data, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, data); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			return wsOpClose, data, nil
+		case wsOpText, wsOpBinary:
+			if messageOp != 0 {
+				return 0, nil, errors.New("websocket message interrupted by a new message")
+			}
+			messageOp = op
+		case wsOpContinuation:
+			if messageOp == 0 {
+				return 0, nil, errors.New("unexpected websocket continuation frame")
+			}
+		default:
+			return 0, nil, fmt.Errorf("unknown websocket opcode %d", op)
+		}
+
+		if len(message)+len(data) > wsMaxMessage {
+			return 0, nil, errMessageTooBig
+		}
+		message = append(message, data...)
+		if fin {
+			return messageOp, message, nil
+		}
+	}
+}
+
+// parseStreamEvents parses the events a client subscribes to. An empty list
+// subscribes to all events.
+func parseStreamEvents(names []string) (map[string]bool, error) {
+	events := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(streamEventNames, name) {
+			return nil, fmt.Errorf("unknown event %q (available: %s)", name, strings.Join(streamEventNames, ", "))
+		}
+		events[name] = true
+	}
+	return events, nil
+}
+
+// subscriptionMessage is sent by clients to change the events they receive
+type subscriptionMessage struct {
+	Events []string `json:"events"`
+}
+
+// websocketHandler streams the events of /api/v1/events as JSON messages of
+// the form {"event": ..., "data": ...}. Clients choose the events with the
+// "events" query parameter, a comma-separated list, and can change them at
+// any time by sending {"events": [...]}; an empty list selects all events.
+func (s *Server) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	if s.syncer == nil {
+		writeProblem(w, r, problemNotConfigured, "Port sync not available")
+		return
+	}
+	var names []string
+	if query := r.URL.Query().Get("events"); query != "" {
+		names = strings.Split(query, ",")
+	}
+	events, err := parseStreamEvents(names)
+	if err != nil {
+		writeProblem(w, r, problemInvalidRequest, err.Error())
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		slog.Debug("websocket upgrade failed", "error", err)
+		return
+	}
+
+	records, unsubscribe := s.syncer.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	defer close(done)
+	subscriptions := make(chan map[string]bool)
+	closed := make(chan uint16, 1)
+	go s.readWebSocket(conn, subscriptions, closed, done)
+
+	send := func(event streamEvent) error {
+		if len(events) > 0 && !events[event.Event] {
+			return nil
+		}
+		return conn.writeJSON(event)
+	}
+
+	var stopped <-chan struct{}
+	if s.streams != nil {
+		stopped = s.streams.Done()
+	}
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	err = send(streamEvent{Event: eventStatus, Data: s.syncer.Status()})
+	for err == nil {
+		select {
+		case <-stopped:
+			conn.close(wsCloseGoingAway, "server shutting down")
+			return
+		case code := <-closed:
+			conn.close(code, "")
+			return
+		case events = <-subscriptions:
+		case <-keepAlive.C:
+			err = conn.writeFrame(wsOpPing, nil)
+		case record, ok := <-records:
+			if !ok {
+				conn.close(wsCloseGoingAway, "")
+				return
+			}
+			for _, event := range recordEvents(record) {
+				if err = send(event); err != nil {
+					break
+				}
+			}
+		}
+	}
+	slog.Debug("websocket stream closed", "error", err)
+	_ = conn.conn.Close()
+}
+
+// readWebSocket handles the messages of a client until the connection ends,
+// passing subscription changes to the stream. The close code to answer with
+// is sent on closed.
+func (s *Server) readWebSocket(conn *wsConn, subscriptions chan<- map[string]bool, closed chan<- uint16, done <-chan struct{}) {
+	for {
+		opcode, payload, err := conn.readMessage()
+		switch {
+		case errors.Is(err, errMessageTooBig):
+			closed <- wsCloseTooBig
+			return
+		case err != nil:
+			closed <- wsCloseNormal
+			return
+		case opcode == wsOpClose:
+			closed <- wsCloseNormal
+			return
+		case opcode == wsOpBinary:
+			closed <- wsCloseUnsupported
+			return
+		}
+
+		var message subscriptionMessage
+		err = json.Unmarshal(payload, &message)
+		var events map[string]bool
+		if err == nil {
+			events, err = parseStreamEvents(message.Events)
+		}
+		if err != nil {
+			_ = conn.writeJSON(streamEvent{Event: "error", Data: map[string]string{"error": err.Error()}})
+			continue
+		}
+
+		select {
+		case subscriptions <- events:
+		case <-done:
+			return
+		}
+	}
+}
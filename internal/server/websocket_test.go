@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/sync"
+)
+
+// wsTestClient is a minimal WebSocket client for the tests
+type wsTestClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, url, query string) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	request := "GET /ws" + query + " HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("failed to send handshake: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("failed to read handshake response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	// Accept key from the example of RFC 6455, section 1.3, for the same key
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "BACScCJPNqyz+UBoqMH89VmURoA=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return &wsTestClient{t: t, conn: conn, reader: reader}
+}
+
+// send writes a masked frame
+func (c *wsTestClient) send(opcode byte, payload []byte) {
+	c.t.Helper()
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := make([]byte, 4)
+	_, _ = rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatalf("failed to send frame: %v", err)
+	}
+}
+
+// receive reads an unmasked frame
+func (c *wsTestClient) receive() (byte, []byte) {
+	c.t.Helper()
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		c.t.Fatalf("failed to read frame: %v", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		ext := make([]byte, 2)
+		_, _ = io.ReadFull(c.reader, ext)
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatalf("failed to read frame payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func (c *wsTestClient) receiveEvent() (string, json.RawMessage) {
+	c.t.Helper()
+	opcode, payload := c.receive()
+	if opcode != wsOpText {
+		c.t.Fatalf("received opcode %d, want a text message", opcode)
+	}
+	var event struct {
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.t.Fatalf("failed to decode message %s: %v", payload, err)
+	}
+	return event.Event, event.Data
+}
+
+func TestWebsocketHandler(t *testing.T) {
+	syncer := &fakeSyncer{status: sync.Status{CurrentPort: 8080}, records: make(chan sync.SyncRecord, 2)}
+	server := &Server{syncer: syncer}
+	httpServer := httptest.NewServer(http.HandlerFunc(server.websocketHandler))
+	defer httpServer.Close()
+
+	client := dialWebSocket(t, httpServer.URL, "")
+	if event, data := client.receiveEvent(); event != eventStatus || !strings.Contains(string(data), `"current_port":8080`) {
+		t.Fatalf("first message = %s %s, want the current status", event, data)
+	}
+
+	client.send(wsOpPing, []byte("hi"))
+	if opcode, payload := client.receive(); opcode != wsOpPong || string(payload) != "hi" {
+		t.Errorf("ping answered with opcode %d %q, want pong", opcode, payload)
+	}
+
+	// Only port changes from now on
+	client.send(wsOpText, []byte(`{"events":["port_changed"]}`))
+	client.send(wsOpText, []byte(`{"events":["bogus"]}`))
+	if event, data := client.receiveEvent(); event != "error" || !strings.Contains(string(data), "bogus") {
+		t.Errorf("message = %s %s, want an error for the unknown event", event, data)
+	}
+
+	syncer.records <- sync.SyncRecord{Result: sync.SyncResultSuccess, Port: 9090}
+	syncer.records <- sync.SyncRecord{Result: sync.SyncResultSuccess, Port: 9091, OldPort: 9090}
+	if event, data := client.receiveEvent(); event != eventPortChanged || !strings.Contains(string(data), `"new_port":9091`) {
+		t.Errorf("message = %s %s, want only the port change to 9091", event, data)
+	}
+
+	client.send(wsOpClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if opcode, payload := client.receive(); opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsCloseNormal {
+		t.Errorf("close answered with opcode %d %v, want a normal close", opcode, payload)
+	}
+}
+
+func TestWebsocketHandler_QueryFilter(t *testing.T) {
+	syncer := &fakeSyncer{records: make(chan sync.SyncRecord, 1)}
+	server := &Server{syncer: syncer}
+	httpServer := httptest.NewServer(http.HandlerFunc(server.websocketHandler))
+	defer httpServer.Close()
+
+	client := dialWebSocket(t, httpServer.URL, "?events=sync")
+	syncer.records <- sync.SyncRecord{Result: sync.SyncResultFailed, Error: "qbittorrent unreachable"}
+	if event, _ := client.receiveEvent(); event != eventSync {
+		t.Errorf("first message = %s, want sync without the status", event)
+	}
+}
+
+func TestWebsocketHandler_Rejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		syncer     PortSyncer
+		target     string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "no watcher", target: "/ws", wantStatus: http.StatusNotFound},
+		{name: "plain request", syncer: &fakeSyncer{}, target: "/ws", wantStatus: http.StatusUpgradeRequired},
+		{name: "unknown event", syncer: &fakeSyncer{}, target: "/ws?events=vpn_down", wantStatus: http.StatusBadRequest},
+		{
+			name:   "old version",
+			syncer: &fakeSyncer{},
+			target: "/ws",
+			header: http.Header{
+				"Connection":            {"keep-alive, Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-Websocket-Version": {"8"},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{syncer: tt.syncer}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			w := httptest.NewRecorder()
+			server.websocketHandler(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("websocketHandler() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package slskd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// apiKeyHeader carries an API key of slskd
+const apiKeyHeader = "X-API-Key"
+
+// maxResponseSize limits a response of slskd, which includes its
+// configuration file
+const maxResponseSize = 1 << 20
+
+// Client talks to the API of slskd, a Soulseek client. Its listening port is
+// soulseek.listen_port in the configuration file, which the client edits
+// through the API; slskd applies the change and listens on the new port.
+// This requires remote_configuration to be enabled in slskd.
+//
+// With a username and password, the client logs in for a token and logs in
+// again when slskd rejects it.
+type Client struct {
+	baseURL string
+	auth    Options
+	client  *http.Client
+	retry   torrent.RetryPolicy
+
+	tokenMu sync.Mutex
+	token   string
+}
+
+// Options configure how the client connects to slskd. An APIKey is sent with
+// every request; otherwise a Username logs in with Password, and without
+// either slskd must have authentication disabled.
+type Options struct {
+	Username  string
+	Password  string
+	APIKey    string
+	Transport torrent.TransportOptions
+}
+
+// usesLogin reports whether the client logs in for a token
+func (o Options) usesLogin() bool {
+	return o.APIKey == "" && o.Username != ""
+}
+
+// ErrPortNotApplied means slskd accepted the configuration but kept the
+// previous listening port
+var ErrPortNotApplied = errors.New("slskd did not apply the listening port")
+
+// NewClient creates a client for the slskd at baseURL, logs in if needed and
+// checks that it accepts requests
+func NewClient(baseURL string, opts Options) (*Client, error) {
+	client := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		auth:    opts,
+		client:  torrent.NewHTTPClient(opts.Transport),
+		retry:   torrent.DefaultRetryPolicy,
+	}
+
+	// The caller retries the initial request, e.g. until slskd has started
+	if err := client.PingOnce(); err != nil {
+		return nil, fmt.Errorf("initial request failed: %w", err)
+	}
+	return client, nil
+}
+
+// SetRetryPolicy changes how failed requests to slskd are retried. It must
+// be called before the client is shared.
+func (c *Client) SetRetryPolicy(policy torrent.RetryPolicy) {
+	c.retry = policy
+}
+
+// GetPort returns the listening port of slskd
+func (c *Client) GetPort() (int, error) {
+	var port int
+	err := c.retry.Run("get port", func() error {
+		var err error
+		port, err = c.listenPort()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// SetPort writes the port to soulseek.listen_port of the configuration file
+// and reads the options back to verify that slskd applied it. slskd reloads
+// the file asynchronously, so a port that was not applied yet is retried like
+// a failed request.
+func (c *Client) SetPort(port int) error {
+	return c.retry.Run("set port", func() error {
+		current, err := c.configuration()
+		if err != nil {
+			return err
+		}
+		if updated := setListenPort(current, port); updated != current {
+			if err := c.setConfiguration(updated); err != nil {
+				return err
+			}
+		}
+
+		applied, err := c.listenPort()
+		if err != nil {
+			return fmt.Errorf("failed to verify port: %w", err)
+		}
+		if applied != port {
+			return fmt.Errorf("%w: listen port is %d instead of %d", ErrPortNotApplied, applied, port)
+		}
+		slog.Info("successfully updated slskd listening port", "port", port)
+		return nil
+	})
+}
+
+// listenPort reads the listening port from the current options with a single
+// attempt
+func (c *Client) listenPort() (int, error) {
+	var options struct {
+		Soulseek struct {
+			ListenPort int `json:"listenPort"`
+		} `json:"soulseek"`
+	}
+	if err := c.getJSON("/api/v0/options", &options); err != nil {
+		return 0, fmt.Errorf("failed to get options: %w", err)
+	}
+	return options.Soulseek.ListenPort, nil
+}
+
+// configuration returns the YAML configuration file of slskd
+func (c *Client) configuration() (string, error) {
+	var yaml string
+	if err := c.getJSON("/api/v0/options/yaml", &yaml); err != nil {
+		return "", fmt.Errorf("failed to get configuration: %w", err)
+	}
+	return yaml, nil
+}
+
+// setConfiguration replaces the YAML configuration file of slskd, which
+// validates it first
+func (c *Client) setConfiguration(yaml string) error {
+	body, err := json.Marshal(yaml)
+	if err != nil {
+		return fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+	resp, err := c.do(http.MethodPost, "/api/v0/options/yaml", body)
+	if err != nil {
+		return fmt.Errorf("failed to update configuration: %w", err)
+	}
+	defer closeResponseBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Ping checks that slskd is reachable, retrying failed attempts so that a
+// brief restart is not reported as an outage
+func (c *Client) Ping() error {
+	return c.retry.Run("ping", c.PingOnce)
+}
+
+// PingOnce checks that slskd is reachable with a single attempt, for checks
+// that report the current state such as readiness probes
+func (c *Client) PingOnce() error {
+	if err := c.getJSON("/api/v0/application", nil); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+// getJSON gets path and decodes the JSON response into result, unless it is
+// nil
+func (c *Client) getJSON(path string, result any) error {
+	resp, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer closeResponseBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request with the configured credentials. With a username, it
+// logs in first if there is no token yet, and again once if slskd rejects
+// the token.
+func (c *Client) do(method, path string, body []byte) (*http.Response, error) {
+	send := func(token string) (*http.Response, error) {
+		req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.auth.APIKey != "" {
+			req.Header.Set(apiKeyHeader, c.auth.APIKey)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return c.client.Do(req)
+	}
+
+	if !c.auth.usesLogin() {
+		resp, err := send("")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			closeResponseBody(resp)
+			return nil, torrent.Permanent(errors.New("slskd rejected the request: check the API key, or that authentication is disabled"))
+		}
+		return resp, nil
+	}
+
+	token, err := c.currentToken("")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	closeResponseBody(resp)
+	slog.Warn("received 401 from slskd, re-authenticating...")
+	token, err = c.currentToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("re-authentication failed: %w", err)
+	}
+	return send(token)
+}
+
+// currentToken returns the token of the current login, logging in if there
+// is none or it is still the rejected one, unless another request has
+// already done so
+func (c *Client) currentToken(rejected string) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token != "" && c.token != rejected {
+		return c.token, nil
+	}
+
+	token, err := c.login()
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	return token, nil
+}
+
+// login creates a session with the username and password and returns its
+// token
+func (c *Client) login() (string, error) {
+	body, err := json.Marshal(map[string]string{"username": c.auth.Username, "password": c.auth.Password})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal login: %w", err)
+	}
+	resp, err := c.client.Post(c.baseURL+"/api/v0/session", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("login request failed: %w", err)
+	}
+	defer closeResponseBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		err := responseError(resp)
+		if resp.StatusCode == http.StatusUnauthorized {
+			return "", torrent.Permanent(fmt.Errorf("login failed: %w", err))
+		}
+		return "", fmt.Errorf("login failed: %w", err)
+	}
+	var session struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return "", fmt.Errorf("failed to decode session: %w", err)
+	}
+	if session.Token == "" {
+		return "", errors.New("login failed: no token in session")
+	}
+	slog.Debug("successfully authenticated with slskd")
+	return session.Token, nil
+}
+
+// responseError describes an unexpected response. 403 means remote
+// configuration is disabled, which retrying cannot fix.
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	if resp.StatusCode == http.StatusForbidden {
+		return torrent.Permanent(fmt.Errorf("%w (is remote_configuration enabled in slskd?)", err))
+	}
+	return err
+}
+
+func closeResponseBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+}
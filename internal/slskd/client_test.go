@@ -0,0 +1,159 @@
+package slskd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// fakeSlskd emulates the API of slskd with a configuration file whose
+// soulseek.listen_port is applied on upload
+type fakeSlskd struct {
+	apiKey   string
+	username string
+	password string
+	yaml     string
+	// ignorePort keeps an uploaded configuration from changing the port
+	ignorePort bool
+	// logins counts the issued tokens; expired rejects the current one
+	logins  int
+	expired bool
+	port    int
+}
+
+var listenPortPattern = regexp.MustCompile(`(?m)^\s+listen_port: (\d+)$`)
+
+func newFakeSlskd(t *testing.T, fake *fakeSlskd) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v0/session", func(w http.ResponseWriter, r *http.Request) {
+		var login struct{ Username, Password string }
+		_ = json.NewDecoder(r.Body).Decode(&login)
+		if login.Username != fake.username || login.Password != fake.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.logins++
+		fake.expired = false
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "token-" + strconv.Itoa(fake.logins)})
+	})
+	authorized := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case fake.apiKey != "":
+				if r.Header.Get(apiKeyHeader) != fake.apiKey {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+			case fake.username != "":
+				if fake.expired || r.Header.Get("Authorization") != "Bearer token-"+strconv.Itoa(fake.logins) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+			}
+			next(w, r)
+		}
+	}
+	mux.HandleFunc("GET /api/v0/application", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"server": map[string]any{"state": "Connected, LoggedIn"}})
+	}))
+	mux.HandleFunc("GET /api/v0/options", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"soulseek": map[string]any{"listenPort": fake.port}})
+	}))
+	mux.HandleFunc("GET /api/v0/options/yaml", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(fake.yaml)
+	}))
+	mux.HandleFunc("POST /api/v0/options/yaml", authorized(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&fake.yaml); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if match := listenPortPattern.FindStringSubmatch(fake.yaml); match != nil && !fake.ignorePort {
+			fake.port, _ = strconv.Atoi(match[1])
+		}
+	}))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_APIKey(t *testing.T) {
+	fake := &fakeSlskd{apiKey: "key", port: 50300, yaml: "soulseek:\n  username: user\n  listen_port: 50300\n"}
+	server := newFakeSlskd(t, fake)
+
+	client, err := NewClient(server.URL, Options{APIKey: "key"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	port, err := client.GetPort()
+	if err != nil {
+		t.Fatalf("GetPort() error = %v", err)
+	}
+	if port != 50300 {
+		t.Errorf("GetPort() = %d, want 50300", port)
+	}
+
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if want := "soulseek:\n  username: user\n  listen_port: 6000\n"; fake.yaml != want {
+		t.Errorf("configuration = %q, want %q", fake.yaml, want)
+	}
+}
+
+func TestClient_PasswordLogin(t *testing.T) {
+	fake := &fakeSlskd{username: "slskd", password: "secret", port: 50300}
+	server := newFakeSlskd(t, fake)
+
+	client, err := NewClient(server.URL, Options{Username: "slskd", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// A restarted slskd rejects the previous token
+	fake.expired = true
+	if err := client.PingOnce(); err != nil {
+		t.Fatalf("PingOnce() error = %v", err)
+	}
+	if fake.logins != 2 {
+		t.Errorf("logins = %d, want 2", fake.logins)
+	}
+
+	_, err = NewClient(server.URL, Options{Username: "slskd", Password: "wrong"})
+	if !torrent.IsPermanent(err) {
+		t.Errorf("NewClient() error = %v, want permanent error for rejected credentials", err)
+	}
+}
+
+func TestClient_RejectedAPIKey(t *testing.T) {
+	fake := &fakeSlskd{apiKey: "key"}
+	server := newFakeSlskd(t, fake)
+
+	_, err := NewClient(server.URL, Options{APIKey: "wrong"})
+	if !torrent.IsPermanent(err) {
+		t.Errorf("NewClient() error = %v, want permanent error", err)
+	}
+}
+
+func TestSetPort_NotApplied(t *testing.T) {
+	fake := &fakeSlskd{port: 50300, ignorePort: true}
+	server := newFakeSlskd(t, fake)
+
+	client, err := NewClient(server.URL, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	if err := client.SetPort(6000); !errors.Is(err, ErrPortNotApplied) {
+		t.Errorf("SetPort() error = %v, want ErrPortNotApplied", err)
+	}
+}
@@ -0,0 +1,70 @@
+package slskd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// defaultIndent indents a key added to the soulseek section
+const defaultIndent = "  "
+
+// setListenPort sets soulseek.listen_port in the YAML configuration of
+// slskd. The file is edited line by line rather than decoded and encoded
+// again, so that comments and the order of the keys are kept. A missing key
+// or section is added; flow style sections are not supported.
+func setListenPort(config string, port int) string {
+	value := "listen_port: " + strconv.Itoa(port)
+	lines := strings.Split(config, "\n")
+
+	section := -1
+	for i, line := range lines {
+		if isKey(line, "soulseek") {
+			section = i
+			break
+		}
+	}
+	if section < 0 {
+		config = strings.TrimRight(config, "\n")
+		if config != "" {
+			config += "\n"
+		}
+		return config + "soulseek:\n" + defaultIndent + value + "\n"
+	}
+
+	indent := ""
+	for i := section + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if lineIndent == "" {
+			// The next top-level key ends the section
+			break
+		}
+		if indent == "" {
+			indent = lineIndent
+		}
+		if lineIndent == indent && isKey(trimmed, "listen_port") {
+			lines[i] = indent + value
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	if indent == "" {
+		indent = defaultIndent
+	}
+	lines = append(lines[:section+1], append([]string{indent + value}, lines[section+1:]...)...)
+	return strings.Join(lines, "\n")
+}
+
+// isKey reports whether line starts the mapping key
+func isKey(line, key string) bool {
+	rest, ok := strings.CutPrefix(line, key)
+	if !ok {
+		return false
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	return strings.HasPrefix(rest, ":")
+}
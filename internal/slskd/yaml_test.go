@@ -0,0 +1,45 @@
+package slskd
+
+import "testing"
+
+func TestSetListenPort(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "replaces the port",
+			config: "web:\n  port: 5030\nsoulseek:\n  username: user # login\n  listen_port: 50300\nshares:\n  directories: []\n",
+			want:   "web:\n  port: 5030\nsoulseek:\n  username: user # login\n  listen_port: 6000\nshares:\n  directories: []\n",
+		},
+		{
+			name:   "adds the key to the section",
+			config: "soulseek:\n    username: user\n",
+			want:   "soulseek:\n    listen_port: 6000\n    username: user\n",
+		},
+		{
+			name:   "ignores nested keys",
+			config: "soulseek:\n  connection:\n    listen_port: 1\n  username: user\n",
+			want:   "soulseek:\n  listen_port: 6000\n  connection:\n    listen_port: 1\n  username: user\n",
+		},
+		{
+			name:   "adds the section",
+			config: "# soulseek:\n#   listen_port: 50300\nweb:\n  port: 5030\n",
+			want:   "# soulseek:\n#   listen_port: 50300\nweb:\n  port: 5030\nsoulseek:\n  listen_port: 6000\n",
+		},
+		{
+			name:   "empty configuration",
+			config: "",
+			want:   "soulseek:\n  listen_port: 6000\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := setListenPort(tt.config, 6000); got != tt.want {
+				t.Errorf("setListenPort() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
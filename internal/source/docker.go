@@ -0,0 +1,74 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eslutz/forwardarr/internal/docker"
+)
+
+// DockerOptions select where the Docker source finds the port in the
+// container. Exactly one of them is set.
+type DockerOptions struct {
+	// Env is the environment variable of the container that holds the port
+	Env string
+	// Label is the label of the container that holds the port
+	Label string
+	// Command is run in the container and prints the port like the command
+	// of the exec source, e.g. cat /tmp/gluetun/forwarded_port
+	Command []string
+}
+
+// Docker reads the forwarded port from another container through the Docker
+// API, from its configuration or from a command run inside it, so that no
+// volume needs to be shared
+type Docker struct {
+	client    *docker.Client
+	container string
+	opts      DockerOptions
+}
+
+// NewDocker creates a source that reads the port from the container with the
+// name or ID
+func NewDocker(client *docker.Client, container string, opts DockerOptions) (*Docker, error) {
+	if container == "" {
+		return nil, errors.New("Docker source container is required")
+	}
+	set := 0
+	for _, ok := range []bool{opts.Env != "", opts.Label != "", len(opts.Command) > 0} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("Docker source needs exactly one of an environment variable, a label or a command")
+	}
+	return &Docker{client: client, container: container, opts: opts}, nil
+}
+
+// ForwardedPort returns the port in the container, or 0 if the variable or
+// label is missing or the command printed no port
+func (s *Docker) ForwardedPort() (int, error) {
+	if len(s.opts.Command) > 0 {
+		output, err := s.client.Exec(s.container, s.opts.Command)
+		if err != nil {
+			return 0, fmt.Errorf("failed to run port command in container %s: %w", s.container, err)
+		}
+		return parseOutput(strings.TrimSpace(output))
+	}
+
+	container, err := s.client.Inspect(s.container)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect container %s: %w", s.container, err)
+	}
+	if s.opts.Label != "" {
+		return parsePort(container.Labels[s.opts.Label])
+	}
+	for _, env := range container.Env {
+		if value, ok := strings.CutPrefix(env, s.opts.Env+"="); ok {
+			return parsePort(value)
+		}
+	}
+	return 0, nil
+}
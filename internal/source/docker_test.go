@@ -0,0 +1,96 @@
+package source
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/docker"
+)
+
+// dockerClient returns a client of a daemon whose container gluetun has the
+// port 51413 in VPN_PORT, 51414 in the label vpn.port, and whose exec prints
+// output
+func dockerClient(t *testing.T, output string) *docker.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/gluetun/json":
+			_, _ = w.Write([]byte(`{"Config": {"Env": ["VPN_PORT=51413"], "Labels": {"vpn.port": "51414"}}}`))
+		case "/containers/gluetun/exec":
+			_, _ = w.Write([]byte(`{"Id": "abc"}`))
+		case "/exec/abc/start":
+			header := make([]byte, 8)
+			header[0] = 1
+			binary.BigEndian.PutUint32(header[4:], uint32(len(output)))
+			_, _ = w.Write(append(header, output...))
+		case "/exec/abc/json":
+			_, _ = w.Write([]byte(`{"ExitCode": 0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := docker.NewClient("tcp://" + strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestDocker_ForwardedPort(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		opts   DockerOptions
+		want   int
+	}{
+		{"env", "", DockerOptions{Env: "VPN_PORT"}, 51413},
+		{"missing env", "", DockerOptions{Env: "FORWARDED_PORT"}, 0},
+		{"label", "", DockerOptions{Label: "vpn.port"}, 51414},
+		{"missing label", "", DockerOptions{Label: "port"}, 0},
+		{"command", "51415\n", DockerOptions{Command: []string{"cat", "/tmp/gluetun/forwarded_port"}}, 51415},
+		{"command json", `{"port": 51416}`, DockerOptions{Command: []string{"wget", "-qO-", "http://localhost:8000/v1/portforward"}}, 51416},
+		{"command without port", "", DockerOptions{Command: []string{"cat", "/tmp/gluetun/forwarded_port"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewDocker(dockerClient(t, tt.output), "gluetun", tt.opts)
+			if err != nil {
+				t.Fatalf("NewDocker() error = %v", err)
+			}
+			port, err := source.ForwardedPort()
+			if err != nil || port != tt.want {
+				t.Errorf("ForwardedPort() = %d, %v, want %d", port, err, tt.want)
+			}
+		})
+	}
+
+	source, err := NewDocker(dockerClient(t, ""), "missing", DockerOptions{Env: "VPN_PORT"})
+	if err != nil {
+		t.Fatalf("NewDocker() error = %v", err)
+	}
+	if _, err := source.ForwardedPort(); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("ForwardedPort() error = %v, want missing container", err)
+	}
+}
+
+func TestNewDocker_Invalid(t *testing.T) {
+	client, err := docker.NewClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDocker(client, "", DockerOptions{Env: "VPN_PORT"}); err == nil {
+		t.Error("NewDocker() without container error = nil")
+	}
+	for name, opts := range map[string]DockerOptions{
+		"none": {},
+		"both": {Env: "VPN_PORT", Label: "vpn.port"},
+	} {
+		if _, err := NewDocker(client, "gluetun", opts); err == nil {
+			t.Errorf("NewDocker() with %s error = nil", name)
+		}
+	}
+}
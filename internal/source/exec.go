@@ -0,0 +1,122 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/command"
+)
+
+// DefaultExecTimeout bounds a run of the exec source
+const DefaultExecTimeout = 10 * time.Second
+
+// maxStderr is how much of the error output of a run is kept for errors
+const maxStderr = 4096
+
+// Failure kinds of ExecError
+const (
+	// FailureStart means the command could not be started, e.g. because it
+	// was removed or is not executable
+	FailureStart = "start"
+	// FailureTimeout means the command ran longer than the timeout
+	FailureTimeout = "timeout"
+	// FailureExit means the command exited with a non-zero status
+	FailureExit = "exit"
+	// FailureOutput means the output of the command holds no valid port
+	FailureOutput = "output"
+)
+
+// ExecError is a failed run of the exec source. Kind classifies the failure
+// as one of the Failure constants.
+type ExecError struct {
+	Kind   string
+	Err    error
+	Stderr string
+}
+
+func (e *ExecError) Error() string {
+	message := fmt.Sprintf("port command failed (%s): %v", e.Kind, e.Err)
+	if e.Stderr != "" {
+		message += ", stderr: " + e.Stderr
+	}
+	return message
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// ExecOptions configure how the exec source runs the command
+type ExecOptions struct {
+	// Timeout kills the command when it runs longer; 0 is DefaultExecTimeout
+	Timeout time.Duration
+}
+
+// Exec reads the forwarded port from the output of a command, run on every
+// poll. The command is run directly, not through a shell, and prints the
+// port to stdout, either as a plain number or as a JSON object with a port
+// key, e.g. {"port": 51413}. Empty output means no port.
+type Exec struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewExec creates a source that runs the command, which is looked up in
+// PATH unless it contains a slash
+func NewExec(command string, opts ExecOptions) (*Exec, error) {
+	if command == "" {
+		return nil, errors.New("exec source command is required")
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("port command not found: %w", err)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	return &Exec{path: path, timeout: timeout}, nil
+}
+
+// ForwardedPort runs the command and returns the port it printed, or 0 if
+// it printed none. Failed runs return an *ExecError.
+func (s *Exec) ForwardedPort() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	stdout := command.NewOutput(maxResponseSize)
+	stderr := command.NewOutput(maxStderr)
+	err := command.Run(ctx, s.path, nil, nil, stdout, stderr)
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return 0, &ExecError{Kind: FailureTimeout, Err: fmt.Errorf("timed out after %s", s.timeout), Stderr: stderr.String()}
+	case errors.As(err, &exitErr):
+		return 0, &ExecError{Kind: FailureExit, Err: err, Stderr: stderr.String()}
+	case err != nil:
+		return 0, &ExecError{Kind: FailureStart, Err: err}
+	}
+
+	port, err := parseOutput(stdout.String())
+	if err != nil {
+		return 0, &ExecError{Kind: FailureOutput, Err: err, Stderr: stderr.String()}
+	}
+	return port, nil
+}
+
+// parseOutput parses the port printed by the command
+func parseOutput(output string) (int, error) {
+	if !strings.HasPrefix(output, "{") {
+		return parsePort(output)
+	}
+	var object map[string]any
+	if err := json.Unmarshal([]byte(output), &object); err != nil {
+		return 0, fmt.Errorf("failed to decode output: %w", err)
+	}
+	return portValue(object["port"])
+}
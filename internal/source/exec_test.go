@@ -0,0 +1,96 @@
+package source
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/command/commandtest"
+)
+
+func TestExec_ForwardedPort(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   int
+	}{
+		{"plain", "echo 51413\n", 51413},
+		{"empty", "", 0},
+		{"json", `echo '{"port": 51413, "protocol": "tcp"}'` + "\n", 51413},
+		{"json string", `echo '{"port": "51413"}'` + "\n", 51413},
+		{"json without port", `echo '{"status": "pending"}'` + "\n", 0},
+		{"stderr", "echo 'looking up port' >&2\necho 51413\n", 51413},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewExec(commandtest.WriteScript(t, tt.script), ExecOptions{})
+			if err != nil {
+				t.Fatalf("NewExec() error = %v", err)
+			}
+			port, err := source.ForwardedPort()
+			if err != nil || port != tt.want {
+				t.Errorf("ForwardedPort() = %d, %v, want %d", port, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExec_ForwardedPortFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		kind   string
+		output string
+	}{
+		{"exit", "echo 'VPN is down' >&2\nexit 2\n", FailureExit, "VPN is down"},
+		{"timeout", "echo started >&2\nexec sleep 5\n", FailureTimeout, "started"},
+		{"not a number", "echo unknown\n", FailureOutput, "invalid port"},
+		{"out of range", "echo 70000\n", FailureOutput, "out of valid range"},
+		{"invalid json", "echo '{\"port\":'\n", FailureOutput, "decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewExec(commandtest.WriteScript(t, tt.script), ExecOptions{Timeout: 200 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("NewExec() error = %v", err)
+			}
+			start := time.Now()
+			_, err = source.ForwardedPort()
+			var execErr *ExecError
+			if !errors.As(err, &execErr) || execErr.Kind != tt.kind || !strings.Contains(err.Error(), tt.output) {
+				t.Errorf("ForwardedPort() error = %v, want %s failure with %q", err, tt.kind, tt.output)
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("ForwardedPort() took %v, want the command killed", elapsed)
+			}
+		})
+	}
+}
+
+func TestExec_ForwardedPortRemoved(t *testing.T) {
+	script := commandtest.WriteScript(t, "echo 51413\n")
+	source, err := NewExec(script, ExecOptions{})
+	if err != nil {
+		t.Fatalf("NewExec() error = %v", err)
+	}
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+	_, err = source.ForwardedPort()
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || execErr.Kind != FailureStart {
+		t.Errorf("ForwardedPort() error = %v, want start failure", err)
+	}
+}
+
+func TestNewExec_NotFound(t *testing.T) {
+	if _, err := NewExec(filepath.Join(t.TempDir(), "missing.sh"), ExecOptions{}); err == nil {
+		t.Error("NewExec() error = nil, want missing command")
+	}
+	if _, err := NewExec("", ExecOptions{}); err == nil {
+		t.Error("NewExec() error = nil, want no command")
+	}
+}
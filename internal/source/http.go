@@ -0,0 +1,218 @@
+// Package source provides the forwarded port from places other than the
+// Gluetun port file, such as HTTP endpoints or scripts, for use as port
+// source of the watcher.
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPTimeout bounds a request of the HTTP source
+const DefaultHTTPTimeout = 10 * time.Second
+
+// maxResponseSize bounds the response read from the URL
+const maxResponseSize = 1 << 20
+
+// HTTPOptions configure how the HTTP source requests the URL and where it
+// finds the port in the response. JSONPath and Regex are exclusive; without
+// either, the whole response body is the port.
+type HTTPOptions struct {
+	Headers map[string]string
+	// JSONPath selects the port in a JSON response, e.g. $.data.ports[0]
+	JSONPath string
+	// Regex matches the port in the response; the first capture group is
+	// the port, or the whole match without groups
+	Regex   string
+	Timeout time.Duration
+}
+
+// HTTP reads the forwarded port from the response to a GET request, e.g. of
+// a script or tool that already exposes it
+type HTTP struct {
+	url     string
+	headers map[string]string
+	path    []pathStep
+	regex   *regexp.Regexp
+	client  *http.Client
+}
+
+// NewHTTP creates a source that requests url
+func NewHTTP(url string, opts HTTPOptions) (*HTTP, error) {
+	if url == "" {
+		return nil, errors.New("HTTP source URL is required")
+	}
+	if opts.JSONPath != "" && opts.Regex != "" {
+		return nil, errors.New("HTTP source can use a JSON path or a regex, not both")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultHTTPTimeout
+	}
+	s := &HTTP{url: url, headers: opts.Headers, client: &http.Client{Timeout: opts.Timeout}}
+	if opts.JSONPath != "" {
+		path, err := parseJSONPath(opts.JSONPath)
+		if err != nil {
+			return nil, err
+		}
+		s.path = path
+	}
+	if opts.Regex != "" {
+		regex, err := regexp.Compile(opts.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP source regex: %w", err)
+		}
+		s.regex = regex
+	}
+	return s, nil
+}
+
+// ForwardedPort requests the URL and returns the port in the response, or 0
+// if it holds none
+func (s *HTTP) ForwardedPort() (int, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid HTTP source URL: %w", err)
+	}
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to request port: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("port request returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read port response: %w", err)
+	}
+	return s.extract(body)
+}
+
+// extract finds the port in a response body
+func (s *HTTP) extract(body []byte) (int, error) {
+	switch {
+	case s.path != nil:
+		var document any
+		if err := json.Unmarshal(body, &document); err != nil {
+			return 0, fmt.Errorf("failed to decode port response: %w", err)
+		}
+		value, err := lookup(document, s.path)
+		if err != nil {
+			return 0, err
+		}
+		return portValue(value)
+	case s.regex != nil:
+		match := s.regex.FindSubmatch(body)
+		if match == nil {
+			return 0, nil
+		}
+		if len(match) > 1 {
+			return parsePort(string(match[1]))
+		}
+		return parsePort(string(match[0]))
+	default:
+		return parsePort(string(body))
+	}
+}
+
+// portValue converts a JSON value to a port; null means no port
+func portValue(value any) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return parsePort(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		return parsePort(v)
+	default:
+		return 0, fmt.Errorf("port is a %T, expected a number", value)
+	}
+}
+
+// parsePort parses a port; an empty value or 0 means no port
+func parsePort(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	if port < 0 || port > 65535 {
+		return 0, fmt.Errorf("port %d out of valid range", port)
+	}
+	return port, nil
+}
+
+// pathStep is a key of an object, or an index of an array if key is empty
+type pathStep struct {
+	key   string
+	index int
+}
+
+// parseJSONPath parses the subset of JSONPath used to select a single
+// value: keys separated by dots and array indexes in brackets, with an
+// optional leading $, e.g. $.data.ports[0]
+func parseJSONPath(path string) ([]pathStep, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if rest == "" {
+		return nil, fmt.Errorf("invalid JSON path %q: no key", path)
+	}
+	var steps []pathStep
+	for _, part := range strings.Split(rest, ".") {
+		key, indexes, _ := strings.Cut(part, "[")
+		if key == "" && indexes == "" {
+			return nil, fmt.Errorf("invalid JSON path %q: empty key", path)
+		}
+		if key != "" {
+			steps = append(steps, pathStep{key: key})
+		}
+		if indexes == "" {
+			continue
+		}
+		for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index %q", path, index)
+			}
+			steps = append(steps, pathStep{index: n})
+		}
+	}
+	return steps, nil
+}
+
+// lookup follows the path through a decoded JSON document. A missing key or
+// index means no port.
+func lookup(document any, path []pathStep) (any, error) {
+	value := document
+	for _, step := range path {
+		if step.key != "" {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("port response has no object at %q", step.key)
+			}
+			value = object[step.key]
+			continue
+		}
+		array, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("port response has no array at index %d", step.index)
+		}
+		if step.index >= len(array) {
+			return nil, nil
+		}
+		value = array[step.index]
+	}
+	return value, nil
+}
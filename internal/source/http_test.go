@@ -0,0 +1,105 @@
+package source
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve returns the URL of a server that answers with status and body
+func serve(t *testing.T, status int, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestHTTP_ForwardedPort(t *testing.T) {
+	headers := map[string]string{"Authorization": "Bearer secret"}
+	tests := []struct {
+		name string
+		body string
+		opts HTTPOptions
+		want int
+	}{
+		{"plain", "51413\n", HTTPOptions{}, 51413},
+		{"empty", "", HTTPOptions{}, 0},
+		{"json path", `{"data": {"ports": [51413, 51414]}}`, HTTPOptions{JSONPath: "$.data.ports[0]"}, 51413},
+		{"json path without $", `{"port": "51413"}`, HTTPOptions{JSONPath: "port"}, 51413},
+		{"nested arrays", `{"ports": [[1, 51413]]}`, HTTPOptions{JSONPath: "ports[0][1]"}, 51413},
+		{"missing key", `{"data": {}}`, HTTPOptions{JSONPath: "data.port"}, 0},
+		{"null", `{"port": null}`, HTTPOptions{JSONPath: "port"}, 0},
+		{"missing index", `{"ports": []}`, HTTPOptions{JSONPath: "ports[0]"}, 0},
+		{"regex group", "forwarded port: 51413 (tcp)", HTTPOptions{Regex: `port: (\d+)`}, 51413},
+		{"regex match", "<b>51413</b>", HTTPOptions{Regex: `\d+`}, 51413},
+		{"regex no match", "no port yet", HTTPOptions{Regex: `port: (\d+)`}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Headers = headers
+			source, err := NewHTTP(serve(t, http.StatusOK, tt.body), tt.opts)
+			if err != nil {
+				t.Fatalf("NewHTTP() error = %v", err)
+			}
+			port, err := source.ForwardedPort()
+			if err != nil || port != tt.want {
+				t.Errorf("ForwardedPort() = %d, %v, want %d", port, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTP_ForwardedPortErrors(t *testing.T) {
+	headers := map[string]string{"Authorization": "Bearer secret"}
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		opts   HTTPOptions
+	}{
+		{"status", http.StatusServiceUnavailable, "", HTTPOptions{Headers: headers}},
+		{"unauthorized", http.StatusOK, "51413", HTTPOptions{}},
+		{"not a number", http.StatusOK, "unknown", HTTPOptions{Headers: headers}},
+		{"out of range", http.StatusOK, "70000", HTTPOptions{Headers: headers}},
+		{"invalid json", http.StatusOK, "51413", HTTPOptions{Headers: headers, JSONPath: "port"}},
+		{"object", http.StatusOK, `{"port": {"tcp": 51413}}`, HTTPOptions{Headers: headers, JSONPath: "port"}},
+		{"not an array", http.StatusOK, `{"port": 51413}`, HTTPOptions{Headers: headers, JSONPath: "port[0]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewHTTP(serve(t, tt.status, tt.body), tt.opts)
+			if err != nil {
+				t.Fatalf("NewHTTP() error = %v", err)
+			}
+			if port, err := source.ForwardedPort(); err == nil {
+				t.Errorf("ForwardedPort() = %d, want an error", port)
+			}
+		})
+	}
+}
+
+func TestNewHTTP_Invalid(t *testing.T) {
+	for name, opts := range map[string]HTTPOptions{
+		"both":        {JSONPath: "port", Regex: `\d+`},
+		"regex":       {Regex: `(\d+`},
+		"empty path":  {JSONPath: "$"},
+		"empty key":   {JSONPath: "data..port"},
+		"bad index":   {JSONPath: "ports[x]"},
+		"minus index": {JSONPath: "ports[-1]"},
+	} {
+		if _, err := NewHTTP("http://localhost:8000/port", opts); err == nil {
+			t.Errorf("NewHTTP() with invalid %s error = nil", name)
+		}
+	}
+	if _, err := NewHTTP("", HTTPOptions{}); err == nil {
+		t.Error("NewHTTP() without URL error = nil")
+	}
+}
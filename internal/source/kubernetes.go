@@ -0,0 +1,185 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/kubernetes"
+)
+
+// kubernetesRetryDelay is how long the Kubernetes source waits before
+// watching again after the watch failed
+var kubernetesRetryDelay = 5 * time.Second
+
+// KubernetesOptions select the object that holds the port: the key of a
+// ConfigMap, or the annotation of a pod. Exactly one of ConfigMap and Pod is
+// set.
+type KubernetesOptions struct {
+	// Namespace of the object; empty is the namespace of the client
+	Namespace  string
+	ConfigMap  string
+	Key        string
+	Pod        string
+	Annotation string
+	// PollInterval is how often the object is read while it is not
+	// watched; 0 reads it on every call of ForwardedPort
+	PollInterval time.Duration
+}
+
+// Kubernetes keeps the forwarded port in a ConfigMap or a pod annotation,
+// where a Gluetun sidecar published it. While Run is running, the object is
+// watched and a new port is known as soon as it is published. Until the
+// watch delivers the object, and while it fails, the object is read instead.
+type Kubernetes struct {
+	client *kubernetes.Client
+	opts   KubernetesOptions
+
+	mu       sync.Mutex
+	watching bool
+	port     int
+	err      error
+	readAt   time.Time
+}
+
+// NewKubernetes creates a source that reads the object with the client
+func NewKubernetes(client *kubernetes.Client, opts KubernetesOptions) (*Kubernetes, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = client.Namespace()
+	}
+	if opts.Namespace == "" {
+		return nil, errors.New("Kubernetes source namespace is required")
+	}
+	switch {
+	case opts.ConfigMap != "" && opts.Pod != "":
+		return nil, errors.New("Kubernetes source can read a ConfigMap or a pod, not both")
+	case opts.ConfigMap != "" && opts.Key == "":
+		return nil, errors.New("Kubernetes source ConfigMap key is required")
+	case opts.Pod != "" && opts.Annotation == "":
+		return nil, errors.New("Kubernetes source pod annotation is required")
+	case opts.ConfigMap == "" && opts.Pod == "":
+		return nil, errors.New("Kubernetes source needs a ConfigMap or a pod")
+	}
+	return &Kubernetes{client: client, opts: opts}, nil
+}
+
+// ForwardedPort returns the port in the ConfigMap key or pod annotation, as
+// a plain number or JSON with a port key, or 0 while it is missing. While
+// the object is watched, it returns the port of the last change; otherwise
+// it reads the object, at most once per poll interval.
+func (s *Kubernetes) ForwardedPort() (int, error) {
+	s.mu.Lock()
+	if s.watching || (!s.readAt.IsZero() && time.Since(s.readAt) < s.opts.PollInterval) {
+		defer s.mu.Unlock()
+		return s.port, s.err
+	}
+	s.mu.Unlock()
+	return s.read()
+}
+
+// Refresh reads the object, so that the port is known before the first sync
+func (s *Kubernetes) Refresh() error {
+	_, err := s.read()
+	return err
+}
+
+// Run watches the object until ctx is done. When the watch fails, it is
+// restarted after kubernetesRetryDelay, and the object is read meanwhile.
+func (s *Kubernetes) Run(ctx context.Context) {
+	for {
+		err := s.watch(ctx)
+		s.setWatching(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// The API server ended the watch, as it does every few minutes
+			continue
+		}
+		slog.Warn("Kubernetes watch failed, reading the object until it is restarted", "retry_delay", kubernetesRetryDelay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(kubernetesRetryDelay):
+		}
+	}
+}
+
+// read reads the object and keeps its port
+func (s *Kubernetes) read() (int, error) {
+	var (
+		values map[string]string
+		err    error
+	)
+	if s.opts.ConfigMap != "" {
+		values, err = s.client.ConfigMapData(s.opts.Namespace, s.opts.ConfigMap)
+		if err != nil {
+			err = fmt.Errorf("failed to read ConfigMap %s/%s: %w", s.opts.Namespace, s.opts.ConfigMap, err)
+		}
+	} else {
+		values, err = s.client.PodAnnotations(s.opts.Namespace, s.opts.Pod)
+		if err != nil {
+			err = fmt.Errorf("failed to read pod %s/%s: %w", s.opts.Namespace, s.opts.Pod, err)
+		}
+	}
+	port := 0
+	if err == nil {
+		port, err = s.parse(values)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watching {
+		// The watch delivered the object while it was read, and is newer
+		return s.port, s.err
+	}
+	s.port, s.err, s.readAt = port, err, time.Now()
+	return port, err
+}
+
+// watch watches the object and keeps the port of every change. A deleted
+// object ends watching until the next watch, so that reads report it.
+func (s *Kubernetes) watch(ctx context.Context) error {
+	handle := func(values map[string]string, exists bool) {
+		if !exists {
+			s.setWatching(false)
+			return
+		}
+		port, err := s.parse(values)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if port != s.port && err == nil {
+			slog.Info("received forwarded port from Kubernetes", "port", port, "previous_port", s.port)
+		}
+		s.watching, s.port, s.err = true, port, err
+	}
+	if s.opts.ConfigMap != "" {
+		if err := s.client.WatchConfigMapData(ctx, s.opts.Namespace, s.opts.ConfigMap, handle); err != nil {
+			return fmt.Errorf("failed to watch ConfigMap %s/%s: %w", s.opts.Namespace, s.opts.ConfigMap, err)
+		}
+		return nil
+	}
+	if err := s.client.WatchPodAnnotations(ctx, s.opts.Namespace, s.opts.Pod, handle); err != nil {
+		return fmt.Errorf("failed to watch pod %s/%s: %w", s.opts.Namespace, s.opts.Pod, err)
+	}
+	return nil
+}
+
+// parse returns the port in the ConfigMap data or pod annotations
+func (s *Kubernetes) parse(values map[string]string) (int, error) {
+	key := s.opts.Key
+	if s.opts.Pod != "" {
+		key = s.opts.Annotation
+	}
+	return parseOutput(strings.TrimSpace(values[key]))
+}
+
+func (s *Kubernetes) setWatching(watching bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watching = watching
+}
@@ -0,0 +1,171 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/kubernetes"
+)
+
+// kubernetesClient returns a client of an API server with the ConfigMap
+// media/gluetun and the pod media/qbittorrent
+func kubernetesClient(t *testing.T) *kubernetes.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/media/configmaps/gluetun":
+			_, _ = w.Write([]byte(`{"data": {"port": "51413", "status": "{\"port\": 51415}"}}`))
+		case "/api/v1/namespaces/media/pods/qbittorrent":
+			_, _ = w.Write([]byte(`{"metadata": {"annotations": {"gluetun/port": "51414"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := kubernetes.NewClient(server.URL, kubernetes.Options{Namespace: "media"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestKubernetes_ForwardedPort(t *testing.T) {
+	tests := []struct {
+		name string
+		opts KubernetesOptions
+		want int
+	}{
+		{"configmap", KubernetesOptions{ConfigMap: "gluetun", Key: "port"}, 51413},
+		{"configmap json", KubernetesOptions{ConfigMap: "gluetun", Key: "status"}, 51415},
+		{"missing key", KubernetesOptions{ConfigMap: "gluetun", Key: "forwarded_port"}, 0},
+		{"annotation", KubernetesOptions{Namespace: "media", Pod: "qbittorrent", Annotation: "gluetun/port"}, 51414},
+		{"missing annotation", KubernetesOptions{Pod: "qbittorrent", Annotation: "port"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewKubernetes(kubernetesClient(t), tt.opts)
+			if err != nil {
+				t.Fatalf("NewKubernetes() error = %v", err)
+			}
+			port, err := source.ForwardedPort()
+			if err != nil || port != tt.want {
+				t.Errorf("ForwardedPort() = %d, %v, want %d", port, err, tt.want)
+			}
+		})
+	}
+
+	source, err := NewKubernetes(kubernetesClient(t), KubernetesOptions{ConfigMap: "vpn", Key: "port"})
+	if err != nil {
+		t.Fatalf("NewKubernetes() error = %v", err)
+	}
+	if _, err := source.ForwardedPort(); err == nil || !strings.Contains(err.Error(), "media/vpn") {
+		t.Errorf("ForwardedPort() error = %v, want missing ConfigMap", err)
+	}
+}
+
+func TestNewKubernetes_Invalid(t *testing.T) {
+	client := kubernetesClient(t)
+	for name, opts := range map[string]KubernetesOptions{
+		"none":          {},
+		"both":          {ConfigMap: "gluetun", Key: "port", Pod: "qbittorrent", Annotation: "gluetun/port"},
+		"no key":        {ConfigMap: "gluetun"},
+		"no annotation": {Pod: "qbittorrent"},
+	} {
+		if _, err := NewKubernetes(client, opts); err == nil {
+			t.Errorf("NewKubernetes() with %s error = nil", name)
+		}
+	}
+
+	withoutNamespace, err := kubernetes.NewClient("https://kubernetes.default.svc", kubernetes.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKubernetes(withoutNamespace, KubernetesOptions{ConfigMap: "gluetun", Key: "port"}); err == nil {
+		t.Error("NewKubernetes() without namespace error = nil")
+	}
+}
+
+func TestKubernetes_Run(t *testing.T) {
+	original := kubernetesRetryDelay
+	kubernetesRetryDelay = time.Hour
+	t.Cleanup(func() { kubernetesRetryDelay = original })
+
+	var reads atomic.Int32
+	events := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "" {
+			reads.Add(1)
+			_, _ = w.Write([]byte(`{"data": {"port": "51413"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type": "ADDED", "object": {"data": {"port": "51414"}}}` + "\n"))
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case event := <-events:
+				_, _ = w.Write([]byte(event + "\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := kubernetes.NewClient(server.URL, kubernetes.Options{Namespace: "media"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewKubernetes(client, KubernetesOptions{ConfigMap: "gluetun", Key: "port"})
+	if err != nil {
+		t.Fatalf("NewKubernetes() error = %v", err)
+	}
+
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		source.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForKubernetesPort(t, source, 51414)
+	// The watched port is used without reading the object
+	before := reads.Load()
+	events <- `{"type": "MODIFIED", "object": {"data": {"port": "51415"}}}`
+	waitForKubernetesPort(t, source, 51415)
+	if got := reads.Load(); got != before {
+		t.Errorf("reads while watching = %d, want none", got-before)
+	}
+
+	// A failed watch falls back to reading the object
+	events <- `{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}`
+	waitForKubernetesPort(t, source, 51413)
+}
+
+// waitForKubernetesPort polls the source until it reports want
+func waitForKubernetesPort(t *testing.T, source *Kubernetes, want int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		port, err := source.ForwardedPort()
+		if err == nil && port == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ForwardedPort() = %d, %v, want %d", port, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,138 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/mqtt"
+)
+
+// mqttRetryDelay is how long the MQTT source waits before reconnecting
+var mqttRetryDelay = 5 * time.Second
+
+// mqttRetainedWait is how long Refresh waits for a retained port
+const mqttRetainedWait = 2 * time.Second
+
+// MQTT keeps the forwarded port last published to a topic, as a plain number
+// or JSON with a port key, e.g. by an automation of Home Assistant. The port
+// is received as soon as it is published while Run is running; publishers
+// should retain it, so that it is known right after connecting.
+type MQTT struct {
+	client *mqtt.Client
+	topic  string
+
+	mu   sync.Mutex
+	port int
+	err  error
+}
+
+// NewMQTT creates a source subscribed to the topic with the client
+func NewMQTT(client *mqtt.Client, topic string) (*MQTT, error) {
+	if topic == "" {
+		return nil, errors.New("MQTT source topic is required")
+	}
+	return &MQTT{client: client, topic: topic}, nil
+}
+
+// ForwardedPort returns the last port published, or 0 before the first one.
+// While the broker is unreachable, it returns the connection error.
+func (s *MQTT) ForwardedPort() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.port, nil
+}
+
+// Refresh connects to the broker and waits briefly for a retained port, so
+// that the port is known before the first sync
+func (s *MQTT) Refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mqttRetainedWait)
+	defer cancel()
+	sub, err := s.client.Subscribe(ctx, s.topic)
+	if err != nil {
+		s.setErr(err)
+		return err
+	}
+	s.setErr(nil)
+
+	messages := make(chan mqtt.Message, 1)
+	go func() {
+		if message, err := sub.Next(); err == nil {
+			messages <- message
+		}
+	}()
+	select {
+	case message := <-messages:
+		s.handle(message)
+	case <-ctx.Done():
+	}
+	return sub.Close()
+}
+
+// Run stays subscribed to the topic until ctx is done, and reconnects after
+// mqttRetryDelay when the connection fails
+func (s *MQTT) Run(ctx context.Context) {
+	for {
+		err := s.receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		s.setErr(err)
+		slog.Warn("MQTT source disconnected, reconnecting", "retry_delay", mqttRetryDelay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(mqttRetryDelay):
+		}
+	}
+}
+
+// receive subscribes to the topic and handles messages until the connection
+// fails or ctx is done
+func (s *MQTT) receive(ctx context.Context) error {
+	sub, err := s.client.Subscribe(ctx, s.topic)
+	if err != nil {
+		return err
+	}
+	s.setErr(nil)
+	stop := context.AfterFunc(ctx, func() { _ = sub.Close() })
+	defer stop()
+	defer func() { _ = sub.Close() }()
+
+	for {
+		message, err := sub.Next()
+		if err != nil {
+			return fmt.Errorf("lost connection to MQTT broker: %w", err)
+		}
+		s.handle(message)
+	}
+}
+
+// handle takes the port of a message. Messages without a valid port are
+// ignored, so that other messages on the topic don't drop the port.
+func (s *MQTT) handle(message mqtt.Message) {
+	port, err := parseOutput(strings.TrimSpace(string(message.Payload)))
+	if err != nil {
+		slog.Warn("ignoring MQTT message without a valid port", "topic", message.Topic, "error", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if port != s.port {
+		slog.Info("received forwarded port over MQTT", "port", port, "previous_port", s.port, "topic", message.Topic, "retained", message.Retain)
+	}
+	s.port = port
+}
+
+func (s *MQTT) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/mqtt"
+	"github.com/eslutz/forwardarr/internal/mqtt/mqtttest"
+)
+
+// waitForPort polls the source until it reports want
+func waitForPort(t *testing.T, source *MQTT, want int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		port, err := source.ForwardedPort()
+		if err == nil && port == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ForwardedPort() = %d, %v, want %d", port, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newMQTTSource(t *testing.T, broker *mqtttest.Broker) *MQTT {
+	t.Helper()
+	client, err := mqtt.NewClient(broker.URL(), mqtt.Options{ClientID: "forwardarr-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewMQTT(client, "vpn/port")
+	if err != nil {
+		t.Fatalf("NewMQTT() error = %v", err)
+	}
+	return source
+}
+
+func TestMQTT_Refresh(t *testing.T) {
+	source := newMQTTSource(t, mqtttest.NewBroker(t, mqtttest.Config{Retained: `{"port": 51413}`}))
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if port, err := source.ForwardedPort(); err != nil || port != 51413 {
+		t.Errorf("ForwardedPort() = %d, %v, want the retained port", port, err)
+	}
+
+	source = newMQTTSource(t, mqtttest.NewBroker(t, mqtttest.Config{}))
+	start := time.Now()
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() without retained port error = %v", err)
+	}
+	if port, err := source.ForwardedPort(); err != nil || port != 0 {
+		t.Errorf("ForwardedPort() = %d, %v, want no port", port, err)
+	}
+	if elapsed := time.Since(start); elapsed > mqttRetainedWait+time.Second {
+		t.Errorf("Refresh() took %v, want at most %v", elapsed, mqttRetainedWait)
+	}
+}
+
+func TestMQTT_Run(t *testing.T) {
+	original := mqttRetryDelay
+	mqttRetryDelay = 10 * time.Millisecond
+	t.Cleanup(func() { mqttRetryDelay = original })
+
+	broker := mqtttest.NewBroker(t, mqtttest.Config{Retained: "51413"})
+	source := newMQTTSource(t, broker)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		source.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForPort(t, source, 51413)
+	broker.Publish("not a port")
+	broker.Publish("51414\n")
+	waitForPort(t, source, 51414)
+
+	// Dropping the connection reconnects and restores the retained port
+	broker.DropConnection()
+	waitForPort(t, source, 51413)
+
+	broker.Close()
+	broker.DropConnection()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, err := source.ForwardedPort()
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ForwardedPort() error = nil, want the connection error")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewMQTT_NoTopic(t *testing.T) {
+	client, err := mqtt.NewClient("mqtt://broker", mqtt.Options{ClientID: "forwardarr-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMQTT(client, ""); err == nil || !strings.Contains(err.Error(), "topic") {
+		t.Errorf("NewMQTT() error = %v, want missing topic", err)
+	}
+}
@@ -0,0 +1,42 @@
+package sync
+
+import "log/slog"
+
+// subscriberBuffer is the number of sync records a subscriber can fall
+// behind before further records are dropped for it
+const subscriberBuffer = 16
+
+// Subscribe returns a channel that receives every sync attempt from now on,
+// and a function that ends the subscription and closes the channel. Records
+// are dropped rather than delaying the sync if the subscriber falls behind.
+func (w *Watcher) Subscribe() (records <-chan SyncRecord, unsubscribe func()) {
+	ch := make(chan SyncRecord, subscriberBuffer)
+
+	w.mu.Lock()
+	if w.subscribers == nil {
+		w.subscribers = make(map[chan SyncRecord]struct{})
+	}
+	w.subscribers[ch] = struct{}{}
+	w.mu.Unlock()
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subscribers[ch]; ok {
+			delete(w.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish hands the record to all subscribers. It must be called with w.mu
+// held.
+func (w *Watcher) publish(record SyncRecord) {
+	for ch := range w.subscribers {
+		select {
+		case ch <- record:
+		default:
+			slog.Debug("dropped sync event for slow subscriber", "result", record.Result)
+		}
+	}
+}
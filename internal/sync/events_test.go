@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatcherSubscribe(t *testing.T) {
+	watcher := &Watcher{}
+	records, unsubscribe := watcher.Subscribe()
+
+	watcher.recordSync(SyncResultSuccess, 9090, 8080, nil)
+	watcher.recordSync(SyncResultFailed, 9090, 0, errors.New("qbittorrent unreachable"))
+
+	if got := <-records; got.Result != SyncResultSuccess || got.Port != 9090 || got.OldPort != 8080 {
+		t.Errorf("first record = %+v, want the change from 8080 to 9090", got)
+	}
+	if got := <-records; got.Result != SyncResultFailed || got.Error == "" {
+		t.Errorf("second record = %+v, want the failed sync", got)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-records; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+	watcher.recordSync(SyncResultSuccess, 9090, 0, nil)
+}
+
+func TestWatcherSubscribe_SlowSubscriber(t *testing.T) {
+	watcher := &Watcher{}
+	records, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+
+	for port := range subscriberBuffer + 5 {
+		watcher.recordSync(SyncResultSuccess, port, 0, nil)
+	}
+
+	if len(records) != subscriberBuffer {
+		t.Errorf("subscriber has %d pending records, want %d", len(records), subscriberBuffer)
+	}
+	if got := <-records; got.Port != 0 {
+		t.Errorf("first record has port %d, want the oldest record kept", got.Port)
+	}
+}
@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// NamedSource is a source of a Fallback, named in logs and notifications,
+// e.g. "file"
+type NamedSource struct {
+	Name   string
+	Source PortSource
+}
+
+// Fallback provides the port of the first of its sources, in order of
+// priority, that reports one. Sources that fail or report no port are
+// skipped, so that e.g. the port file takes over while the Gluetun control
+// server is unreachable, and the control server takes over again once it
+// recovers.
+type Fallback struct {
+	sources []NamedSource
+
+	// mu guards active, the source that provided the last port, reason,
+	// why the sources before it were skipped, and results, the outcome of
+	// every source read for the last port
+	mu      sync.Mutex
+	active  string
+	reason  error
+	results []SourceResult
+}
+
+// NewFallback creates a source that falls back through the sources in order
+func NewFallback(sources ...NamedSource) *Fallback {
+	return &Fallback{sources: sources}
+}
+
+// ForwardedPort returns the port of the first source that reports one. If
+// none does, it returns 0, with the errors of the failed sources if any.
+func (f *Fallback) ForwardedPort() (int, error) {
+	var skipped, failed []error
+	var results []SourceResult
+	for _, s := range f.sources {
+		port, err := s.Source.ForwardedPort()
+		results = append(results, SourceResult{Name: s.Name, Port: port, Err: err})
+		if err == nil && port >= 1 && port <= 65535 {
+			f.setActive(s.Name, errors.Join(skipped...), results)
+			return port, nil
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", s.Name, err)
+			failed = append(failed, err)
+		} else {
+			err = fmt.Errorf("%s: no forwarded port", s.Name)
+		}
+		skipped = append(skipped, err)
+	}
+	f.setActive("", errors.Join(skipped...), results)
+	return 0, errors.Join(failed...)
+}
+
+func (f *Fallback) setActive(name string, reason error, results []SourceResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active, f.reason, f.results = name, reason, results
+}
+
+// Active returns the name of the source that provided the last port, or ""
+// if none did, and why the sources before it were skipped
+func (f *Fallback) Active() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active, f.reason
+}
+
+// Results returns the outcome of the sources read for the last port, up to
+// the one that provided it. Sources after it were not read.
+func (f *Fallback) Results() []SourceResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.results
+}
+
+// failoverSource is a port source that switches between several sources,
+// such as Fallback
+type failoverSource interface {
+	Active() (string, error)
+}
+
+// checkFailover records which source of a failover source provided port, and
+// sends a source_failover notification when that changed since the last
+// port. The first source used is only logged.
+func (w *Watcher) checkFailover(ctx context.Context, port int) {
+	source, ok := w.source.(failoverSource)
+	if !ok {
+		return
+	}
+	active, reason := source.Active()
+	w.mu.Lock()
+	previous := w.status.ActiveSource
+	if active == "" || active == previous {
+		w.mu.Unlock()
+		return
+	}
+	w.status.ActiveSource = active
+	w.mu.Unlock()
+
+	if previous == "" {
+		slog.Info("using port source", "source", active, "port", port)
+		return
+	}
+	slog.Warn("port source changed", "from", previous, "to", active, "port", port, "reason", reason)
+	IncrementSourceFailovers()
+	if notifier := w.currentNotifier(); notifier != nil {
+		if err := notifier.SendSourceFailover(ctx, previous, active, port, reason); err != nil {
+			slog.Warn("failed to send webhook notification", "error", err)
+		}
+	}
+}
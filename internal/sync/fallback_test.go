@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	control := &fakeSource{err: errors.New("connection refused")}
+	file := &fakeSource{}
+	fallback := NewFallback(
+		NamedSource{Name: "gluetun_control", Source: control},
+		NamedSource{Name: "file", Source: file},
+		NamedSource{Name: "static", Source: StaticPort(51413)},
+	)
+
+	port, err := fallback.ForwardedPort()
+	if port != 51413 || err != nil {
+		t.Fatalf("ForwardedPort() = %d, %v, want the static port", port, err)
+	}
+	active, reason := fallback.Active()
+	if active != "static" || reason == nil || !strings.Contains(reason.Error(), "gluetun_control: connection refused") || !strings.Contains(reason.Error(), "file: no forwarded port") {
+		t.Errorf("Active() = %q, %v, want static with the skipped sources", active, reason)
+	}
+
+	control.port, control.err = 9090, nil
+	if port, _ := fallback.ForwardedPort(); port != 9090 {
+		t.Errorf("ForwardedPort() = %d, want the control server again", port)
+	}
+	if active, reason := fallback.Active(); active != "gluetun_control" || reason != nil {
+		t.Errorf("Active() = %q, %v, want gluetun_control", active, reason)
+	}
+	if results := fallback.Results(); len(results) != 1 || results[0].Name != "gluetun_control" || results[0].Port != 9090 {
+		t.Errorf("Results() = %+v, want only the control server", results)
+	}
+}
+
+func TestFallback_NoPort(t *testing.T) {
+	fallback := NewFallback(
+		NamedSource{Name: "a", Source: &fakeSource{}},
+		NamedSource{Name: "b", Source: &fakeSource{}},
+	)
+	if port, err := fallback.ForwardedPort(); port != 0 || err != nil {
+		t.Errorf("ForwardedPort() = %d, %v, want no port without error", port, err)
+	}
+
+	fallback = NewFallback(
+		NamedSource{Name: "a", Source: &fakeSource{}},
+		NamedSource{Name: "b", Source: &fakeSource{err: errors.New("timeout")}},
+	)
+	if port, err := fallback.ForwardedPort(); port != 0 || err == nil || err.Error() != "b: timeout" {
+		t.Errorf("ForwardedPort() = %d, %v, want the error of b", port, err)
+	}
+	if active, _ := fallback.Active(); active != "" {
+		t.Errorf("Active() = %q, want none", active)
+	}
+	if results := fallback.Results(); len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("Results() = %+v, want both sources", results)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forwarded_port")
+	if _, err := (FileSource{Path: path}).ForwardedPort(); err == nil {
+		t.Error("ForwardedPort() error = nil, want missing file")
+	}
+	for content, want := range map[string]int{"51413\n": 51413, "": 0, "abc": 0, "70000": 0, `{"port": 51414}`: 51414, "51415\n51416\n": 51415} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if port, err := (FileSource{Path: path}).ForwardedPort(); port != want || err != nil {
+			t.Errorf("ForwardedPort() of %q = %d, %v, want %d", content, port, err, want)
+		}
+	}
+}
+
+func TestWatcherCheckFailover(t *testing.T) {
+	control := &fakeSource{port: 9090}
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{qbitClient: &memoryClient{}, notifier: notifier}
+	watcher.SetPortSource(NewFallback(
+		NamedSource{Name: "gluetun_control", Source: control},
+		NamedSource{Name: "static", Source: StaticPort(51413)},
+	), 0)
+
+	failovers := func() []string {
+		return slices.DeleteFunc(slices.Clone(*events), func(event string) bool { return event != "source_failover" })
+	}
+
+	// The first source used is not a failover
+	watcher.pollSource(t.Context())
+	if got := watcher.Status().ActiveSource; got != "gluetun_control" || len(failovers()) != 0 {
+		t.Fatalf("ActiveSource = %q, events = %v, want gluetun_control without failover", got, *events)
+	}
+
+	control.err = errors.New("connection refused")
+	watcher.pollSource(t.Context())
+	watcher.pollSource(t.Context())
+	control.err = nil
+	watcher.pollSource(t.Context())
+	if got := failovers(); len(got) != 2 {
+		t.Errorf("webhook events = %v, want a failover and a failback", *events)
+	}
+	if got := watcher.Status().ActiveSource; got != "gluetun_control" {
+		t.Errorf("ActiveSource = %q, want gluetun_control", got)
+	}
+}
+
+func TestWatcherPortSourceName(t *testing.T) {
+	watcher := &Watcher{}
+	if got := watcher.portSourceName(); got != "file" {
+		t.Errorf("portSourceName() of the port file = %q, want file", got)
+	}
+	watcher.SetPortSource(&fakeSource{}, 0)
+	watcher.SetSourceHealth("natpmp", 0, 0)
+	if got := watcher.portSourceName(); got != "natpmp" {
+		t.Errorf("portSourceName() = %q, want natpmp", got)
+	}
+
+	control := &fakeSource{err: errors.New("connection refused")}
+	fallback := NewFallback(
+		NamedSource{Name: "gluetun_control", Source: control},
+		NamedSource{Name: "file", Source: &fakeSource{}},
+	)
+	watcher.SetPortSource(fallback, 0)
+	_, _ = fallback.ForwardedPort()
+	if got := watcher.portSourceName(); got != "gluetun_control,file" {
+		t.Errorf("portSourceName() without a port = %q, want every source", got)
+	}
+	control.port, control.err = 9090, nil
+	_, _ = fallback.ForwardedPort()
+	if got := watcher.portSourceName(); got != "gluetun_control" {
+		t.Errorf("portSourceName() = %q, want the active source", got)
+	}
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// firewallGracePeriod is how long qBittorrent may report firewalled before
+// qbit_firewalled is sent. qBittorrent reports firewalled until the first
+// incoming connection arrives, which can take minutes after a port change.
+const firewallGracePeriod = 5 * time.Minute
+
+// checkFirewall asks qBittorrent whether peers reach the applied port, and
+// sends qbit_firewalled and qbit_connectable notifications when that
+// changes. Firewalled despite a forwarded port usually means the forward of
+// the VPN is broken. Torrent clients that do not report their connection
+// status are not checked.
+func (w *Watcher) checkFirewall(ctx context.Context) {
+	checker, ok := w.qbitClient.(torrent.ConnectionChecker)
+	if !ok {
+		return
+	}
+	// Without an applied port, or with qBittorrent down or left alone, the
+	// status says nothing about the forward
+	port := w.appliedPort()
+	if port == 0 || w.isPaused() || !w.qbitDownSince.IsZero() {
+		return
+	}
+	status, err := checker.ConnectionStatus()
+	if err != nil {
+		slog.Debug("failed to get qBittorrent connection status", "error", err)
+		return
+	}
+	firewalled := status == torrent.ConnectionFirewalled
+	setConnectionStatus(status)
+
+	w.mu.Lock()
+	w.status.ConnectionStatus = status
+	since := w.firewalledSince
+	reported := w.firewallReported
+	if !firewalled {
+		w.firewalledSince = time.Time{}
+		w.firewallReported = false
+	} else if since.IsZero() {
+		w.firewalledSince = time.Now()
+	} else if !reported && time.Since(since) >= firewallGracePeriod {
+		w.firewallReported = true
+	}
+	report := w.firewallReported && !reported
+	w.mu.Unlock()
+
+	notifier := w.currentNotifier()
+	switch {
+	case report:
+		duration := time.Since(since)
+		slog.Warn("qBittorrent is firewalled, the port forward may be broken", "port", port, "duration", duration)
+		if notifier != nil {
+			if err := notifier.SendQbitFirewalled(ctx, port, duration); err != nil {
+				slog.Warn("failed to send webhook notification", "error", err)
+			}
+		}
+	case reported && !firewalled:
+		downtime := time.Since(since)
+		slog.Info("qBittorrent is connectable again", "port", port, "downtime", downtime)
+		if notifier != nil {
+			if err := notifier.SendQbitConnectable(ctx, port, downtime); err != nil {
+				slog.Warn("failed to send webhook notification", "error", err)
+			}
+		}
+	}
+}
+
+// resetFirewall restarts the grace period after a port change, since the
+// new port has not received connections yet. A firewalled state that was
+// already reported stays until qBittorrent is connectable.
+func (w *Watcher) resetFirewall() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.firewallReported {
+		w.firewalledSince = time.Time{}
+	}
+}
+
+// appliedPort returns the port last confirmed in qBittorrent
+func (w *Watcher) appliedPort() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status.CurrentPort
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// newFirewallServer fakes a qBittorrent that reports *status as its
+// connection status
+func newFirewallServer(t *testing.T) (*qbit.Client, *string) {
+	t.Helper()
+	status := new(string)
+	*status = torrent.ConnectionConnected
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/transfer/info":
+			_ = json.NewEncoder(w).Encode(map[string]any{"connection_status": *status, "dht_nodes": 300})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client, status
+}
+
+func TestWatcherCheckFirewall(t *testing.T) {
+	client, status := newFirewallServer(t)
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{qbitClient: client, notifier: notifier}
+	watcher.status.CurrentPort = 6000
+
+	watcher.checkFirewall(t.Context())
+	if got := watcher.Status().ConnectionStatus; got != torrent.ConnectionConnected {
+		t.Fatalf("ConnectionStatus = %q, want connected", got)
+	}
+
+	// Firewalled within the grace period, e.g. right after a port change
+	*status = torrent.ConnectionFirewalled
+	watcher.checkFirewall(t.Context())
+	watcher.checkFirewall(t.Context())
+	if len(*events) != 0 {
+		t.Fatalf("webhook events = %v, want none within the grace period", *events)
+	}
+	if got := watcher.Status(); got.FirewalledSince.IsZero() || got.Firewalled {
+		t.Errorf("status = firewalled %v since %v, want since set but not yet firewalled", got.Firewalled, got.FirewalledSince)
+	}
+
+	watcher.firewalledSince = time.Now().Add(-firewallGracePeriod)
+	watcher.checkFirewall(t.Context())
+	watcher.checkFirewall(t.Context())
+	if !slices.Equal(*events, []string{"qbit_firewalled"}) {
+		t.Fatalf("webhook events = %v, want [qbit_firewalled]", *events)
+	}
+	if !watcher.Status().Firewalled {
+		t.Error("Firewalled not set after the grace period")
+	}
+
+	// A port change keeps the reported state until qBittorrent is reachable
+	watcher.resetFirewall()
+	*status = torrent.ConnectionConnected
+	watcher.checkFirewall(t.Context())
+	if !slices.Equal(*events, []string{"qbit_firewalled", "qbit_connectable"}) {
+		t.Errorf("webhook events = %v, want [qbit_firewalled qbit_connectable]", *events)
+	}
+	if got := watcher.Status(); !got.FirewalledSince.IsZero() || got.Firewalled {
+		t.Error("firewall state not reset after recovery")
+	}
+}
+
+func TestWatcherCheckFirewall_PortChangeRestartsGrace(t *testing.T) {
+	client, status := newFirewallServer(t)
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{qbitClient: client, notifier: notifier}
+	watcher.status.CurrentPort = 6000
+
+	*status = torrent.ConnectionFirewalled
+	watcher.checkFirewall(t.Context())
+	watcher.firewalledSince = time.Now().Add(-firewallGracePeriod)
+	watcher.resetFirewall()
+	watcher.checkFirewall(t.Context())
+	if len(*events) != 0 {
+		t.Errorf("webhook events = %v, want none after a port change", *events)
+	}
+}
+
+func TestWatcherCheckFirewall_Skipped(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(w *Watcher)
+	}{
+		{name: "no port applied", setup: func(w *Watcher) { w.status.CurrentPort = 0 }},
+		{name: "paused", setup: func(w *Watcher) { w.status.Paused = true }},
+		{name: "qBittorrent down", setup: func(w *Watcher) { w.qbitDownSince = time.Now() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, status := newFirewallServer(t)
+			*status = torrent.ConnectionFirewalled
+			watcher := &Watcher{qbitClient: client}
+			watcher.status.CurrentPort = 6000
+			tt.setup(watcher)
+
+			watcher.checkFirewall(t.Context())
+			if got := watcher.Status(); got.ConnectionStatus != "" || !got.FirewalledSince.IsZero() {
+				t.Errorf("status = %q since %v, want unchecked", got.ConnectionStatus, got.FirewalledSince)
+			}
+		})
+	}
+}
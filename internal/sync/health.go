@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Defaults of SetSourceHealth
+const (
+	DefaultUnhealthyFailures = 3
+	DefaultStaleAfter        = 5 * time.Minute
+)
+
+// SourceHealth describes how reliably a port source provides the port
+type SourceHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// ConsecutiveFailures counts the reads in a row that failed with an
+	// error, LastError is the error of the last one
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error,omitempty"`
+	// LastPort is the last port the source provided at LastPortTime
+	LastPort     int       `json:"last_port,omitempty"`
+	LastPortTime time.Time `json:"last_port_time,omitzero"`
+	// UnhealthySince is set while the source is unhealthy
+	UnhealthySince time.Time `json:"unhealthy_since,omitzero"`
+}
+
+// SourceResult is the outcome of reading a single port source: its port, 0
+// if it had none, or the error
+type SourceResult struct {
+	Name string
+	Port int
+	Err  error
+}
+
+// resultSource is a port source made of several sources, such as Fallback,
+// that reports the results of the sources it read for the last port
+type resultSource interface {
+	Results() []SourceResult
+}
+
+// sourceState tracks the health of a source; since is when it was first
+// read, so that a source that never provided a port also becomes stale
+type sourceState struct {
+	health SourceHealth
+	since  time.Time
+}
+
+// SetSourceHealth names the single port source, or the port file, in the
+// source health, and sets when a source counts as unhealthy: after failures
+// failed reads in a row, or after providing no port for staleAfter. Zero
+// disables either check. It must be called before Start.
+func (w *Watcher) SetSourceHealth(name string, failures int, staleAfter time.Duration) {
+	w.sourceName = name
+	w.unhealthyFailures = failures
+	w.staleAfter = staleAfter
+}
+
+// sourceResults returns the results of the sources read for port, which was
+// read with err
+func (w *Watcher) sourceResults(port int, err error) []SourceResult {
+	if source, ok := w.source.(resultSource); ok {
+		return source.Results()
+	}
+	return []SourceResult{{Name: w.singleSourceName(), Port: port, Err: err}}
+}
+
+// singleSourceName returns the name of the single port source or the port
+// file, as set with SetSourceHealth
+func (w *Watcher) singleSourceName() string {
+	switch {
+	case w.sourceName != "":
+		return w.sourceName
+	case w.source != nil:
+		return "source"
+	}
+	return "file"
+}
+
+// portSourceName names the component of vpn_down and vpn_recovered
+// notifications: the source of a fallback that provided the last port, or
+// every source read if none did, otherwise the single source
+func (w *Watcher) portSourceName() string {
+	failover, ok := w.source.(failoverSource)
+	if !ok {
+		return w.singleSourceName()
+	}
+	if active, _ := failover.Active(); active != "" {
+		return active
+	}
+	var names []string
+	if source, ok := w.source.(resultSource); ok {
+		for _, result := range source.Results() {
+			names = append(names, result.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// checkSourceHealth records the results of the sources read for port and
+// sends a source_unhealthy notification for every source that just became
+// unhealthy
+func (w *Watcher) checkSourceHealth(ctx context.Context, port int, err error) {
+	now := time.Now()
+	var unhealthy []SourceHealth
+	w.mu.Lock()
+	if w.sourceStates == nil {
+		w.sourceStates = make(map[string]*sourceState)
+	}
+	for _, result := range w.sourceResults(port, err) {
+		state, ok := w.sourceStates[result.Name]
+		if !ok {
+			state = &sourceState{health: SourceHealth{Name: result.Name, Healthy: true}, since: now}
+			w.sourceStates[result.Name] = state
+			w.sourceOrder = append(w.sourceOrder, result.Name)
+		}
+		if w.observe(state, result, now) {
+			unhealthy = append(unhealthy, state.health)
+		}
+		setSourceHealth(state.health)
+	}
+	w.mu.Unlock()
+
+	for _, health := range unhealthy {
+		reason := unhealthyReason(health)
+		slog.Warn("port source is unhealthy", "source", health.Name, "reason", reason)
+		notifier := w.currentNotifier()
+		if notifier == nil {
+			continue
+		}
+		if err := notifier.SendSourceUnhealthy(ctx, health.Name, health.ConsecutiveFailures, health.LastPortTime, reason); err != nil {
+			slog.Warn("failed to send webhook notification", "error", err)
+		}
+	}
+}
+
+// observe applies a result to the state of its source and reports whether
+// the source just became unhealthy. The caller holds w.mu.
+func (w *Watcher) observe(state *sourceState, result SourceResult, now time.Time) bool {
+	health := &state.health
+	if result.Err == nil && result.Port >= 1 && result.Port <= 65535 {
+		if !health.Healthy {
+			slog.Info("port source is healthy again", "source", health.Name, "port", result.Port, "downtime", now.Sub(health.UnhealthySince))
+		}
+		*health = SourceHealth{Name: health.Name, Healthy: true, LastPort: result.Port, LastPortTime: now}
+		return false
+	}
+
+	if result.Err != nil {
+		health.ConsecutiveFailures++
+		health.LastError = result.Err.Error()
+	} else {
+		// the source answered, but has no port
+		health.ConsecutiveFailures = 0
+		health.LastError = ""
+	}
+	if !health.Healthy {
+		return false
+	}
+	lastPort := health.LastPortTime
+	if lastPort.IsZero() {
+		lastPort = state.since
+	}
+	failing := w.unhealthyFailures > 0 && health.ConsecutiveFailures >= w.unhealthyFailures
+	stale := w.staleAfter > 0 && now.Sub(lastPort) >= w.staleAfter
+	if !failing && !stale {
+		return false
+	}
+	health.Healthy = false
+	health.UnhealthySince = now
+	return true
+}
+
+// unhealthyReason explains why a source became unhealthy
+func unhealthyReason(health SourceHealth) error {
+	if health.ConsecutiveFailures > 0 {
+		return fmt.Errorf("%d failed reads in a row: %s", health.ConsecutiveFailures, health.LastError)
+	}
+	if health.LastPortTime.IsZero() {
+		return errors.New("no port provided yet")
+	}
+	return fmt.Errorf("no port provided since %s", health.LastPortTime.UTC().Format(time.RFC3339))
+}
+
+// sourceHealth returns the health of the sources in the order they were
+// first read. The caller holds w.mu.
+func (w *Watcher) sourceHealth() []SourceHealth {
+	if len(w.sourceOrder) == 0 {
+		return nil
+	}
+	health := make([]SourceHealth, 0, len(w.sourceOrder))
+	for _, name := range w.sourceOrder {
+		health = append(health, w.sourceStates[name].health)
+	}
+	return health
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatcherCheckSourceHealth_Failures(t *testing.T) {
+	controlSource := &fakeSource{err: errors.New("connection refused")}
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{qbitClient: &memoryClient{}, notifier: notifier}
+	watcher.SetPortSource(NewFallback(
+		NamedSource{Name: "gluetun_control", Source: controlSource},
+		NamedSource{Name: "static", Source: StaticPort(51413)},
+	), 0)
+	watcher.SetSourceHealth("", 3, 0)
+
+	unhealthy := func() int {
+		return len(slices.DeleteFunc(slices.Clone(*events), func(event string) bool { return event != "source_unhealthy" }))
+	}
+	watcher.pollSource(t.Context())
+	sources := watcher.Status().Sources
+	if len(sources) != 2 || sources[0].Name != "gluetun_control" || !sources[0].Healthy || sources[0].ConsecutiveFailures != 1 {
+		t.Fatalf("Sources = %+v, want gluetun_control healthy after 1 failure", sources)
+	}
+	if !sources[1].Healthy || sources[1].LastPort != 51413 || sources[1].LastPortTime.IsZero() {
+		t.Errorf("static = %+v, want healthy with its port", sources[1])
+	}
+
+	// Only the read that reaches the threshold is reported
+	for range 3 {
+		watcher.pollSource(t.Context())
+	}
+	control := watcher.Status().Sources[0]
+	if control.Healthy || control.UnhealthySince.IsZero() || control.LastError != "connection refused" || unhealthy() != 1 {
+		t.Errorf("gluetun_control = %+v, events = %v, want unhealthy and reported once", control, *events)
+	}
+
+	controlSource.port, controlSource.err = 9090, nil
+	watcher.pollSource(t.Context())
+	if control := watcher.Status().Sources[0]; !control.Healthy || control.ConsecutiveFailures != 0 || control.LastPort != 9090 {
+		t.Errorf("gluetun_control = %+v, want healthy again", control)
+	}
+}
+
+func TestWatcherCheckSourceHealth_Stale(t *testing.T) {
+	source := &fakeSource{port: 51413}
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{qbitClient: &memoryClient{}, notifier: notifier}
+	watcher.SetPortSource(source, 0)
+	watcher.SetSourceHealth("http", 0, time.Minute)
+
+	watcher.pollSource(t.Context())
+	source.port = 0
+	watcher.pollSource(t.Context())
+	if health := watcher.Status().Sources; len(health) != 1 || health[0].Name != "http" || !health[0].Healthy {
+		t.Fatalf("Sources = %+v, want http healthy", health)
+	}
+
+	// Pretend the port was provided long ago
+	watcher.sourceStates["http"].health.LastPortTime = time.Now().Add(-2 * time.Minute)
+	watcher.pollSource(t.Context())
+	if health := watcher.Status().Sources[0]; health.Healthy || health.ConsecutiveFailures != 0 {
+		t.Errorf("http = %+v, want unhealthy without failures", health)
+	}
+	if !slices.Contains(*events, "source_unhealthy") {
+		t.Errorf("webhook events = %v, want source_unhealthy", *events)
+	}
+}
+
+func TestWatcherCheckSourceHealth_Disabled(t *testing.T) {
+	watcher := &Watcher{qbitClient: &memoryClient{}}
+	watcher.SetPortSource(&fakeSource{err: errors.New("timeout")}, 0)
+	watcher.SetSourceHealth("exec", 0, 0)
+	for range 10 {
+		watcher.pollSource(t.Context())
+	}
+	if health := watcher.Status().Sources[0]; !health.Healthy || health.ConsecutiveFailures != 10 {
+		t.Errorf("exec = %+v, want healthy with the failures counted", health)
+	}
+}
+
+func TestUnhealthyReason(t *testing.T) {
+	lastPort := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		health SourceHealth
+		want   string
+	}{
+		{SourceHealth{ConsecutiveFailures: 3, LastError: "timeout"}, "3 failed reads in a row: timeout"},
+		{SourceHealth{}, "no port provided yet"},
+		{SourceHealth{LastPortTime: lastPort}, "no port provided since 2026-01-02T03:04:05Z"},
+	} {
+		if got := unhealthyReason(tt.health).Error(); got != tt.want {
+			t.Errorf("unhealthyReason(%+v) = %q, want %q", tt.health, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of sync attempts kept by default
+const DefaultHistorySize = 1000
+
+// History keeps the most recent sync attempts in memory and, if a file is
+// set, appends them to it as JSON lines so they survive restarts
+type History struct {
+	size int
+	path string
+
+	mu sync.Mutex
+	// records holds the attempts oldest first
+	records []SyncRecord
+	// lines is the number of records in the file, which is compacted once it
+	// holds twice the size
+	lines int
+}
+
+// NewHistory creates a history of up to size attempts, stored in the file at
+// path unless it is empty. Attempts already in the file are loaded.
+func NewHistory(size int, path string) (*History, error) {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	h := &History{size: size, path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	records, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+	h.lines = len(records)
+	h.records = records[max(len(records)-size, 0):]
+	return h, nil
+}
+
+// Add records a sync attempt. Failures to write the file are logged, since
+// they must not affect the sync.
+func (h *History) Add(record SyncRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, record)
+	if len(h.records) > h.size {
+		h.records = slices.Clone(h.records[len(h.records)-h.size:])
+	}
+	if h.path == "" {
+		return
+	}
+
+	var err error
+	if h.lines >= 2*h.size {
+		err = h.save()
+	} else {
+		err = h.append(record)
+	}
+	if err != nil {
+		slog.Warn("failed to write sync history", "path", h.path, "error", err)
+	}
+}
+
+// Recent returns up to n of the most recent attempts, newest first
+func (h *History) Recent(n int) []SyncRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n = min(n, len(h.records))
+	recent := make([]SyncRecord, n)
+	for i := range n {
+		recent[i] = h.records[len(h.records)-1-i]
+	}
+	return recent
+}
+
+// HistoryQuery selects attempts from the history. Zero values do not filter.
+type HistoryQuery struct {
+	// Since and Until bound the time of the attempts, inclusively
+	Since time.Time
+	Until time.Time
+	// Result selects attempts with the given result
+	Result string
+	// Changes selects attempts that changed the port
+	Changes bool
+	// Offset skips the newest matching attempts; Limit caps the attempts
+	// returned
+	Offset int
+	Limit  int
+}
+
+// HistoryPage is a page of matching attempts, newest first. Total is the
+// number of matching attempts on all pages.
+type HistoryPage struct {
+	Total   int          `json:"total"`
+	Offset  int          `json:"offset"`
+	Limit   int          `json:"limit"`
+	Records []SyncRecord `json:"records"`
+}
+
+// Query returns the page of attempts selected by q
+func (h *History) Query(q HistoryQuery) HistoryPage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	page := HistoryPage{Offset: q.Offset, Limit: q.Limit, Records: []SyncRecord{}}
+	for i := len(h.records) - 1; i >= 0; i-- {
+		record := h.records[i]
+		if !q.matches(record) {
+			continue
+		}
+		if page.Total >= q.Offset && (q.Limit <= 0 || len(page.Records) < q.Limit) {
+			page.Records = append(page.Records, record)
+		}
+		page.Total++
+	}
+	return page
+}
+
+func (q HistoryQuery) matches(record SyncRecord) bool {
+	switch {
+	case !q.Since.IsZero() && record.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && record.Time.After(q.Until):
+		return false
+	case q.Result != "" && record.Result != q.Result:
+		return false
+	case q.Changes && record.OldPort == 0:
+		return false
+	}
+	return true
+}
+
+// load reads the attempts in the file. Unreadable lines are skipped, so a
+// truncated write does not lose the rest of the history.
+func (h *History) load() ([]SyncRecord, error) {
+	f, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync history: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var records []SyncRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record SyncRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			slog.Warn("skipping invalid sync history entry", "error", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sync history: %w", err)
+	}
+	return records, nil
+}
+
+// append adds a record to the file
+func (h *History) append(record SyncRecord) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create sync history directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open sync history: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write sync history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write sync history: %w", err)
+	}
+	h.lines++
+	return nil
+}
+
+// save atomically replaces the file with the records in memory
+func (h *History) save() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create sync history directory: %w", err)
+	}
+
+	tmp := h.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write sync history: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, record := range h.records {
+		if err := enc.Encode(record); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to write sync history: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write sync history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("failed to write sync history: %w", err)
+	}
+	h.lines = len(h.records)
+	return nil
+}
+
+// SetHistory replaces the in-memory history of NewWatcher, e.g. with one
+// stored in a file. It must be called before Start.
+func (w *Watcher) SetHistory(history *History) {
+	w.longHistory = history
+}
+
+// History returns the page of recorded sync attempts selected by q
+func (w *Watcher) History(q HistoryQuery) HistoryPage {
+	if w.longHistory == nil {
+		return HistoryPage{Offset: q.Offset, Limit: q.Limit, Records: []SyncRecord{}}
+	}
+	return w.longHistory.Query(q)
+}
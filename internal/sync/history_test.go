@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryQuery(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	history, err := NewHistory(10, "")
+	if err != nil {
+		t.Fatalf("NewHistory() error = %v", err)
+	}
+	// one attempt per minute: ports 1 to 6, changing the port on even ones
+	// and failing on the fifth
+	for i := 1; i <= 6; i++ {
+		record := SyncRecord{Time: start.Add(time.Duration(i) * time.Minute), Result: SyncResultSuccess, Port: i}
+		if i%2 == 0 {
+			record.OldPort = i - 1
+		}
+		if i == 5 {
+			record.Result = SyncResultFailed
+		}
+		history.Add(record)
+	}
+
+	tests := []struct {
+		name      string
+		query     HistoryQuery
+		wantTotal int
+		wantPorts []int
+	}{
+		{name: "all", wantTotal: 6, wantPorts: []int{6, 5, 4, 3, 2, 1}},
+		{name: "page", query: HistoryQuery{Offset: 2, Limit: 3}, wantTotal: 6, wantPorts: []int{4, 3, 2}},
+		{name: "beyond last page", query: HistoryQuery{Offset: 6, Limit: 3}, wantTotal: 6, wantPorts: []int{}},
+		{
+			name:      "time range",
+			query:     HistoryQuery{Since: start.Add(2 * time.Minute), Until: start.Add(4 * time.Minute)},
+			wantTotal: 3,
+			wantPorts: []int{4, 3, 2},
+		},
+		{name: "result", query: HistoryQuery{Result: SyncResultFailed}, wantTotal: 1, wantPorts: []int{5}},
+		{name: "changes", query: HistoryQuery{Changes: true, Limit: 2}, wantTotal: 3, wantPorts: []int{6, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := history.Query(tt.query)
+			if page.Total != tt.wantTotal {
+				t.Errorf("Total = %d, want %d", page.Total, tt.wantTotal)
+			}
+			ports := make([]int, len(page.Records))
+			for i, record := range page.Records {
+				ports[i] = record.Port
+			}
+			if !slices.Equal(ports, tt.wantPorts) {
+				t.Errorf("ports = %v, want %v", ports, tt.wantPorts)
+			}
+		})
+	}
+}
+
+func TestHistory_Size(t *testing.T) {
+	history, err := NewHistory(3, "")
+	if err != nil {
+		t.Fatalf("NewHistory() error = %v", err)
+	}
+	for port := 1; port <= 5; port++ {
+		history.Add(SyncRecord{Result: SyncResultSuccess, Port: port})
+	}
+
+	recent := history.Recent(10)
+	if len(recent) != 3 || recent[0].Port != 5 || recent[2].Port != 3 {
+		t.Errorf("Recent() = %+v, want ports 5 to 3", recent)
+	}
+}
+
+func TestHistory_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "history.jsonl")
+	history, err := NewHistory(3, path)
+	if err != nil {
+		t.Fatalf("NewHistory() error = %v", err)
+	}
+	// enough attempts to compact the file once
+	for port := 1; port <= 8; port++ {
+		history.Add(SyncRecord{Result: SyncResultSuccess, Port: port})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read history file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > 6 {
+		t.Errorf("history file has %d lines, want it compacted to at most 6", lines)
+	}
+
+	reloaded, err := NewHistory(3, path)
+	if err != nil {
+		t.Fatalf("NewHistory() reload error = %v", err)
+	}
+	recent := reloaded.Recent(10)
+	if len(recent) != 3 || recent[0].Port != 8 || recent[2].Port != 6 {
+		t.Errorf("reloaded history = %+v, want ports 8 to 6", recent)
+	}
+}
+
+func TestNewHistory_SkipsInvalidLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	content := `{"time":"2025-01-02T03:04:05Z","result":"success","port":8080}` + "\n" +
+		`{"time":"2025-01-02T03:05` + "\n" +
+		`{"time":"2025-01-02T03:06:05Z","result":"failed","port":9090,"error":"timeout"}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write history file: %v", err)
+	}
+
+	history, err := NewHistory(10, path)
+	if err != nil {
+		t.Fatalf("NewHistory() error = %v", err)
+	}
+	recent := history.Recent(10)
+	if len(recent) != 2 || recent[0].Port != 9090 || recent[1].Port != 8080 {
+		t.Errorf("loaded history = %+v, want ports 9090 and 8080", recent)
+	}
+}
+
+func TestWatcherHistory(t *testing.T) {
+	watcher := &Watcher{longHistory: &History{size: DefaultHistorySize}}
+	watcher.recordSync(SyncResultSuccess, 9090, 8080, nil)
+	watcher.recordSync(SyncResultSuccess, 9090, 0, nil)
+
+	page := watcher.History(HistoryQuery{Changes: true})
+	if page.Total != 1 || page.Records[0].OldPort != 8080 {
+		t.Errorf("History() = %+v, want the port change from 8080", page)
+	}
+}
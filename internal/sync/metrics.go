@@ -3,6 +3,8 @@ package sync
 import (
 	"time"
 
+	"github.com/eslutz/forwardarr/internal/torrent"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -27,8 +29,42 @@ var (
 		Name: "forwardarr_last_sync_timestamp",
 		Help: "Unix timestamp of the last successful sync",
 	})
+
+	qbitFirewalled = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "forwardarr_qbit_firewalled",
+		Help: "Whether qBittorrent reported its connection as firewalled at the last check (1) or not (0)",
+	})
+
+	qbitConnectionStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "forwardarr_qbit_connection_status",
+		Help: "Connection status reported by qBittorrent at the last check; 1 for the current status, 0 for the others",
+	}, []string{"status"})
+
+	sourceFailovers = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "forwardarr_source_failovers_total",
+		Help: "Total number of switches between the sources of PORT_SOURCES",
+	})
+
+	sourceHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "forwardarr_source_healthy",
+		Help: "Whether the port source provides the port reliably (1) or is unhealthy (0)",
+	}, []string{"source"})
+
+	sourceFailures = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "forwardarr_source_consecutive_failures",
+		Help: "Number of reads of the port source in a row that failed",
+	}, []string{"source"})
+
+	sourceLastPort = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "forwardarr_source_last_port_timestamp",
+		Help: "Unix timestamp of the last time the port source provided a port",
+	}, []string{"source"})
 )
 
+// connectionStatuses are the values of the status label of
+// forwardarr_qbit_connection_status
+var connectionStatuses = []string{torrent.ConnectionConnected, torrent.ConnectionFirewalled, torrent.ConnectionDisconnected}
+
 func SetCurrentPort(port int) {
 	currentPort.Set(float64(port))
 }
@@ -41,6 +77,40 @@ func IncrementSyncErrors() {
 	syncErrors.Inc()
 }
 
+// IncrementSourceFailovers counts a switch to another port source
+func IncrementSourceFailovers() {
+	sourceFailovers.Inc()
+}
+
+// setSourceHealth records the health of a port source
+func setSourceHealth(health SourceHealth) {
+	healthy := 0.0
+	if health.Healthy {
+		healthy = 1
+	}
+	sourceHealthy.WithLabelValues(health.Name).Set(healthy)
+	sourceFailures.WithLabelValues(health.Name).Set(float64(health.ConsecutiveFailures))
+	if !health.LastPortTime.IsZero() {
+		sourceLastPort.WithLabelValues(health.Name).Set(float64(health.LastPortTime.Unix()))
+	}
+}
+
 func UpdateLastSyncTimestamp() {
 	lastSyncTimestamp.Set(float64(time.Now().Unix()))
 }
+
+// setConnectionStatus records the connection status reported by qBittorrent
+func setConnectionStatus(status string) {
+	for _, known := range connectionStatuses {
+		value := 0.0
+		if status == known {
+			value = 1
+		}
+		qbitConnectionStatus.WithLabelValues(known).Set(value)
+	}
+	if status == torrent.ConnectionFirewalled {
+		qbitFirewalled.Set(1)
+	} else {
+		qbitFirewalled.Set(0)
+	}
+}
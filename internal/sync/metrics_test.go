@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/eslutz/forwardarr/internal/torrent"
+
 	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
@@ -29,4 +31,16 @@ func TestMetricsHelpers(t *testing.T) {
 	if got := testutil.ToFloat64(lastSyncTimestamp); got <= float64(time.Now().Add(-1*time.Second).Unix()) {
 		t.Fatalf("lastSyncTimestamp not updated, got %v", got)
 	}
+
+	setConnectionStatus(torrent.ConnectionFirewalled)
+	setConnectionStatus(torrent.ConnectionConnected)
+	if got := testutil.ToFloat64(qbitConnectionStatus.WithLabelValues(torrent.ConnectionConnected)); got != 1 {
+		t.Fatalf("connection status connected = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(qbitConnectionStatus.WithLabelValues(torrent.ConnectionFirewalled)); got != 0 {
+		t.Fatalf("connection status firewalled = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(qbitFirewalled); got != 0 {
+		t.Fatalf("qbitFirewalled = %v, want 0", got)
+	}
 }
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Pause puts the watcher in maintenance mode, e.g. while qBittorrent is
+// upgraded. Syncs keep reading the port file and record the port they would
+// apply with the result paused, but leave qBittorrent alone. Pause returns
+// false if the sync was already paused.
+func (w *Watcher) Pause() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status.Paused {
+		return false
+	}
+	w.status.Paused = true
+	w.status.PausedSince = time.Now()
+	slog.Info("port sync paused")
+	return true
+}
+
+// Resume ends the maintenance mode of Pause and syncs the port right away, so
+// that changes made while paused are applied. Resume returns false if the sync
+// was not paused.
+func (w *Watcher) Resume() bool {
+	w.mu.Lock()
+	if !w.status.Paused {
+		w.mu.Unlock()
+		return false
+	}
+	pausedFor := time.Since(w.status.PausedSince)
+	w.status.Paused = false
+	w.status.PausedSince = time.Time{}
+	w.mu.Unlock()
+
+	slog.Info("port sync resumed", "paused_for", pausedFor)
+	w.TriggerSync()
+	return true
+}
+
+func (w *Watcher) isPaused() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status.Paused
+}
@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+)
+
+func TestWatcherPause(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("9090"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	server, port, getPortCalls, setPortCalls := newTestQbitServer(t, 8080, 0, 0)
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	watcher, err := NewWatcher(portFile, client, nil, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	if !watcher.Pause() {
+		t.Fatal("Pause() = false, want true")
+	}
+	if watcher.Pause() {
+		t.Error("second Pause() = true, want false")
+	}
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+	if *getPortCalls != 0 || *setPortCalls != 0 {
+		t.Errorf("paused sync called qBittorrent %d times, want 0", *getPortCalls+*setPortCalls)
+	}
+	status := watcher.Status()
+	if !status.Paused || status.PausedSince.IsZero() {
+		t.Errorf("Status() paused = %v since %v, want paused", status.Paused, status.PausedSince)
+	}
+	if status.LastSyncResult != SyncResultPaused || status.History[0].Port != 9090 {
+		t.Errorf("last sync = %+v, want paused with port 9090", status.History[0])
+	}
+
+	if !watcher.Resume() {
+		t.Fatal("Resume() = false, want true")
+	}
+	if watcher.Resume() {
+		t.Error("second Resume() = true, want false")
+	}
+	select {
+	case <-watcher.syncNow:
+	default:
+		t.Error("Resume() did not trigger a sync")
+	}
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+	if *port != 9090 {
+		t.Errorf("qBittorrent port = %d after resume, want 9090", *port)
+	}
+	if status := watcher.Status(); status.Paused || !status.PausedSince.IsZero() {
+		t.Errorf("Status() paused = %v since %v after resume", status.Paused, status.PausedSince)
+	}
+}
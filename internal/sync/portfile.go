@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// portFileDebounce is how long the watcher waits after an event of the port
+// file for further events before it reads the file. Writers such as Gluetun
+// truncate the file before writing the port, which arrives as separate
+// events; reading right after the first one would find the file empty and
+// report the VPN down.
+const portFileDebounce = 50 * time.Millisecond
+
+// rewatchInterval is how often the watcher tries to watch the directory of
+// the port file again after it was removed, e.g. with a recreated volume
+const rewatchInterval = time.Second
+
+// portFileEvents turns the events of the watched directory into syncs of the
+// port file for Start
+type portFileEvents struct {
+	watcher *fsnotify.Watcher
+	file    string
+	dir     string
+
+	// debounce fires once the port file has settled; rewatch ticks while
+	// the directory is gone
+	debounce *time.Timer
+	rewatch  *time.Ticker
+}
+
+func newPortFileEvents(watcher *fsnotify.Watcher, file string) *portFileEvents {
+	return &portFileEvents{watcher: watcher, file: file, dir: filepath.Dir(file)}
+}
+
+// handle processes an event of the watched directory. Writing or creating
+// the port file, also by renaming another file onto it, schedules a sync;
+// removing the file is ignored until it is created again.
+func (p *portFileEvents) handle(event fsnotify.Event) {
+	switch {
+	case event.Name == p.file && event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+		slog.Debug("port file changed", "event", event.Op.String())
+		p.schedule()
+
+	case event.Name == p.dir && (event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename)):
+		if p.rewatch != nil {
+			return
+		}
+		slog.Warn("port file directory was removed, waiting for it to come back", "directory", p.dir)
+		// A moved directory is still watched under its new name
+		_ = p.watcher.Remove(p.dir)
+		p.rewatch = time.NewTicker(rewatchInterval)
+	}
+}
+
+// schedule starts or restarts the debounce of the port file
+func (p *portFileEvents) schedule() {
+	if p.debounce == nil {
+		p.debounce = time.NewTimer(portFileDebounce)
+		return
+	}
+	p.debounce.Reset(portFileDebounce)
+}
+
+// settled returns the channel that receives once the port file has settled,
+// or nil while no change is pending or no port file is watched
+func (p *portFileEvents) settled() <-chan time.Time {
+	if p == nil || p.debounce == nil {
+		return nil
+	}
+	return p.debounce.C
+}
+
+// rewatchTicks returns the channel that ticks while the directory is gone,
+// or nil while it is watched or no port file is watched
+func (p *portFileEvents) rewatchTicks() <-chan time.Time {
+	if p == nil || p.rewatch == nil {
+		return nil
+	}
+	return p.rewatch.C
+}
+
+// tryRewatch watches the directory again if it exists, and schedules a sync
+// since the port file may have been written in the meantime
+func (p *portFileEvents) tryRewatch() {
+	if err := p.watcher.Add(p.dir); err != nil {
+		slog.Debug("port file directory still missing", "directory", p.dir, "error", err)
+		return
+	}
+	p.rewatch.Stop()
+	p.rewatch = nil
+	slog.Info("watching for port file changes again", "directory", p.dir, "file", p.file)
+	p.schedule()
+}
+
+// stop releases the timers
+func (p *portFileEvents) stop() {
+	if p.debounce != nil {
+		p.debounce.Stop()
+	}
+	if p.rewatch != nil {
+		p.rewatch.Stop()
+	}
+}
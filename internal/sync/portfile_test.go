@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryClient is a torrent client that records the ports set on it
+type memoryClient struct {
+	mu    sync.Mutex
+	port  int
+	ports []int
+}
+
+func (c *memoryClient) GetPort() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.port, nil
+}
+
+func (c *memoryClient) SetPort(port int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.port = port
+	c.ports = append(c.ports, port)
+	return nil
+}
+
+func (c *memoryClient) Ping() error     { return nil }
+func (c *memoryClient) PingOnce() error { return nil }
+
+// waitForPort waits until the client has the port
+func (c *memoryClient) waitForPort(t *testing.T, port int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got, _ := c.GetPort(); got == port {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	got, _ := c.GetPort()
+	t.Fatalf("port = %d, want %d", got, port)
+}
+
+// startWatcher runs a watcher of portFile until the test ends
+func startWatcher(t *testing.T, portFile string, client *memoryClient) *Watcher {
+	t.Helper()
+	watcher, err := NewWatcher(portFile, client, nil, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	done := make(chan struct{})
+	ctx := t.Context()
+	go func() {
+		defer close(done)
+		_ = watcher.Start(ctx)
+	}()
+	t.Cleanup(func() { <-done })
+	return watcher
+}
+
+func TestWatcherPortFileTruncated(t *testing.T) {
+	portFile := filepath.Join(t.TempDir(), "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("9090"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &memoryClient{}
+	watcher := startWatcher(t, portFile, client)
+	client.waitForPort(t, 9090)
+
+	// Truncating and writing separately, as Gluetun does, is one change
+	file, err := os.OpenFile(portFile, os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString("9191"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client.waitForPort(t, 9191)
+
+	for _, record := range watcher.Status().History {
+		if record.Result == SyncResultSkipped {
+			t.Errorf("sync skipped for the truncated file: %+v", record)
+		}
+	}
+}
+
+func TestWatcherPortFileRecreated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gluetun")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// An unclean path still matches the events
+	portFile := dir + "//forwarded_port"
+	if err := os.WriteFile(portFile, []byte("9090"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &memoryClient{}
+	startWatcher(t, portFile, client)
+	client.waitForPort(t, 9090)
+
+	// A replaced file is picked up
+	tmp := filepath.Join(dir, "forwarded_port.tmp")
+	if err := os.WriteFile(tmp, []byte("9191"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, portFile); err != nil {
+		t.Fatal(err)
+	}
+	client.waitForPort(t, 9191)
+
+	// So is a recreated directory, e.g. a recreated volume
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(portFile, []byte("9292"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client.waitForPort(t, 9292)
+}
@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortFileFormat is the layout of the port file
+type PortFileFormat int
+
+const (
+	// PortFileAuto detects the layout from the content
+	PortFileAuto PortFileFormat = iota
+	// PortFilePlain is a single port, as written by Gluetun before v3.40
+	PortFilePlain
+	// PortFileList holds several ports separated by newlines, commas or
+	// spaces, as written by Gluetun when it forwards several ports or by a
+	// port forwarding up command with {{PORTS}}; the first one is used
+	PortFileList
+	// PortFileJSON is an object with the port in "port", or the ports in
+	// "ports" like the response of the Gluetun control server
+	PortFileJSON
+)
+
+// ParsePortFileFormat parses a port file format; an empty name is auto
+func ParsePortFileFormat(name string) (PortFileFormat, error) {
+	switch name {
+	case "", "auto":
+		return PortFileAuto, nil
+	case "plain":
+		return PortFilePlain, nil
+	case "list":
+		return PortFileList, nil
+	case "json":
+		return PortFileJSON, nil
+	}
+	return PortFileAuto, fmt.Errorf("unknown port file format %q (expected auto, plain, list or json)", name)
+}
+
+func (f PortFileFormat) String() string {
+	switch f {
+	case PortFilePlain:
+		return "plain"
+	case PortFileList:
+		return "list"
+	case PortFileJSON:
+		return "json"
+	default:
+		return "auto"
+	}
+}
+
+// SetPortFileFormat sets the layout of the port file. It must be called
+// before Start.
+func (w *Watcher) SetPortFileFormat(format PortFileFormat) {
+	w.portFileFormat = format
+}
+
+// parsePortFile returns the port in the content of a port file, or 0 if it
+// is empty
+func parsePortFile(content string, format PortFileFormat) (int, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return 0, nil
+	}
+	if format == PortFileAuto {
+		format = detectPortFileFormat(content)
+	}
+
+	var port int
+	switch format {
+	case PortFileJSON:
+		var body struct {
+			Port  int   `json:"port"`
+			Ports []int `json:"ports"`
+		}
+		if err := json.Unmarshal([]byte(content), &body); err != nil {
+			return 0, fmt.Errorf("invalid JSON port file: %w", err)
+		}
+		port = body.Port
+		if port == 0 && len(body.Ports) > 0 {
+			port = body.Ports[0]
+		}
+		if port == 0 {
+			return 0, nil
+		}
+	case PortFileList:
+		fields := strings.FieldsFunc(content, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+		})
+		for i, field := range fields {
+			p, err := strconv.Atoi(field)
+			if err != nil {
+				return 0, fmt.Errorf("invalid port %q in port list", field)
+			}
+			if i == 0 {
+				port = p
+			}
+		}
+	default:
+		p, err := strconv.Atoi(content)
+		if err != nil {
+			return 0, fmt.Errorf("invalid port %q", content)
+		}
+		port = p
+	}
+
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d out of valid range", port)
+	}
+	return port, nil
+}
+
+// detectPortFileFormat guesses the layout of non-empty port file content
+func detectPortFileFormat(content string) PortFileFormat {
+	switch {
+	case strings.HasPrefix(content, "{"):
+		return PortFileJSON
+	case strings.ContainsAny(content, ", \t\n"):
+		return PortFileList
+	default:
+		return PortFilePlain
+	}
+}
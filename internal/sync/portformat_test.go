@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePortFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  PortFileFormat
+		want    int
+		wantErr bool
+	}{
+		{"plain", "51413\n", PortFileAuto, 51413, false},
+		{"empty", "\n", PortFileAuto, 0, false},
+		{"list", "51413\n51414\n", PortFileAuto, 51413, false},
+		{"comma list", "51413,51414", PortFileAuto, 51413, false},
+		{"single port list", "51413", PortFileList, 51413, false},
+		{"json", `{"port": 51413}`, PortFileAuto, 51413, false},
+		{"json ports", `{"ports": [51413, 51414]}`, PortFileAuto, 51413, false},
+		{"json without port", `{"port": 0}`, PortFileJSON, 0, false},
+		{"not a number", "abc", PortFileAuto, 0, true},
+		{"out of range", "70000", PortFileAuto, 0, true},
+		{"zero", "0", PortFileAuto, 0, true},
+		{"invalid list", "51413\nabc", PortFileAuto, 0, true},
+		{"invalid json", `{"port": "51413"`, PortFileAuto, 0, true},
+		{"list as plain", "51413\n51414", PortFilePlain, 0, true},
+		{"plain as json", "51413", PortFileJSON, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, err := parsePortFile(tt.content, tt.format)
+			if port != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("parsePortFile(%q, %s) = %d, %v, want %d", tt.content, tt.format, port, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePortFileFormat(t *testing.T) {
+	for name, want := range map[string]PortFileFormat{"": PortFileAuto, "auto": PortFileAuto, "plain": PortFilePlain, "list": PortFileList, "json": PortFileJSON} {
+		format, err := ParsePortFileFormat(name)
+		if err != nil || format != want {
+			t.Errorf("ParsePortFileFormat(%q) = %v, %v, want %v", name, format, err, want)
+		}
+		if name != "" && format.String() != name {
+			t.Errorf("String() = %q, want %q", format.String(), name)
+		}
+	}
+	if _, err := ParsePortFileFormat("yaml"); err == nil {
+		t.Error("ParsePortFileFormat() error = nil, want unknown format")
+	}
+}
+
+func TestReadPortFromFile_Format(t *testing.T) {
+	portFile := filepath.Join(t.TempDir(), "forwarded_port")
+	if err := os.WriteFile(portFile, []byte(`{"port": 51413}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w := &Watcher{portFile: portFile}
+	w.SetPortFileFormat(PortFileJSON)
+	if port, err := w.readPortFromFile(); err != nil || port != 51413 {
+		t.Errorf("readPortFromFile() = %d, %v, want 51413", port, err)
+	}
+
+	w.SetPortFileFormat(PortFilePlain)
+	if port, err := w.readPortFromFile(); err != nil || port != 0 {
+		t.Errorf("readPortFromFile() as plain = %d, %v, want no port", port, err)
+	}
+}
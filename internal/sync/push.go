@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// PushPort makes the watcher sync the port pushed by an external system, e.g.
+// a Gluetun up command, instead of the port in the port file. The pushed port
+// is used until the port file or port source changes. The sync runs in the
+// background.
+func (w *Watcher) PushPort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d out of valid range", port)
+	}
+
+	w.mu.Lock()
+	w.pushedPort = port
+	w.mu.Unlock()
+	slog.Info("port pushed", "port", port)
+	w.TriggerSync()
+	return nil
+}
+
+// clearPushedPort lets the port file or source take over again from a pushed
+// port
+func (w *Watcher) clearPushedPort() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pushedPort != 0 {
+		slog.Info("forwarded port changed, replacing the pushed port", "pushed_port", w.pushedPort)
+		w.pushedPort = 0
+	}
+}
+
+// hasPushedPort reports whether a pushed port replaces the port file or
+// source
+func (w *Watcher) hasPushedPort() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.pushedPort != 0
+}
+
+// readPort returns the pushed port, if any, or the port of the port source
+// or file
+func (w *Watcher) readPort() (int, error) {
+	w.mu.Lock()
+	pushed := w.pushedPort
+	w.mu.Unlock()
+	if pushed != 0 {
+		return pushed, nil
+	}
+	if w.source != nil {
+		return w.readPortFromSource()
+	}
+	return w.readPortFromFile()
+}
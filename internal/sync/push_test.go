@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+)
+
+func TestWatcherPushPort(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("9090"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	server, port, _, _ := newTestQbitServer(t, 8080, 0, 0)
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	watcher, err := NewWatcher(portFile, client, nil, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	if err := watcher.PushPort(7070); err != nil {
+		t.Fatalf("PushPort() error = %v", err)
+	}
+	select {
+	case <-watcher.syncNow:
+	default:
+		t.Error("PushPort() did not trigger a sync")
+	}
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+	if *port != 7070 {
+		t.Errorf("qBittorrent port = %d, want pushed port 7070", *port)
+	}
+	if got := watcher.Status().PushedPort; got != 7070 {
+		t.Errorf("Status().PushedPort = %d, want 7070", got)
+	}
+
+	// A change of the port file replaces the pushed port
+	watcher.clearPushedPort()
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+	if *port != 9090 {
+		t.Errorf("qBittorrent port = %d, want port file's 9090", *port)
+	}
+	if got := watcher.Status().PushedPort; got != 0 {
+		t.Errorf("Status().PushedPort = %d, want 0", got)
+	}
+}
+
+func TestWatcherPushPort_Invalid(t *testing.T) {
+	watcher := &Watcher{syncNow: make(chan struct{}, 1)}
+	for _, port := range []int{0, -1, 65536} {
+		if err := watcher.PushPort(port); err == nil {
+			t.Errorf("PushPort(%d) error = nil, want error", port)
+		}
+	}
+	if watcher.pushedPort != 0 {
+		t.Errorf("pushedPort = %d, want 0", watcher.pushedPort)
+	}
+	if len(watcher.syncNow) != 0 {
+		t.Error("invalid push triggered a sync")
+	}
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// Reannounce selects the torrents announced to their trackers after a new
+// port was applied, so that peers learn the port before the next regular
+// announce
+type Reannounce int
+
+const (
+	ReannounceNone Reannounce = iota
+	ReannounceAll
+	ReannounceActive
+)
+
+// ParseReannounce parses a reannounce mode; an empty name is none
+func ParseReannounce(name string) (Reannounce, error) {
+	switch name {
+	case "", "none":
+		return ReannounceNone, nil
+	case "all":
+		return ReannounceAll, nil
+	case "active":
+		return ReannounceActive, nil
+	}
+	return ReannounceNone, fmt.Errorf("unknown reannounce mode %q (expected none, all or active)", name)
+}
+
+func (r Reannounce) String() string {
+	switch r {
+	case ReannounceAll:
+		return "all"
+	case ReannounceActive:
+		return "active"
+	default:
+		return "none"
+	}
+}
+
+// SetReannounce sets the torrents to reannounce after a port change. It must
+// be called before Start.
+func (w *Watcher) SetReannounce(mode Reannounce) {
+	w.reannounce = mode
+}
+
+// reannounceTorrents announces the torrents selected by the reannounce mode.
+// The new port is already applied at this point, so a failure is only
+// logged; the torrents still announce it at their next regular announce.
+// Torrent clients that cannot reannounce are skipped.
+func (w *Watcher) reannounceTorrents(port int) {
+	if w.reannounce == ReannounceNone {
+		return
+	}
+	reannouncer, ok := w.qbitClient.(torrent.Reannouncer)
+	if !ok {
+		slog.Debug("torrent client does not support reannouncing", "port", port)
+		return
+	}
+	if err := reannouncer.Reannounce(w.reannounce == ReannounceActive); err != nil {
+		slog.Warn("failed to reannounce torrents", "torrents", w.reannounce.String(), "port", port, "error", err)
+		return
+	}
+	slog.Info("reannounced torrents", "torrents", w.reannounce.String(), "port", port)
+}
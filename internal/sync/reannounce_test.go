@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+func TestParseReannounce(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Reannounce
+		wantErr bool
+	}{
+		{name: "", want: ReannounceNone},
+		{name: "none", want: ReannounceNone},
+		{name: "all", want: ReannounceAll},
+		{name: "active", want: ReannounceActive},
+		{name: "paused", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseReannounce(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseReannounce(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseReannounce(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newReannounceServer fakes a qBittorrent at port whose reannounce endpoint
+// answers with status and counts its calls
+func newReannounceServer(t *testing.T, port, status int) (*httptest.Server, *int) {
+	t.Helper()
+	calls := new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/app/preferences":
+			_ = json.NewEncoder(w).Encode(qbit.Preferences{ListenPort: port})
+		case "/api/v2/app/setPreferences":
+			if err := r.ParseForm(); err == nil {
+				var prefs qbit.Preferences
+				if json.Unmarshal([]byte(r.Form.Get("json")), &prefs) == nil {
+					port = prefs.ListenPort
+				}
+			}
+		case "/api/v2/torrents/reannounce":
+			*calls++
+			w.WriteHeader(status)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func TestWatcherSyncPortReannounces(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      Reannounce
+		qbitPort  int
+		status    int
+		wantCalls int
+	}{
+		{name: "after port change", mode: ReannounceAll, qbitPort: 4000, status: http.StatusOK, wantCalls: 1},
+		{name: "disabled", mode: ReannounceNone, qbitPort: 4000, status: http.StatusOK, wantCalls: 0},
+		{name: "port unchanged", mode: ReannounceAll, qbitPort: 6000, status: http.StatusOK, wantCalls: 0},
+		{name: "failure keeps the sync", mode: ReannounceAll, qbitPort: 4000, status: http.StatusInternalServerError, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portFile := filepath.Join(t.TempDir(), "forwarded_port")
+			if err := os.WriteFile(portFile, []byte("6000"), 0644); err != nil {
+				t.Fatalf("failed to write port file: %v", err)
+			}
+			server, calls := newReannounceServer(t, tt.qbitPort, tt.status)
+
+			client, err := qbit.NewClient(server.URL, "user", "pass")
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+			watcher := &Watcher{portFile: portFile, qbitClient: client}
+			watcher.SetReannounce(tt.mode)
+			if err := watcher.syncPort(t.Context()); err != nil {
+				t.Fatalf("syncPort() error = %v", err)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("reannounce calls = %d, want %d", *calls, tt.wantCalls)
+			}
+			if status := watcher.Status(); status.LastSyncResult != SyncResultSuccess {
+				t.Errorf("last sync result = %s, want success", status.LastSyncResult)
+			}
+		})
+	}
+}
+
+// portOnlyClient is a torrent client without optional capabilities. Its
+// methods are not expected to be called.
+type portOnlyClient struct {
+	torrent.Client
+}
+
+func TestWatcherOptionalCapabilities(t *testing.T) {
+	watcher := &Watcher{qbitClient: portOnlyClient{}, reannounce: ReannounceAll}
+	watcher.status.CurrentPort = 6000
+
+	// Neither may call the client, which would panic
+	watcher.reannounceTorrents(6000)
+	watcher.checkFirewall(t.Context())
+
+	if got := watcher.Status().ConnectionStatus; got != "" {
+		t.Errorf("ConnectionStatus = %q, want unchecked", got)
+	}
+}
@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// PortSource provides the forwarded port instead of the port file, e.g. the
+// control server of Gluetun. ForwardedPort returns 0 while no port is
+// forwarded.
+type PortSource interface {
+	ForwardedPort() (int, error)
+}
+
+// FileSource reads the port from a port file in the format, for use in a
+// Fallback. Unlike the watched port file, it is read whenever the source is
+// polled.
+type FileSource struct {
+	Path   string
+	Format PortFileFormat
+}
+
+// ForwardedPort returns the port in the file, or 0 if it holds none
+func (f FileSource) ForwardedPort() (int, error) {
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read port file: %w", err)
+	}
+	port, err := parsePortFile(string(content), f.Format)
+	if err != nil {
+		return 0, nil
+	}
+	return port, nil
+}
+
+// StaticPort is a fixed port, e.g. the last resort of a Fallback
+type StaticPort int
+
+// ForwardedPort returns the port
+func (p StaticPort) ForwardedPort() (int, error) {
+	return int(p), nil
+}
+
+// SetPortSource makes the watcher read the port from source instead of the
+// port file. The source is polled every pollInterval, and a changed port is
+// synced right away like a changed port file. It must be called before
+// Start.
+func (w *Watcher) SetPortSource(source PortSource, pollInterval time.Duration) {
+	w.source = source
+	w.pollInterval = pollInterval
+}
+
+// readPortFromSource returns the port of the port source, 0 if it has none
+func (w *Watcher) readPortFromSource() (int, error) {
+	port, err := w.source.ForwardedPort()
+	if err != nil {
+		return 0, err
+	}
+	return w.useSourcePort(port), nil
+}
+
+// useSourcePort remembers the port the source provided and returns it, or 0
+// if it is out of range
+func (w *Watcher) useSourcePort(port int) int {
+	w.sourcePort = port
+	if port < 1 || port > 65535 {
+		return 0
+	}
+	return port
+}
+
+// pollSource syncs the polled port when the port source provides a different
+// port than last time. A failed poll is only logged; the next sync reports
+// it.
+func (w *Watcher) pollSource(ctx context.Context) {
+	port, err := w.source.ForwardedPort()
+	w.checkSourceHealth(ctx, port, err)
+	if err != nil {
+		slog.Debug("failed to poll port source", "error", err)
+		return
+	}
+	w.checkFailover(ctx, port)
+	if port == w.sourcePort {
+		return
+	}
+	slog.Debug("port source changed", "old_port", w.sourcePort, "new_port", port)
+	w.clearPushedPort()
+	if err := w.applyPort(ctx, w.useSourcePort(port), nil); err != nil {
+		slog.Error("failed to sync port after source change", "error", err)
+		IncrementSyncErrors()
+	}
+}
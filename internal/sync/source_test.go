@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/qbit"
+)
+
+// fakeSource is a port source with a settable port
+type fakeSource struct {
+	port  int
+	err   error
+	reads int
+}
+
+func (s *fakeSource) ForwardedPort() (int, error) {
+	s.reads++
+	return s.port, s.err
+}
+
+func TestWatcherPortSource(t *testing.T) {
+	server, port, _, _ := newTestQbitServer(t, 8080, 0, 0)
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	// Without a port file, no directory is watched
+	watcher, err := NewWatcher("", client, nil, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	source := &fakeSource{port: 9090}
+	watcher.SetPortSource(source, time.Minute)
+
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+	if *port != 9090 {
+		t.Errorf("qBittorrent port = %d, want 9090", *port)
+	}
+
+	// A poll syncs a changed port, without reading the source again, and
+	// replaces a pushed one
+	watcher.pushedPort = 7070
+	source.port = 9191
+	source.reads = 0
+	watcher.pollSource(t.Context())
+	if *port != 9191 {
+		t.Errorf("qBittorrent port = %d, want 9191 after poll", *port)
+	}
+	if source.reads != 1 {
+		t.Errorf("source reads = %d, want 1 per poll", source.reads)
+	}
+	if watcher.pushedPort != 0 {
+		t.Errorf("pushedPort = %d, want cleared", watcher.pushedPort)
+	}
+
+	// Gluetun without a forwarded port skips the sync
+	source.port = 0
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+	if status := watcher.Status(); status.SourceHealthy {
+		t.Error("SourceHealthy = true, want false without a forwarded port")
+	}
+
+	source.err = errors.New("connection refused")
+	if err := watcher.syncPort(t.Context()); err == nil {
+		t.Error("syncPort() error = nil, want the source error")
+	}
+}
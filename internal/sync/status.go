@@ -0,0 +1,146 @@
+package sync
+
+import "time"
+
+// Results of a sync attempt
+const (
+	SyncResultSuccess = "success"
+	SyncResultFailed  = "failed"
+	// SyncResultSkipped means the port file held no valid port
+	SyncResultSkipped = "skipped"
+	// SyncResultPaused means the sync was paused and left qBittorrent
+	// unchanged
+	SyncResultPaused = "paused"
+)
+
+// maxSyncHistory is the number of recent sync attempts kept for Status
+const maxSyncHistory = 20
+
+// SyncRecord describes a single sync attempt
+type SyncRecord struct {
+	Time   time.Time `json:"time"`
+	Result string    `json:"result"`
+	// Port is the port read from the port file; OldPort is the port it
+	// replaced in qBittorrent if the sync changed it
+	Port    int    `json:"port,omitempty"`
+	OldPort int    `json:"old_port,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status is a snapshot of the watcher's state for diagnostics
+type Status struct {
+	// CurrentPort is the port last confirmed in qBittorrent and PreviousPort
+	// the one it replaced at LastChange
+	CurrentPort  int       `json:"current_port"`
+	PreviousPort int       `json:"previous_port,omitempty"`
+	LastChange   time.Time `json:"last_change,omitzero"`
+
+	LastSync       time.Time `json:"last_sync,omitzero"`
+	LastSyncResult string    `json:"last_sync_result,omitempty"`
+	LastSyncError  string    `json:"last_sync_error,omitempty"`
+	LastSuccess    time.Time `json:"last_successful_sync,omitzero"`
+	SyncFailures   int       `json:"consecutive_sync_failures"`
+	// Running reports whether the sync loop of Start is running
+	Running bool `json:"running"`
+	// Paused reports whether the sync is paused for maintenance since
+	// PausedSince
+	Paused      bool      `json:"paused"`
+	PausedSince time.Time `json:"paused_since,omitzero"`
+
+	// SourceHealthy reports whether the port file provides a valid port
+	SourceHealthy   bool      `json:"source_healthy"`
+	SourceError     string    `json:"source_error,omitempty"`
+	SourceDownSince time.Time `json:"source_down_since,omitzero"`
+	// ActiveSource names the source of PORT_SOURCES that provided the last
+	// port
+	ActiveSource string `json:"active_source,omitempty"`
+	// Sources describes the health of every port source read so far
+	Sources []SourceHealth `json:"sources,omitempty"`
+
+	QbitDownSince time.Time `json:"qbittorrent_down_since,omitzero"`
+	// ConnectionStatus is the last connection status reported by
+	// qBittorrent: connected, firewalled or disconnected. FirewalledSince is
+	// set while it is firewalled, and Firewalled once that lasted beyond the
+	// grace period after a port change.
+	ConnectionStatus string    `json:"connection_status,omitempty"`
+	FirewalledSince  time.Time `json:"firewalled_since,omitzero"`
+	Firewalled       bool      `json:"firewalled"`
+
+	// PushedPort is the port pushed with PushPort while it replaces the port
+	// file
+	PushedPort int `json:"pushed_port,omitempty"`
+
+	// History lists the recent sync attempts, newest first
+	History []SyncRecord `json:"history"`
+}
+
+// Status returns the current state of the watcher. It is safe to call while
+// the watcher is running.
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := w.status
+	status.SyncFailures = w.syncFailures
+	status.SourceDownSince = w.vpnDownSince
+	status.SourceHealthy = w.vpnDownSince.IsZero()
+	status.QbitDownSince = w.qbitDownSince
+	status.FirewalledSince = w.firewalledSince
+	status.Firewalled = w.firewallReported
+	status.PushedPort = w.pushedPort
+	status.Sources = w.sourceHealth()
+	status.History = make([]SyncRecord, len(w.history))
+	for i, record := range w.history {
+		status.History[len(w.history)-1-i] = record
+	}
+	return status
+}
+
+// setRunning records whether the sync loop is running
+func (w *Watcher) setRunning(running bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.Running = running
+}
+
+// recordPort stores the port confirmed in qBittorrent. Previous is the port
+// it replaced, or 0 if qBittorrent already used it.
+func (w *Watcher) recordPort(port, previous int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status.CurrentPort = port
+	if previous != 0 {
+		w.status.PreviousPort = previous
+		w.status.LastChange = time.Now()
+	}
+}
+
+// recordSync stores the outcome of a sync attempt of the given port, if one
+// was read, and adds it to the history. OldPort is set if the sync changed
+// the port.
+func (w *Watcher) recordSync(result string, port, oldPort int, err error) {
+	record := SyncRecord{Time: time.Now(), Result: result, Port: port, OldPort: oldPort}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	// the long history may write to a file, so it is not updated under w.mu
+	if w.longHistory != nil {
+		w.longHistory.Add(record)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status.LastSync = record.Time
+	w.status.LastSyncResult = record.Result
+	w.status.LastSyncError = record.Error
+	if result == SyncResultSuccess {
+		w.status.LastSuccess = record.Time
+	}
+	if len(w.history) == maxSyncHistory {
+		w.history = w.history[1:]
+	}
+	w.history = append(w.history, record)
+	w.publish(record)
+}
@@ -1,42 +1,107 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 
-	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/torrent"
 	"github.com/eslutz/forwardarr/internal/webhook"
 )
 
 type Watcher struct {
-	portFile      string
-	qbitClient    *qbit.Client
-	webhookClient *webhook.Client
-	syncInterval  time.Duration
-	lastPort      int
+	portFile     string
+	qbitClient   torrent.Client
+	notifier     *webhook.Dispatcher
+	syncInterval time.Duration
+	lastPort     int
+	// syncFailures counts consecutive failed syncs with qBittorrent since
+	// firstFailure
+	syncFailures int
+	firstFailure time.Time
+	// qbitDownSince and vpnDownSince are set while qBittorrent is unreachable
+	// or the port file provides no valid port
+	qbitDownSince time.Time
+	vpnDownSince  time.Time
 	watcher       *fsnotify.Watcher
+
+	// mu guards notifier, status, subscribers and the writes to the failure
+	// tracking above, which Status reads from other goroutines
+	mu          sync.Mutex
+	status      Status
+	history     []SyncRecord
+	subscribers map[chan SyncRecord]struct{}
+	// longHistory keeps the attempts for History, beyond the recent ones
+	// in Status
+	longHistory *History
+	// pushedPort is the port set by PushPort, which takes precedence over
+	// the port file until the file changes
+	pushedPort int
+	// reannounce selects the torrents announced after a port change
+	reannounce Reannounce
+	// firewalledSince is set while qBittorrent reports firewalled;
+	// firewallReported once qbit_firewalled was sent
+	firewalledSince  time.Time
+	firewallReported bool
+
+	// portFileFormat is the layout of portFile
+	portFileFormat PortFileFormat
+
+	// syncNow requests an immediate sync from Start
+	syncNow chan struct{}
+
+	// source provides the port instead of the port file when set, polled
+	// every pollInterval; sourcePort is the port it provided last
+	source       PortSource
+	pollInterval time.Duration
+	sourcePort   int
+
+	// sourceStates tracks the health of the sources by name, in
+	// sourceOrder, guarded by mu; a source is unhealthy after
+	// unhealthyFailures failed reads or staleAfter without a port
+	sourceStates      map[string]*sourceState
+	sourceOrder       []string
+	sourceName        string
+	unhealthyFailures int
+	staleAfter        time.Duration
 }
 
-func NewWatcher(portFile string, qbitClient *qbit.Client, webhookClient *webhook.Client, syncInterval time.Duration) (*Watcher, error) {
+// NewWatcher creates a watcher that syncs the port in portFile to the torrent
+// client. Without a port file, the port comes from the source set with
+// SetPortSource.
+func NewWatcher(portFile string, qbitClient torrent.Client, notifier *webhook.Dispatcher, syncInterval time.Duration) (*Watcher, error) {
+	if portFile != "" {
+		// Events name the file by the cleaned path of its directory
+		portFile = filepath.Clean(portFile)
+	}
+	w := &Watcher{
+		portFile:     portFile,
+		qbitClient:   qbitClient,
+		notifier:     notifier,
+		syncInterval: syncInterval,
+		syncNow:      make(chan struct{}, 1),
+		longHistory:  &History{size: DefaultHistorySize},
+
+		unhealthyFailures: DefaultUnhealthyFailures,
+		staleAfter:        DefaultStaleAfter,
+	}
+	if portFile == "" {
+		return w, nil
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
-
-	w := &Watcher{
-		portFile:      portFile,
-		qbitClient:    qbitClient,
-		webhookClient: webhookClient,
-		syncInterval:  syncInterval,
-		watcher:       watcher,
-	}
+	w.watcher = watcher
 
 	dir := filepath.Dir(portFile)
 	if err := watcher.Add(dir); err != nil {
@@ -48,7 +113,10 @@ func NewWatcher(portFile string, qbitClient *qbit.Client, webhookClient *webhook
 	return w, nil
 }
 
-func (w *Watcher) Start() error {
+// Start syncs the port whenever the port file or the polled port source
+// changes, and periodically if a sync interval is set, until ctx is done.
+// Notifications still in progress are canceled with ctx.
+func (w *Watcher) Start(ctx context.Context) error {
 	var ticker *time.Ticker
 	var tickerC <-chan time.Time
 	if w.syncInterval > 0 {
@@ -56,60 +124,151 @@ func (w *Watcher) Start() error {
 		defer ticker.Stop()
 		tickerC = ticker.C
 	}
-	defer func() {
-		if err := w.watcher.Close(); err != nil {
-			slog.Warn("failed to close watcher", "error", err)
-		}
-	}()
+	var pollC <-chan time.Time
+	if w.source != nil && w.pollInterval > 0 {
+		poll := time.NewTicker(w.pollInterval)
+		defer poll.Stop()
+		pollC = poll.C
+	}
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	var portFile *portFileEvents
+	if w.watcher != nil {
+		events, watchErrors = w.watcher.Events, w.watcher.Errors
+		portFile = newPortFileEvents(w.watcher, w.portFile)
+		defer portFile.stop()
+		defer func() {
+			if err := w.watcher.Close(); err != nil {
+				slog.Warn("failed to close watcher", "error", err)
+			}
+		}()
+	}
+	w.setRunning(true)
+	defer w.setRunning(false)
 
-	if err := w.syncPort(); err != nil {
+	w.checkQbit(ctx)
+	if err := w.syncPort(ctx); err != nil {
 		slog.Warn("initial sync failed", "error", err)
 	}
+	w.checkFirewall(ctx)
 
 	for {
 		select {
-		case event, ok := <-w.watcher.Events:
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-events:
 			if !ok {
 				return fmt.Errorf("watcher channel closed")
 			}
 
-			if event.Name == w.portFile && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create) {
-				slog.Debug("port file changed", "event", event.Op.String())
-				if err := w.syncPort(); err != nil {
-					slog.Error("failed to sync port after file change", "error", err)
-					IncrementSyncErrors()
-				}
+			portFile.handle(event)
+
+		case <-portFile.settled():
+			w.clearPushedPort()
+			if err := w.syncPort(ctx); err != nil {
+				slog.Error("failed to sync port after file change", "error", err)
+				IncrementSyncErrors()
 			}
 
-		case err, ok := <-w.watcher.Errors:
+		case <-portFile.rewatchTicks():
+			portFile.tryRewatch()
+
+		case err, ok := <-watchErrors:
 			if !ok {
 				return fmt.Errorf("watcher error channel closed")
 			}
 			slog.Error("file watcher error", "error", err)
 
+		case <-pollC:
+			w.pollSource(ctx)
+
 		case <-tickerC:
 			slog.Debug("periodic sync triggered")
-			if err := w.syncPort(); err != nil {
+			w.checkQbit(ctx)
+			if err := w.syncPort(ctx); err != nil {
 				slog.Warn("periodic sync failed", "error", err)
 			}
+			w.checkFirewall(ctx)
+
+		case <-w.syncNow:
+			slog.Info("manual sync triggered")
+			w.checkQbit(ctx)
+			if err := w.syncPort(ctx); err != nil {
+				slog.Warn("manual sync failed", "error", err)
+			}
+			w.checkFirewall(ctx)
 		}
 	}
 }
 
-func (w *Watcher) syncPort() error {
-	gluetunPort, err := w.readPortFromFile()
+// TriggerSync asks the running watcher to sync the port now. Requests made
+// while a sync is already pending are merged with it.
+func (w *Watcher) TriggerSync() {
+	select {
+	case w.syncNow <- struct{}{}:
+	default:
+	}
+}
+
+// SetNotifier replaces the dispatcher of the notifications, e.g. after a
+// config reload. It is safe to call while the watcher is running;
+// notifications already in progress use the previous dispatcher.
+func (w *Watcher) SetNotifier(notifier *webhook.Dispatcher) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.notifier = notifier
+}
+
+func (w *Watcher) currentNotifier() *webhook.Dispatcher {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.notifier
+}
+
+func (w *Watcher) syncPort(ctx context.Context) error {
+	gluetunPort, err := w.readPort()
+	if !w.hasPushedPort() {
+		w.checkSourceHealth(ctx, gluetunPort, err)
+	}
+	return w.applyPort(ctx, gluetunPort, err)
+}
+
+// applyPort syncs qBittorrent to gluetunPort, read from the port file,
+// source or push with err. A port of 0 skips the sync.
+func (w *Watcher) applyPort(ctx context.Context, gluetunPort int, err error) error {
 	if err != nil {
-		return fmt.Errorf("failed to read Gluetun port: %w", err)
+		w.markVPNDown(ctx, err)
+		err = fmt.Errorf("failed to read Gluetun port: %w", err)
+		w.recordSync(SyncResultFailed, 0, 0, err)
+		return err
 	}
 
 	// If port is 0, it means we should skip this sync (invalid/empty port file)
 	if gluetunPort == 0 {
+		reason := errors.New("port file is empty or contains no valid port")
+		if w.source != nil {
+			reason = errors.New("port source reports no forwarded port")
+		}
+		w.markVPNDown(ctx, reason)
+		w.recordSync(SyncResultSkipped, 0, 0, reason)
+		return nil
+	}
+	w.markVPNUp(ctx, gluetunPort)
+	w.checkFailover(ctx, gluetunPort)
+
+	if w.isPaused() {
+		slog.Debug("port sync paused, leaving qBittorrent unchanged", "port", gluetunPort)
+		w.recordSync(SyncResultPaused, gluetunPort, 0, nil)
 		return nil
 	}
 
 	qbitPort, err := w.qbitClient.GetPort()
 	if err != nil {
-		return fmt.Errorf("failed to get qBittorrent port: %w", err)
+		err = fmt.Errorf("failed to get qBittorrent port: %w", err)
+		w.notifySyncError(ctx, err)
+		w.recordSync(SyncResultFailed, gluetunPort, 0, err)
+		return err
 	}
 
 	slog.Debug("port status", "gluetun_port", gluetunPort, "qbit_port", qbitPort)
@@ -118,27 +277,55 @@ func (w *Watcher) syncPort() error {
 		slog.Info("port mismatch detected, updating...", "old_port", qbitPort, "new_port", gluetunPort)
 		if err := w.qbitClient.SetPort(gluetunPort); err != nil {
 			IncrementSyncErrors()
-			return fmt.Errorf("failed to set qBittorrent port: %w", err)
+			err = fmt.Errorf("failed to set qBittorrent port: %w", err)
+			w.notifySyncError(ctx, err)
+			w.recordSync(SyncResultFailed, gluetunPort, 0, err)
+			return err
 		}
 
 		w.lastPort = gluetunPort
+		w.recordPort(gluetunPort, qbitPort)
+		w.recordSync(SyncResultSuccess, gluetunPort, qbitPort, nil)
+		w.notifySyncRecovered(ctx, gluetunPort)
+		w.reannounceTorrents(gluetunPort)
+		w.resetFirewall()
 		SetCurrentPort(gluetunPort)
 		IncrementSyncTotal()
 		UpdateLastSyncTimestamp()
 
-		// Send webhook notification if webhook client is configured
-		if w.webhookClient != nil {
-			if err := w.webhookClient.SendPortChange(qbitPort, gluetunPort); err != nil {
+		// Send webhook notifications if any webhook targets are configured
+		if notifier := w.currentNotifier(); notifier != nil {
+			if err := notifier.SendPortChange(ctx, qbitPort, gluetunPort); err != nil {
 				slog.Warn("failed to send webhook notification", "error", err)
 			}
 		}
 	} else {
+		w.notifySyncRecovered(ctx, gluetunPort)
+		w.recordPort(gluetunPort, 0)
+		w.recordSync(SyncResultSuccess, gluetunPort, 0, nil)
 		slog.Debug("ports are in sync", "port", gluetunPort)
 	}
 
 	return nil
 }
 
+// notifySyncError records a failed sync and sends a sync_error notification
+func (w *Watcher) notifySyncError(ctx context.Context, err error) {
+	w.mu.Lock()
+	if w.syncFailures == 0 {
+		w.firstFailure = time.Now()
+	}
+	w.syncFailures++
+	w.mu.Unlock()
+	notifier := w.currentNotifier()
+	if notifier == nil {
+		return
+	}
+	if sendErr := notifier.SendSyncError(ctx, "qbittorrent", err, w.syncFailures); sendErr != nil {
+		slog.Warn("failed to send webhook notification", "error", sendErr)
+	}
+}
+
 func (w *Watcher) readPortFromFile() (int, error) {
 	content, err := os.ReadFile(w.portFile)
 	if err != nil {
@@ -153,16 +340,110 @@ func (w *Watcher) readPortFromFile() (int, error) {
 		return 0, nil
 	}
 
-	port, err := strconv.Atoi(portStr)
+	port, err := parsePortFile(portStr, w.portFileFormat)
 	if err != nil {
-		slog.Warn("invalid port value in file, skipping sync", "value", portStr, "error", err)
+		slog.Warn("invalid port value in file, skipping sync", "value", portStr, "format", w.portFileFormat, "error", err)
 		return 0, nil
 	}
+	if port == 0 {
+		slog.Warn("port file holds no port, skipping sync", "value", portStr)
+	}
+	return port, nil
+}
+
+// notifySyncRecovered sends a sync_recovered notification if the previous
+// syncs failed and resets the failure tracking
+func (w *Watcher) notifySyncRecovered(ctx context.Context, port int) {
+	if w.syncFailures == 0 {
+		return
+	}
+	failures, downtime := w.syncFailures, time.Since(w.firstFailure)
+	w.mu.Lock()
+	w.syncFailures = 0
+	w.mu.Unlock()
 
-	if port < 1 || port > 65535 {
-		slog.Warn("port out of valid range, skipping sync", "port", port)
-		return 0, nil
+	slog.Info("port sync recovered", "port", port, "failed_attempts", failures, "downtime", downtime)
+	notifier := w.currentNotifier()
+	if notifier == nil {
+		return
+	}
+	if err := notifier.SendSyncRecovered(ctx, port, failures, downtime); err != nil {
+		slog.Warn("failed to send webhook notification", "error", err)
 	}
+}
 
-	return port, nil
+// checkQbit pings qBittorrent and sends qbit_unreachable and qbit_recovered
+// notifications when its reachability changes
+func (w *Watcher) checkQbit(ctx context.Context) {
+	// qBittorrent may be down on purpose while the sync is paused
+	if w.isPaused() {
+		return
+	}
+	err := w.qbitClient.Ping()
+	if err != nil {
+		if !w.qbitDownSince.IsZero() {
+			return
+		}
+		w.mu.Lock()
+		w.qbitDownSince = time.Now()
+		w.mu.Unlock()
+		slog.Warn("qBittorrent is unreachable", "error", err)
+		if notifier := w.currentNotifier(); notifier != nil {
+			if sendErr := notifier.SendQbitUnreachable(ctx, err); sendErr != nil {
+				slog.Warn("failed to send webhook notification", "error", sendErr)
+			}
+		}
+		return
+	}
+
+	if w.qbitDownSince.IsZero() {
+		return
+	}
+	downtime := time.Since(w.qbitDownSince)
+	w.mu.Lock()
+	w.qbitDownSince = time.Time{}
+	w.mu.Unlock()
+	slog.Info("qBittorrent is reachable again", "downtime", downtime)
+	if notifier := w.currentNotifier(); notifier != nil {
+		if err := notifier.SendQbitRecovered(ctx, downtime); err != nil {
+			slog.Warn("failed to send webhook notification", "error", err)
+		}
+	}
+}
+
+// markVPNDown sends a vpn_down notification when the port file stops
+// providing a valid port
+func (w *Watcher) markVPNDown(ctx context.Context, reason error) {
+	if !w.vpnDownSince.IsZero() {
+		return
+	}
+	w.mu.Lock()
+	w.vpnDownSince = time.Now()
+	w.status.SourceError = reason.Error()
+	w.mu.Unlock()
+	slog.Warn("forwarded port is unavailable", "reason", reason)
+	if notifier := w.currentNotifier(); notifier != nil {
+		if err := notifier.SendVPNDown(ctx, w.portSourceName(), reason); err != nil {
+			slog.Warn("failed to send webhook notification", "error", err)
+		}
+	}
+}
+
+// markVPNUp sends a vpn_recovered notification when the port file provides a
+// valid port again after an outage
+func (w *Watcher) markVPNUp(ctx context.Context, port int) {
+	if w.vpnDownSince.IsZero() {
+		return
+	}
+	downtime := time.Since(w.vpnDownSince)
+	w.mu.Lock()
+	w.vpnDownSince = time.Time{}
+	w.status.SourceError = ""
+	w.mu.Unlock()
+	slog.Info("forwarded port is available again", "port", port, "downtime", downtime)
+	if notifier := w.currentNotifier(); notifier != nil {
+		if err := notifier.SendVPNRecovered(ctx, w.portSourceName(), port, downtime); err != nil {
+			slog.Warn("failed to send webhook notification", "error", err)
+		}
+	}
 }
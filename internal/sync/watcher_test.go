@@ -1,7 +1,9 @@
 package sync
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/eslutz/forwardarr/internal/qbit"
+	"github.com/eslutz/forwardarr/internal/torrent"
 	"github.com/eslutz/forwardarr/internal/webhook"
 )
 
@@ -48,6 +51,8 @@ func newTestQbitServer(t *testing.T, initialPort int, getStatus, setStatus int)
 		case "/api/v2/auth/login":
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/app/version":
+			_, _ = w.Write([]byte("v4.6.0"))
 		case "/api/v2/app/preferences":
 			*getPortCalls++
 			status := http.StatusOK
@@ -115,8 +120,8 @@ func TestWatcherSyncPortUpdatesPort(t *testing.T) {
 		t.Fatalf("NewClient() error = %v", err)
 	}
 
-	watcher := &Watcher{portFile: portFile, qbitClient: client, webhookClient: nil}
-	if err := watcher.syncPort(); err != nil {
+	watcher := &Watcher{portFile: portFile, qbitClient: client}
+	if err := watcher.syncPort(t.Context()); err != nil {
 		t.Fatalf("syncPort() error = %v", err)
 	}
 
@@ -146,8 +151,8 @@ func TestWatcherSyncPortAlreadyInSync(t *testing.T) {
 		t.Fatalf("NewClient() error = %v", err)
 	}
 
-	watcher := &Watcher{portFile: portFile, qbitClient: client, webhookClient: nil}
-	if err := watcher.syncPort(); err != nil {
+	watcher := &Watcher{portFile: portFile, qbitClient: client}
+	if err := watcher.syncPort(t.Context()); err != nil {
 		t.Fatalf("syncPort() error = %v", err)
 	}
 
@@ -173,9 +178,10 @@ func TestWatcherSyncPortGetPortError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewClient() error = %v", err)
 	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
 
-	watcher := &Watcher{portFile: portFile, qbitClient: client, webhookClient: nil}
-	if err := watcher.syncPort(); err == nil {
+	watcher := &Watcher{portFile: portFile, qbitClient: client}
+	if err := watcher.syncPort(t.Context()); err == nil {
 		t.Fatal("syncPort() error = nil, want error")
 	}
 	if *setPortCalls != 0 {
@@ -197,9 +203,10 @@ func TestWatcherSyncPortSetPortError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewClient() error = %v", err)
 	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
 
-	watcher := &Watcher{portFile: portFile, qbitClient: client, webhookClient: nil}
-	if err := watcher.syncPort(); err == nil {
+	watcher := &Watcher{portFile: portFile, qbitClient: client}
+	if err := watcher.syncPort(t.Context()); err == nil {
 		t.Fatal("syncPort() error = nil, want error")
 	}
 
@@ -211,6 +218,39 @@ func TestWatcherSyncPortSetPortError(t *testing.T) {
 	}
 }
 
+func TestWatcherSyncPortNotApplied(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("6000"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	// qBittorrent accepts the new port but keeps the old one
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			_, _ = w.Write([]byte("Ok."))
+		case "/api/v2/app/preferences":
+			_ = json.NewEncoder(w).Encode(qbit.Preferences{ListenPort: 4000})
+		}
+	}))
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	watcher := &Watcher{portFile: portFile, qbitClient: client}
+	if err := watcher.syncPort(t.Context()); !errors.Is(err, qbit.ErrPortNotApplied) {
+		t.Fatalf("syncPort() error = %v, want ErrPortNotApplied", err)
+	}
+	if status := watcher.Status(); status.LastSyncResult != SyncResultFailed || status.CurrentPort != 0 {
+		t.Errorf("status = %s with port %d, want failed without a port", status.LastSyncResult, status.CurrentPort)
+	}
+}
+
 func TestReadPortFromFile_WithWhitespace(t *testing.T) {
 	tmpDir := t.TempDir()
 	portFile := filepath.Join(tmpDir, "forwarded_port")
@@ -380,11 +420,20 @@ func TestWatcherSyncPortWithWebhook(t *testing.T) {
 	}))
 	defer webhookServer.Close()
 
-	// Create webhook client
-	webhookClient := webhook.NewClient(webhookServer.URL, 5*time.Second, webhook.TemplateJSON, []string{"port_changed"})
+	// Create webhook dispatcher
+	webhookClient, err := webhook.NewClient(webhook.Target{
+		URL:      webhookServer.URL,
+		Timeout:  5 * time.Second,
+		Template: webhook.TemplateJSON,
+		Events:   []string{"port_changed"},
+	})
+	if err != nil {
+		t.Fatalf("webhook.NewClient() error = %v", err)
+	}
+	notifier := webhook.NewDispatcher(webhookClient)
 
-	watcher := &Watcher{portFile: portFile, qbitClient: qbitClient, webhookClient: webhookClient}
-	if err := watcher.syncPort(); err != nil {
+	watcher := &Watcher{portFile: portFile, qbitClient: qbitClient, notifier: notifier}
+	if err := watcher.syncPort(t.Context()); err != nil {
 		t.Fatalf("syncPort() error = %v", err)
 	}
 
@@ -433,8 +482,8 @@ func TestWatcherSyncPortSkipsInvalidPort(t *testing.T) {
 				t.Fatalf("NewClient() error = %v", err)
 			}
 
-			watcher := &Watcher{portFile: portFile, qbitClient: client, webhookClient: nil}
-			if err := watcher.syncPort(); err != nil {
+			watcher := &Watcher{portFile: portFile, qbitClient: client}
+			if err := watcher.syncPort(t.Context()); err != nil {
 				t.Fatalf("syncPort() error = %v, want nil (graceful handling)", err)
 			}
 
@@ -453,3 +502,383 @@ func TestWatcherSyncPortSkipsInvalidPort(t *testing.T) {
 		})
 	}
 }
+
+func TestWatcherSyncPortSendsSyncError(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("6000"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	qbitServer, _, _, _ := newTestQbitServer(t, 4000, 0, http.StatusInternalServerError)
+	defer qbitServer.Close()
+
+	qbitClient, err := qbit.NewClient(qbitServer.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	qbitClient.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	var attempts []int
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Event     string `json:"event"`
+			Component string `json:"component"`
+			Attempt   int    `json:"attempt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		if payload.Event != "sync_error" || payload.Component != "qbittorrent" {
+			t.Errorf("webhook payload = %+v, want qbittorrent sync_error", payload)
+		}
+		attempts = append(attempts, payload.Attempt)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer webhookServer.Close()
+
+	webhookClient, err := webhook.NewClient(webhook.Target{
+		URL:     webhookServer.URL,
+		Timeout: 5 * time.Second,
+		Events:  []string{"sync_error"},
+	})
+	if err != nil {
+		t.Fatalf("webhook.NewClient() error = %v", err)
+	}
+
+	watcher := &Watcher{portFile: portFile, qbitClient: qbitClient, notifier: webhook.NewDispatcher(webhookClient)}
+	for range 2 {
+		if err := watcher.syncPort(t.Context()); err == nil {
+			t.Fatal("syncPort() error = nil, want error")
+		}
+	}
+
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("sync_error attempts = %v, want [1 2]", attempts)
+	}
+}
+
+func TestWatcherSyncPortSendsSyncRecovered(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("6000"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	qbitServer, _, _, _ := newTestQbitServer(t, 6000, 0, 0)
+	defer qbitServer.Close()
+
+	qbitClient, err := qbit.NewClient(qbitServer.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	var events []string
+	var attempt int
+	var downtime float64
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Event           string  `json:"event"`
+			Attempt         int     `json:"attempt"`
+			DowntimeSeconds float64 `json:"downtime_seconds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		events = append(events, payload.Event)
+		attempt = payload.Attempt
+		downtime = payload.DowntimeSeconds
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer webhookServer.Close()
+
+	webhookClient, err := webhook.NewClient(webhook.Target{
+		URL:     webhookServer.URL,
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("webhook.NewClient() error = %v", err)
+	}
+
+	watcher := &Watcher{
+		portFile:     portFile,
+		qbitClient:   qbitClient,
+		notifier:     webhook.NewDispatcher(webhookClient),
+		syncFailures: 2,
+		firstFailure: time.Now().Add(-time.Minute),
+	}
+	// The second sync must not report the recovery again
+	for range 2 {
+		if err := watcher.syncPort(t.Context()); err != nil {
+			t.Fatalf("syncPort() error = %v", err)
+		}
+	}
+
+	if len(events) != 1 || events[0] != "sync_recovered" {
+		t.Fatalf("webhook events = %v, want [sync_recovered]", events)
+	}
+	if attempt != 2 {
+		t.Errorf("attempt = %d, want 2", attempt)
+	}
+	if downtime < 60 {
+		t.Errorf("downtime_seconds = %v, want >= 60", downtime)
+	}
+	if watcher.syncFailures != 0 {
+		t.Errorf("syncFailures = %d, want 0", watcher.syncFailures)
+	}
+}
+
+// newEventRecorder returns a dispatcher whose single target records the
+// events it receives
+func newEventRecorder(t *testing.T) (*webhook.Dispatcher, *[]string) {
+	t.Helper()
+
+	events := new([]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Event string `json:"event"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		*events = append(*events, payload.Event)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := webhook.NewClient(webhook.Target{URL: server.URL, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("webhook.NewClient() error = %v", err)
+	}
+	return webhook.NewDispatcher(client), events
+}
+
+func TestWatcherSyncPortSendsVPNDownAndRecovered(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte(""), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	qbitServer, _, _, _ := newTestQbitServer(t, 6000, 0, 0)
+	defer qbitServer.Close()
+
+	qbitClient, err := qbit.NewClient(qbitServer.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{portFile: portFile, qbitClient: qbitClient, notifier: notifier}
+
+	// Repeated failures only report the outage once
+	for range 2 {
+		if err := watcher.syncPort(t.Context()); err != nil {
+			t.Fatalf("syncPort() error = %v", err)
+		}
+	}
+	if err := os.WriteFile(portFile, []byte("6000"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+
+	if len(*events) != 2 || (*events)[0] != "vpn_down" || (*events)[1] != "vpn_recovered" {
+		t.Errorf("webhook events = %v, want [vpn_down vpn_recovered]", *events)
+	}
+}
+
+func TestWatcherCheckQbitSendsUnreachableAndRecovered(t *testing.T) {
+	qbitServer, _, _, _ := newTestQbitServer(t, 6000, 0, 0)
+	defer qbitServer.Close()
+
+	qbitClient, err := qbit.NewClient(qbitServer.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	qbitClient.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	notifier, events := newEventRecorder(t)
+	watcher := &Watcher{qbitClient: qbitClient, notifier: notifier}
+
+	// Healthy qBittorrent sends nothing
+	watcher.checkQbit(t.Context())
+	if len(*events) != 0 {
+		t.Fatalf("webhook events = %v, want none while reachable", *events)
+	}
+
+	qbitServer.Close()
+	watcher.checkQbit(t.Context())
+	watcher.checkQbit(t.Context())
+	if len(*events) != 1 || (*events)[0] != "qbit_unreachable" {
+		t.Fatalf("webhook events = %v, want [qbit_unreachable]", *events)
+	}
+
+	recovered, _, _, _ := newTestQbitServer(t, 6000, 0, 0)
+	defer recovered.Close()
+	watcher.qbitClient, err = qbit.NewClient(recovered.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	watcher.checkQbit(t.Context())
+
+	if len(*events) != 2 || (*events)[1] != "qbit_recovered" {
+		t.Errorf("webhook events = %v, want [qbit_unreachable qbit_recovered]", *events)
+	}
+	if !watcher.qbitDownSince.IsZero() {
+		t.Error("qbitDownSince not reset after recovery")
+	}
+}
+
+func TestWatcherStartStopsWithContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("9090"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	server, _, _, _ := newTestQbitServer(t, 9090, 0, 0)
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	watcher, err := NewWatcher(portFile, client, nil, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Start(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start() error = %v, want nil after cancellation", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start() did not return after its context was canceled")
+	}
+}
+
+func TestWatcherStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("9090"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	server, _, _, _ := newTestQbitServer(t, 8080, 0, 0)
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	watcher := &Watcher{portFile: portFile, qbitClient: client}
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+
+	status := watcher.Status()
+	if status.CurrentPort != 9090 || status.PreviousPort != 8080 || status.LastChange.IsZero() {
+		t.Errorf("ports = %d (was %d) changed at %v, want 9090 (was 8080) with a change time", status.CurrentPort, status.PreviousPort, status.LastChange)
+	}
+	if status.LastSyncResult != SyncResultSuccess || !status.SourceHealthy {
+		t.Errorf("last sync = %q, source healthy = %v, want success and healthy", status.LastSyncResult, status.SourceHealthy)
+	}
+
+	if err := os.WriteFile(portFile, []byte(""), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+	if err := watcher.syncPort(t.Context()); err != nil {
+		t.Fatalf("syncPort() error = %v", err)
+	}
+
+	status = watcher.Status()
+	if status.LastSyncResult != SyncResultSkipped || status.SourceHealthy || status.SourceError == "" {
+		t.Errorf("last sync = %q, source healthy = %v, error = %q, want skipped and unhealthy", status.LastSyncResult, status.SourceHealthy, status.SourceError)
+	}
+	if status.CurrentPort != 9090 {
+		t.Errorf("CurrentPort = %d, want the last confirmed port 9090", status.CurrentPort)
+	}
+
+	if len(status.History) != 2 {
+		t.Fatalf("history has %d entries, want 2", len(status.History))
+	}
+	if got := status.History[0]; got.Result != SyncResultSkipped || got.Error == "" {
+		t.Errorf("latest history entry = %+v, want the skipped sync", got)
+	}
+	if got := status.History[1]; got.Result != SyncResultSuccess || got.Port != 9090 || got.OldPort != 8080 {
+		t.Errorf("first history entry = %+v, want the change from 8080 to 9090", got)
+	}
+}
+
+func TestWatcherStatus_HistoryLimit(t *testing.T) {
+	watcher := &Watcher{}
+	for port := range maxSyncHistory + 5 {
+		watcher.recordSync(SyncResultSuccess, port, 0, nil)
+	}
+
+	history := watcher.Status().History
+	if len(history) != maxSyncHistory {
+		t.Fatalf("history has %d entries, want %d", len(history), maxSyncHistory)
+	}
+	if history[0].Port != maxSyncHistory+4 || history[len(history)-1].Port != 5 {
+		t.Errorf("history covers ports %d to %d, want %d to 5", history[0].Port, history[len(history)-1].Port, maxSyncHistory+4)
+	}
+}
+
+func TestWatcherTriggerSync(t *testing.T) {
+	tmpDir := t.TempDir()
+	portFile := filepath.Join(tmpDir, "forwarded_port")
+	if err := os.WriteFile(portFile, []byte("9090"), 0644); err != nil {
+		t.Fatalf("failed to write port file: %v", err)
+	}
+
+	server, _, _, _ := newTestQbitServer(t, 9090, 0, 0)
+	defer server.Close()
+
+	client, err := qbit.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	watcher, err := NewWatcher(portFile, client, nil, 0)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Start(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForHistory := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(watcher.Status().History) < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("history has %d entries, want %d", len(watcher.Status().History), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The initial sync runs before the watcher waits for triggers
+	waitForHistory(1)
+	watcher.TriggerSync()
+	waitForHistory(2)
+
+	if status := watcher.Status(); !status.Running || status.LastSuccess.IsZero() {
+		t.Errorf("status running = %v, last success = %v, want running with a success", status.Running, status.LastSuccess)
+	}
+}
@@ -0,0 +1,156 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Member is a torrent client of a Group, with the name that identifies it in
+// errors
+type Member struct {
+	Name   string
+	Client Client
+}
+
+// Group is a Client that forwards every call to several torrent clients,
+// e.g. qBittorrent and Transmission, so that all of them listen on the
+// forwarded port. A call fails if it fails for any member; the error names
+// each member that failed. GetPort is the exception, so that an unreachable
+// member does not keep the others from getting the port.
+type Group struct {
+	members []Member
+}
+
+// NewGroup returns a group of the members
+func NewGroup(members ...Member) *Group {
+	return &Group{members: members}
+}
+
+// GetPort returns the listening port the members agree on. If they disagree,
+// or some of them fail, it returns 0, which never matches a forwarded port,
+// so the port is set on all of them again and SetPort reports the members
+// that still fail. It only fails if every member does.
+func (g *Group) GetPort() (int, error) {
+	ports := make([]int, len(g.members))
+	errs := make([]error, len(g.members))
+	g.each(func(i int, client Client) error {
+		ports[i], errs[i] = client.GetPort()
+		return nil
+	})
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		errs[i] = fmt.Errorf("%s: %w", g.members[i].Name, err)
+		slog.Warn("failed to get the listening port of a torrent client", "client", g.members[i].Name, "error", err)
+	}
+	if failed == len(g.members) {
+		return 0, errors.Join(errs...)
+	}
+	if failed > 0 {
+		return 0, nil
+	}
+	for _, port := range ports[1:] {
+		if port != ports[0] {
+			return 0, nil
+		}
+	}
+	return ports[0], nil
+}
+
+// SetPort sets the listening port of all members concurrently
+func (g *Group) SetPort(port int) error {
+	return g.each(func(_ int, client Client) error {
+		return client.SetPort(port)
+	})
+}
+
+// Ping checks that all members are reachable
+func (g *Group) Ping() error {
+	return g.each(func(_ int, client Client) error {
+		return client.Ping()
+	})
+}
+
+// PingOnce checks that all members are reachable with a single attempt each
+func (g *Group) PingOnce() error {
+	return g.each(func(_ int, client Client) error {
+		return client.PingOnce()
+	})
+}
+
+// Reannounce announces the torrents of the members that can reannounce; the
+// others are skipped
+func (g *Group) Reannounce(activeOnly bool) error {
+	return g.each(func(_ int, client Client) error {
+		if reannouncer, ok := client.(Reannouncer); ok {
+			return reannouncer.Reannounce(activeOnly)
+		}
+		return nil
+	})
+}
+
+// ConnectionStatus returns the worst status of the members that report one:
+// disconnected before firewalled before connected. Without such a member it
+// returns errors.ErrUnsupported.
+func (g *Group) ConnectionStatus() (string, error) {
+	statuses := make([]string, len(g.members))
+	err := g.each(func(i int, client Client) error {
+		checker, ok := client.(ConnectionChecker)
+		if !ok {
+			return nil
+		}
+		status, err := checker.ConnectionStatus()
+		statuses[i] = status
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	result := ""
+	for _, status := range statuses {
+		if status != "" && (result == "" || connectionSeverity(status) > connectionSeverity(result)) {
+			result = status
+		}
+	}
+	if result == "" {
+		return "", errors.ErrUnsupported
+	}
+	return result, nil
+}
+
+// connectionSeverity orders the connection statuses from connected to
+// disconnected
+func connectionSeverity(status string) int {
+	switch status {
+	case ConnectionDisconnected:
+		return 2
+	case ConnectionFirewalled:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// each calls fn for every member concurrently and joins the errors, prefixed
+// by the names of the members
+func (g *Group) each(fn func(i int, client Client) error) error {
+	errs := make([]error, len(g.members))
+	var wg sync.WaitGroup
+	for i, member := range g.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(i, member.Client); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", member.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
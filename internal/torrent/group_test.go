@@ -0,0 +1,138 @@
+package torrent
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeClient is a torrent client that keeps its port in memory
+type fakeClient struct {
+	mu          sync.Mutex
+	port        int
+	err         error
+	reannounced int
+}
+
+func (c *fakeClient) GetPort() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.port, c.err
+}
+
+func (c *fakeClient) SetPort(port int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.port = port
+	return nil
+}
+
+func (c *fakeClient) Ping() error     { return c.err }
+func (c *fakeClient) PingOnce() error { return c.err }
+
+// reannouncingClient can also reannounce and report its connection status
+type reannouncingClient struct {
+	fakeClient
+	status string
+}
+
+func (c *reannouncingClient) Reannounce(bool) error {
+	c.reannounced++
+	return nil
+}
+
+func (c *reannouncingClient) ConnectionStatus() (string, error) {
+	return c.status, nil
+}
+
+func TestGroupPort(t *testing.T) {
+	first := &fakeClient{port: 6000}
+	second := &reannouncingClient{fakeClient: fakeClient{port: 6000}}
+	group := NewGroup(Member{Name: "first", Client: first}, Member{Name: "second", Client: second})
+
+	if port, err := group.GetPort(); err != nil || port != 6000 {
+		t.Errorf("GetPort() = %d, %v, want 6000", port, err)
+	}
+
+	second.port = 7000
+	if port, err := group.GetPort(); err != nil || port != 0 {
+		t.Errorf("GetPort() with differing ports = %d, %v, want 0", port, err)
+	}
+
+	if err := group.SetPort(8000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if first.port != 8000 || second.port != 8000 {
+		t.Errorf("ports = %d and %d, want 8000", first.port, second.port)
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	group := NewGroup(
+		Member{Name: "qBittorrent", Client: &fakeClient{}},
+		Member{Name: "Transmission", Client: &fakeClient{err: errors.New("connection refused")}},
+	)
+
+	err := group.SetPort(6000)
+	if err == nil || !strings.Contains(err.Error(), "Transmission: connection refused") {
+		t.Errorf("SetPort() error = %v, want the failed member named", err)
+	}
+	if err := group.PingOnce(); err == nil {
+		t.Error("PingOnce() error = nil, want the failed member")
+	}
+}
+
+func TestGroupPortWithFailingMember(t *testing.T) {
+	healthy := &fakeClient{port: 6000}
+	failing := &fakeClient{port: 6000, err: errors.New("connection refused")}
+	group := NewGroup(Member{Name: "qBittorrent", Client: healthy}, Member{Name: "Transmission", Client: failing})
+
+	port, err := group.GetPort()
+	if err != nil || port != 0 {
+		t.Fatalf("GetPort() = %d, %v, want 0 so that the port is set again", port, err)
+	}
+	err = group.SetPort(7000)
+	if err == nil || !strings.Contains(err.Error(), "Transmission: connection refused") {
+		t.Errorf("SetPort() error = %v, want the failed member named", err)
+	}
+	if healthy.port != 7000 {
+		t.Errorf("healthy member port = %d, want 7000", healthy.port)
+	}
+
+	healthy.err = errors.New("timeout")
+	if _, err := group.GetPort(); err == nil || !strings.Contains(err.Error(), "qBittorrent: timeout") {
+		t.Errorf("GetPort() error = %v, want the errors of all members", err)
+	}
+}
+
+func TestGroupOptionalCapabilities(t *testing.T) {
+	plain := &fakeClient{}
+	group := NewGroup(Member{Name: "plain", Client: plain})
+	if err := group.Reannounce(true); err != nil {
+		t.Errorf("Reannounce() error = %v, want members without support skipped", err)
+	}
+	if _, err := group.ConnectionStatus(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("ConnectionStatus() error = %v, want ErrUnsupported", err)
+	}
+
+	connected := &reannouncingClient{status: ConnectionConnected}
+	firewalled := &reannouncingClient{status: ConnectionFirewalled}
+	group = NewGroup(
+		Member{Name: "plain", Client: plain},
+		Member{Name: "connected", Client: connected},
+		Member{Name: "firewalled", Client: firewalled},
+	)
+	if err := group.Reannounce(false); err != nil {
+		t.Fatalf("Reannounce() error = %v", err)
+	}
+	if connected.reannounced != 1 || firewalled.reannounced != 1 {
+		t.Errorf("reannounced = %d and %d, want 1", connected.reannounced, firewalled.reannounced)
+	}
+	if status, err := group.ConnectionStatus(); err != nil || status != ConnectionFirewalled {
+		t.Errorf("ConnectionStatus() = %q, %v, want %q", status, err, ConnectionFirewalled)
+	}
+}
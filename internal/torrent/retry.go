@@ -0,0 +1,102 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RetryPolicy controls how failed requests to the torrent client are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy retries failed requests for about half a minute, long
+// enough for the torrent client to restart
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}
+
+// attempts returns the total number of attempts, never less than one
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the delay to wait after the given failed attempt. The delay
+// doubles with every attempt and is capped at MaxDelay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if attempt < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		if delay > time.Duration(1<<62) {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Run calls request until it succeeds, fails permanently or the attempts are
+// exhausted, waiting with backoff between attempts. The returned error wraps
+// the last failure.
+func (p RetryPolicy) Run(operation string, request func() error) error {
+	attempts := p.attempts()
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		lastErr = request()
+		if lastErr == nil {
+			return nil
+		}
+		if IsPermanent(lastErr) {
+			return lastErr
+		}
+
+		if attempt < attempts {
+			delay := p.backoff(attempt)
+			slog.Warn(operation+" failed, retrying",
+				"attempt", attempt,
+				"max_attempts", attempts,
+				"retry_delay", delay,
+				"error", lastErr,
+			)
+			time.Sleep(delay)
+		}
+	}
+	if attempts == 1 {
+		return lastErr
+	}
+	return fmt.Errorf("%s failed after %d attempts: %w", operation, attempts, lastErr)
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that Run does not retry, e.g. rejected
+// credentials
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked by Permanent
+func IsPermanent(err error) bool {
+	var permanentErr *permanentError
+	return errors.As(err, &permanentErr)
+}
@@ -0,0 +1,56 @@
+package torrent
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		if got := policy.backoff(i + 1); got != delay {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, delay)
+		}
+	}
+	if got := (RetryPolicy{}).backoff(1); got != 0 {
+		t.Errorf("backoff() without base delay = %v, want 0", got)
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", policy: RetryPolicy{MaxAttempts: 3}, wantCalls: 1},
+		{name: "recovers", policy: RetryPolicy{MaxAttempts: 3}, failures: 2, err: errors.New("refused"), wantCalls: 3},
+		{name: "exhausted", policy: RetryPolicy{MaxAttempts: 3}, failures: 5, err: errors.New("refused"), wantCalls: 3, wantErr: true},
+		{name: "permanent", policy: RetryPolicy{MaxAttempts: 3}, failures: 5, err: Permanent(errors.New("rejected")), wantCalls: 1, wantErr: true},
+		{name: "no retries", failures: 5, err: errors.New("refused"), wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.policy.Run("test", func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Run() calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
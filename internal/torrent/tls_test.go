@@ -0,0 +1,117 @@
+package torrent
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePEM writes a single PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestNewHTTPClient_TLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Ok."))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", cert.Certificate[0])
+	keyFile := writePEM(t, dir, "client.key", "PRIVATE KEY", key)
+
+	tests := []struct {
+		name     string
+		opts     TLSOptions
+		wantErr  bool
+		wantCert bool
+	}{
+		{name: "untrusted certificate", wantErr: true},
+		{name: "custom CA bundle", opts: TLSOptions{CAFile: caFile}},
+		{name: "insecure skip verify", opts: TLSOptions{InsecureSkipVerify: true}},
+		{name: "client certificate", opts: TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, wantCert: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCert bool
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotCert = len(r.TLS.PeerCertificates) > 0
+				_, _ = w.Write([]byte("Ok."))
+			})
+
+			tlsConfig, err := tt.opts.Config()
+			if err != nil {
+				t.Fatalf("Config() error = %v", err)
+			}
+			resp, err := NewHTTPClient(TransportOptions{TLS: tlsConfig}).Get(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+			if !tt.wantErr && gotCert != tt.wantCert {
+				t.Errorf("client certificate sent = %v, want %v", gotCert, tt.wantCert)
+			}
+		})
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", cert.Certificate[0])
+	keyFile := writePEM(t, dir, "client.key", "PRIVATE KEY", key)
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no options", opts: TLSOptions{}, wantNil: true},
+		{name: "client certificate", opts: TLSOptions{CertFile: certFile, KeyFile: keyFile}},
+		{name: "certificate without key", opts: TLSOptions{CertFile: certFile}, wantErr: true},
+		{name: "missing CA bundle", opts: TLSOptions{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "CA bundle without certificates", opts: TLSOptions{CAFile: keyFile}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.opts.Config()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (cfg == nil) != tt.wantNil {
+				t.Errorf("Config() = %v, want nil %v", cfg, tt.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Package torrent holds what the clients of the supported torrent clients
+// share: the interface the watcher and the server use, and the retry and
+// transport settings of their requests.
+package torrent
+
+// Client is a torrent client whose listening port is kept in sync with the
+// forwarded port
+type Client interface {
+	// GetPort returns the current listening port
+	GetPort() (int, error)
+	// SetPort sets the listening port and verifies that it was applied
+	SetPort(port int) error
+	// Ping checks that the torrent client is reachable, retrying failed
+	// attempts
+	Ping() error
+	// PingOnce checks that the torrent client is reachable with a single
+	// attempt
+	PingOnce() error
+}
+
+// Reannouncer is implemented by clients that can announce torrents to their
+// trackers on demand. With activeOnly, only the active torrents are
+// announced; otherwise all of them.
+type Reannouncer interface {
+	Reannounce(activeOnly bool) error
+}
+
+// ConnectionChecker is implemented by clients that report whether peers can
+// reach the listening port. ConnectionStatus returns one of the Connection
+// constants.
+type ConnectionChecker interface {
+	ConnectionStatus() (string, error)
+}
+
+// Connection statuses reported by ConnectionChecker
+const (
+	// ConnectionConnected means the client received incoming connections
+	ConnectionConnected = "connected"
+	// ConnectionFirewalled means no incoming connection reached the
+	// listening port yet
+	ConnectionFirewalled = "firewalled"
+	// ConnectionDisconnected means the client has no network connection
+	ConnectionDisconnected = "disconnected"
+)
@@ -0,0 +1,102 @@
+package torrent
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Defaults of the connection settings in TransportOptions
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultDialTimeout = 5 * time.Second
+	DefaultIdleTimeout = 90 * time.Second
+)
+
+// Fixed settings of the transport. The watcher, the HTTP server and the
+// notifications share the client and may call the torrent client at the same
+// time, so a few idle connections are kept instead of dialing for each
+// request.
+const (
+	tcpKeepAlive        = 30 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+	maxIdleConnsPerHost = 4
+)
+
+// TransportOptions configure how a client connects to the torrent client
+type TransportOptions struct {
+	// TLS configures HTTPS connections; nil uses the defaults
+	TLS *tls.Config
+	// Timeout bounds every request including its response, and DialTimeout
+	// establishing a connection; zero uses DefaultTimeout and
+	// DefaultDialTimeout
+	Timeout     time.Duration
+	DialTimeout time.Duration
+	// IdleTimeout closes connections kept alive for reuse after being idle
+	// that long; zero uses DefaultIdleTimeout. DisableKeepAlives opens a new
+	// connection for every request instead.
+	IdleTimeout       time.Duration
+	DisableKeepAlives bool
+	// Proxy routes the requests through an HTTP or SOCKS5 proxy; nil uses
+	// the standard proxy environment variables
+	Proxy *url.URL
+}
+
+// ParseProxyURL validates the URL of an HTTP, HTTPS or SOCKS5 proxy for
+// TransportOptions.Proxy
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+	}
+	return u, nil
+}
+
+// NewHTTPClient creates an HTTP client for the options
+func NewHTTPClient(opts TransportOptions) *http.Client {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{Timeout: timeout, Transport: newTransport(opts)}
+}
+
+// newTransport creates the transport of a client for the options
+func newTransport(opts TransportOptions) *http.Transport {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	idleTimeout := opts.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != nil {
+		proxy = http.ProxyURL(opts.Proxy)
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: tcpKeepAlive}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       opts.TLS,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		DisableKeepAlives:     opts.DisableKeepAlives,
+		IdleConnTimeout:       idleTimeout,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+	}
+}
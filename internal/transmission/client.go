@@ -0,0 +1,295 @@
+package transmission
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// rpcPath is where Transmission serves RPC unless its rpc-url is changed
+const rpcPath = "/transmission/rpc"
+
+// sessionIDHeader carries the token Transmission requires against CSRF
+const sessionIDHeader = "X-Transmission-Session-Id"
+
+// Client talks to the Transmission RPC API. Transmission rejects requests
+// without its current session id with 409 and the id to use in
+// X-Transmission-Session-Id; the client keeps the id and resends the request
+// with it.
+type Client struct {
+	rpcURL   string
+	username string
+	password string
+	client   *http.Client
+	retry    torrent.RetryPolicy
+	// portArguments are session arguments written along with every peer port
+	portArguments map[string]any
+
+	sessionMu sync.Mutex
+	sessionID string
+}
+
+// Options configure how the client connects to Transmission. Username and
+// Password are sent with basic authentication if rpc-authentication-required
+// is on.
+type Options struct {
+	Username  string
+	Password  string
+	Transport torrent.TransportOptions
+}
+
+// ErrPortNotApplied means Transmission accepted a new peer port but kept the
+// previous one
+var ErrPortNotApplied = errors.New("transmission did not apply the peer port")
+
+// NewClient creates a client for the Transmission at baseURL and checks that
+// it accepts requests. Without a path, baseURL is completed with the default
+// RPC path /transmission/rpc.
+func NewClient(baseURL string, opts Options) (*Client, error) {
+	rpcURL, err := rpcEndpoint(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		rpcURL:   rpcURL,
+		username: opts.Username,
+		password: opts.Password,
+		client:   torrent.NewHTTPClient(opts.Transport),
+		retry:    torrent.DefaultRetryPolicy,
+	}
+
+	// The caller retries the initial request, e.g. until Transmission has
+	// started
+	if err := client.PingOnce(); err != nil {
+		return nil, fmt.Errorf("initial request failed: %w", err)
+	}
+	return client, nil
+}
+
+// rpcEndpoint returns the RPC URL for the URL of Transmission
+func rpcEndpoint(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid Transmission URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid Transmission URL %q: scheme and host are required", baseURL)
+	}
+	if u.Path == "" {
+		u.Path = rpcPath
+	}
+	return u.String(), nil
+}
+
+// SetRetryPolicy changes how failed requests to Transmission are retried. It
+// must be called before the client is shared.
+func (c *Client) SetRetryPolicy(policy torrent.RetryPolicy) {
+	c.retry = policy
+}
+
+// SetPortArguments sets session arguments that are written along with every
+// peer port, e.g. to keep Transmission from changing the port itself. It
+// must be called before the client is shared.
+func (c *Client) SetPortArguments(args map[string]any) {
+	c.portArguments = args
+}
+
+// GetPort returns the peer port of Transmission
+func (c *Client) GetPort() (int, error) {
+	var port int
+	err := c.retry.Run("get port", func() error {
+		var err error
+		port, err = c.peerPort()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// SetPort sets the peer port, together with the arguments of
+// SetPortArguments, and reads the session back to verify that Transmission
+// applied it. A port that was not applied is retried like a failed request.
+func (c *Client) SetPort(port int) error {
+	args := make(map[string]any, len(c.portArguments)+1)
+	maps.Copy(args, c.portArguments)
+	args["peer-port"] = port
+
+	return c.retry.Run("set port", func() error {
+		if err := c.call("session-set", args, nil); err != nil {
+			return fmt.Errorf("failed to set session: %w", err)
+		}
+		applied, err := c.peerPort()
+		if err != nil {
+			return fmt.Errorf("failed to verify port: %w", err)
+		}
+		if applied != port {
+			return fmt.Errorf("%w: peer-port is %d instead of %d", ErrPortNotApplied, applied, port)
+		}
+		slog.Info("successfully updated Transmission peer port", "port", port)
+		return nil
+	})
+}
+
+// peerPort reads the peer port from the session with a single attempt
+func (c *Client) peerPort() (int, error) {
+	var session struct {
+		PeerPort int `json:"peer-port"`
+	}
+	if err := c.call("session-get", map[string]any{"fields": []string{"peer-port"}}, &session); err != nil {
+		return 0, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session.PeerPort, nil
+}
+
+// Reannounce makes Transmission announce torrents to their trackers now, e.g.
+// so that they learn a new peer port. With activeOnly, only the recently
+// active torrents are announced; otherwise all of them.
+func (c *Client) Reannounce(activeOnly bool) error {
+	args := map[string]any{}
+	if activeOnly {
+		args["ids"] = "recently-active"
+	}
+	return c.retry.Run("reannounce", func() error {
+		if err := c.call("torrent-reannounce", args, nil); err != nil {
+			return fmt.Errorf("failed to reannounce torrents: %w", err)
+		}
+		return nil
+	})
+}
+
+// Ping checks that Transmission is reachable, retrying failed attempts so
+// that a brief restart is not reported as an outage
+func (c *Client) Ping() error {
+	return c.retry.Run("ping", c.PingOnce)
+}
+
+// PingOnce checks that Transmission is reachable with a single attempt, for
+// checks that report the current state such as readiness probes
+func (c *Client) PingOnce() error {
+	if err := c.call("session-get", map[string]any{"fields": []string{"version"}}, nil); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+// rpcRequest and rpcResponse are the envelope of every RPC call
+type rpcRequest struct {
+	Method    string `json:"method"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+type rpcResponse struct {
+	Result    string          `json:"result"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// call invokes method with args and decodes the arguments of the response
+// into result, unless it is nil. Transmission reports failures of a method
+// in result, which is "success" otherwise.
+func (c *Client) call(method string, args any, result any) error {
+	body, err := json.Marshal(rpcRequest{Method: method, Arguments: args})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+
+	resp, err := c.do(body)
+	if err != nil {
+		return err
+	}
+	defer closeResponseBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(data))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			// Transmission answers 401 to wrong credentials and 403 to
+			// addresses outside its rpc-whitelist
+			return torrent.Permanent(err)
+		}
+		return err
+	}
+
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if response.Result != "success" {
+		return fmt.Errorf("%s failed: %s", method, response.Result)
+	}
+	if result != nil {
+		if err := json.Unmarshal(response.Arguments, result); err != nil {
+			return fmt.Errorf("failed to decode %s arguments: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// do posts body with the current session id, and once more with the new id
+// if Transmission answers 409 because the id is missing or has expired
+func (c *Client) do(body []byte) (*http.Response, error) {
+	send := func(sessionID string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, c.rpcURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if sessionID != "" {
+			req.Header.Set(sessionIDHeader, sessionID)
+		}
+		if c.username != "" || c.password != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		return c.client.Do(req)
+	}
+
+	resp, err := send(c.currentSessionID())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusConflict {
+		return resp, nil
+	}
+
+	sessionID := resp.Header.Get(sessionIDHeader)
+	closeResponseBody(resp)
+	if sessionID == "" {
+		return nil, fmt.Errorf("status %d without %s", http.StatusConflict, sessionIDHeader)
+	}
+	c.setSessionID(sessionID)
+	slog.Debug("received new Transmission session id")
+	return send(sessionID)
+}
+
+func (c *Client) currentSessionID() string {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.sessionID
+}
+
+func (c *Client) setSessionID(sessionID string) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.sessionID = sessionID
+}
+
+func closeResponseBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+}
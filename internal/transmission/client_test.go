@@ -0,0 +1,215 @@
+package transmission
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/torrent"
+)
+
+// fakeTransmission emulates the RPC API of Transmission with the session id
+// handshake and basic authentication
+type fakeTransmission struct {
+	sessionID string
+	username  string
+	password  string
+	peerPort  int
+	// ignorePort keeps session-set from changing peerPort
+	ignorePort bool
+	// requests records the methods called with a valid session id, and
+	// arguments the last arguments of each method
+	requests  []string
+	arguments map[string]map[string]any
+}
+
+func newFakeTransmission(t *testing.T, fake *fakeTransmission) *httptest.Server {
+	t.Helper()
+	fake.arguments = make(map[string]map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != rpcPath {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != fake.username || pass != fake.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(sessionIDHeader) != fake.sessionID {
+			w.Header().Set(sessionIDHeader, fake.sessionID)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		var req struct {
+			Method    string         `json:"method"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fake.requests = append(fake.requests, req.Method)
+		fake.arguments[req.Method] = req.Arguments
+
+		response := map[string]any{"result": "success", "arguments": map[string]any{}}
+		switch req.Method {
+		case "session-get":
+			response["arguments"] = map[string]any{"peer-port": fake.peerPort, "version": "4.0.6"}
+		case "session-set":
+			if port, ok := req.Arguments["peer-port"].(float64); ok && !fake.ignorePort {
+				fake.peerPort = int(port)
+			}
+		case "torrent-reannounce":
+		default:
+			response["result"] = "method name not recognized"
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewClient(t *testing.T) {
+	fake := &fakeTransmission{sessionID: "abc", username: "admin", password: "secret", peerPort: 51413}
+	server := newFakeTransmission(t, fake)
+
+	client, err := NewClient(server.URL, Options{Username: "admin", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if client.rpcURL != server.URL+rpcPath {
+		t.Errorf("rpcURL = %q, want %q", client.rpcURL, server.URL+rpcPath)
+	}
+	port, err := client.GetPort()
+	if err != nil {
+		t.Fatalf("GetPort() error = %v", err)
+	}
+	if port != 51413 {
+		t.Errorf("GetPort() = %d, want 51413", port)
+	}
+}
+
+func TestNewClient_RejectedCredentials(t *testing.T) {
+	fake := &fakeTransmission{sessionID: "abc", username: "admin", password: "secret"}
+	server := newFakeTransmission(t, fake)
+
+	_, err := NewClient(server.URL, Options{Username: "admin", Password: "wrong"})
+	if err == nil {
+		t.Fatal("NewClient() error = nil, want rejected credentials")
+	}
+	if !torrent.IsPermanent(err) {
+		t.Errorf("error %v is retried, want permanent", err)
+	}
+}
+
+func TestClient_SessionIDRenewed(t *testing.T) {
+	fake := &fakeTransmission{sessionID: "first"}
+	server := newFakeTransmission(t, fake)
+
+	client, err := NewClient(server.URL, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// Transmission issues a new session id after a restart
+	fake.sessionID = "second"
+	if err := client.PingOnce(); err != nil {
+		t.Fatalf("PingOnce() error = %v", err)
+	}
+	if client.currentSessionID() != "second" {
+		t.Errorf("session id = %q, want second", client.currentSessionID())
+	}
+}
+
+func TestSetPort(t *testing.T) {
+	fake := &fakeTransmission{sessionID: "abc", peerPort: 51413}
+	server := newFakeTransmission(t, fake)
+
+	client, err := NewClient(server.URL, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetPortArguments(map[string]any{"peer-port-random-on-start": false})
+
+	if err := client.SetPort(6000); err != nil {
+		t.Fatalf("SetPort() error = %v", err)
+	}
+	if fake.peerPort != 6000 {
+		t.Errorf("peer-port = %d, want 6000", fake.peerPort)
+	}
+	if got := fake.arguments["session-set"]["peer-port-random-on-start"]; got != false {
+		t.Errorf("peer-port-random-on-start = %v, want false", got)
+	}
+}
+
+func TestSetPort_NotApplied(t *testing.T) {
+	fake := &fakeTransmission{sessionID: "abc", peerPort: 51413, ignorePort: true}
+	server := newFakeTransmission(t, fake)
+
+	client, err := NewClient(server.URL, Options{})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetRetryPolicy(torrent.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	if err := client.SetPort(6000); !errors.Is(err, ErrPortNotApplied) {
+		t.Errorf("SetPort() error = %v, want ErrPortNotApplied", err)
+	}
+}
+
+func TestReannounce(t *testing.T) {
+	tests := []struct {
+		name       string
+		activeOnly bool
+		wantIDs    any
+	}{
+		{name: "all torrents"},
+		{name: "active torrents", activeOnly: true, wantIDs: "recently-active"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTransmission{sessionID: "abc"}
+			server := newFakeTransmission(t, fake)
+
+			client, err := NewClient(server.URL, Options{})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if err := client.Reannounce(tt.activeOnly); err != nil {
+				t.Fatalf("Reannounce() error = %v", err)
+			}
+			if got := fake.arguments["torrent-reannounce"]["ids"]; got != tt.wantIDs {
+				t.Errorf("ids = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestRPCEndpoint(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "http://localhost:9091", want: "http://localhost:9091/transmission/rpc"},
+		{baseURL: "http://localhost:9091/", want: "http://localhost:9091/transmission/rpc"},
+		{baseURL: "https://seedbox.example/custom/rpc", want: "https://seedbox.example/custom/rpc"},
+		{baseURL: "localhost:9091", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := rpcEndpoint(tt.baseURL)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("rpcEndpoint(%q) error = %v, wantErr %v", tt.baseURL, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("rpcEndpoint(%q) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package upnp maps a port on the router with UPnP IGD, discovering the
+// router with SSDP, and keeps the mapping renewed.
+package upnp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ssdpAddr is the multicast address SSDP searches are sent to
+var ssdpAddr = "239.255.255.250:1900"
+
+// discoverTimeout bounds the wait for answers to a search
+const discoverTimeout = 3 * time.Second
+
+// requestTimeout bounds requests to the router
+const requestTimeout = 5 * time.Second
+
+// maxDescriptionSize bounds the device description read from the router
+const maxDescriptionSize = 1 << 20
+
+// searchTargets are the device types searched for, newest first
+var searchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+}
+
+// connectionServices are the services that map ports, in order of
+// preference
+var connectionServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// Discover searches the local network for an internet gateway device and
+// returns the first one that answers
+func Discover() (*Gateway, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSDP socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range searchTargets {
+		search := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: 239.255.255.250:1900\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + target + "\r\n\r\n"
+		if _, err := conn.WriteTo([]byte(search), addr); err != nil {
+			return nil, fmt.Errorf("failed to send SSDP search: %w", err)
+		}
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(discoverTimeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, errors.New("no UPnP internet gateway device found, is UPnP enabled on the router?")
+			}
+			return nil, fmt.Errorf("failed to read SSDP response: %w", err)
+		}
+		location := ssdpLocation(buf[:n])
+		if location == "" {
+			continue
+		}
+		// Devices without a connection service, e.g. a second IGD without
+		// WAN access, are skipped in favor of later answers
+		gateway, err := NewGateway(location)
+		if err == nil {
+			return gateway, nil
+		}
+	}
+}
+
+// ssdpLocation returns the description URL of an SSDP response, or "" if
+// the response is not one
+func ssdpLocation(response []byte) string {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response)), nil)
+	if err != nil {
+		return ""
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	return resp.Header.Get("Location")
+}
+
+// description is the part of a device description that leads to the
+// services of the device and its embedded devices
+type description struct {
+	URLBase string `xml:"URLBase"`
+	Device  device `xml:"device"`
+}
+
+type device struct {
+	Services []service `xml:"serviceList>service"`
+	Devices  []device  `xml:"deviceList>device"`
+}
+
+type service struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// find returns the first service of the type in the device tree
+func (d device) find(serviceType string) (service, bool) {
+	for _, s := range d.Services {
+		if s.ServiceType == serviceType {
+			return s, true
+		}
+	}
+	for _, child := range d.Devices {
+		if s, ok := child.find(serviceType); ok {
+			return s, true
+		}
+	}
+	return service{}, false
+}
+
+// NewGateway reads the device description at location, e.g.
+// http://192.168.1.1:5000/rootDesc.xml, and returns the gateway of its
+// connection service. The address Forwardarr reaches the router from is
+// mapped as internal client.
+func NewGateway(location string) (*Gateway, error) {
+	base, err := url.Parse(location)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid UPnP description URL %q", location)
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read UPnP device description: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("UPnP device description returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDescriptionSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read UPnP device description: %w", err)
+	}
+	var desc description
+	if err := xml.Unmarshal(body, &desc); err != nil {
+		return nil, fmt.Errorf("failed to decode UPnP device description: %w", err)
+	}
+	if desc.URLBase != "" {
+		if urlBase, err := url.Parse(desc.URLBase); err == nil {
+			base = urlBase
+		}
+	}
+
+	for _, serviceType := range connectionServices {
+		s, ok := desc.Device.find(serviceType)
+		if !ok {
+			continue
+		}
+		controlURL, err := base.Parse(strings.TrimSpace(s.ControlURL))
+		if err != nil {
+			return nil, fmt.Errorf("invalid control URL %q: %w", s.ControlURL, err)
+		}
+		localIP, err := localAddress(controlURL.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &Gateway{
+			controlURL:  controlURL.String(),
+			serviceType: serviceType,
+			localIP:     localIP,
+			client:      client,
+		}, nil
+	}
+	return nil, fmt.Errorf("UPnP device at %s has no WAN connection service", location)
+}
+
+// localAddress returns the local address used to reach host
+func localAddress(host string) (string, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "80")
+	}
+	// Connecting a UDP socket sends nothing, but selects the local address
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return "", fmt.Errorf("failed to find local address for the router: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
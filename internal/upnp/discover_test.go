@@ -0,0 +1,144 @@
+package upnp
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRouter serves a device description with the service in an embedded
+// device and answers port mapping actions
+type fakeRouter struct {
+	*httptest.Server
+	service string
+
+	mu        sync.Mutex
+	calls     []string // action and arguments, e.g. "AddPortMapping TCP 6881->6881 3600"
+	fault     int      // error code returned for every action
+	permanent bool     // reject leases other than 0 with 725
+	reserved  int      // port returned by AddAnyPortMapping
+}
+
+func newFakeRouter(t *testing.T, service string) *fakeRouter {
+	t.Helper()
+	r := &fakeRouter{service: service}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <serviceList><service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType><controlURL>/l3f</controlURL></service></serviceList>
+    <deviceList><device><deviceList><device>
+      <serviceList><service><serviceType>%s</serviceType><controlURL>/ctl/IPConn</controlURL></service></serviceList>
+    </device></deviceList></device></deviceList>
+  </device>
+</root>`, service)
+	})
+	mux.HandleFunc("/ctl/IPConn", r.control)
+	r.Server = httptest.NewServer(mux)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *fakeRouter) control(w http.ResponseWriter, req *http.Request) {
+	action := strings.Trim(req.Header.Get("SOAPAction"), `"`)
+	action = action[strings.Index(action, "#")+1:]
+	body, _ := io.ReadAll(req.Body)
+	args := map[string]string{}
+	decoder := xml.NewDecoder(strings.NewReader(string(body)))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if start, ok := tok.(xml.StartElement); ok && strings.HasPrefix(start.Name.Local, "New") {
+			var value string
+			_ = decoder.DecodeElement(&value, &start)
+			args[start.Name.Local] = value
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, fmt.Sprintf("%s %s %s->%s %s %s", action, args["NewProtocol"], args["NewExternalPort"], args["NewInternalPort"], args["NewInternalClient"], args["NewLeaseDuration"]))
+	code := r.fault
+	if r.permanent && args["NewLeaseDuration"] != "0" {
+		code = codeOnlyPermanentLeases
+	}
+	if code != 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>Error</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`, code)
+		return
+	}
+	response := ""
+	if action == "AddAnyPortMapping" {
+		response = fmt.Sprintf("<NewReservedPort>%d</NewReservedPort>", r.reserved)
+	}
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:%sResponse xmlns:u="%s">%s</u:%sResponse></s:Body></s:Envelope>`, action, r.service, response, action)
+}
+
+func (r *fakeRouter) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func TestNewGateway(t *testing.T) {
+	router := newFakeRouter(t, "urn:schemas-upnp-org:service:WANIPConnection:1")
+	gateway, err := NewGateway(router.URL + "/rootDesc.xml")
+	if err != nil {
+		t.Fatalf("NewGateway() error = %v", err)
+	}
+	if gateway.controlURL != router.URL+"/ctl/IPConn" || gateway.localIP != "127.0.0.1" {
+		t.Errorf("NewGateway() = %+v", gateway)
+	}
+
+	other := newFakeRouter(t, "urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1")
+	if _, err := NewGateway(other.URL + "/rootDesc.xml"); err == nil || !strings.Contains(err.Error(), "no WAN connection service") {
+		t.Errorf("NewGateway() error = %v, want no connection service", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	router := newFakeRouter(t, "urn:schemas-upnp-org:service:WANIPConnection:1")
+
+	// A fake SSDP responder on localhost instead of the multicast group
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	previous := ssdpAddr
+	ssdpAddr = conn.LocalAddr().String()
+	t.Cleanup(func() { ssdpAddr = previous })
+
+	searches := make(chan string, 2)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			searches <- string(buf[:n])
+			_, _ = conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: upnp:rootdevice\r\nLOCATION: "+router.URL+"/rootDesc.xml\r\n\r\n"), from)
+		}
+	}()
+
+	gateway, err := Discover()
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if gateway.controlURL != router.URL+"/ctl/IPConn" {
+		t.Errorf("controlURL = %q", gateway.controlURL)
+	}
+	if search := <-searches; !strings.HasPrefix(search, "M-SEARCH * HTTP/1.1\r\n") || !strings.Contains(search, "ST: urn:schemas-upnp-org:device:InternetGatewayDevice:2") {
+		t.Errorf("search = %q", search)
+	}
+}
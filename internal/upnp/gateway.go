@@ -0,0 +1,163 @@
+package upnp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error codes of the connection service
+const (
+	codeInvalidAction       = 401
+	codeOnlyPermanentLeases = 725
+)
+
+// mappingDescription names the mappings of Forwardarr in the router
+const mappingDescription = "Forwardarr"
+
+// maxControlResponseSize bounds responses to actions
+const maxControlResponseSize = 64 << 10
+
+// addAnyPortMappingService is the service that can choose a free external
+// port itself
+const addAnyPortMappingService = "urn:schemas-upnp-org:service:WANIPConnection:2"
+
+const (
+	soapEnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soapEncodingStyle     = "http://schemas.xmlsoap.org/soap/encoding/"
+)
+
+// Gateway maps ports with the connection service of an internet gateway
+// device
+type Gateway struct {
+	controlURL  string
+	serviceType string
+	localIP     string
+	client      *http.Client
+}
+
+// SOAPError is an error returned by the router for an action
+type SOAPError struct {
+	Code        int
+	Description string
+}
+
+func (e *SOAPError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("router rejected the request: %s (%d)", e.Description, e.Code)
+	}
+	return fmt.Sprintf("router rejected the request with error %d", e.Code)
+}
+
+// hasCode reports whether err is a SOAPError with the code
+func hasCode(err error, code int) bool {
+	var soapErr *SOAPError
+	return errors.As(err, &soapErr) && soapErr.Code == code
+}
+
+// AddPortMapping maps externalPort of the protocol ("TCP" or "UDP") to
+// internalPort of this host for lease, where 0 means a permanent mapping,
+// and returns the mapped external port. Routers with IGDv2 pick another
+// external port if externalPort is taken; older ones fail instead.
+func (g *Gateway) AddPortMapping(protocol string, internalPort, externalPort int, lease time.Duration) (int, error) {
+	args := []arg{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(externalPort)},
+		{"NewProtocol", protocol},
+		{"NewInternalPort", strconv.Itoa(internalPort)},
+		{"NewInternalClient", g.localIP},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", mappingDescription},
+		{"NewLeaseDuration", strconv.Itoa(int(lease / time.Second))},
+	}
+
+	if g.serviceType == addAnyPortMappingService {
+		response, err := g.call("AddAnyPortMapping", args)
+		if err == nil {
+			port, err := strconv.Atoi(strings.TrimSpace(response["NewReservedPort"]))
+			if err != nil {
+				return 0, fmt.Errorf("router returned invalid reserved port %q", response["NewReservedPort"])
+			}
+			return port, nil
+		}
+		if !hasCode(err, codeInvalidAction) {
+			return 0, err
+		}
+	}
+	if _, err := g.call("AddPortMapping", args); err != nil {
+		return 0, err
+	}
+	return externalPort, nil
+}
+
+// arg is an argument of an action, which must be sent in order
+type arg struct {
+	name, value string
+}
+
+// call invokes the action on the connection service and returns the
+// arguments of the response
+func (g *Gateway) call(action string, args []arg) (map[string]string, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>`)
+	fmt.Fprintf(&body, `<s:Envelope xmlns:s="%s" s:encodingStyle="%s"><s:Body>`, soapEnvelopeNamespace, soapEncodingStyle)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, g.serviceType)
+	for _, a := range args {
+		body.WriteString("<" + a.name + ">")
+		_ = xml.EscapeText(&body, []byte(a.value))
+		body.WriteString("</" + a.name + ">")
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest(http.MethodPost, g.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, g.serviceType, action))
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach router: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxControlResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", action, err)
+	}
+
+	var envelope struct {
+		Body struct {
+			Fault *struct {
+				Code        int    `xml:"detail>UPnPError>errorCode"`
+				Description string `xml:"detail>UPnPError>errorDescription"`
+			} `xml:"Fault"`
+			Response struct {
+				Args []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	decodeErr := xml.Unmarshal(data, &envelope)
+	if fault := envelope.Body.Fault; decodeErr == nil && fault != nil {
+		return nil, &SOAPError{Code: fault.Code, Description: strings.TrimSpace(fault.Description)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("router returned status %d for %s", resp.StatusCode, action)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", action, decodeErr)
+	}
+	values := make(map[string]string)
+	for _, a := range envelope.Body.Response.Args {
+		values[a.XMLName.Local] = a.Value
+	}
+	return values, nil
+}
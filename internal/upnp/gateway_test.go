@@ -0,0 +1,49 @@
+package upnp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGateway_AddPortMapping(t *testing.T) {
+	router := newFakeRouter(t, "urn:schemas-upnp-org:service:WANIPConnection:1")
+	gateway, _ := NewGateway(router.URL + "/rootDesc.xml")
+
+	port, err := gateway.AddPortMapping("TCP", 6881, 6881, time.Hour)
+	if err != nil || port != 6881 {
+		t.Fatalf("AddPortMapping() = %d, %v, want 6881", port, err)
+	}
+	if got := router.received(); len(got) != 1 || got[0] != "AddPortMapping TCP 6881->6881 127.0.0.1 3600" {
+		t.Errorf("calls = %q", got)
+	}
+
+	router.mu.Lock()
+	router.fault = 718
+	router.mu.Unlock()
+	_, err = gateway.AddPortMapping("TCP", 6881, 6881, time.Hour)
+	if !hasCode(err, 718) {
+		t.Errorf("AddPortMapping() error = %v, want code 718", err)
+	}
+}
+
+func TestGateway_AddAnyPortMapping(t *testing.T) {
+	router := newFakeRouter(t, addAnyPortMappingService)
+	router.reserved = 40000
+	gateway, _ := NewGateway(router.URL + "/rootDesc.xml")
+
+	port, err := gateway.AddPortMapping("UDP", 6881, 6881, time.Hour)
+	if err != nil || port != 40000 {
+		t.Errorf("AddPortMapping() = %d, %v, want the reserved port", port, err)
+	}
+
+	// Routers without AddAnyPortMapping fall back to AddPortMapping, which
+	// fails for the test since every action is rejected
+	router.mu.Lock()
+	router.fault = codeInvalidAction
+	router.mu.Unlock()
+	_, _ = gateway.AddPortMapping("UDP", 6881, 6881, time.Hour)
+	if got := router.received(); len(got) != 3 || !strings.HasPrefix(got[2], "AddPortMapping ") {
+		t.Errorf("calls = %q, want a fallback to AddPortMapping", got)
+	}
+}
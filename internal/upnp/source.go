@@ -0,0 +1,157 @@
+package upnp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// retryDelay is how long the source waits after a failed renewal
+const retryDelay = 30 * time.Second
+
+// Source maps an internal port on the router for TCP and UDP, renews the
+// mappings before their lease ends, and provides the external port as
+// forwarded port. The external port requested is the internal port, so that
+// the torrent client can listen on the port it announces; routers with IGDv2
+// may map another one if it is taken.
+type Source struct {
+	locate       func() (*Gateway, error)
+	internalPort int
+	lease        time.Duration
+
+	mu        sync.Mutex
+	gateway   *Gateway
+	permanent bool
+	port      int
+	expires   time.Time
+	err       error
+}
+
+// NewSource creates a source mapping internalPort for lease. The router is
+// discovered with SSDP, or read from descriptionURL if set.
+func NewSource(descriptionURL string, internalPort int, lease time.Duration) *Source {
+	locate := Discover
+	if descriptionURL != "" {
+		locate = func() (*Gateway, error) { return NewGateway(descriptionURL) }
+	}
+	return &Source{locate: locate, internalPort: internalPort, lease: lease}
+}
+
+// ForwardedPort returns the external port of the mappings, or 0 before the
+// first mapping. Once the lease ended without renewal, it returns the error
+// of the last attempt.
+func (s *Source) ForwardedPort() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.port != 0 && time.Now().Before(s.expires) {
+		return s.port, nil
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return 0, nil
+}
+
+// Refresh requests or renews the mappings, discovering the router first if
+// it is not known yet or stopped answering
+func (s *Source) Refresh() error {
+	err := s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = fmt.Errorf("failed to map port: %w", err)
+		return s.err
+	}
+	s.err = nil
+	return nil
+}
+
+func (s *Source) refresh() error {
+	s.mu.Lock()
+	gateway, suggested, permanent := s.gateway, s.port, s.permanent
+	s.mu.Unlock()
+	if suggested == 0 {
+		suggested = s.internalPort
+	}
+
+	if gateway == nil {
+		var err error
+		if gateway, err = s.locate(); err != nil {
+			return err
+		}
+		slog.Info("found UPnP internet gateway device", "control_url", gateway.controlURL, "service", gateway.serviceType)
+	}
+
+	lease := s.lease
+	if permanent {
+		lease = 0
+	}
+	tcp, err := gateway.AddPortMapping("TCP", s.internalPort, suggested, lease)
+	if hasCode(err, codeOnlyPermanentLeases) && lease != 0 {
+		// The mappings are still renewed, which restores them after a
+		// reboot of the router
+		slog.Info("router only supports permanent UPnP mappings")
+		permanent, lease = true, 0
+		tcp, err = gateway.AddPortMapping("TCP", s.internalPort, suggested, lease)
+	}
+	var udp int
+	if err == nil {
+		udp, err = gateway.AddPortMapping("UDP", s.internalPort, tcp, lease)
+	}
+	if err == nil && udp != tcp {
+		err = fmt.Errorf("router mapped TCP port %d but UDP port %d", tcp, udp)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var soapErr *SOAPError
+	if err != nil && !errors.As(err, &soapErr) {
+		// The router may have restarted at another address
+		s.gateway = nil
+		return err
+	}
+	s.gateway, s.permanent = gateway, permanent
+	if err != nil {
+		return err
+	}
+	if tcp != s.port {
+		slog.Info("UPnP mapped port", "port", tcp, "internal_port", s.internalPort, "previous_port", s.port)
+	}
+	s.port = tcp
+	s.expires = time.Now().Add(s.lease)
+	return nil
+}
+
+// Run renews the mappings at half their lease until ctx is done, and retries
+// failed renewals. The first mapping is requested right away unless Refresh
+// already succeeded.
+func (s *Source) Run(ctx context.Context) {
+	for {
+		delay := s.nextRenewal()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err := s.Refresh(); err != nil {
+			slog.Warn("UPnP renewal failed, retrying", "retry_delay", retryDelay, "error", err)
+		}
+	}
+}
+
+// nextRenewal returns how long to wait before the next renewal: half the
+// remaining lease, or retryDelay after a failure
+func (s *Source) nextRenewal() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.port == 0 && s.err == nil {
+		return 0
+	}
+	if s.err != nil {
+		return retryDelay
+	}
+	return max(time.Until(s.expires)/2, 0)
+}
@@ -0,0 +1,56 @@
+package upnp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSource_Refresh(t *testing.T) {
+	router := newFakeRouter(t, "urn:schemas-upnp-org:service:WANIPConnection:1")
+	router.permanent = true
+	source := NewSource(router.URL+"/rootDesc.xml", 6881, time.Hour)
+
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if port, err := source.ForwardedPort(); port != 6881 || err != nil {
+		t.Errorf("ForwardedPort() = %d, %v, want 6881", port, err)
+	}
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	want := []string{
+		"AddPortMapping TCP 6881->6881 127.0.0.1 3600",
+		"AddPortMapping TCP 6881->6881 127.0.0.1 0",
+		"AddPortMapping UDP 6881->6881 127.0.0.1 0",
+		"AddPortMapping TCP 6881->6881 127.0.0.1 0",
+		"AddPortMapping UDP 6881->6881 127.0.0.1 0",
+	}
+	if got := router.received(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestSource_RouterGone(t *testing.T) {
+	router := newFakeRouter(t, "urn:schemas-upnp-org:service:WANIPConnection:1")
+	source := NewSource(router.URL+"/rootDesc.xml", 6881, time.Hour)
+	if err := source.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	router.Close()
+	if err := source.Refresh(); err == nil {
+		t.Fatal("Refresh() error = nil, want unreachable router")
+	}
+	if source.gateway != nil {
+		t.Error("gateway kept after the router stopped answering, want rediscovery")
+	}
+	// The mappings are still valid until the lease ends
+	if port, err := source.ForwardedPort(); port != 6881 || err != nil {
+		t.Errorf("ForwardedPort() = %d, %v, want 6881", port, err)
+	}
+	if got := source.nextRenewal(); got != retryDelay {
+		t.Errorf("nextRenewal() = %v, want %v", got, retryDelay)
+	}
+}
@@ -0,0 +1,116 @@
+package vpn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlTimeout bounds a request to the Gluetun control server
+const controlTimeout = 5 * time.Second
+
+// Endpoints of the forwarded port on the Gluetun control server: the current
+// one, and the one of versions before v3.40, which only covers OpenVPN
+const (
+	portForwardPath       = "/v1/portforward"
+	legacyPortForwardPath = "/v1/openvpn/portforwarded"
+)
+
+// ControlServer reads the forwarded port from the control server of Gluetun,
+// so that no volume needs to be shared for its port file. The API key is sent
+// in the X-API-Key header, as configured for the role in the auth config of
+// the control server.
+type ControlServer struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+
+	// mu guards path, the endpoint that answered last
+	mu   sync.Mutex
+	path string
+}
+
+// NewControlServer creates a client for the control server at baseURL, e.g.
+// http://gluetun:8000
+func NewControlServer(baseURL, apiKey string) *ControlServer {
+	return &ControlServer{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: controlTimeout},
+		path:    portForwardPath,
+	}
+}
+
+// ForwardedPort returns the forwarded port, or 0 while Gluetun has none.
+// Control servers without the current endpoint are asked at the endpoint of
+// older versions from then on.
+func (c *ControlServer) ForwardedPort() (int, error) {
+	c.mu.Lock()
+	path := c.path
+	c.mu.Unlock()
+
+	port, err := c.get(path)
+	if errors.Is(err, errNotFound) && path == portForwardPath {
+		port, err = c.get(legacyPortForwardPath)
+		if err == nil {
+			c.mu.Lock()
+			c.path = legacyPortForwardPath
+			c.mu.Unlock()
+		}
+	}
+	if errors.Is(err, errNotFound) {
+		return 0, fmt.Errorf("control server at %s has no port forwarding endpoint", c.baseURL)
+	}
+	return port, err
+}
+
+// errNotFound means the control server does not know the endpoint
+var errNotFound = errors.New("endpoint not found")
+
+// get requests the forwarded port at path. Newer versions may return all
+// forwarded ports in "ports"; the first one is used.
+func (c *ControlServer) get(path string) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid control server URL: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to request forwarded port: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return 0, errNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return 0, fmt.Errorf("control server rejected the request with status %d, check GLUETUN_CONTROL_API_KEY and the role of %s", resp.StatusCode, path)
+	default:
+		return 0, fmt.Errorf("control server returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Port  int   `json:"port"`
+		Ports []int `json:"ports"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode forwarded port: %w", err)
+	}
+	port := body.Port
+	if port == 0 && len(body.Ports) > 0 {
+		port = body.Ports[0]
+	}
+	if port < 0 || port > 65535 {
+		return 0, fmt.Errorf("forwarded port %d out of valid range", port)
+	}
+	return port, nil
+}
@@ -0,0 +1,77 @@
+package vpn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestControlServer_ForwardedPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{name: "current endpoint", path: portForwardPath, body: `{"port":51413}`, want: 51413},
+		{name: "several ports", path: portForwardPath, body: `{"ports":[51413,51414]}`, want: 51413},
+		{name: "older version", path: legacyPortForwardPath, body: `{"port":51413}`, want: 51413},
+		{name: "no port yet", path: portForwardPath, body: `{"port":0}`, want: 0},
+		{name: "out of range", path: portForwardPath, body: `{"port":70000}`, wantErr: true},
+		{name: "no endpoint", path: "/v1/other", body: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			port, err := NewControlServer(server.URL, "").ForwardedPort()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForwardedPort() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if port != tt.want {
+				t.Errorf("ForwardedPort() = %d, want %d", port, tt.want)
+			}
+		})
+	}
+}
+
+func TestControlServer_APIKey(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != legacyPortForwardPath {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"port":51413}`))
+	}))
+	defer server.Close()
+
+	control := NewControlServer(server.URL+"/", "secret")
+	for range 2 {
+		if port, err := control.ForwardedPort(); err != nil || port != 51413 {
+			t.Fatalf("ForwardedPort() = %d, %v, want 51413", port, err)
+		}
+	}
+	// The older endpoint is remembered after the first fallback
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+
+	if _, err := NewControlServer(server.URL, "wrong").ForwardedPort(); err == nil {
+		t.Error("ForwardedPort() error = nil, want rejected API key")
+	}
+}
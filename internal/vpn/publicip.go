@@ -0,0 +1,104 @@
+package vpn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// lookupTimeout bounds a public IP request to the echo endpoint
+const lookupTimeout = 5 * time.Second
+
+// maxResponseSize caps how much of an echo endpoint response is read
+const maxResponseSize = 4096
+
+// IPLookup finds the public IP address of the VPN tunnel, either from a
+// file written by the VPN container (such as Gluetun's PUBLICIP_FILE) or from
+// an IP-echo endpoint reached through the tunnel
+type IPLookup struct {
+	file   string
+	url    string
+	client *http.Client
+}
+
+// NewIPLookup creates a lookup reading the IP from file, or requesting it
+// from url when file is empty. It returns nil if neither is set.
+func NewIPLookup(file, url string) *IPLookup {
+	if file == "" && url == "" {
+		return nil
+	}
+	return &IPLookup{
+		file:   file,
+		url:    url,
+		client: &http.Client{Timeout: lookupTimeout},
+	}
+}
+
+// PublicIP returns the current public IP address of the tunnel
+func (l *IPLookup) PublicIP() (string, error) {
+	var data []byte
+	var err error
+	if l.file != "" {
+		data, err = os.ReadFile(l.file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read public IP file: %w", err)
+		}
+	} else {
+		data, err = l.fetch()
+		if err != nil {
+			return "", err
+		}
+	}
+	return parseIP(data)
+}
+
+// fetch requests the echo endpoint
+func (l *IPLookup) fetch() ([]byte, error) {
+	resp, err := l.client.Get(l.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request public IP: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("public IP endpoint returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public IP response: %w", err)
+	}
+	return data, nil
+}
+
+// parseIP extracts the address from a plain text response or a JSON object
+// with a "public_ip" (Gluetun control server) or "ip" (ipify, ipinfo) field
+func parseIP(data []byte) (string, error) {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "{") {
+		var body struct {
+			PublicIP string `json:"public_ip"`
+			IP       string `json:"ip"`
+		}
+		if err := json.Unmarshal([]byte(text), &body); err != nil {
+			return "", fmt.Errorf("failed to decode public IP response: %w", err)
+		}
+		text = body.PublicIP
+		if text == "" {
+			text = body.IP
+		}
+	}
+
+	if text == "" {
+		return "", errors.New("public IP is empty")
+	}
+	if net.ParseIP(text) == nil {
+		return "", fmt.Errorf("invalid public IP %q", text)
+	}
+	return text, nil
+}
@@ -0,0 +1,65 @@
+package vpn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIPLookup_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ip")
+	if err := os.WriteFile(file, []byte("203.0.113.7\n"), 0o644); err != nil {
+		t.Fatalf("failed to write IP file: %v", err)
+	}
+
+	ip, err := NewIPLookup(file, "http://unused.invalid").PublicIP()
+	if err != nil {
+		t.Fatalf("PublicIP() error = %v", err)
+	}
+	if ip != "203.0.113.7" {
+		t.Errorf("PublicIP() = %q, want 203.0.113.7", ip)
+	}
+}
+
+func TestIPLookup_Endpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", body: "203.0.113.7\n", status: http.StatusOK, want: "203.0.113.7"},
+		{name: "gluetun", body: `{"public_ip":"203.0.113.7","country":"Netherlands"}`, status: http.StatusOK, want: "203.0.113.7"},
+		{name: "ipify", body: `{"ip":"2001:db8::1"}`, status: http.StatusOK, want: "2001:db8::1"},
+		{name: "not an IP", body: "<html>", status: http.StatusOK, wantErr: true},
+		{name: "empty", body: "", status: http.StatusOK, wantErr: true},
+		{name: "error status", body: "203.0.113.7", status: http.StatusBadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			ip, err := NewIPLookup("", server.URL).PublicIP()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PublicIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ip != tt.want {
+				t.Errorf("PublicIP() = %q, want %q", ip, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIPLookup_Disabled(t *testing.T) {
+	if l := NewIPLookup("", ""); l != nil {
+		t.Errorf("NewIPLookup() = %v, want nil", l)
+	}
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// AppriseOptions configures the Apprise API template. The target URL is the
+// Apprise API notify endpoint: /notify for stateless requests that carry their
+// own service URLs, or /notify/{key} to use a configuration stored on the
+// Apprise server.
+type AppriseOptions struct {
+	// URLs are Apprise service URLs (e.g. tgram://, mailto://) to notify.
+	// They are required by the stateless /notify endpoint.
+	URLs []string
+	// Tag restricts a stored configuration to the services with this tag
+	Tag string
+}
+
+// formatApprise formats payload for the Apprise API notify endpoint
+func (c *Client) formatApprise(payload Payload) ([]byte, error) {
+	apprise := map[string]interface{}{
+		"title": payloadTitle(payload),
+		"body":  payload.Message,
+		"type":  "info",
+	}
+	if len(c.apprise.URLs) > 0 {
+		apprise["urls"] = strings.Join(c.apprise.URLs, ",")
+	}
+	if c.apprise.Tag != "" {
+		apprise["tag"] = c.apprise.Tag
+	}
+	return json.Marshal(apprise)
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AuthOptions holds the credentials a client sends in the Authorization
+// header. Bearer and basic authentication are mutually exclusive.
+type AuthOptions struct {
+	BearerToken string
+	Username    string
+	Password    string
+}
+
+func (o AuthOptions) validate() error {
+	if o.BearerToken != "" && (o.Username != "" || o.Password != "") {
+		return fmt.Errorf("bearer token and basic auth credentials are mutually exclusive")
+	}
+	if o.Password != "" && o.Username == "" {
+		return fmt.Errorf("basic auth password requires a username")
+	}
+	return nil
+}
+
+// apply sets the Authorization header of req if credentials are configured
+func (o AuthOptions) apply(req *http.Request) {
+	switch {
+	case o.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+o.BearerToken)
+	case o.Username != "":
+		req.SetBasicAuth(o.Username, o.Password)
+	}
+}
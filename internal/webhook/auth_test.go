@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientSend_Auth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    AuthOptions
+		headers map[string]string
+		want    string
+	}{
+		{name: "none", want: ""},
+		{name: "bearer", auth: AuthOptions{BearerToken: "abc123"}, want: "Bearer abc123"},
+		{name: "basic", auth: AuthOptions{Username: "forwardarr", Password: "s3cr3t"}, want: "Basic Zm9yd2FyZGFycjpzM2NyM3Q="},
+		{
+			name:    "custom header overrides",
+			auth:    AuthOptions{BearerToken: "abc123"},
+			headers: map[string]string{"Authorization": "Token xyz"},
+			want:    "Token xyz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Auth: tt.auth, Headers: tt.headers})
+			if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+				t.Fatalf("SendPortChange() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    AuthOptions
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "bearer", auth: AuthOptions{BearerToken: "abc"}},
+		{name: "basic without password", auth: AuthOptions{Username: "user"}},
+		{name: "bearer and basic", auth: AuthOptions{BearerToken: "abc", Username: "user"}, wantErr: true},
+		{name: "password without username", auth: AuthOptions{Password: "pass"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.auth.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batcher collects the notifications of a window that starts with the first
+// notification and hands them to flush when it ends
+type batcher struct {
+	window time.Duration
+	flush  func([]Payload)
+
+	mu      sync.Mutex
+	pending []Payload
+	timer   *time.Timer
+	stopped bool
+}
+
+// add queues the payload for the current window and reports false once the
+// batcher is stopped
+func (b *batcher) add(payload Payload) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return false
+	}
+	b.pending = append(b.pending, payload)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.fire)
+	}
+	return true
+}
+
+// fire ends the current window
+func (b *batcher) fire() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.timer = nil
+	b.mu.Unlock()
+
+	if len(pending) > 0 {
+		b.flush(pending)
+	}
+}
+
+// stop ends batching and returns the notifications of the open window
+func (b *batcher) stop() []Payload {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	pending := b.pending
+	b.pending = nil
+	return pending
+}
+
+// newBatchPayload summarizes several notifications in one payload. A single
+// notification is returned unchanged.
+func newBatchPayload(entries []Payload) Payload {
+	if len(entries) == 1 {
+		return entries[0]
+	}
+
+	last := entries[len(entries)-1]
+	messages := make([]string, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.Message
+	}
+	payload := Payload{
+		Event:     EventBatch,
+		Timestamp: last.Timestamp,
+		Message:   fmt.Sprintf("%d events: %s", len(entries), strings.Join(messages, "; ")),
+		Batch:     entries,
+		Instance:  last.Instance,
+		Hostname:  last.Hostname,
+		Version:   last.Version,
+		Label:     last.Label,
+	}
+	// Port changes within the batch are summarized as a single change
+	for _, entry := range entries {
+		if entry.Event != EventPortChanged {
+			continue
+		}
+		if payload.OldPort == 0 {
+			payload.OldPort = entry.OldPort
+		}
+		payload.NewPort = entry.NewPort
+		payload.PublicIP = entry.PublicIP
+		payload.Provider = entry.Provider
+	}
+	return payload
+}
+
+// filterEvents applies a target's event filter and minimum severity to the
+// payload. Batches keep only the enabled notifications; ok is false if
+// nothing is left to send.
+func filterEvents(events map[string]bool, minSeverity Severity, payload Payload) (filtered Payload, ok bool) {
+	if payload.Event != EventBatch {
+		return payload, eventEnabled(events, minSeverity, payload.Event)
+	}
+
+	var entries []Payload
+	for _, entry := range payload.Batch {
+		if eventEnabled(events, minSeverity, entry.Event) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return Payload{}, false
+	}
+	return newBatchPayload(entries), true
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSender records payloads and signals every delivery
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Payload
+	done chan struct{}
+}
+
+func (s *recordingSender) Name() string { return "recorder" }
+
+func (s *recordingSender) Send(ctx context.Context, payload Payload) error {
+	s.mu.Lock()
+	s.sent = append(s.sent, payload)
+	s.mu.Unlock()
+	s.done <- struct{}{}
+	return nil
+}
+
+func TestDispatcherSetBatching_Coalesces(t *testing.T) {
+	sender := &recordingSender{done: make(chan struct{}, 1)}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetBatching(50 * time.Millisecond)
+
+	if err := dispatcher.SendStartup(t.Context(), "1.0.0", 8080); err != nil {
+		t.Fatalf("SendStartup() error = %v", err)
+	}
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	if err := dispatcher.SendSyncRecovered(t.Context(), 9090, 2, time.Minute); err != nil {
+		t.Fatalf("SendSyncRecovered() error = %v", err)
+	}
+
+	select {
+	case <-sender.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch was not delivered")
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1 batch", len(sender.sent))
+	}
+	batch := sender.sent[0]
+	if batch.Event != EventBatch || len(batch.Batch) != 3 {
+		t.Fatalf("payload = %s with %d entries, want batch with 3", batch.Event, len(batch.Batch))
+	}
+	if batch.OldPort != 8080 || batch.NewPort != 9090 {
+		t.Errorf("batch ports = %d -> %d, want 8080 -> 9090", batch.OldPort, batch.NewPort)
+	}
+}
+
+func TestDispatcherClose_FlushesBatch(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetBatching(time.Hour)
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	if len(sender.sent) != 0 {
+		t.Fatal("notification delivered before the batch window ended")
+	}
+	if err := dispatcher.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Event != EventPortChanged {
+		t.Fatalf("sent = %v, want the single port change", sender.sent)
+	}
+
+	if err := dispatcher.SendShutdown(t.Context(), "1.0.0", 9090); err != nil {
+		t.Fatalf("SendShutdown() error = %v", err)
+	}
+	if len(sender.sent) != 2 {
+		t.Error("notification after Close was not delivered immediately")
+	}
+}
+
+func TestFilterEvents_Batch(t *testing.T) {
+	batch := newBatchPayload([]Payload{
+		newLifecyclePayload(EventStartup, "1.0.0", nil, 0),
+		newPortChangePayload(8080, 9090),
+		newSyncErrorPayload("qbittorrent", errors.New("timeout"), 1),
+	})
+
+	tests := []struct {
+		name      string
+		events    []string
+		wantOK    bool
+		wantEvent string
+	}{
+		{name: "no filter", wantOK: true, wantEvent: EventBatch},
+		{name: "single entry left", events: []string{EventPortChanged}, wantOK: true, wantEvent: EventPortChanged},
+		{name: "nothing left", events: []string{EventVPNDown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newEventFilter(tt.events)
+			if err != nil {
+				t.Fatalf("newEventFilter() error = %v", err)
+			}
+			got, ok := filterEvents(filter, SeverityInfo, batch)
+			if ok != tt.wantOK {
+				t.Fatalf("filterEvents() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got.Event != tt.wantEvent {
+				t.Errorf("filterEvents() event = %s, want %s", got.Event, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestNewEventFilter_RejectsBatch(t *testing.T) {
+	if _, err := newEventFilter([]string{EventBatch}); err == nil {
+		t.Error("newEventFilter() error = nil, want error for the batch event")
+	}
+}
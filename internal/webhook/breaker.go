@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for targets that are skipped because they failed
+// too often in a row
+var ErrCircuitOpen = errors.New("target suspended after repeated failures")
+
+// breaker is a per-target circuit breaker. After threshold consecutive failed
+// deliveries the target is skipped until cooldown has passed; the next
+// delivery then probes the target and either closes the breaker or reopens it
+// for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// allow reports whether a delivery may be attempted at now
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+// suspendedUntil returns the end of the cooldown if the target is suspended
+// at now, or the zero time
+func (b *breaker) suspendedUntil(now time.Time) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.openUntil) {
+		return b.openUntil
+	}
+	return time.Time{}
+}
+
+// record updates the breaker with the result of a delivery and reports
+// whether the breaker opened because of it
+func (b *breaker) record(err error, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return false
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = now.Add(b.cooldown)
+	return true
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{threshold: 2, cooldown: time.Minute}
+	now := time.Now()
+	failure := errors.New("timeout")
+
+	if b.record(failure, now) {
+		t.Fatal("breaker opened after 1 failure, want threshold 2")
+	}
+	if !b.record(failure, now) {
+		t.Fatal("breaker did not open after 2 failures")
+	}
+	if b.allow(now.Add(30 * time.Second)) {
+		t.Error("allow() = true during cooldown")
+	}
+	if !b.allow(now.Add(time.Minute)) {
+		t.Fatal("allow() = false after cooldown")
+	}
+
+	// A failed probe reopens the breaker immediately
+	probe := now.Add(time.Minute)
+	if !b.record(failure, probe) {
+		t.Error("breaker did not reopen after failed probe")
+	}
+	if b.allow(probe.Add(time.Second)) {
+		t.Error("allow() = true after failed probe")
+	}
+
+	// A successful delivery resets the failure count
+	b.record(nil, probe.Add(time.Minute))
+	if b.record(failure, probe.Add(time.Minute)) {
+		t.Error("breaker opened after 1 failure following success")
+	}
+}
+
+func TestDispatcherCircuitBreakerSkipsFailingTarget(t *testing.T) {
+	down := &fakeSender{name: "down", err: errors.New("timeout")}
+	up := &fakeSender{name: "up"}
+
+	dispatcher := NewDispatcher(down, up)
+	dispatcher.SetCircuitBreaker(2, time.Hour)
+
+	for range 4 {
+		_ = dispatcher.SendPortChange(t.Context(), 8080, 9090)
+	}
+
+	if down.calls != 2 {
+		t.Errorf("failing target called %d times, want 2 before being suspended", down.calls)
+	}
+	if len(up.sent) != 5 {
+		t.Fatalf("healthy target got %d payloads, want 4 port changes and 1 suspension", len(up.sent))
+	}
+	suspended := up.sent[2]
+	if suspended.Event != EventTargetSuspended || suspended.Component != "down" || suspended.Attempt != 2 {
+		t.Errorf("suspension payload = %+v", suspended)
+	}
+
+	err := dispatcher.SendPortChange(t.Context(), 9090, 9091)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("SendPortChange() error = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestDispatcherCircuitBreakerQueuesSkippedDeliveries(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	down := &fakeSender{name: "down", err: errors.New("timeout")}
+
+	dispatcher := NewDispatcher(down)
+	dispatcher.SetQueue(queue)
+	dispatcher.SetCircuitBreaker(1, time.Hour)
+
+	_ = dispatcher.SendPortChange(t.Context(), 8080, 9090)
+	_ = dispatcher.SendPortChange(t.Context(), 9090, 9091)
+
+	if down.calls != 1 {
+		t.Errorf("failing target called %d times, want 1", down.calls)
+	}
+	if n, err := queue.Len(); err != nil || n != 2 {
+		t.Errorf("queue.Len() = %d, %v, want 2", n, err)
+	}
+}
+
+func TestDispatcherCircuitBreakerDisabled(t *testing.T) {
+	down := &fakeSender{name: "down", err: errors.New("timeout")}
+
+	dispatcher := NewDispatcher(down)
+	dispatcher.SetCircuitBreaker(0, time.Hour)
+
+	for range 3 {
+		_ = dispatcher.SendPortChange(t.Context(), 8080, 9090)
+	}
+	if down.calls != 3 {
+		t.Errorf("target called %d times, want 3 with breaker disabled", down.calls)
+	}
+}
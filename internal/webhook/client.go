@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -15,19 +17,87 @@ import (
 type Template string
 
 const (
-	TemplateJSON    Template = "json"
-	TemplateDiscord Template = "discord"
-	TemplateSlack   Template = "slack"
-	TemplateGotify  Template = "gotify"
+	TemplateJSON     Template = "json"
+	TemplateDiscord  Template = "discord"
+	TemplateSlack    Template = "slack"
+	TemplateGotify   Template = "gotify"
+	TemplateCustom   Template = "custom"
+	TemplatePushover Template = "pushover"
+	TemplateNtfy     Template = "ntfy"
+	TemplateMatrix   Template = "matrix"
+	TemplateApprise  Template = "apprise"
+	// TemplatePagerDuty only delivers alerting events; see pagerDutyHandles
+	TemplatePagerDuty Template = "pagerduty"
 )
 
-// Client handles sending webhook notifications
+// Target describes a single webhook destination
+type Target struct {
+	Name     string
+	URL      string
+	Template Template
+	Timeout  time.Duration
+	Events   []string
+	// MinSeverity drops events below the given severity (info, warning or
+	// error); empty delivers all
+	MinSeverity string
+	Secret      string
+	Headers     map[string]string
+	// Method overrides the HTTP method of the template, e.g. PUT for
+	// receivers that do not accept POST
+	Method string
+	// ContentType selects the body encoding of the json template: json
+	// (default) or form. FormFields maps form keys to payload fields; all
+	// payload fields are sent when it is empty.
+	ContentType string
+	FormFields  map[string]string
+	// CustomTemplate is the Go text/template used to render the request
+	// body when Template is TemplateCustom. It is executed with the Payload.
+	CustomTemplate string
+	Pushover       PushoverOptions
+	Ntfy           NtfyOptions
+	Matrix         MatrixOptions
+	Apprise        AppriseOptions
+	PagerDuty      PagerDutyOptions
+	Email          EmailOptions
+	MQTT           MQTTOptions
+	Retry          RetryPolicy
+	// Proxy is the URL of an HTTP, HTTPS or SOCKS5 proxy for HTTP targets.
+	// When empty, the standard proxy environment variables are used.
+	Proxy string
+	TLS   TLSOptions
+	Auth  AuthOptions
+	// Discord customizes embeds sent with the discord template
+	Discord DiscordOptions
+	Slack   SlackOptions
+	Gotify  GotifyOptions
+}
+
+// Client handles sending webhook notifications to a single target
 type Client struct {
-	url      string
-	timeout  time.Duration
-	template Template
-	events   map[string]bool
-	client   *http.Client
+	name        string
+	url         string
+	timeout     time.Duration
+	template    Template
+	events      map[string]bool
+	minSeverity Severity
+	secret      string
+	headers     map[string]string
+	method      string
+	form        bool
+	// formFields maps form keys to the JSON names of payload fields
+	formFields map[string]string
+	body       *template.Template
+	pushover   PushoverOptions
+	ntfy       NtfyOptions
+	matrix     MatrixOptions
+	apprise    AppriseOptions
+	pagerduty  PagerDutyOptions
+	auth       AuthOptions
+	discord    DiscordOptions
+	slack      SlackOptions
+	gotify     GotifyOptions
+	retry      RetryPolicy
+	client     *http.Client
 }
 
 // Payload represents the webhook notification payload
@@ -37,78 +107,254 @@ type Payload struct {
 	OldPort   int       `json:"old_port"`
 	NewPort   int       `json:"new_port"`
 	Message   string    `json:"message"`
+	// Title is set when the built-in title of the event is overridden
+	Title string `json:"title,omitempty"`
+	// Error, Component and Attempt describe failures (sync_error); on
+	// sync_recovered Attempt is the number of failed syncs that preceded it
+	Error           string  `json:"error,omitempty"`
+	Component       string  `json:"component,omitempty"`
+	Attempt         int     `json:"attempt,omitempty"`
+	DowntimeSeconds float64 `json:"downtime_seconds,omitempty"`
+	// Targets and CurrentPort describe the instance on startup and shutdown
+	Targets     []string `json:"targets,omitempty"`
+	CurrentPort int      `json:"current_port,omitempty"`
+	// PublicIP and Provider describe the VPN egress of a port change
+	PublicIP string `json:"public_ip,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// Instance, Hostname, Version and Label identify the sending instance;
+	// see Instance
+	Instance string `json:"instance,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Label    string `json:"label,omitempty"`
+	// Batch holds the notifications summarized by a batch event
+	Batch []Payload `json:"events,omitempty"`
 }
 
-// NewClient creates a new webhook client
-func NewClient(url string, timeout time.Duration, template Template, events []string) *Client {
-	eventMap := make(map[string]bool)
-	for _, event := range events {
-		eventMap[strings.TrimSpace(event)] = true
+// eventEnabled reports whether an event passes a target's event filter and
+// minimum severity. An empty filter enables all events; test notifications
+// are always enabled.
+func eventEnabled(events map[string]bool, minSeverity Severity, event string) bool {
+	if event == EventTest {
+		return true
 	}
+	return (len(events) == 0 || events[event]) && eventSeverity(event) >= minSeverity
+}
 
-	return &Client{
-		url:      url,
-		timeout:  timeout,
-		template: template,
-		events:   eventMap,
-		client:   &http.Client{},
+// NewClient creates a new webhook client for the given target
+func NewClient(target Target) (*Client, error) {
+	eventMap, err := newEventFilter(target.Events)
+	if err != nil {
+		return nil, err
+	}
+	minSeverity, err := ParseSeverity(target.MinSeverity)
+	if err != nil {
+		return nil, err
 	}
+
+	var body *template.Template
+	switch target.Template {
+	case TemplateCustom:
+		body, err = parseCustomTemplate(target.Name, target.CustomTemplate)
+		if err != nil {
+			return nil, err
+		}
+	case TemplateDiscord:
+		if err := target.Discord.validate(); err != nil {
+			return nil, err
+		}
+	case TemplateGotify:
+		if err := target.Gotify.validate(); err != nil {
+			return nil, err
+		}
+	case TemplatePushover:
+		if err := target.Pushover.validate(); err != nil {
+			return nil, err
+		}
+	case TemplateNtfy:
+		if err := target.Ntfy.validate(); err != nil {
+			return nil, err
+		}
+	case TemplateMatrix:
+		if err := target.Matrix.validate(); err != nil {
+			return nil, err
+		}
+	case TemplatePagerDuty:
+		if err := target.PagerDuty.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	method := strings.ToUpper(target.Method)
+	if err := validateMethod(method); err != nil {
+		return nil, err
+	}
+	if err := validateContentType(target.Template, target.ContentType, target.FormFields); err != nil {
+		return nil, err
+	}
+	if err := target.Auth.validate(); err != nil {
+		return nil, err
+	}
+	httpClient, err := newHTTPClient(target.Proxy, target.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		name:        target.Name,
+		url:         target.URL,
+		timeout:     target.Timeout,
+		template:    target.Template,
+		events:      eventMap,
+		minSeverity: minSeverity,
+		secret:      target.Secret,
+		headers:     target.Headers,
+		method:      method,
+		form:        target.ContentType == ContentTypeForm,
+		formFields:  target.FormFields,
+		body:        body,
+		pushover:    target.Pushover,
+		ntfy:        target.Ntfy,
+		matrix:      target.Matrix,
+		apprise:     target.Apprise,
+		pagerduty:   target.PagerDuty,
+		auth:        target.Auth,
+		discord:     target.Discord,
+		slack:       target.Slack,
+		gotify:      target.Gotify,
+		retry:       target.Retry,
+		client:      httpClient,
+	}, nil
+}
+
+// Name returns the configured name of the webhook target
+func (c *Client) Name() string {
+	return c.name
 }
 
 // SendPortChange sends a port change notification
-func (c *Client) SendPortChange(oldPort, newPort int) error {
-	event := "port_changed"
+func (c *Client) SendPortChange(ctx context.Context, oldPort, newPort int) error {
+	return c.Send(ctx, newPortChangePayload(oldPort, newPort))
+}
+
+// SendTest sends a test notification with fake ports, regardless of the
+// event filter, and returns the delivery error
+func (c *Client) SendTest(ctx context.Context) error {
+	return c.Send(ctx, newTestPayload())
+}
 
-	// Check if this event is enabled
-	if len(c.events) > 0 && !c.events[event] {
-		slog.Debug("webhook event filtered out", "event", event)
+// Send delivers the payload unless its event is filtered out for this
+// target. Canceling ctx aborts the request in flight and further retries.
+func (c *Client) Send(ctx context.Context, payload Payload) error {
+	filtered, ok := filterEvents(c.events, c.minSeverity, payload)
+	if !ok {
+		slog.Debug("webhook event filtered out", "webhook", c.name, "event", payload.Event)
 		return nil
 	}
-
-	payload := Payload{
-		Event:     event,
-		Timestamp: time.Now().UTC(),
-		OldPort:   oldPort,
-		NewPort:   newPort,
-		Message:   fmt.Sprintf("Port changed from %d to %d", oldPort, newPort),
+	payload = filtered
+	if c.template == TemplatePagerDuty && payload.Event == EventBatch {
+		// PagerDuty needs one event per alert, so batches are sent one by one
+		var errs []error
+		for _, entry := range payload.Batch {
+			errs = append(errs, c.Send(ctx, entry))
+		}
+		return errors.Join(errs...)
+	}
+	if c.template == TemplatePagerDuty && !pagerDutyHandles(payload.Event) {
+		slog.Debug("event is not an alert, skipping pagerduty", "webhook", c.name, "event", payload.Event)
+		return nil
 	}
 
-	return c.send(payload)
+	return c.send(ctx, payload)
 }
 
-// send sends the webhook payload to the configured URL
-func (c *Client) send(payload Payload) error {
-	var jsonData []byte
-	var err error
+// request is a formatted webhook delivery
+type request struct {
+	method      string
+	url         string
+	body        []byte
+	contentType string
+	// header holds template-specific headers; user-configured headers are
+	// applied on top of them
+	header map[string]string
+}
+
+// format builds the request for the payload based on the client's template
+func (c *Client) format(payload Payload) (request, error) {
+	req := request{method: http.MethodPost, url: c.url, contentType: "application/json"}
 
-	// Format payload based on template
+	var err error
 	switch c.template {
 	case TemplateDiscord:
-		jsonData, err = c.formatDiscord(payload)
+		req, err = c.formatDiscord(payload)
 	case TemplateSlack:
-		jsonData, err = c.formatSlack(payload)
+		req.body, err = c.formatSlack(payload)
 	case TemplateGotify:
-		jsonData, err = c.formatGotify(payload)
+		req, err = c.formatGotify(payload)
+	case TemplateCustom:
+		req.body, err = c.formatCustom(payload)
+	case TemplatePushover:
+		req.body, err = c.formatPushover(payload)
+	case TemplateNtfy:
+		req = c.formatNtfy(payload)
+	case TemplateMatrix:
+		req, err = c.formatMatrix(payload)
+	case TemplateApprise:
+		req.body, err = c.formatApprise(payload)
+	case TemplatePagerDuty:
+		req.body, err = c.formatPagerDuty(payload)
 	default:
-		jsonData, err = json.Marshal(payload)
+		if c.form {
+			req.contentType = "application/x-www-form-urlencoded"
+			req.body, err = c.formatForm(payload)
+		} else {
+			req.body, err = json.Marshal(payload)
+		}
 	}
 
+	if c.method != "" {
+		req.method = c.method
+	}
+	return req, err
+}
+
+// send sends the webhook payload to the configured URL, retrying failed
+// deliveries according to the client's retry policy
+func (c *Client) send(ctx context.Context, payload Payload) error {
+	req, err := c.format(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+		return &PermanentError{Err: fmt.Errorf("failed to format webhook payload: %w", err)}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	return c.retry.run(ctx, c.name, func() error {
+		return c.post(ctx, req, payload.Event)
+	})
+}
+
+// post performs a single delivery attempt of an already formatted payload
+func (c *Client) post(ctx context.Context, r request, event string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, bytes.NewReader(r.body))
 	if err != nil {
-		return fmt.Errorf("failed to create webhook request: %w", err)
+		return &PermanentError{Err: fmt.Errorf("failed to create webhook request: %w", err)}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", r.contentType)
 	req.Header.Set("User-Agent", "Forwardarr-Webhook/1.0")
+	for key, value := range r.header {
+		req.Header.Set(key, value)
+	}
+	c.auth.apply(req)
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if c.secret != "" {
+		req.Header.Set(SignatureHeader, sign(c.secret, r.body))
+	}
 
-	slog.Debug("sending webhook", "url", c.url, "event", payload.Event, "template", c.template)
+	slog.Debug("sending webhook", "webhook", c.name, "url", r.url, "event", event, "template", c.template)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -121,96 +367,17 @@ func (c *Client) send(payload Payload) error {
 	}()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook returned non-2xx status: %d", resp.StatusCode)
+		statusErr := newStatusError(resp)
+		slog.Debug("webhook rejected",
+			"webhook", c.name,
+			"event", event,
+			"status", resp.StatusCode,
+			"body", statusErr.Body,
+			"headers", statusErr.Header,
+		)
+		return statusErr
 	}
 
-	slog.Info("webhook sent successfully", "url", c.url, "status", resp.StatusCode)
+	slog.Info("webhook sent successfully", "webhook", c.name, "url", c.url, "status", resp.StatusCode)
 	return nil
 }
-
-// formatDiscord formats payload for Discord webhook
-func (c *Client) formatDiscord(payload Payload) ([]byte, error) {
-	discord := map[string]interface{}{
-		"content": payload.Message,
-		"embeds": []map[string]interface{}{
-			{
-				"title":       "Port Change Notification",
-				"description": payload.Message,
-				"color":       3447003, // Blue color
-				"fields": []map[string]interface{}{
-					{
-						"name":   "Event",
-						"value":  payload.Event,
-						"inline": true,
-					},
-					{
-						"name":   "Old Port",
-						"value":  fmt.Sprintf("%d", payload.OldPort),
-						"inline": true,
-					},
-					{
-						"name":   "New Port",
-						"value":  fmt.Sprintf("%d", payload.NewPort),
-						"inline": true,
-					},
-				},
-				"timestamp": payload.Timestamp.Format(time.RFC3339),
-			},
-		},
-	}
-	return json.Marshal(discord)
-}
-
-// formatSlack formats payload for Slack webhook
-func (c *Client) formatSlack(payload Payload) ([]byte, error) {
-	slack := map[string]interface{}{
-		"text": payload.Message,
-		"blocks": []map[string]interface{}{
-			{
-				"type": "section",
-				"text": map[string]string{
-					"type": "mrkdwn",
-					"text": fmt.Sprintf("*%s*\n%s", "Port Change Notification", payload.Message),
-				},
-			},
-			{
-				"type": "section",
-				"fields": []map[string]string{
-					{
-						"type": "mrkdwn",
-						"text": fmt.Sprintf("*Event:*\n%s", payload.Event),
-					},
-					{
-						"type": "mrkdwn",
-						"text": fmt.Sprintf("*Old Port:*\n%d", payload.OldPort),
-					},
-					{
-						"type": "mrkdwn",
-						"text": fmt.Sprintf("*New Port:*\n%d", payload.NewPort),
-					},
-					{
-						"type": "mrkdwn",
-						"text": fmt.Sprintf("*Time:*\n%s", payload.Timestamp.Format(time.RFC3339)),
-					},
-				},
-			},
-		},
-	}
-	return json.Marshal(slack)
-}
-
-// formatGotify formats payload for Gotify webhook
-func (c *Client) formatGotify(payload Payload) ([]byte, error) {
-	gotify := map[string]interface{}{
-		"title":    "Port Change Notification",
-		"message":  payload.Message,
-		"priority": 5,
-		"extras": map[string]interface{}{
-			"event":     payload.Event,
-			"old_port":  payload.OldPort,
-			"new_port":  payload.NewPort,
-			"timestamp": payload.Timestamp.Format(time.RFC3339),
-		},
-	}
-	return json.Marshal(gotify)
-}
@@ -13,8 +13,23 @@ func TestNewClient(t *testing.T) {
 	timeout := 5 * time.Second
 	template := TemplateJSON
 	events := []string{"port_changed"}
+	retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+	client, err := NewClient(Target{
+		Name:     "discord",
+		URL:      url,
+		Timeout:  timeout,
+		Template: template,
+		Events:   events,
+		Retry:    retry,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v, want nil", err)
+	}
 
-	client := NewClient(url, timeout, template, events)
+	if client.Name() != "discord" {
+		t.Errorf("client.Name() = %v, want discord", client.Name())
+	}
 
 	if client.url != url {
 		t.Errorf("client.url = %v, want %v", client.url, url)
@@ -28,11 +43,24 @@ func TestNewClient(t *testing.T) {
 	if len(client.events) != len(events) {
 		t.Errorf("client.events length = %d, want %d", len(client.events), len(events))
 	}
+	if client.retry != retry {
+		t.Errorf("client.retry = %+v, want %+v", client.retry, retry)
+	}
 	if client.client == nil {
 		t.Error("client.client is nil, want non-nil")
 	}
 }
 
+func newTestClient(t *testing.T, target Target) *Client {
+	t.Helper()
+
+	client, err := NewClient(target)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
 func TestSendPortChange_Success(t *testing.T) {
 	var receivedPayload Payload
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -54,8 +82,8 @@ func TestSendPortChange_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient(server.URL, 5*time.Second, TemplateJSON, []string{"port_changed"})
-	err := client.SendPortChange(8080, 9090)
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
 
 	if err != nil {
 		t.Errorf("SendPortChange() error = %v, want nil", err)
@@ -78,14 +106,46 @@ func TestSendPortChange_Success(t *testing.T) {
 	}
 }
 
+func TestSendPortChange_CustomHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:      server.URL,
+		Timeout:  5 * time.Second,
+		Template: TemplateJSON,
+		Headers: map[string]string{
+			"X-Gotify-Key": "app-token",
+			"User-Agent":   "custom-agent",
+		},
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	if got := received.Get("X-Gotify-Key"); got != "app-token" {
+		t.Errorf("X-Gotify-Key = %q, want %q", got, "app-token")
+	}
+	if got := received.Get("User-Agent"); got != "custom-agent" {
+		t.Errorf("User-Agent = %q, want %q (custom headers override defaults)", got, "custom-agent")
+	}
+	if got := received.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
 func TestSendPortChange_ServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer server.Close()
 
-	client := NewClient(server.URL, 5*time.Second, TemplateJSON, []string{"port_changed"})
-	err := client.SendPortChange(8080, 9090)
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
 
 	if err == nil {
 		t.Error("SendPortChange() error = nil, want error")
@@ -99,8 +159,8 @@ func TestSendPortChange_Timeout(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient(server.URL, 10*time.Millisecond, TemplateJSON, []string{"port_changed"})
-	err := client.SendPortChange(8080, 9090)
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 10 * time.Millisecond, Template: TemplateJSON, Events: []string{"port_changed"}})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
 
 	if err == nil {
 		t.Error("SendPortChange() error = nil, want timeout error")
@@ -108,14 +168,58 @@ func TestSendPortChange_Timeout(t *testing.T) {
 }
 
 func TestSendPortChange_InvalidURL(t *testing.T) {
-	client := NewClient("http://[::1]:namedport", 5*time.Second, TemplateJSON, []string{"port_changed"})
-	err := client.SendPortChange(8080, 9090)
+	client := newTestClient(t, Target{URL: "http://[::1]:namedport", Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
 
 	if err == nil {
 		t.Error("SendPortChange() error = nil, want error")
 	}
 }
 
+func TestSendPortChange_RetriesUntilSuccess(t *testing.T) {
+	callCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		if callCount < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}, Retry: retry})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
+
+	if err != nil {
+		t.Errorf("SendPortChange() error = %v, want nil", err)
+	}
+	if callCount != 3 {
+		t.Errorf("webhook call count = %d, want 3", callCount)
+	}
+}
+
+func TestSendPortChange_RetriesExhausted(t *testing.T) {
+	callCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retry := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}, Retry: retry})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
+
+	if err == nil {
+		t.Error("SendPortChange() error = nil, want error")
+	}
+	if callCount != 4 {
+		t.Errorf("webhook call count = %d, want 4", callCount)
+	}
+}
+
 func TestSendPortChange_NonOKStatusCodes(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -135,8 +239,8 @@ func TestSendPortChange_NonOKStatusCodes(t *testing.T) {
 			}))
 			defer server.Close()
 
-			client := NewClient(server.URL, 5*time.Second, TemplateJSON, []string{"port_changed"})
-			err := client.SendPortChange(8080, 9090)
+			client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}})
+			err := client.SendPortChange(t.Context(), 8080, 9090)
 
 			if err == nil {
 				t.Errorf("SendPortChange() error = nil, want error for status %d", tt.statusCode)
@@ -163,8 +267,8 @@ func TestSendPortChange_SuccessStatusCodes(t *testing.T) {
 			}))
 			defer server.Close()
 
-			client := NewClient(server.URL, 5*time.Second, TemplateJSON, []string{"port_changed"})
-			err := client.SendPortChange(8080, 9090)
+			client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: []string{"port_changed"}})
+			err := client.SendPortChange(t.Context(), 8080, 9090)
 
 			if err != nil {
 				t.Errorf("SendPortChange() error = %v, want nil for status %d", err, tt.statusCode)
@@ -246,8 +350,8 @@ w.WriteHeader(http.StatusOK)
 }))
 defer server.Close()
 
-client := NewClient(server.URL, 5*time.Second, tt.template, []string{"port_changed"})
-err := client.SendPortChange(8080, 9090)
+client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: tt.template, Events: []string{"port_changed"}})
+err := client.SendPortChange(t.Context(), 8080, 9090)
 
 if err != nil {
 t.Errorf("SendPortChange() error = %v, want nil", err)
@@ -295,8 +399,8 @@ w.WriteHeader(http.StatusOK)
 }))
 defer server.Close()
 
-client := NewClient(server.URL, 5*time.Second, TemplateJSON, tt.events)
-err := client.SendPortChange(8080, 9090)
+client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Events: tt.events})
+err := client.SendPortChange(t.Context(), 8080, 9090)
 
 if err != nil {
 t.Errorf("SendPortChange() error = %v, want nil", err)
@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are available to user-defined payload templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value as JSON, which safely quotes and escapes strings
+	// embedded in a JSON body, e.g. {"text": {{json .Message}}}
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parseCustomTemplate parses a user-supplied payload template
+func parseCustomTemplate(name, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("custom template for webhook %q is empty", name)
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse custom template for webhook %q: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// formatCustom renders the payload with the user-supplied template
+func (c *Client) formatCustom(payload Payload) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.body.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("failed to render custom template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
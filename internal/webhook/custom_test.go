@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCustomTemplate_RendersPayload(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:            server.URL,
+		Timeout:        5 * time.Second,
+		Template:       TemplateCustom,
+		CustomTemplate: `{"text": {{json .Message}}, "port": {{.NewPort}}, "previous": {{.OldPort}}}`,
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	var got struct {
+		Text     string `json:"text"`
+		Port     int    `json:"port"`
+		Previous int    `json:"previous"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("rendered body is not valid JSON: %v (%s)", err, body)
+	}
+	if got.Text != "Port changed from 8080 to 9090" {
+		t.Errorf("text = %q, want %q", got.Text, "Port changed from 8080 to 9090")
+	}
+	if got.Port != 9090 {
+		t.Errorf("port = %d, want 9090", got.Port)
+	}
+	if got.Previous != 8080 {
+		t.Errorf("previous = %d, want 8080", got.Previous)
+	}
+}
+
+func TestCustomTemplate_PlainText(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:            server.URL,
+		Timeout:        5 * time.Second,
+		Template:       TemplateCustom,
+		CustomTemplate: `{{.Event}}: {{.OldPort}} -> {{.NewPort}}`,
+	})
+	if err := client.SendPortChange(t.Context(), 1000, 2000); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	if string(body) != "port_changed: 1000 -> 2000" {
+		t.Errorf("body = %q, want %q", body, "port_changed: 1000 -> 2000")
+	}
+}
+
+func TestCustomTemplate_InvalidTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", ""},
+		{"whitespace only", "   \n"},
+		{"syntax error", `{"port": {{.NewPort}`},
+		{"unknown function", `{{shout .Message}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(Target{Name: "custom", Template: TemplateCustom, CustomTemplate: tt.text})
+			if err == nil {
+				t.Error("NewClient() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestCustomTemplate_ExecutionError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:            server.URL,
+		Timeout:        5 * time.Second,
+		Template:       TemplateCustom,
+		CustomTemplate: `{{.MissingField}}`,
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err == nil {
+		t.Error("SendPortChange() error = nil, want error")
+	}
+	if calls != 0 {
+		t.Errorf("webhook calls = %d, want 0", calls)
+	}
+}
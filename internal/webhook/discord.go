@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// discordDefaultColor is the embed color used unless overridden (blue)
+const discordDefaultColor = 0x3498db
+
+// DiscordOptions configures the appearance of Discord embeds
+type DiscordOptions struct {
+	// Color of the embed as hex (#3498db, 0x3498db) or decimal
+	Color string
+	// Title replaces the per-event embed title
+	Title string
+	// Username and AvatarURL override the webhook's default identity
+	Username  string
+	AvatarURL string
+	// Mention is prepended to sync_error messages, e.g. <@&ROLE_ID> or
+	// <@USER_ID>
+	Mention string
+	// ThreadID posts into an existing thread of the channel; ThreadName
+	// creates a new post per notification in a forum channel
+	ThreadID   string
+	ThreadName string
+}
+
+func (o DiscordOptions) validate() error {
+	if o.ThreadID != "" && o.ThreadName != "" {
+		return fmt.Errorf("discord thread ID and thread name are mutually exclusive")
+	}
+	if o.ThreadID != "" {
+		if _, err := strconv.ParseUint(o.ThreadID, 10, 64); err != nil {
+			return fmt.Errorf("invalid discord thread ID %q: must be numeric", o.ThreadID)
+		}
+	}
+	_, err := o.color()
+	return err
+}
+
+// color returns the configured embed color
+func (o DiscordOptions) color() (int, error) {
+	if o.Color == "" {
+		return discordDefaultColor, nil
+	}
+
+	value, base := o.Color, 10
+	if hex, ok := strings.CutPrefix(value, "#"); ok {
+		value, base = hex, 16
+	} else if hex, ok := strings.CutPrefix(strings.ToLower(value), "0x"); ok {
+		value, base = hex, 16
+	}
+	color, err := strconv.ParseInt(value, base, 32)
+	if err != nil || color < 0 || color > 0xffffff {
+		return 0, fmt.Errorf("invalid discord color %q: must be hex like #3498db or decimal up to 16777215", o.Color)
+	}
+	return int(color), nil
+}
+
+// formatDiscord formats payload for Discord webhook
+func (c *Client) formatDiscord(payload Payload) (request, error) {
+	fields := []map[string]interface{}{}
+	for _, f := range payloadFields(payload) {
+		fields = append(fields, map[string]interface{}{
+			"name":   f.name,
+			"value":  f.value,
+			"inline": f.name != "Error",
+		})
+	}
+
+	color, err := c.discord.color()
+	if err != nil {
+		return request{}, err
+	}
+	title := c.discord.Title
+	if title == "" {
+		title = payloadTitle(payload)
+	}
+	content := payload.Message
+	if c.discord.Mention != "" && payload.Event == EventSyncError {
+		content = c.discord.Mention + " " + content
+	}
+
+	discord := map[string]interface{}{
+		"content": content,
+		"embeds": []map[string]interface{}{
+			{
+				"title":       title,
+				"description": payload.Message,
+				"color":       color,
+				"fields":      fields,
+				"timestamp":   payload.Timestamp.Format(time.RFC3339),
+			},
+		},
+	}
+	if c.discord.Username != "" {
+		discord["username"] = c.discord.Username
+	}
+	if c.discord.AvatarURL != "" {
+		discord["avatar_url"] = c.discord.AvatarURL
+	}
+	if c.discord.ThreadName != "" {
+		discord["thread_name"] = c.discord.ThreadName
+	}
+	body, err := json.Marshal(discord)
+	if err != nil {
+		return request{}, err
+	}
+
+	req := request{method: http.MethodPost, url: c.url, body: body, contentType: "application/json"}
+	if c.discord.ThreadID != "" {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			return request{}, fmt.Errorf("invalid discord URL: %w", err)
+		}
+		query := u.Query()
+		query.Set("thread_id", c.discord.ThreadID)
+		u.RawQuery = query.Encode()
+		req.url = u.String()
+	}
+	return req, nil
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDiscordOptionsColor(t *testing.T) {
+	tests := []struct {
+		color   string
+		want    int
+		wantErr bool
+	}{
+		{color: "", want: 3447003},
+		{color: "#ff0000", want: 0xff0000},
+		{color: "0x00FF00", want: 0x00ff00},
+		{color: "255", want: 255},
+		{color: "#1000000", wantErr: true},
+		{color: "blue", wantErr: true},
+		{color: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.color, func(t *testing.T) {
+			got, err := DiscordOptions{Color: tt.color}.color()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("color() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("color() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscordTemplate_Appearance(t *testing.T) {
+	client := newTestClient(t, Target{
+		Template: TemplateDiscord,
+		Discord: DiscordOptions{
+			Color:     "#e74c3c",
+			Title:     "Seedbox",
+			Username:  "Forwardarr",
+			AvatarURL: "https://example.com/avatar.png",
+			Mention:   "<@&123>",
+		},
+	})
+
+	var msg struct {
+		Content   string `json:"content"`
+		Username  string `json:"username"`
+		AvatarURL string `json:"avatar_url"`
+		Embeds    []struct {
+			Title string `json:"title"`
+			Color int    `json:"color"`
+		} `json:"embeds"`
+	}
+
+	req, err := client.formatDiscord(newSyncErrorPayload("qbittorrent", errors.New("timeout"), 1))
+	if err != nil {
+		t.Fatalf("formatDiscord() error = %v", err)
+	}
+	if err := json.Unmarshal(req.body, &msg); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if msg.Content != "<@&123> Failed to sync port (attempt 1): timeout" {
+		t.Errorf("content = %q, want mention prefix", msg.Content)
+	}
+	if msg.Username != "Forwardarr" || msg.AvatarURL != "https://example.com/avatar.png" {
+		t.Errorf("username = %q, avatar_url = %q", msg.Username, msg.AvatarURL)
+	}
+	if msg.Embeds[0].Title != "Seedbox" || msg.Embeds[0].Color != 0xe74c3c {
+		t.Errorf("embed title = %q, color = %d", msg.Embeds[0].Title, msg.Embeds[0].Color)
+	}
+
+	// Only sync errors mention
+	req, err = client.formatDiscord(newPortChangePayload(8080, 9090))
+	if err != nil {
+		t.Fatalf("formatDiscord() error = %v", err)
+	}
+	if err := json.Unmarshal(req.body, &msg); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if msg.Content != "Port changed from 8080 to 9090" {
+		t.Errorf("content = %q, want no mention for port changes", msg.Content)
+	}
+}
+
+func TestNewClient_InvalidDiscordColor(t *testing.T) {
+	_, err := NewClient(Target{Template: TemplateDiscord, Discord: DiscordOptions{Color: "blue"}})
+	if err == nil {
+		t.Error("NewClient() error = nil, want error for invalid color")
+	}
+}
+
+func TestDiscordTemplate_Threads(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		options        DiscordOptions
+		wantURL        string
+		wantThreadName string
+	}{
+		{
+			name:    "channel",
+			url:     "https://discord.com/api/webhooks/1/abc",
+			wantURL: "https://discord.com/api/webhooks/1/abc",
+		},
+		{
+			name:    "existing thread",
+			url:     "https://discord.com/api/webhooks/1/abc?wait=true",
+			options: DiscordOptions{ThreadID: "123456789012345678"},
+			wantURL: "https://discord.com/api/webhooks/1/abc?thread_id=123456789012345678&wait=true",
+		},
+		{
+			name:           "forum post",
+			url:            "https://discord.com/api/webhooks/1/abc",
+			options:        DiscordOptions{ThreadName: "Port changes"},
+			wantURL:        "https://discord.com/api/webhooks/1/abc",
+			wantThreadName: "Port changes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, Target{URL: tt.url, Template: TemplateDiscord, Discord: tt.options})
+			req, err := client.formatDiscord(newPortChangePayload(8080, 9090))
+			if err != nil {
+				t.Fatalf("formatDiscord() error = %v", err)
+			}
+			if req.url != tt.wantURL {
+				t.Errorf("url = %q, want %q", req.url, tt.wantURL)
+			}
+
+			var msg struct {
+				ThreadName string `json:"thread_name"`
+			}
+			if err := json.Unmarshal(req.body, &msg); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if msg.ThreadName != tt.wantThreadName {
+				t.Errorf("thread_name = %q, want %q", msg.ThreadName, tt.wantThreadName)
+			}
+		})
+	}
+}
+
+func TestNewClient_InvalidDiscordThread(t *testing.T) {
+	tests := []struct {
+		name    string
+		options DiscordOptions
+	}{
+		{name: "non-numeric thread ID", options: DiscordOptions{ThreadID: "general"}},
+		{name: "thread ID and name", options: DiscordOptions{ThreadID: "123", ThreadName: "Forwardarr"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(Target{Template: TemplateDiscord, Discord: tt.options}); err == nil {
+				t.Error("NewClient() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sender delivers notifications to a single destination. Senders apply their
+// own event filter, so Send may return nil without delivering anything.
+// Canceling ctx should abort a delivery in progress.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, payload Payload) error
+}
+
+// Dispatcher fans out notifications to every configured target
+type Dispatcher struct {
+	senders  []Sender
+	queue    *Queue
+	breakers []*breaker
+	dedup    *deduplicator
+	limiters []*rateLimiter
+	instance Instance
+	vpnInfo  func() VPNInfo
+	batch    *batcher
+	messages *Messages
+	// health tracks the deliveries to each sender
+	health []*targetHealth
+
+	// ctx bounds the deliveries made in the background, by the workers and
+	// when a batch window ends; Close cancels it once its deadline passes
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// jobs feeds the workers started by Start; nil while sending synchronously
+	jobs    chan Payload
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// ErrBufferFull is returned when an asynchronous dispatcher drops a
+// notification because all workers are busy and the buffer is full
+var ErrBufferFull = errors.New("webhook buffer full, notification dropped")
+
+// NewDispatcher creates a dispatcher that delivers to all given senders
+func NewDispatcher(senders ...Sender) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	health := make([]*targetHealth, len(senders))
+	for i := range senders {
+		health[i] = &targetHealth{}
+	}
+	return &Dispatcher{senders: senders, health: health, ctx: ctx, cancel: cancel}
+}
+
+// SetQueue stores deliveries that fail after all retries in q. They are
+// redelivered by Replay and after the next successful delivery to the same
+// target.
+func (d *Dispatcher) SetQueue(q *Queue) {
+	d.queue = q
+}
+
+// SetCircuitBreaker suspends a target for cooldown after threshold
+// consecutive failed deliveries, so that an unreachable target does not delay
+// every notification with its retries and timeouts. Deliveries skipped while
+// a target is suspended fail with ErrCircuitOpen and are queued if a queue is
+// set. A threshold of 0 or less disables the breaker.
+func (d *Dispatcher) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		d.breakers = nil
+		return
+	}
+	d.breakers = make([]*breaker, len(d.senders))
+	for i := range d.senders {
+		d.breakers[i] = &breaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+// SetInstance adds the metadata of the sending instance to every
+// notification
+func (d *Dispatcher) SetInstance(instance Instance) {
+	d.instance = instance
+}
+
+// SetMessages replaces the built-in messages and titles of notifications
+func (d *Dispatcher) SetMessages(messages *Messages) {
+	d.messages = messages
+}
+
+// VPNInfo describes the VPN tunnel a forwarded port belongs to. Empty fields
+// are left out of the payload.
+type VPNInfo struct {
+	PublicIP string
+	Provider string
+}
+
+// SetVPNInfo makes port change notifications include the VPN details
+// returned by info, which is called for every port change
+func (d *Dispatcher) SetVPNInfo(info func() VPNInfo) {
+	d.vpnInfo = info
+}
+
+// SetBatching coalesces the notifications sent within window after a first
+// notification into a single batch notification, e.g. the startup, port
+// change and recovery that follow a restart. Each target receives the
+// notifications that pass its event filter; a single remaining notification
+// is sent as is. A window of 0 or less disables batching.
+func (d *Dispatcher) SetBatching(window time.Duration) {
+	if window <= 0 {
+		d.batch = nil
+		return
+	}
+	d.batch = &batcher{window: window, flush: func(entries []Payload) {
+		payload := d.batchPayload(entries)
+		if err := d.dispatch(d.ctx, payload); err != nil {
+			slog.Warn("webhook notification failed", "event", payload.Event, "error", err)
+		}
+	}}
+}
+
+// Start makes the dispatcher deliver notifications in the background. Send
+// methods then only queue the notification in a buffer of the given size and
+// return immediately, so slow targets never delay the caller; delivery
+// errors are logged instead of returned, and the caller's context no longer
+// applies to the delivery. With more than one worker,
+// notifications may be delivered out of order. Must be called before the
+// first notification is sent.
+func (d *Dispatcher) Start(workers, buffer int) {
+	d.jobs = make(chan Payload, max(buffer, 0))
+	for range max(workers, 1) {
+		d.workers.Go(func() {
+			for payload := range d.jobs {
+				if err := d.deliver(d.ctx, payload); err != nil {
+					slog.Warn("webhook notification failed", "event", payload.Event, "error", err)
+				}
+			}
+		})
+	}
+}
+
+// Close stops accepting asynchronous notifications and waits until the
+// buffered ones, including an open batch, are delivered or ctx is done, which
+// cancels the deliveries still in progress. Notifications sent after Close
+// are delivered synchronously.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d.batch != nil {
+		if pending := d.batch.stop(); len(pending) > 0 {
+			if err := d.dispatch(ctx, d.batchPayload(pending)); err != nil {
+				slog.Warn("webhook notification failed", "event", EventBatch, "error", err)
+			}
+		}
+	}
+
+	d.mu.Lock()
+	if d.jobs == nil || d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.jobs)
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return fmt.Errorf("pending webhook notifications not delivered: %w", ctx.Err())
+	}
+}
+
+// SetDeduplication drops notifications that repeat the event and ports of
+// another notification sent within window. A window of 0 or less disables
+// deduplication.
+func (d *Dispatcher) SetDeduplication(window time.Duration) {
+	if window <= 0 {
+		d.dedup = nil
+		return
+	}
+	d.dedup = &deduplicator{window: window}
+}
+
+// SetRateLimit limits every target to perMinute notifications within any
+// minute; further notifications are dropped. A limit of 0 or less disables
+// rate limiting.
+func (d *Dispatcher) SetRateLimit(perMinute int) {
+	if perMinute <= 0 {
+		d.limiters = nil
+		return
+	}
+	d.limiters = make([]*rateLimiter, len(d.senders))
+	for i := range d.senders {
+		d.limiters[i] = &rateLimiter{limit: perMinute}
+	}
+}
+
+// Replay redelivers all queued deliveries, e.g. after a restart
+func (d *Dispatcher) Replay(ctx context.Context) error {
+	if d.queue == nil {
+		return nil
+	}
+	return d.queue.replay(ctx, d.senders, true)
+}
+
+// Senders returns the targets the dispatcher delivers to
+func (d *Dispatcher) Senders() []Sender {
+	return d.senders
+}
+
+// SendPortChange sends a port change notification to all targets concurrently.
+// A failing target does not prevent delivery to the others; the errors of all
+// failed targets are joined into the returned error. Canceling ctx aborts the
+// deliveries in progress, e.g. on shutdown.
+func (d *Dispatcher) SendPortChange(ctx context.Context, oldPort, newPort int) error {
+	payload := newPortChangePayload(oldPort, newPort)
+	if d.vpnInfo != nil {
+		info := d.vpnInfo()
+		payload.PublicIP = info.PublicIP
+		payload.Provider = info.Provider
+	}
+	return d.send(ctx, payload)
+}
+
+// SendSyncError notifies all targets that applying the port failed. Attempt
+// is the number of consecutive failed syncs.
+func (d *Dispatcher) SendSyncError(ctx context.Context, component string, err error, attempt int) error {
+	return d.send(ctx, newSyncErrorPayload(component, err, attempt))
+}
+
+// SendSyncRecovered notifies all targets that the port was applied again
+// after the given number of failed syncs spanning downtime
+func (d *Dispatcher) SendSyncRecovered(ctx context.Context, port, failures int, downtime time.Duration) error {
+	return d.send(ctx, newSyncRecoveredPayload(port, failures, downtime))
+}
+
+// SendStartup notifies all targets that Forwardarr started. Port is the
+// port currently set in qBittorrent, or 0 if unknown.
+func (d *Dispatcher) SendStartup(ctx context.Context, version string, port int) error {
+	return d.send(ctx, newLifecyclePayload(EventStartup, version, d.names(), port))
+}
+
+// SendShutdown notifies all targets that Forwardarr is shutting down
+func (d *Dispatcher) SendShutdown(ctx context.Context, version string, port int) error {
+	return d.send(ctx, newLifecyclePayload(EventShutdown, version, d.names(), port))
+}
+
+// SendQbitUnreachable notifies all targets that qBittorrent stopped
+// responding
+func (d *Dispatcher) SendQbitUnreachable(ctx context.Context, err error) error {
+	return d.send(ctx, newOutagePayload(EventQbitUnreachable, "qbittorrent", err))
+}
+
+// SendQbitRecovered notifies all targets that qBittorrent responds again
+func (d *Dispatcher) SendQbitRecovered(ctx context.Context, downtime time.Duration) error {
+	return d.send(ctx, newOutageRecoveredPayload(EventQbitRecovered, "qbittorrent", 0, downtime))
+}
+
+// SendQbitFirewalled notifies all targets that qBittorrent reports no
+// incoming connections to port, although the port was applied
+func (d *Dispatcher) SendQbitFirewalled(ctx context.Context, port int, since time.Duration) error {
+	err := fmt.Errorf("no incoming connections on port %d for %s", port, since.Round(time.Second))
+	payload := newOutagePayload(EventQbitFirewalled, "qbittorrent", err)
+	payload.CurrentPort = port
+	return d.send(ctx, payload)
+}
+
+// SendQbitConnectable notifies all targets that qBittorrent receives
+// incoming connections to port again
+func (d *Dispatcher) SendQbitConnectable(ctx context.Context, port int, downtime time.Duration) error {
+	return d.send(ctx, newOutageRecoveredPayload(EventQbitConnectable, "qbittorrent", port, downtime))
+}
+
+// SendVPNDown notifies all targets that the forwarded port is unavailable
+// from the named port source, e.g. file or gluetun_control
+func (d *Dispatcher) SendVPNDown(ctx context.Context, source string, err error) error {
+	return d.send(ctx, newOutagePayload(EventVPNDown, source, err))
+}
+
+// SendVPNRecovered notifies all targets that the named port source provides
+// a forwarded port again
+func (d *Dispatcher) SendVPNRecovered(ctx context.Context, source string, port int, downtime time.Duration) error {
+	return d.send(ctx, newOutageRecoveredPayload(EventVPNRecovered, source, port, downtime))
+}
+
+// SendSourceFailover notifies all targets that the port now comes from
+// another source of PORT_SOURCES
+func (d *Dispatcher) SendSourceFailover(ctx context.Context, from, to string, port int, reason error) error {
+	return d.send(ctx, newSourceFailoverPayload(from, to, port, reason))
+}
+
+// SendSourceUnhealthy notifies all targets that a port source keeps failing
+// or stopped providing a port
+func (d *Dispatcher) SendSourceUnhealthy(ctx context.Context, source string, failures int, lastPort time.Time, reason error) error {
+	return d.send(ctx, newSourceUnhealthyPayload(source, failures, lastPort, reason))
+}
+
+// SendTest sends a test notification to the target with the given name, or
+// to all targets if name is empty. Test notifications bypass the event
+// filters, circuit breakers, rate limits and background workers, so the
+// result of every delivery is returned keyed by target name. The result is
+// empty if no target matches.
+func (d *Dispatcher) SendTest(ctx context.Context, name string) map[string]error {
+	var targets []Sender
+	for _, s := range d.senders {
+		if name == "" || s.Name() == name {
+			targets = append(targets, s)
+		}
+	}
+
+	payload := d.prepare(newTestPayload())
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, s := range targets {
+		wg.Go(func() {
+			errs[i] = s.Send(ctx, payload)
+		})
+	}
+	wg.Wait()
+
+	results := make(map[string]error, len(targets))
+	for i, s := range targets {
+		results[s.Name()] = errs[i]
+	}
+	return results
+}
+
+// names returns the names of all targets
+func (d *Dispatcher) names() []string {
+	names := make([]string, len(d.senders))
+	for i, s := range d.senders {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// prepare adds the instance metadata and the configured texts to a new
+// notification
+func (d *Dispatcher) prepare(payload Payload) Payload {
+	return d.messages.apply(d.instance.apply(payload))
+}
+
+// batchPayload summarizes the notifications of a batching window
+func (d *Dispatcher) batchPayload(entries []Payload) Payload {
+	payload := newBatchPayload(entries)
+	if payload.Event == EventBatch {
+		payload = d.messages.apply(payload)
+	}
+	return payload
+}
+
+// send drops duplicate notifications and passes the others on to the open
+// batch, if batching is enabled, or to dispatch
+func (d *Dispatcher) send(ctx context.Context, payload Payload) error {
+	payload = d.prepare(payload)
+
+	if d.dedup != nil && d.dedup.duplicate(payload, time.Now()) {
+		slog.Info("suppressing duplicate notification",
+			"event", payload.Event,
+			"old_port", payload.OldPort,
+			"new_port", payload.NewPort,
+			"window", d.dedup.window,
+		)
+		return nil
+	}
+	if d.batch != nil && d.batch.add(payload) {
+		return nil
+	}
+	return d.dispatch(ctx, payload)
+}
+
+// dispatch delivers the payload, or hands it to the workers if the
+// dispatcher was started
+func (d *Dispatcher) dispatch(ctx context.Context, payload Payload) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.jobs == nil || d.closed {
+		return d.deliver(ctx, payload)
+	}
+
+	select {
+	case d.jobs <- payload:
+		return nil
+	default:
+		return fmt.Errorf("%s: %w", payload.Event, ErrBufferFull)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, payload Payload) error {
+	errs := make([]error, len(d.senders))
+	suspended := make([]bool, len(d.senders))
+	// dropped marks the targets over their rate limit, which were not
+	// contacted and must not get the queue replayed either
+	dropped := make([]bool, len(d.senders))
+
+	var wg sync.WaitGroup
+	for i, s := range d.senders {
+		wg.Go(func() {
+			var b *breaker
+			if d.breakers != nil {
+				b = d.breakers[i]
+			}
+			if b != nil && !b.allow(time.Now()) {
+				slog.Debug("skipping suspended webhook target", "webhook", s.Name(), "event", payload.Event)
+				errs[i] = fmt.Errorf("webhook %q: %w", s.Name(), ErrCircuitOpen)
+				return
+			}
+			if d.limiters != nil && !d.limiters[i].allow(time.Now()) {
+				slog.Warn("webhook rate limit exceeded, dropping notification",
+					"webhook", s.Name(),
+					"event", payload.Event,
+					"limit_per_minute", d.limiters[i].limit,
+				)
+				dropped[i] = true
+				return
+			}
+
+			err := s.Send(ctx, payload)
+			d.health[i].record(err, time.Now())
+			if err != nil {
+				errs[i] = fmt.Errorf("webhook %q: %w", s.Name(), err)
+			}
+			if b != nil && b.record(err, time.Now()) {
+				suspended[i] = true
+			}
+		})
+	}
+	wg.Wait()
+
+	d.notifySuspended(ctx, suspended)
+
+	if d.queue != nil {
+		d.enqueueFailed(ctx, payload, errs, dropped)
+	}
+	return errors.Join(errs...)
+}
+
+// enqueueFailed queues the payload for every target that failed temporarily
+// and replays earlier deliveries to the targets that succeeded, which are
+// evidently reachable again. Permanent failures are not queued since they
+// would fail again on every replay. Dropped targets exceeded their rate
+// limit and get neither.
+func (d *Dispatcher) enqueueFailed(ctx context.Context, payload Payload, errs []error, dropped []bool) {
+	var healthy []Sender
+	for i, s := range d.senders {
+		if dropped[i] {
+			continue
+		}
+		if errs[i] == nil {
+			healthy = append(healthy, s)
+			continue
+		}
+		if !IsRetryable(errs[i]) {
+			slog.Warn("not queueing permanently failed webhook delivery", "webhook", s.Name(), "event", payload.Event)
+			continue
+		}
+		if err := d.queue.Add(s.Name(), payload); err != nil {
+			slog.Error("failed to queue webhook delivery", "webhook", s.Name(), "error", err)
+		}
+	}
+
+	if len(healthy) > 0 {
+		if err := d.queue.replay(ctx, healthy, false); err != nil {
+			slog.Error("failed to replay queued webhook deliveries", "error", err)
+		}
+	}
+}
+
+// notifySuspended logs every target whose breaker just opened and sends a
+// webhook_suspended event to the remaining targets
+func (d *Dispatcher) notifySuspended(ctx context.Context, suspended []bool) {
+	for i, s := range d.senders {
+		if !suspended[i] {
+			continue
+		}
+		b := d.breakers[i]
+		slog.Warn("suspending failing webhook target",
+			"webhook", s.Name(),
+			"consecutive_failures", b.threshold,
+			"cooldown", b.cooldown,
+		)
+
+		payload := d.prepare(newTargetSuspendedPayload(s.Name(), b.threshold, b.cooldown))
+		for j, other := range d.senders {
+			if suspended[j] || !d.breakers[j].allow(time.Now()) {
+				continue
+			}
+			if err := other.Send(ctx, payload); err != nil {
+				slog.Warn("failed to send webhook_suspended notification", "webhook", other.Name(), "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcherSendPortChange_FansOut(t *testing.T) {
+	var firstCalls, secondCalls atomic.Int32
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		firstCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondCalls.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer second.Close()
+
+	dispatcher := NewDispatcher(
+		newTestClient(t, Target{Name: "first", URL: first.URL, Timeout: 5 * time.Second, Template: TemplateJSON}),
+		newTestClient(t, Target{Name: "second", URL: second.URL, Timeout: 5 * time.Second, Template: TemplateSlack}),
+	)
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+	if firstCalls.Load() != 1 {
+		t.Errorf("first target calls = %d, want 1", firstCalls.Load())
+	}
+	if secondCalls.Load() != 1 {
+		t.Errorf("second target calls = %d, want 1", secondCalls.Load())
+	}
+}
+
+func TestDispatcherSendPortChange_AggregatesErrors(t *testing.T) {
+	var healthyCalls atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	dispatcher := NewDispatcher(
+		newTestClient(t, Target{Name: "broken-a", URL: broken.URL, Timeout: 5 * time.Second}),
+		newTestClient(t, Target{Name: "healthy", URL: healthy.URL, Timeout: 5 * time.Second}),
+		newTestClient(t, Target{Name: "broken-b", URL: broken.URL, Timeout: 5 * time.Second}),
+	)
+
+	err := dispatcher.SendPortChange(t.Context(), 8080, 9090)
+	if err == nil {
+		t.Fatal("SendPortChange() error = nil, want error")
+	}
+	for _, name := range []string{"broken-a", "broken-b"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention target %q", err, name)
+		}
+	}
+	if strings.Contains(err.Error(), "healthy") {
+		t.Errorf("error %q mentions healthy target", err)
+	}
+	if healthyCalls.Load() != 1 {
+		t.Errorf("healthy target calls = %d, want 1", healthyCalls.Load())
+	}
+}
+
+func TestDispatcherSendPortChange_PerTargetEventFilter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(
+		newTestClient(t, Target{Name: "ports", URL: server.URL, Timeout: 5 * time.Second, Events: []string{"port_changed"}}),
+		newTestClient(t, Target{Name: "errors", URL: server.URL, Timeout: 5 * time.Second, Events: []string{"sync_error"}}),
+	)
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("webhook calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestDispatcherSendPortChange_NoTargets(t *testing.T) {
+	if err := NewDispatcher().SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Errorf("SendPortChange() error = %v, want nil", err)
+	}
+}
+
+// blockingSender signals every delivery on started and blocks it until
+// release is closed or the delivery is canceled
+type blockingSender struct {
+	started  chan Payload
+	release  chan struct{}
+	canceled chan Payload
+}
+
+func newBlockingSender() *blockingSender {
+	return &blockingSender{
+		started:  make(chan Payload, 10),
+		release:  make(chan struct{}),
+		canceled: make(chan Payload, 10),
+	}
+}
+
+func (s *blockingSender) Name() string { return "slow" }
+
+func (s *blockingSender) Send(ctx context.Context, payload Payload) error {
+	s.started <- payload
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		s.canceled <- payload
+		return ctx.Err()
+	}
+}
+
+func TestDispatcherStart_SendsAsynchronously(t *testing.T) {
+	sender := newBlockingSender()
+	dispatcher := NewDispatcher(sender)
+	dispatcher.Start(1, 10)
+
+	// Returns while the sender is still blocked
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+	if payload := <-sender.started; payload.NewPort != 9090 {
+		t.Errorf("delivered NewPort = %d, want 9090", payload.NewPort)
+	}
+
+	close(sender.release)
+	if err := dispatcher.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// After Close notifications are delivered synchronously
+	if err := dispatcher.SendPortChange(t.Context(), 9090, 9091); err != nil {
+		t.Fatalf("SendPortChange() after Close error = %v", err)
+	}
+	if payload := <-sender.started; payload.NewPort != 9091 {
+		t.Errorf("delivered NewPort = %d, want 9091", payload.NewPort)
+	}
+}
+
+func TestDispatcherStart_DropsWhenBufferFull(t *testing.T) {
+	sender := newBlockingSender()
+	defer close(sender.release)
+	dispatcher := NewDispatcher(sender)
+	dispatcher.Start(1, 1)
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("first SendPortChange() error = %v", err)
+	}
+	<-sender.started // the worker is busy with the first notification
+	if err := dispatcher.SendPortChange(t.Context(), 9090, 9091); err != nil {
+		t.Fatalf("second SendPortChange() error = %v, want it buffered", err)
+	}
+	if err := dispatcher.SendPortChange(t.Context(), 9091, 9092); !errors.Is(err, ErrBufferFull) {
+		t.Errorf("third SendPortChange() error = %v, want ErrBufferFull", err)
+	}
+}
+
+func TestDispatcherClose_Timeout(t *testing.T) {
+	sender := newBlockingSender()
+	defer close(sender.release)
+	dispatcher := NewDispatcher(sender)
+	dispatcher.Start(1, 1)
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	<-sender.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := dispatcher.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want deadline exceeded", err)
+	}
+	select {
+	case <-sender.canceled:
+	case <-time.After(2 * time.Second):
+		t.Error("delivery in progress was not canceled after the Close deadline")
+	}
+}
+
+func TestDispatcherSendPortChange_Canceled(t *testing.T) {
+	sender := newBlockingSender()
+	dispatcher := NewDispatcher(sender)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- dispatcher.SendPortChange(ctx, 8080, 9090)
+	}()
+	<-sender.started
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("SendPortChange() error = %v, want context canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SendPortChange() did not return after its context was canceled")
+	}
+}
+
+func TestDispatcherSetVPNInfo(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	calls := 0
+	dispatcher.SetVPNInfo(func() VPNInfo {
+		calls++
+		return VPNInfo{PublicIP: "203.0.113.7", Provider: "mullvad"}
+	})
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	if err := dispatcher.SendVPNDown(t.Context(), "file", errors.New("missing")); err != nil {
+		t.Fatalf("SendVPNDown() error = %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("VPN info looked up %d times, want only for the port change", calls)
+	}
+	if got := sender.sent[0]; got.PublicIP != "203.0.113.7" || got.Provider != "mullvad" {
+		t.Errorf("port change VPN = %q/%q, want 203.0.113.7/mullvad", got.PublicIP, got.Provider)
+	}
+	if got := sender.sent[1]; got.PublicIP != "" || got.Provider != "" || got.Component != "file" {
+		t.Errorf("vpn_down VPN = %q/%q, component = %q, want empty and file", got.PublicIP, got.Provider, got.Component)
+	}
+}
+
+func TestDispatcherSendQbitFirewalled(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+
+	if err := dispatcher.SendQbitFirewalled(t.Context(), 6000, 5*time.Minute); err != nil {
+		t.Fatalf("SendQbitFirewalled() error = %v", err)
+	}
+	if err := dispatcher.SendQbitConnectable(t.Context(), 6000, 10*time.Minute); err != nil {
+		t.Fatalf("SendQbitConnectable() error = %v", err)
+	}
+
+	firewalled := sender.sent[0]
+	if firewalled.Event != EventQbitFirewalled || firewalled.CurrentPort != 6000 || firewalled.Error == "" {
+		t.Errorf("firewalled payload = %+v, want qbit_firewalled with port and error", firewalled)
+	}
+	connectable := sender.sent[1]
+	if connectable.Event != EventQbitConnectable || connectable.CurrentPort != 6000 || connectable.DowntimeSeconds != 600 {
+		t.Errorf("connectable payload = %+v, want qbit_connectable with port and downtime", connectable)
+	}
+}
+
+func TestDispatcherSendSourceUnhealthy(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+
+	lastPort := time.Now().Add(-10 * time.Minute)
+	if err := dispatcher.SendSourceUnhealthy(t.Context(), "gluetun_control", 3, lastPort, errors.New("3 failed reads in a row: connection refused")); err != nil {
+		t.Fatalf("SendSourceUnhealthy() error = %v", err)
+	}
+	payload := sender.sent[0]
+	if payload.Event != EventSourceUnhealthy || payload.Component != "gluetun_control" || payload.Attempt != 3 || payload.DowntimeSeconds < 600 {
+		t.Errorf("payload = %+v, want source_unhealthy of gluetun_control with failures and downtime", payload)
+	}
+	if want := "gluetun_control: 3 failed reads in a row: connection refused"; payload.Error != want {
+		t.Errorf("Error = %q, want %q", payload.Error, want)
+	}
+	if eventSeverity(EventSourceUnhealthy) != SeverityWarning || !isFailure(EventSourceUnhealthy) {
+		t.Error("source_unhealthy is not a warning failure event")
+	}
+}
+
+func TestDispatcherSendSourceFailover(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+
+	if err := dispatcher.SendSourceFailover(t.Context(), "gluetun_control", "file", 6000, errors.New("gluetun_control: connection refused")); err != nil {
+		t.Fatalf("SendSourceFailover() error = %v", err)
+	}
+	if err := dispatcher.SendSourceFailover(t.Context(), "file", "gluetun_control", 6000, nil); err != nil {
+		t.Fatalf("SendSourceFailover() error = %v", err)
+	}
+
+	failover := sender.sent[0]
+	if failover.Event != EventSourceFailover || failover.Component != "file" || failover.CurrentPort != 6000 || failover.Error != "gluetun_control: connection refused" {
+		t.Errorf("failover payload = %+v, want source_failover to file with port and reason", failover)
+	}
+	if want := "Port source changed from gluetun_control to file (port 6000): gluetun_control: connection refused"; failover.Message != want {
+		t.Errorf("Message = %q, want %q", failover.Message, want)
+	}
+	if failback := sender.sent[1]; failback.Error != "" || failback.Message != "Port source changed from file to gluetun_control (port 6000)" {
+		t.Errorf("failback payload = %+v, want no error", failback)
+	}
+}
@@ -0,0 +1,253 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TemplateEmail selects the SMTP email sender instead of an HTTP webhook
+const TemplateEmail Template = "email"
+
+// Email TLS modes
+const (
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "tls"
+	EmailTLSNone     = "none"
+)
+
+// EmailOptions holds the settings for email notifications
+type EmailOptions struct {
+	// TLS is one of starttls (default), tls or none
+	TLS      string
+	Username string
+	Password string
+	From     string
+	To       []string
+	HTML     bool
+}
+
+// validate checks that the email options are usable
+func (o EmailOptions) validate() error {
+	switch o.TLS {
+	case "", EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone:
+	default:
+		return fmt.Errorf("invalid email TLS mode %q: must be starttls, tls or none", o.TLS)
+	}
+	if o.From == "" {
+		return fmt.Errorf("email sender address is required")
+	}
+	if len(o.To) == 0 {
+		return fmt.Errorf("at least one email recipient is required")
+	}
+	return nil
+}
+
+// EmailSender delivers notifications by email over SMTP
+type EmailSender struct {
+	name        string
+	host        string
+	addr        string
+	tls         *tls.Config
+	timeout     time.Duration
+	events      map[string]bool
+	minSeverity Severity
+	email       EmailOptions
+	retry       RetryPolicy
+}
+
+// NewEmailSender creates an email sender for the given target. The target URL
+// names the SMTP server as smtp://host:port.
+func NewEmailSender(target Target) (*EmailSender, error) {
+	if err := target.Email.validate(); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP server URL: %w", err)
+	}
+	if u.Scheme != "smtp" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid SMTP server URL %q: expected smtp://host:port", target.URL)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "587"
+		if target.Email.TLS == EmailTLSImplicit {
+			port = "465"
+		}
+	}
+
+	eventMap, err := newEventFilter(target.Events)
+	if err != nil {
+		return nil, err
+	}
+	minSeverity, err := ParseSeverity(target.MinSeverity)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := target.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = u.Hostname()
+
+	return &EmailSender{
+		name:        target.Name,
+		host:        u.Hostname(),
+		addr:        net.JoinHostPort(u.Hostname(), port),
+		tls:         tlsConfig,
+		timeout:     target.Timeout,
+		events:      eventMap,
+		minSeverity: minSeverity,
+		email:       target.Email,
+		retry:       target.Retry,
+	}, nil
+}
+
+// Name returns the configured name of the email target
+func (s *EmailSender) Name() string {
+	return s.name
+}
+
+// Send emails the payload unless its event is filtered out for this target
+func (s *EmailSender) Send(ctx context.Context, payload Payload) error {
+	filtered, ok := filterEvents(s.events, s.minSeverity, payload)
+	if !ok {
+		slog.Debug("webhook event filtered out", "webhook", s.name, "event", payload.Event)
+		return nil
+	}
+	payload = filtered
+
+	msg := s.message(payload)
+	return s.retry.run(ctx, s.name, func() error {
+		return classifySMTPError(s.deliver(ctx, msg))
+	})
+}
+
+// classifySMTPError marks permanent SMTP rejections (5xx replies, e.g. a
+// failed login or an unknown recipient) so that they are not retried
+func classifySMTPError(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
+
+// message builds the RFC 5322 message for the payload
+func (s *EmailSender) message(payload Payload) []byte {
+	fields := append(payloadFields(payload), field{name: "Time", value: payload.Timestamp.Format(time.RFC3339)})
+
+	var body strings.Builder
+	contentType := "text/plain; charset=UTF-8"
+	if s.email.HTML {
+		contentType = "text/html; charset=UTF-8"
+		fmt.Fprintf(&body, "<h3>%s</h3>\r\n<p>%s</p>\r\n<table>\r\n",
+			html.EscapeString(payloadTitle(payload)), html.EscapeString(payload.Message))
+		for _, f := range fields {
+			fmt.Fprintf(&body, "<tr><td><b>%s</b></td><td>%s</td></tr>\r\n", f.name, html.EscapeString(f.value))
+		}
+		body.WriteString("</table>\r\n")
+	} else {
+		fmt.Fprintf(&body, "%s\r\n\r\n", payload.Message)
+		for _, f := range fields {
+			fmt.Fprintf(&body, "%s: %s\r\n", f.name, f.value)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.email.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.email.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Forwardarr: "+payload.Message))
+	fmt.Fprintf(&buf, "Date: %s\r\n", payload.Timestamp.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n", contentType)
+	buf.WriteString("\r\n")
+	buf.WriteString(body.String())
+	return buf.Bytes()
+}
+
+// deliver performs a single SMTP transaction
+func (s *EmailSender) deliver(ctx context.Context, msg []byte) error {
+	tlsConfig := s.tls.Clone()
+	var implicitTLS *tls.Config
+	if s.email.TLS == EmailTLSImplicit {
+		implicitTLS = tlsConfig
+	}
+
+	conn, err := dialContext(ctx, s.addr, s.timeout, implicitTLS)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	// Closing the connection aborts the session once ctx is done
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	if s.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("failed to set SMTP deadline: %w", err)
+		}
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			slog.Debug("failed to close SMTP connection", "error", err)
+		}
+	}()
+
+	if s.email.TLS == "" || s.email.TLS == EmailTLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if s.email.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.email.Username, s.email.Password, s.host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.email.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM rejected: %w", err)
+	}
+	for _, rcpt := range s.email.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("SMTP recipient %q rejected: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA rejected: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected email: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		slog.Debug("SMTP QUIT failed", "webhook", s.name, "error", err)
+	}
+
+	slog.Info("email notification sent", "webhook", s.name, "server", s.addr, "recipients", len(s.email.To))
+	return nil
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts a single SMTP session and records the envelope and
+// message data it receives
+type fakeSMTPServer struct {
+	addr  string
+	rcpts []string
+	data  chan string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	s := &fakeSMTPServer{addr: ln.Addr().String(), data: make(chan string, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				_ = tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM"):
+				_ = tp.PrintfLine("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO"):
+				s.rcpts = append(s.rcpts, line)
+				_ = tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				_ = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				lines, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				s.data <- strings.Join(lines, "\n")
+				_ = tp.PrintfLine("250 OK")
+			case cmd == "QUIT":
+				_ = tp.PrintfLine("221 Bye")
+				return
+			default:
+				_ = tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	return s
+}
+
+func TestEmailSender_Send(t *testing.T) {
+	server := newFakeSMTPServer(t)
+
+	sender, err := NewEmailSender(Target{
+		Name:     "email",
+		URL:      "smtp://" + server.addr,
+		Template: TemplateEmail,
+		Timeout:  5 * time.Second,
+		Email: EmailOptions{
+			TLS:  EmailTLSNone,
+			From: "forwardarr@example.com",
+			To:   []string{"a@example.com", "b@example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEmailSender() error = %v", err)
+	}
+
+	if err := sender.Send(t.Context(), newPortChangePayload(8080, 9090)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var data string
+	select {
+	case data = <-server.data:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for email data")
+	}
+
+	if len(server.rcpts) != 2 {
+		t.Errorf("recipients = %v, want 2", server.rcpts)
+	}
+	for _, want := range []string{
+		"From: forwardarr@example.com",
+		"To: a@example.com, b@example.com",
+		"Subject: Forwardarr: Port changed from 8080 to 9090",
+		"Content-Type: text/plain; charset=UTF-8",
+		"New Port: 9090",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("email does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestEmailSender_HTMLMessage(t *testing.T) {
+	sender := &EmailSender{email: EmailOptions{From: "f@example.com", To: []string{"t@example.com"}, HTML: true}}
+
+	msg := string(sender.message(newPortChangePayload(8080, 9090)))
+
+	if !strings.Contains(msg, "Content-Type: text/html; charset=UTF-8") {
+		t.Errorf("message is not HTML:\n%s", msg)
+	}
+	if !strings.Contains(msg, "<td>9090</td>") {
+		t.Errorf("message does not contain new port:\n%s", msg)
+	}
+}
+
+func TestEmailSender_EventFilter(t *testing.T) {
+	sender, err := NewEmailSender(Target{
+		Name:   "email",
+		URL:    "smtp://127.0.0.1:1",
+		Events: []string{EventSyncError},
+		Email:  EmailOptions{From: "f@example.com", To: []string{"t@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("NewEmailSender() error = %v", err)
+	}
+
+	// The server is unreachable, so only a filtered event succeeds
+	if err := sender.Send(t.Context(), newPortChangePayload(8080, 9090)); err != nil {
+		t.Errorf("Send() error = %v, want nil for filtered event", err)
+	}
+}
+
+func TestNewEmailSender_Validation(t *testing.T) {
+	valid := EmailOptions{From: "f@example.com", To: []string{"t@example.com"}}
+
+	tests := []struct {
+		name    string
+		url     string
+		opts    EmailOptions
+		wantErr string
+	}{
+		{name: "valid", url: "smtp://mail.example.com:587", opts: valid},
+		{name: "default port", url: "smtp://mail.example.com", opts: valid},
+		{name: "wrong scheme", url: "https://mail.example.com", opts: valid, wantErr: "expected smtp://host:port"},
+		{name: "missing from", url: "smtp://mail.example.com", opts: EmailOptions{To: valid.To}, wantErr: "sender address"},
+		{name: "missing to", url: "smtp://mail.example.com", opts: EmailOptions{From: valid.From}, wantErr: "recipient"},
+		{
+			name:    "invalid tls mode",
+			url:     "smtp://mail.example.com",
+			opts:    EmailOptions{TLS: "ssl", From: valid.From, To: valid.To},
+			wantErr: "invalid email TLS mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEmailSender(Target{Name: "email", URL: tt.url, Email: tt.opts})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewEmailSender() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewEmailSender() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewEmailSender_DefaultPort(t *testing.T) {
+	tests := []struct {
+		tls  string
+		want string
+	}{
+		{tls: "", want: "mail.example.com:587"},
+		{tls: EmailTLSImplicit, want: "mail.example.com:465"},
+	}
+
+	for _, tt := range tests {
+		sender, err := NewEmailSender(Target{
+			URL:   "smtp://mail.example.com",
+			Email: EmailOptions{TLS: tt.tls, From: "f@example.com", To: []string{"t@example.com"}},
+		})
+		if err != nil {
+			t.Fatalf("NewEmailSender() error = %v", err)
+		}
+		if sender.addr != tt.want {
+			t.Errorf("addr = %q, want %q", sender.addr, tt.want)
+		}
+	}
+}
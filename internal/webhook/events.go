@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event names used in payloads and WEBHOOK_EVENTS filters
+const (
+	EventPortChanged     = "port_changed"
+	EventSyncError       = "sync_error"
+	EventSyncRecovered   = "sync_recovered"
+	EventStartup         = "startup"
+	EventShutdown        = "shutdown"
+	EventQbitUnreachable = "qbit_unreachable"
+	EventQbitRecovered   = "qbit_recovered"
+	EventQbitFirewalled  = "qbit_firewalled"
+	EventQbitConnectable = "qbit_connectable"
+	EventVPNDown         = "vpn_down"
+	EventVPNRecovered    = "vpn_recovered"
+	EventSourceFailover  = "source_failover"
+	EventSourceUnhealthy = "source_unhealthy"
+	EventTargetSuspended = "webhook_suspended"
+	EventTest            = "test"
+	// EventBatch summarizes the notifications of a batching window; it
+	// cannot be selected in event filters, which apply to its entries
+	EventBatch = "batch"
+)
+
+// eventTitles are the human-readable notification titles per event
+var eventTitles = map[string]string{
+	EventPortChanged:     "Port Change Notification",
+	EventSyncError:       "Port Sync Failed",
+	EventSyncRecovered:   "Port Sync Recovered",
+	EventStartup:         "Forwardarr Started",
+	EventShutdown:        "Forwardarr Stopped",
+	EventQbitUnreachable: "qBittorrent Unreachable",
+	EventQbitRecovered:   "qBittorrent Reachable Again",
+	EventQbitFirewalled:  "qBittorrent Firewalled",
+	EventQbitConnectable: "qBittorrent Connectable Again",
+	EventVPNDown:         "VPN Port Unavailable",
+	EventVPNRecovered:    "VPN Port Available Again",
+	EventSourceFailover:  "Port Source Changed",
+	EventSourceUnhealthy: "Port Source Unhealthy",
+	EventTargetSuspended: "Webhook Target Suspended",
+	EventTest:            "Forwardarr Test Notification",
+	EventBatch:           "Forwardarr Summary",
+}
+
+// failureEvents report that something stopped working
+var failureEvents = map[string]bool{
+	EventSyncError:       true,
+	EventQbitUnreachable: true,
+	EventQbitFirewalled:  true,
+	EventVPNDown:         true,
+	EventSourceUnhealthy: true,
+	EventTargetSuspended: true,
+}
+
+// isFailure reports whether an event signals a failure rather than
+// information or a recovery
+func isFailure(event string) bool {
+	return failureEvents[event]
+}
+
+// eventTitle returns the notification title for an event
+func eventTitle(event string) string {
+	if title, ok := eventTitles[event]; ok {
+		return title
+	}
+	return "Forwardarr Notification"
+}
+
+// newEventFilter builds a target's event filter. Unknown event names are
+// rejected because a misspelled event would silently never be delivered.
+func newEventFilter(events []string) (map[string]bool, error) {
+	filter := make(map[string]bool)
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if _, ok := eventTitles[event]; !ok || event == EventBatch {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+		filter[event] = true
+	}
+	return filter, nil
+}
+
+// field is a labelled value shown by templates that render structured
+// details, such as Discord embeds or Slack blocks
+type field struct {
+	name  string
+	value string
+}
+
+// payloadFields returns the details relevant to the payload's event
+func payloadFields(payload Payload) []field {
+	fields := []field{{name: "Event", value: payload.Event}}
+	if len(payload.Batch) > 0 {
+		events := make([]string, len(payload.Batch))
+		for i, entry := range payload.Batch {
+			events[i] = entry.Event
+		}
+		fields = append(fields, field{name: "Events", value: strings.Join(events, ", ")})
+	}
+	if payload.Event == EventPortChanged || payload.OldPort != 0 {
+		fields = append(fields, field{name: "Old Port", value: strconv.Itoa(payload.OldPort)})
+	}
+	if payload.Event == EventPortChanged || payload.NewPort != 0 {
+		fields = append(fields, field{name: "New Port", value: strconv.Itoa(payload.NewPort)})
+	}
+	if payload.PublicIP != "" {
+		fields = append(fields, field{name: "Public IP", value: payload.PublicIP})
+	}
+	if payload.Provider != "" {
+		fields = append(fields, field{name: "Provider", value: payload.Provider})
+	}
+	if payload.Version != "" {
+		fields = append(fields, field{name: "Version", value: payload.Version})
+	}
+	if payload.CurrentPort != 0 {
+		fields = append(fields, field{name: "Current Port", value: strconv.Itoa(payload.CurrentPort)})
+	}
+	if len(payload.Targets) > 0 {
+		fields = append(fields, field{name: "Targets", value: strings.Join(payload.Targets, ", ")})
+	}
+	if payload.Component != "" {
+		fields = append(fields, field{name: "Component", value: payload.Component})
+	}
+	if payload.Attempt != 0 {
+		fields = append(fields, field{name: "Attempt", value: strconv.Itoa(payload.Attempt)})
+	}
+	if payload.DowntimeSeconds != 0 {
+		downtime := time.Duration(payload.DowntimeSeconds * float64(time.Second)).Round(time.Second)
+		fields = append(fields, field{name: "Downtime", value: downtime.String()})
+	}
+	if payload.Error != "" {
+		fields = append(fields, field{name: "Error", value: payload.Error})
+	}
+	if payload.Instance != "" {
+		fields = append(fields, field{name: "Instance", value: payload.Instance})
+	}
+	if payload.Hostname != "" {
+		fields = append(fields, field{name: "Host", value: payload.Hostname})
+	}
+	if payload.Label != "" {
+		fields = append(fields, field{name: "Label", value: payload.Label})
+	}
+	return fields
+}
+
+// newPortChangePayload creates the payload for a port_changed event
+func newPortChangePayload(oldPort, newPort int) Payload {
+	return Payload{
+		Event:     EventPortChanged,
+		Timestamp: time.Now().UTC(),
+		OldPort:   oldPort,
+		NewPort:   newPort,
+		Message:   fmt.Sprintf("Port changed from %d to %d", oldPort, newPort),
+	}
+}
+
+// newSyncErrorPayload creates the payload for a sync_error event. Attempt is
+// the number of consecutive failed syncs.
+func newSyncErrorPayload(component string, err error, attempt int) Payload {
+	return Payload{
+		Event:     EventSyncError,
+		Timestamp: time.Now().UTC(),
+		Message:   fmt.Sprintf("Failed to sync port (attempt %d): %v", attempt, err),
+		Error:     err.Error(),
+		Component: component,
+		Attempt:   attempt,
+	}
+}
+
+// newSyncRecoveredPayload creates the payload for a sync_recovered event
+// after the given number of failed syncs spanning downtime
+func newSyncRecoveredPayload(port, failures int, downtime time.Duration) Payload {
+	return Payload{
+		Event:     EventSyncRecovered,
+		Timestamp: time.Now().UTC(),
+		NewPort:   port,
+		Message: fmt.Sprintf("Port sync recovered after %s (%d failed attempts), port %d applied",
+			downtime.Round(time.Second), failures, port),
+		Component:       "qbittorrent",
+		Attempt:         failures,
+		DowntimeSeconds: downtime.Seconds(),
+	}
+}
+
+// newLifecyclePayload creates the payload for a startup or shutdown event
+func newLifecyclePayload(event, version string, targets []string, port int) Payload {
+	action := "started"
+	if event == EventShutdown {
+		action = "is shutting down"
+	}
+	message := fmt.Sprintf("Forwardarr %s %s", version, action)
+	if port != 0 {
+		message += fmt.Sprintf(" (current port %d)", port)
+	}
+
+	return Payload{
+		Event:       event,
+		Timestamp:   time.Now().UTC(),
+		Message:     message,
+		Version:     version,
+		Targets:     targets,
+		CurrentPort: port,
+	}
+}
+
+// newOutagePayload creates the payload for a qbit_unreachable or vpn_down
+// event
+func newOutagePayload(event, component string, err error) Payload {
+	return Payload{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Message:   fmt.Sprintf("%s: %v", eventTitle(event), err),
+		Error:     err.Error(),
+		Component: component,
+	}
+}
+
+// newOutageRecoveredPayload creates the payload for a qbit_recovered or
+// vpn_recovered event. Port is the forwarded port, or 0 if not applicable.
+func newOutageRecoveredPayload(event, component string, port int, downtime time.Duration) Payload {
+	return Payload{
+		Event:           event,
+		Timestamp:       time.Now().UTC(),
+		Message:         fmt.Sprintf("%s after %s", eventTitle(event), downtime.Round(time.Second)),
+		Component:       component,
+		CurrentPort:     port,
+		DowntimeSeconds: downtime.Seconds(),
+	}
+}
+
+// newSourceFailoverPayload creates the payload for a source_failover event
+// from one source of PORT_SOURCES to another, which provides port. Reason
+// explains why the sources before the new one were skipped; it is nil when
+// the preferred source took over again.
+func newSourceFailoverPayload(from, to string, port int, reason error) Payload {
+	payload := Payload{
+		Event:       EventSourceFailover,
+		Timestamp:   time.Now().UTC(),
+		Message:     fmt.Sprintf("Port source changed from %s to %s (port %d)", from, to, port),
+		Component:   to,
+		CurrentPort: port,
+	}
+	if reason != nil {
+		payload.Message += ": " + reason.Error()
+		payload.Error = reason.Error()
+	}
+	return payload
+}
+
+// newSourceUnhealthyPayload creates the payload for a source_unhealthy event
+// of a port source that failed the given number of reads in a row, or
+// provided no port since lastPort; lastPort is zero if it never did
+func newSourceUnhealthyPayload(source string, failures int, lastPort time.Time, reason error) Payload {
+	payload := newOutagePayload(EventSourceUnhealthy, source, fmt.Errorf("%s: %w", source, reason))
+	payload.Attempt = failures
+	if !lastPort.IsZero() {
+		payload.DowntimeSeconds = time.Since(lastPort).Seconds()
+	}
+	return payload
+}
+
+// newTargetSuspendedPayload creates the payload for a webhook_suspended event
+// after the target failed the given number of times in a row
+func newTargetSuspendedPayload(target string, failures int, cooldown time.Duration) Payload {
+	return Payload{
+		Event:     EventTargetSuspended,
+		Timestamp: time.Now().UTC(),
+		Message: fmt.Sprintf("Webhook %q suspended for %s after %d consecutive failures",
+			target, cooldown, failures),
+		Component: target,
+		Attempt:   failures,
+	}
+}
+
+// Fake ports used by test notifications
+const (
+	testOldPort = 12345
+	testNewPort = 54321
+)
+
+// newTestPayload creates a synthetic notification used to validate a
+// target's URL, template and credentials
+func newTestPayload() Payload {
+	return Payload{
+		Event:     EventTest,
+		Timestamp: time.Now().UTC(),
+		OldPort:   testOldPort,
+		NewPort:   testNewPort,
+		Message:   fmt.Sprintf("Test notification: port changed from %d to %d", testOldPort, testNewPort),
+	}
+}
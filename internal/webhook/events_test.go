@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewSyncErrorPayload(t *testing.T) {
+	payload := newSyncErrorPayload("qbittorrent", errors.New("connection refused"), 3)
+
+	if payload.Event != EventSyncError {
+		t.Errorf("Event = %q, want %q", payload.Event, EventSyncError)
+	}
+	if payload.Error != "connection refused" {
+		t.Errorf("Error = %q, want connection refused", payload.Error)
+	}
+	if payload.Component != "qbittorrent" {
+		t.Errorf("Component = %q, want qbittorrent", payload.Component)
+	}
+	if payload.Attempt != 3 {
+		t.Errorf("Attempt = %d, want 3", payload.Attempt)
+	}
+	if payload.Message != "Failed to sync port (attempt 3): connection refused" {
+		t.Errorf("Message = %q", payload.Message)
+	}
+}
+
+func TestPayloadFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload Payload
+		want    []string
+	}{
+		{
+			name:    "port change",
+			payload: newPortChangePayload(0, 9090),
+			want:    []string{"Event", "Old Port", "New Port"},
+		},
+		{
+			name:    "sync error",
+			payload: newSyncErrorPayload("qbittorrent", errors.New("boom"), 1),
+			want:    []string{"Event", "Component", "Attempt", "Error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := payloadFields(tt.payload)
+			if len(fields) != len(tt.want) {
+				t.Fatalf("payloadFields() = %v, want names %v", fields, tt.want)
+			}
+			for i, name := range tt.want {
+				if fields[i].name != name {
+					t.Errorf("fields[%d].name = %q, want %q", i, fields[i].name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestEventTitle(t *testing.T) {
+	if got := eventTitle(EventSyncError); got != "Port Sync Failed" {
+		t.Errorf("eventTitle(sync_error) = %q, want Port Sync Failed", got)
+	}
+	if got := eventTitle("unknown"); got != "Forwardarr Notification" {
+		t.Errorf("eventTitle(unknown) = %q, want fallback title", got)
+	}
+}
+
+func TestDispatcherSendSyncError(t *testing.T) {
+	var received map[string]interface{}
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(
+		newTestClient(t, Target{Name: "errors", URL: server.URL, Timeout: 5 * time.Second, Events: []string{EventSyncError}}),
+		newTestClient(t, Target{Name: "ports", URL: server.URL, Timeout: 5 * time.Second, Events: []string{EventPortChanged}}),
+	)
+
+	if err := dispatcher.SendSyncError(t.Context(), "qbittorrent", errors.New("connection refused"), 2); err != nil {
+		t.Fatalf("SendSyncError() error = %v, want nil", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("server calls = %d, want 1 (port_changed target filtered out)", calls.Load())
+	}
+	if received["event"] != EventSyncError {
+		t.Errorf("event = %v, want sync_error", received["event"])
+	}
+	if received["error"] != "connection refused" {
+		t.Errorf("error = %v, want connection refused", received["error"])
+	}
+	if received["component"] != "qbittorrent" {
+		t.Errorf("component = %v, want qbittorrent", received["component"])
+	}
+	if received["attempt"] != float64(2) {
+		t.Errorf("attempt = %v, want 2", received["attempt"])
+	}
+}
+
+func TestNewSyncRecoveredPayload(t *testing.T) {
+	payload := newSyncRecoveredPayload(9090, 3, 150*time.Second)
+
+	if payload.Event != EventSyncRecovered {
+		t.Errorf("Event = %q, want %q", payload.Event, EventSyncRecovered)
+	}
+	if payload.NewPort != 9090 {
+		t.Errorf("NewPort = %d, want 9090", payload.NewPort)
+	}
+	if payload.Attempt != 3 {
+		t.Errorf("Attempt = %d, want 3", payload.Attempt)
+	}
+	if payload.DowntimeSeconds != 150 {
+		t.Errorf("DowntimeSeconds = %v, want 150", payload.DowntimeSeconds)
+	}
+	if payload.Message != "Port sync recovered after 2m30s (3 failed attempts), port 9090 applied" {
+		t.Errorf("Message = %q", payload.Message)
+	}
+
+	fields := payloadFields(payload)
+	if last := fields[len(fields)-1]; last.name != "Downtime" || last.value != "2m30s" {
+		t.Errorf("last field = %+v, want Downtime 2m30s", last)
+	}
+}
+
+func TestDispatcherSendStartup(t *testing.T) {
+	var received Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(
+		newTestClient(t, Target{Name: "lifecycle", URL: server.URL, Timeout: 5 * time.Second, Events: []string{EventStartup}}),
+		newTestClient(t, Target{Name: "ports", URL: server.URL, Timeout: 5 * time.Second, Events: []string{EventPortChanged}}),
+	)
+
+	if err := dispatcher.SendStartup(t.Context(), "v1.2.3", 51413); err != nil {
+		t.Fatalf("SendStartup() error = %v, want nil", err)
+	}
+
+	if received.Event != EventStartup {
+		t.Errorf("Event = %q, want startup", received.Event)
+	}
+	if received.Version != "v1.2.3" {
+		t.Errorf("Version = %q, want v1.2.3", received.Version)
+	}
+	if received.CurrentPort != 51413 {
+		t.Errorf("CurrentPort = %d, want 51413", received.CurrentPort)
+	}
+	if len(received.Targets) != 2 || received.Targets[0] != "lifecycle" || received.Targets[1] != "ports" {
+		t.Errorf("Targets = %v, want [lifecycle ports]", received.Targets)
+	}
+	if received.Message != "Forwardarr v1.2.3 started (current port 51413)" {
+		t.Errorf("Message = %q", received.Message)
+	}
+}
+
+func TestNewLifecyclePayload_Shutdown(t *testing.T) {
+	payload := newLifecyclePayload(EventShutdown, "dev", nil, 0)
+
+	if payload.Message != "Forwardarr dev is shutting down" {
+		t.Errorf("Message = %q, want shutdown message without port", payload.Message)
+	}
+	if eventTitle(payload.Event) != "Forwardarr Stopped" {
+		t.Errorf("title = %q, want Forwardarr Stopped", eventTitle(payload.Event))
+	}
+}
+
+func TestOutagePayloads(t *testing.T) {
+	down := newOutagePayload(EventVPNDown, "gluetun", errors.New("port file is empty"))
+	if down.Message != "VPN Port Unavailable: port file is empty" {
+		t.Errorf("Message = %q", down.Message)
+	}
+	if down.Component != "gluetun" || down.Error != "port file is empty" {
+		t.Errorf("payload = %+v, want gluetun component and error", down)
+	}
+
+	up := newOutageRecoveredPayload(EventVPNRecovered, "gluetun", 51413, 90*time.Second)
+	if up.Message != "VPN Port Available Again after 1m30s" {
+		t.Errorf("Message = %q", up.Message)
+	}
+	if up.CurrentPort != 51413 || up.DowntimeSeconds != 90 {
+		t.Errorf("payload = %+v, want port 51413 and 90s downtime", up)
+	}
+}
+
+func TestClientSendTest_BypassesEventFilter(t *testing.T) {
+	var received Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{Name: "errors", URL: server.URL, Timeout: 5 * time.Second, Events: []string{EventSyncError}})
+	if err := client.SendTest(t.Context()); err != nil {
+		t.Fatalf("SendTest() error = %v, want nil", err)
+	}
+
+	if received.Event != EventTest {
+		t.Errorf("Event = %q, want test", received.Event)
+	}
+	if received.OldPort != testOldPort || received.NewPort != testNewPort {
+		t.Errorf("ports = %d -> %d, want fake ports", received.OldPort, received.NewPort)
+	}
+}
+
+func TestDispatcherSendTest(t *testing.T) {
+	up := &fakeSender{name: "up"}
+	down := &fakeSender{name: "down", err: errors.New("connection refused")}
+	dispatcher := NewDispatcher(up, down)
+	dispatcher.SetCircuitBreaker(1, time.Hour)
+
+	results := dispatcher.SendTest(t.Context(), "")
+	if len(results) != 2 || results["up"] != nil || results["down"] == nil {
+		t.Errorf("SendTest(\"\") = %v, want success for up and error for down", results)
+	}
+
+	// Test notifications bypass the circuit breaker
+	results = dispatcher.SendTest(t.Context(), "down")
+	if len(results) != 1 || results["down"] == nil || down.calls != 2 {
+		t.Errorf("SendTest(\"down\") = %v after %d calls, want error after 2 calls", results, down.calls)
+	}
+
+	if results := dispatcher.SendTest(t.Context(), "missing"); len(results) != 0 {
+		t.Errorf("SendTest(\"missing\") = %v, want no results", results)
+	}
+	if len(up.sent) != 1 || up.sent[0].Event != EventTest {
+		t.Errorf("up received %v, want one test notification", up.sent)
+	}
+}
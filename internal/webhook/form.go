@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Body encodings of the json template
+const (
+	ContentTypeJSON = "json"
+	ContentTypeForm = "form"
+)
+
+// validateMethod checks a configured HTTP method override; an empty method
+// keeps the template's default
+func validateMethod(method string) error {
+	switch method {
+	case "", http.MethodPost, http.MethodPut, http.MethodPatch:
+		return nil
+	}
+	return fmt.Errorf("unsupported webhook method %q (expected POST, PUT or PATCH)", method)
+}
+
+// validateContentType checks the body encoding of a target. Form encoding
+// is only available for the plain json template, as the other templates
+// follow a fixed service API.
+func validateContentType(tmpl Template, contentType string, fields map[string]string) error {
+	switch contentType {
+	case "", ContentTypeJSON:
+		if len(fields) > 0 {
+			return fmt.Errorf("form fields require content type %q", ContentTypeForm)
+		}
+		return nil
+	case ContentTypeForm:
+	default:
+		return fmt.Errorf("unsupported webhook content type %q (expected %s or %s)", contentType, ContentTypeJSON, ContentTypeForm)
+	}
+
+	if tmpl != TemplateJSON && tmpl != "" {
+		return fmt.Errorf("content type %q is only supported by the %s template", ContentTypeForm, TemplateJSON)
+	}
+	known := payloadJSONFields()
+	for key, field := range fields {
+		if !known[field] {
+			return fmt.Errorf("form field %q maps unknown payload field %q", key, field)
+		}
+	}
+	return nil
+}
+
+// payloadJSONFields returns the JSON names of the payload fields
+func payloadJSONFields() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeFor[Payload]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// formatForm encodes the payload as application/x-www-form-urlencoded. Each
+// JSON field becomes a form value, unless formFields maps form keys to the
+// payload fields to send. Fields omitted from the JSON payload are left out.
+func (c *Client) formatForm(payload Payload) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	if len(c.formFields) == 0 {
+		for key, value := range fields {
+			addFormValue(values, key, value)
+		}
+	} else {
+		for key, field := range c.formFields {
+			addFormValue(values, key, fields[field])
+		}
+	}
+	return []byte(values.Encode()), nil
+}
+
+// addFormValue adds a decoded JSON value to the form; lists become repeated
+// keys
+func addFormValue(values url.Values, key string, value any) {
+	switch v := value.(type) {
+	case nil:
+	case string:
+		values.Add(key, v)
+	case float64:
+		values.Add(key, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		values.Add(key, strconv.FormatBool(v))
+	case []any:
+		for _, item := range v {
+			addFormValue(values, key, item)
+		}
+	default:
+		values.Add(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestForm_MethodAndBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		want   url.Values
+	}{
+		{
+			name:   "all fields",
+			target: Target{Method: "put", ContentType: ContentTypeForm},
+			want: url.Values{
+				"event":    {EventPortChanged},
+				"old_port": {"8080"},
+				"new_port": {"9090"},
+			},
+		},
+		{
+			name: "mapped fields",
+			target: Target{
+				Method:      "PUT",
+				ContentType: ContentTypeForm,
+				FormFields:  map[string]string{"port": "new_port", "text": "message", "error": "error"},
+			},
+			want: url.Values{"port": {"9090"}, "text": {"Port changed from 8080 to 9090"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType string
+			var form url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				contentType = r.Header.Get("Content-Type")
+				body, _ := io.ReadAll(r.Body)
+				form, _ = url.ParseQuery(string(body))
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			target := tt.target
+			target.URL = server.URL
+			target.Timeout = 5 * time.Second
+			target.Template = TemplateJSON
+			client := newTestClient(t, target)
+			if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+				t.Fatalf("SendPortChange() error = %v", err)
+			}
+
+			if method != http.MethodPut {
+				t.Errorf("method = %s, want PUT", method)
+			}
+			if contentType != "application/x-www-form-urlencoded" {
+				t.Errorf("Content-Type = %q, want application/x-www-form-urlencoded", contentType)
+			}
+			for key, want := range tt.want {
+				if got := form[key]; len(got) != len(want) || got[0] != want[0] {
+					t.Errorf("form[%s] = %v, want %v", key, got, want)
+				}
+			}
+			if len(tt.target.FormFields) > 0 && len(form) != len(tt.want) {
+				t.Errorf("form = %v, want only %v", form, tt.want)
+			}
+		})
+	}
+}
+
+func TestForm_ListValues(t *testing.T) {
+	client := newTestClient(t, Target{Template: TemplateJSON, ContentType: ContentTypeForm})
+
+	body, err := client.formatForm(Payload{Event: EventStartup, Targets: []string{"discord", "slack"}})
+	if err != nil {
+		t.Fatalf("formatForm() error = %v", err)
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("failed to parse form: %v", err)
+	}
+	if got := form["targets"]; len(got) != 2 || got[0] != "discord" || got[1] != "slack" {
+		t.Errorf("targets = %v, want [discord slack]", got)
+	}
+}
+
+func TestForm_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+	}{
+		{name: "unsupported method", target: Target{Template: TemplateJSON, Method: "GET"}},
+		{name: "unknown content type", target: Target{Template: TemplateJSON, ContentType: "xml"}},
+		{name: "form with other template", target: Target{Template: TemplateDiscord, ContentType: ContentTypeForm}},
+		{name: "fields without form", target: Target{Template: TemplateJSON, FormFields: map[string]string{"port": "new_port"}}},
+		{name: "unknown payload field", target: Target{Template: TemplateJSON, ContentType: ContentTypeForm, FormFields: map[string]string{"port": "port"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.target); err == nil {
+				t.Error("NewClient() error = nil, want error")
+			}
+		})
+	}
+}
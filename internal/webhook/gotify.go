@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Gotify token modes
+const (
+	GotifyTokenHeader = "header"
+	GotifyTokenQuery  = "query"
+)
+
+// Default Gotify priorities for informational and failure events
+const (
+	gotifyDefaultPriority      = 5
+	gotifyDefaultErrorPriority = 8
+)
+
+// GotifyOptions configures the gotify template
+type GotifyOptions struct {
+	// Token is the application token. It may also be embedded in the URL
+	// instead.
+	Token string
+	// TokenMode sends the token in the X-Gotify-Key header (default) or as
+	// the token query parameter
+	TokenMode string
+	// Priority applies to informational events and ErrorPriority to
+	// failures such as sync_error; 0 selects the defaults of 5 and 8
+	Priority      int
+	ErrorPriority int
+}
+
+func (o GotifyOptions) validate() error {
+	switch o.TokenMode {
+	case "", GotifyTokenHeader, GotifyTokenQuery:
+	default:
+		return fmt.Errorf("invalid gotify token mode %q: must be header or query", o.TokenMode)
+	}
+	for _, priority := range []int{o.Priority, o.ErrorPriority} {
+		if priority < 0 || priority > 10 {
+			return fmt.Errorf("invalid gotify priority %d: must be between 1 and 10", priority)
+		}
+	}
+	return nil
+}
+
+// priority returns the priority for an event
+func (o GotifyOptions) priority(event string) int {
+	if isFailure(event) {
+		if o.ErrorPriority != 0 {
+			return o.ErrorPriority
+		}
+		return gotifyDefaultErrorPriority
+	}
+	if o.Priority != 0 {
+		return o.Priority
+	}
+	return gotifyDefaultPriority
+}
+
+// formatGotify formats payload for Gotify webhook
+func (c *Client) formatGotify(payload Payload) (request, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    payloadTitle(payload),
+		"message":  payload.Message,
+		"priority": c.gotify.priority(payload.Event),
+		"extras":   payload,
+	})
+	if err != nil {
+		return request{}, err
+	}
+
+	req := request{method: http.MethodPost, url: c.url, body: body, contentType: "application/json"}
+	if c.gotify.Token == "" {
+		return req, nil
+	}
+
+	if c.gotify.TokenMode == GotifyTokenQuery {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			return request{}, fmt.Errorf("invalid gotify URL: %w", err)
+		}
+		query := u.Query()
+		query.Set("token", c.gotify.Token)
+		u.RawQuery = query.Encode()
+		req.url = u.String()
+		return req, nil
+	}
+
+	req.header = map[string]string{"X-Gotify-Key": c.gotify.Token}
+	return req, nil
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGotifyTemplate_Token(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       GotifyOptions
+		wantHeader string
+		wantQuery  string
+	}{
+		{name: "token in URL", wantQuery: "url-token"},
+		{name: "header", opts: GotifyOptions{Token: "app-token"}, wantHeader: "app-token", wantQuery: "url-token"},
+		{name: "query", opts: GotifyOptions{Token: "app-token", TokenMode: GotifyTokenQuery}, wantQuery: "app-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header, query string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Get("X-Gotify-Key")
+				query = r.URL.Query().Get("token")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, Target{
+				URL:      server.URL + "/message?token=url-token",
+				Timeout:  5 * time.Second,
+				Template: TemplateGotify,
+				Gotify:   tt.opts,
+			})
+			if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+				t.Fatalf("SendPortChange() error = %v", err)
+			}
+			if header != tt.wantHeader {
+				t.Errorf("X-Gotify-Key = %q, want %q", header, tt.wantHeader)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("token query = %q, want %q", query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestGotifyTemplate_Priority(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GotifyOptions
+		payload Payload
+		want    int
+	}{
+		{name: "default info", payload: newPortChangePayload(8080, 9090), want: 5},
+		{name: "default failure", payload: newSyncErrorPayload("qbittorrent", errors.New("timeout"), 1), want: 8},
+		{name: "configured info", opts: GotifyOptions{Priority: 2, ErrorPriority: 10}, payload: newPortChangePayload(8080, 9090), want: 2},
+		{name: "configured failure", opts: GotifyOptions{Priority: 2, ErrorPriority: 10}, payload: newOutagePayload(EventVPNDown, "gluetun", errors.New("missing")), want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, Target{Template: TemplateGotify, Gotify: tt.opts})
+
+			req, err := client.formatGotify(tt.payload)
+			if err != nil {
+				t.Fatalf("formatGotify() error = %v", err)
+			}
+			var msg struct {
+				Priority int `json:"priority"`
+			}
+			if err := json.Unmarshal(req.body, &msg); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if msg.Priority != tt.want {
+				t.Errorf("priority = %d, want %d", msg.Priority, tt.want)
+			}
+		})
+	}
+}
+
+func TestGotifyOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GotifyOptions
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "query mode", opts: GotifyOptions{TokenMode: GotifyTokenQuery}},
+		{name: "invalid mode", opts: GotifyOptions{TokenMode: "cookie"}, wantErr: true},
+		{name: "priority too high", opts: GotifyOptions{Priority: 11}, wantErr: true},
+		{name: "negative error priority", opts: GotifyOptions{ErrorPriority: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TargetStatus describes the delivery health of a target
+type TargetStatus struct {
+	Name string `json:"name"`
+	// Healthy is false while the last delivery failed or the target is
+	// suspended by its circuit breaker
+	Healthy             bool      `json:"healthy"`
+	LastSuccess         time.Time `json:"last_success,omitzero"`
+	LastFailure         time.Time `json:"last_failure,omitzero"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	SuspendedUntil      time.Time `json:"suspended_until,omitzero"`
+}
+
+// targetHealth tracks the outcome of the deliveries to a target
+type targetHealth struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+	failures    int
+}
+
+func (h *targetHealth) record(err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		h.lastSuccess = now
+		h.failures = 0
+		return
+	}
+	h.lastFailure = now
+	h.lastError = RedactError(err)
+	h.failures++
+}
+
+// RedactError returns the text of a delivery error with the target URL
+// redacted. Request errors quote the URL, whose path or query holds the
+// token of e.g. Discord and Slack, and TargetStatus is served without
+// authentication.
+func RedactError(err error) string {
+	text := err.Error()
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || urlErr.URL == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, urlErr.URL, redactURL(urlErr.URL))
+}
+
+// redactURL hides the credentials, path and query of a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "[redacted]"
+	}
+	redacted := u.Scheme + "://" + u.Host
+	if u.Path != "" && u.Path != "/" || u.RawQuery != "" {
+		redacted += "/[redacted]"
+	}
+	return redacted
+}
+
+// TargetStatus returns the delivery health of every target in configuration
+// order. Test notifications are not taken into account.
+func (d *Dispatcher) TargetStatus() []TargetStatus {
+	now := time.Now()
+	statuses := make([]TargetStatus, len(d.senders))
+	for i, s := range d.senders {
+		h := d.health[i]
+		h.mu.Lock()
+		status := TargetStatus{
+			Name:                s.Name(),
+			Healthy:             h.failures == 0,
+			LastSuccess:         h.lastSuccess,
+			LastFailure:         h.lastFailure,
+			LastError:           h.lastError,
+			ConsecutiveFailures: h.failures,
+		}
+		h.mu.Unlock()
+
+		if d.breakers != nil {
+			if until := d.breakers[i].suspendedUntil(now); !until.IsZero() {
+				status.SuspendedUntil = until
+				status.Healthy = false
+			}
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
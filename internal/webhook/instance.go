@@ -0,0 +1,30 @@
+package webhook
+
+// Instance identifies the Forwardarr instance sending notifications, so that
+// users running several stacks can tell their notifications apart
+type Instance struct {
+	// Name is the configured instance name
+	Name     string
+	Hostname string
+	Version  string
+	// Label is a free-form user-defined tag such as "homelab"
+	Label string
+}
+
+// apply stamps the instance metadata on the payload. Values already set on
+// the payload are kept.
+func (i Instance) apply(payload Payload) Payload {
+	if payload.Instance == "" {
+		payload.Instance = i.Name
+	}
+	if payload.Hostname == "" {
+		payload.Hostname = i.Hostname
+	}
+	if payload.Version == "" {
+		payload.Version = i.Version
+	}
+	if payload.Label == "" {
+		payload.Label = i.Label
+	}
+	return payload
+}
@@ -0,0 +1,27 @@
+package webhook
+
+import "testing"
+
+func TestDispatcherSetInstance(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetInstance(Instance{Name: "seedbox", Hostname: "nas", Version: "1.2.3", Label: "homelab"})
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	if err := dispatcher.SendStartup(t.Context(), "2.0.0", 9090); err != nil {
+		t.Fatalf("SendStartup() error = %v", err)
+	}
+
+	if len(sender.sent) != 2 {
+		t.Fatalf("sent %d payloads, want 2", len(sender.sent))
+	}
+	got := sender.sent[0]
+	if got.Instance != "seedbox" || got.Hostname != "nas" || got.Version != "1.2.3" || got.Label != "homelab" {
+		t.Errorf("payload instance = %q/%q/%q/%q, want seedbox/nas/1.2.3/homelab", got.Instance, got.Hostname, got.Version, got.Label)
+	}
+	if v := sender.sent[1].Version; v != "2.0.0" {
+		t.Errorf("startup Version = %q, want the explicit 2.0.0", v)
+	}
+}
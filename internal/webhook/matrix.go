@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MatrixOptions configures the Matrix template. The target URL is the
+// homeserver base URL, e.g. https://matrix.example.org.
+type MatrixOptions struct {
+	// RoomID is the internal room ID (e.g. !abc123:example.org) the bot
+	// account has joined
+	RoomID string
+	// AccessToken authenticates the sending account
+	AccessToken string
+}
+
+func (o MatrixOptions) validate() error {
+	if o.RoomID == "" || o.AccessToken == "" {
+		return fmt.Errorf("matrix template requires a room ID and access token")
+	}
+	return nil
+}
+
+// formatMatrix builds an m.room.message event for the client-server API. The
+// transaction ID is generated once per notification so that retries of the
+// same request are deduplicated by the homeserver.
+func (c *Client) formatMatrix(payload Payload) (request, error) {
+	title := payloadTitle(payload)
+	formatted := fmt.Sprintf("<strong>%s</strong><br>%s", html.EscapeString(title), html.EscapeString(payload.Message))
+	if payload.Event == EventPortChanged {
+		formatted += fmt.Sprintf("<br><code>%d</code> → <code>%d</code>", payload.OldPort, payload.NewPort)
+	}
+	message := map[string]string{
+		"msgtype":        "m.notice",
+		"body":           fmt.Sprintf("%s\n%s", title, payload.Message),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return request{}, err
+	}
+
+	txnID := fmt.Sprintf("forwardarr-%d", time.Now().UnixNano())
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimRight(c.url, "/"),
+		url.PathEscape(c.matrix.RoomID),
+		txnID,
+	)
+
+	return request{
+		method:      http.MethodPut,
+		url:         endpoint,
+		body:        body,
+		contentType: "application/json",
+		header: map[string]string{
+			"Authorization": "Bearer " + c.matrix.AccessToken,
+		},
+	}, nil
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatrixTemplate(t *testing.T) {
+	var method, path, auth string
+	var event map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.EscapedPath()
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"event_id":"$abc"}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:      server.URL + "/",
+		Timeout:  5 * time.Second,
+		Template: TemplateMatrix,
+		Matrix:   MatrixOptions{RoomID: "!room:example.org", AccessToken: "syt_token"},
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	prefix := "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/forwardarr-"
+	if !strings.HasPrefix(path, prefix) {
+		t.Errorf("path = %q, want prefix %q", path, prefix)
+	}
+	if auth != "Bearer syt_token" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer syt_token")
+	}
+	if event["msgtype"] != "m.notice" {
+		t.Errorf("msgtype = %q, want m.notice", event["msgtype"])
+	}
+	if !strings.Contains(event["body"], "Port changed from 8080 to 9090") {
+		t.Errorf("body = %q, want port change message", event["body"])
+	}
+	if event["format"] != "org.matrix.custom.html" || !strings.Contains(event["formatted_body"], "<code>9090</code>") {
+		t.Errorf("formatted_body = %q, want HTML with new port", event["formatted_body"])
+	}
+}
+
+func TestMatrixTemplate_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options MatrixOptions
+	}{
+		{"missing room", MatrixOptions{AccessToken: "syt_token"}},
+		{"missing token", MatrixOptions{RoomID: "!room:example.org"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(Target{Template: TemplateMatrix, Matrix: tt.options}); err == nil {
+				t.Error("NewClient() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"text/template"
+)
+
+// Messages replaces the built-in English message and title of notifications,
+// e.g. to translate them. Overrides are Go templates executed with the
+// payload, so they can include details such as {{.NewPort}}.
+type Messages struct {
+	messages map[string]*template.Template
+	titles   map[string]*template.Template
+}
+
+// NewMessages parses message and title overrides keyed by event name
+func NewMessages(messages, titles map[string]string) (*Messages, error) {
+	m := &Messages{}
+	var err error
+	if m.messages, err = parseMessageTemplates("message", messages); err != nil {
+		return nil, err
+	}
+	if m.titles, err = parseMessageTemplates("title", titles); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseMessageTemplates parses the overrides of one kind, rejecting unknown
+// events
+func parseMessageTemplates(kind string, texts map[string]string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template, len(texts))
+	for event, text := range texts {
+		if _, ok := eventTitles[event]; !ok {
+			return nil, fmt.Errorf("%s override for unknown event %q", kind, event)
+		}
+		tmpl, err := template.New(event).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s override for event %q: %w", kind, event, err)
+		}
+		templates[event] = tmpl
+	}
+	return templates, nil
+}
+
+// apply renders the overrides for the payload's event. A template that fails
+// to render keeps the built-in text.
+func (m *Messages) apply(payload Payload) Payload {
+	if m == nil {
+		return payload
+	}
+	if tmpl, ok := m.titles[payload.Event]; ok {
+		if title, err := renderMessage(tmpl, payload); err == nil {
+			payload.Title = title
+		} else {
+			slog.Warn("failed to render title override", "event", payload.Event, "error", err)
+		}
+	}
+	if tmpl, ok := m.messages[payload.Event]; ok {
+		if message, err := renderMessage(tmpl, payload); err == nil {
+			payload.Message = message
+		} else {
+			slog.Warn("failed to render message override", "event", payload.Event, "error", err)
+		}
+	}
+	return payload
+}
+
+func renderMessage(tmpl *template.Template, payload Payload) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// payloadTitle returns the notification title of the payload: its override,
+// if any, or the built-in title of its event
+func payloadTitle(payload Payload) string {
+	if payload.Title != "" {
+		return payload.Title
+	}
+	return eventTitle(payload.Event)
+}
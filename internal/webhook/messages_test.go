@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessages_Apply(t *testing.T) {
+	messages, err := NewMessages(
+		map[string]string{EventPortChanged: "Port geändert: {{.OldPort}} → {{.NewPort}}"},
+		map[string]string{EventPortChanged: "Portwechsel"},
+	)
+	if err != nil {
+		t.Fatalf("NewMessages() error = %v", err)
+	}
+
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetMessages(messages)
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	if err := dispatcher.SendStartup(t.Context(), "1.0.0", 9090); err != nil {
+		t.Fatalf("SendStartup() error = %v", err)
+	}
+
+	got := sender.sent[0]
+	if got.Message != "Port geändert: 8080 → 9090" {
+		t.Errorf("Message = %q, want the override", got.Message)
+	}
+	if payloadTitle(got) != "Portwechsel" {
+		t.Errorf("title = %q, want Portwechsel", payloadTitle(got))
+	}
+	if startup := sender.sent[1]; startup.Title != "" || payloadTitle(startup) != eventTitles[EventStartup] {
+		t.Errorf("startup title = %q, want the built-in title", payloadTitle(startup))
+	}
+}
+
+func TestMessages_TitleInTemplate(t *testing.T) {
+	client := newTestClient(t, Target{Template: TemplateGotify})
+	payload := newPortChangePayload(8080, 9090)
+	payload.Title = "Portwechsel"
+
+	req, err := client.formatGotify(payload)
+	if err != nil {
+		t.Fatalf("formatGotify() error = %v", err)
+	}
+	var msg struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(req.body, &msg); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if msg.Title != "Portwechsel" {
+		t.Errorf("title = %q, want Portwechsel", msg.Title)
+	}
+}
+
+func TestMessages_RenderErrorKeepsDefault(t *testing.T) {
+	messages, err := NewMessages(map[string]string{EventPortChanged: "{{.Missing}}"}, nil)
+	if err != nil {
+		t.Fatalf("NewMessages() error = %v", err)
+	}
+
+	payload := messages.apply(newPortChangePayload(8080, 9090))
+	if payload.Message != "Port changed from 8080 to 9090" {
+		t.Errorf("Message = %q, want the built-in message", payload.Message)
+	}
+}
+
+func TestNewMessages_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages map[string]string
+		titles   map[string]string
+	}{
+		{name: "unknown event", messages: map[string]string{"port_change": "Port {{.NewPort}}"}},
+		{name: "invalid template", titles: map[string]string{EventStartup: "{{.Version"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMessages(tt.messages, tt.titles); err == nil {
+				t.Error("NewMessages() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/eslutz/forwardarr/internal/mqtt"
+)
+
+// TemplateMQTT selects the MQTT publisher instead of an HTTP webhook
+const TemplateMQTT Template = "mqtt"
+
+// MQTTOptions configures MQTT notifications
+type MQTTOptions struct {
+	// Topic defaults to forwardarr/events
+	Topic string
+	// QoS is 0 (at most once) or 1 (at least once)
+	QoS      int
+	Retain   bool
+	Username string
+	Password string
+	// ClientID identifies the connection to the broker; defaults to forwardarr
+	ClientID string
+}
+
+func (o MQTTOptions) validate() error {
+	if strings.ContainsAny(o.Topic, "+#") {
+		return fmt.Errorf("mqtt topic %q must not contain wildcards", o.Topic)
+	}
+	if o.QoS != 0 && o.QoS != 1 {
+		return fmt.Errorf("unsupported mqtt QoS %d: must be 0 or 1", o.QoS)
+	}
+	return nil
+}
+
+// MQTTSender publishes notifications to an MQTT broker
+type MQTTSender struct {
+	name        string
+	client      *mqtt.Client
+	events      map[string]bool
+	minSeverity Severity
+	mqtt        MQTTOptions
+	retry       RetryPolicy
+}
+
+// NewMQTTSender creates an MQTT sender for the given target. The target URL
+// names the broker as mqtt://host:port, or mqtts://host:port for TLS.
+func NewMQTTSender(target Target) (*MQTTSender, error) {
+	opts := target.MQTT
+	if opts.Topic == "" {
+		opts.Topic = "forwardarr/events"
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "forwardarr"
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	eventMap, err := newEventFilter(target.Events)
+	if err != nil {
+		return nil, err
+	}
+	minSeverity, err := ParseSeverity(target.MinSeverity)
+	if err != nil {
+		return nil, err
+	}
+
+	// The TLS options only apply to mqtts:// brokers
+	var tlsConfig *tls.Config
+	if u, err := url.Parse(target.URL); err == nil && u.Scheme == "mqtts" {
+		tlsConfig, err = target.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := mqtt.NewClient(target.URL, mqtt.Options{
+		Username: opts.Username,
+		Password: opts.Password,
+		ClientID: opts.ClientID,
+		TLS:      tlsConfig,
+		Timeout:  target.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MQTTSender{
+		name:        target.Name,
+		client:      client,
+		events:      eventMap,
+		minSeverity: minSeverity,
+		mqtt:        opts,
+		retry:       target.Retry,
+	}, nil
+}
+
+// Name returns the configured name of the MQTT target
+func (s *MQTTSender) Name() string {
+	return s.name
+}
+
+// Send publishes the JSON payload unless its event is filtered out for this
+// target
+func (s *MQTTSender) Send(ctx context.Context, payload Payload) error {
+	filtered, ok := filterEvents(s.events, s.minSeverity, payload)
+	if !ok {
+		slog.Debug("webhook event filtered out", "webhook", s.name, "event", payload.Event)
+		return nil
+	}
+	payload = filtered
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to format webhook payload: %w", err)}
+	}
+
+	return s.retry.run(ctx, s.name, func() error {
+		return s.publish(ctx, body)
+	})
+}
+
+// publish publishes a single message. A refused connection is permanent
+// unless the broker is only unavailable for now; the other return codes
+// reject the protocol version, client ID or credentials.
+func (s *MQTTSender) publish(ctx context.Context, body []byte) error {
+	err := s.client.Publish(ctx, s.mqtt.Topic, body, byte(s.mqtt.QoS), s.mqtt.Retain)
+	var connectErr *mqtt.ConnectError
+	if errors.As(err, &connectErr) && connectErr.Code != mqtt.ServerUnavailable {
+		return &PermanentError{Err: err}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("mqtt notification published", "webhook", s.name, "broker", s.client.Addr(), "topic", s.mqtt.Topic)
+	return nil
+}
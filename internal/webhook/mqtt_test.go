@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eslutz/forwardarr/internal/mqtt/mqtttest"
+)
+
+func TestMQTTSender_Publish(t *testing.T) {
+	tests := []struct {
+		name   string
+		qos    int
+		retain bool
+	}{
+		{name: "qos 0", qos: 0},
+		{name: "qos 1 retained", qos: 1, retain: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := mqtttest.NewBroker(t, mqtttest.Config{})
+
+			sender, err := NewMQTTSender(Target{
+				Name:    "mqtt",
+				URL:     broker.URL(),
+				Timeout: 5 * time.Second,
+				MQTT: MQTTOptions{
+					Topic:    "forwardarr/port",
+					QoS:      tt.qos,
+					Retain:   tt.retain,
+					Username: "user",
+					Password: "pass",
+				},
+			})
+			if err != nil {
+				t.Fatalf("NewMQTTSender() error = %v", err)
+			}
+
+			if err := sender.Send(t.Context(), newPortChangePayload(8080, 9090)); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			var msg mqtttest.Message
+			select {
+			case msg = <-broker.Received():
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for publish")
+			}
+
+			if msg.Topic != "forwardarr/port" {
+				t.Errorf("topic = %q, want forwardarr/port", msg.Topic)
+			}
+			if int(msg.QoS) != tt.qos {
+				t.Errorf("QoS = %d, want %d", msg.QoS, tt.qos)
+			}
+			if msg.Retain != tt.retain {
+				t.Errorf("retain = %v, want %v", msg.Retain, tt.retain)
+			}
+			if _, username, password := broker.Connect(); username != "user" || password != "pass" {
+				t.Errorf("broker got user %q, password %q, want user, pass", username, password)
+			}
+
+			var payload Payload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				t.Fatalf("failed to decode payload: %v", err)
+			}
+			if payload.NewPort != 9090 {
+				t.Errorf("NewPort = %d, want 9090", payload.NewPort)
+			}
+		})
+	}
+}
+
+func TestMQTTSender_ConnectionRefused(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          byte
+		wantPermanent bool
+	}{
+		{name: "bad credentials", code: 5, wantPermanent: true},
+		{name: "server unavailable", code: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := mqtttest.NewBroker(t, mqtttest.Config{ConnAck: tt.code})
+
+			sender, err := NewMQTTSender(Target{
+				Name:    "mqtt",
+				URL:     broker.URL(),
+				Timeout: 5 * time.Second,
+				MQTT:    MQTTOptions{Topic: "forwardarr/port"},
+			})
+			if err != nil {
+				t.Fatalf("NewMQTTSender() error = %v", err)
+			}
+
+			err = sender.Send(t.Context(), newPortChangePayload(8080, 9090))
+			if err == nil || !strings.Contains(err.Error(), "refused connection") {
+				t.Errorf("Send() error = %v, want refused connection", err)
+			}
+			var permanent *PermanentError
+			if got := errors.As(err, &permanent); got != tt.wantPermanent {
+				t.Errorf("Send() error permanent = %v, want %v", got, tt.wantPermanent)
+			}
+		})
+	}
+}
+
+func TestNewMQTTSender_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		opts     MQTTOptions
+		wantErr  string
+		wantAddr string
+	}{
+		{name: "default port", url: "mqtt://broker", opts: MQTTOptions{Topic: "t"}, wantAddr: "broker:1883"},
+		{name: "default tls port", url: "mqtts://broker", opts: MQTTOptions{Topic: "t"}, wantAddr: "broker:8883"},
+		{name: "wrong scheme", url: "http://broker", opts: MQTTOptions{Topic: "t"}, wantErr: "expected mqtt://"},
+		{name: "default topic", url: "mqtt://broker", wantAddr: "broker:1883"},
+		{name: "wildcard topic", url: "mqtt://broker", opts: MQTTOptions{Topic: "a/#"}, wantErr: "wildcards"},
+		{name: "qos 2", url: "mqtt://broker", opts: MQTTOptions{Topic: "t", QoS: 2}, wantErr: "unsupported mqtt QoS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, err := NewMQTTSender(Target{URL: tt.url, MQTT: tt.opts})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NewMQTTSender() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewMQTTSender() error = %v", err)
+			}
+			if addr := sender.client.Addr(); addr != tt.wantAddr {
+				t.Errorf("addr = %q, want %q", addr, tt.wantAddr)
+			}
+		})
+	}
+}
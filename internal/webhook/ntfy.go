@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ntfyPriorities lists the priority names accepted by ntfy next to 1-5
+var ntfyPriorities = map[string]bool{
+	"min":     true,
+	"low":     true,
+	"default": true,
+	"high":    true,
+	"max":     true,
+	"urgent":  true,
+}
+
+// NtfyOptions configures the ntfy template
+type NtfyOptions struct {
+	// Topic is appended to the target URL; leave empty when the URL already
+	// points at the topic (e.g. https://ntfy.sh/forwardarr)
+	Topic string
+	// Priority is 1-5 or one of min, low, default, high, max, urgent
+	Priority string
+	// Tags are shown as emojis or labels next to the notification
+	Tags []string
+	// Token is an ntfy access token sent as a bearer token
+	Token string
+}
+
+func (o NtfyOptions) validate() error {
+	if o.Priority == "" || ntfyPriorities[o.Priority] {
+		return nil
+	}
+	if n, err := strconv.Atoi(o.Priority); err == nil && n >= 1 && n <= 5 {
+		return nil
+	}
+	return fmt.Errorf("invalid ntfy priority %q: must be 1-5 or min, low, default, high, max, urgent", o.Priority)
+}
+
+// formatNtfy builds a publish request for ntfy, which takes the message as a
+// plain-text body and the metadata as headers
+func (c *Client) formatNtfy(payload Payload) request {
+	url := c.url
+	if c.ntfy.Topic != "" {
+		url = strings.TrimRight(url, "/") + "/" + c.ntfy.Topic
+	}
+
+	header := map[string]string{
+		"Title": payloadTitle(payload),
+	}
+	if c.ntfy.Priority != "" {
+		header["Priority"] = c.ntfy.Priority
+	}
+	if len(c.ntfy.Tags) > 0 {
+		header["Tags"] = strings.Join(c.ntfy.Tags, ",")
+	}
+	if c.ntfy.Token != "" {
+		header["Authorization"] = "Bearer " + c.ntfy.Token
+	}
+
+	return request{
+		method:      http.MethodPost,
+		url:         url,
+		body:        []byte(payload.Message),
+		contentType: "text/plain; charset=utf-8",
+		header:      header,
+	}
+}
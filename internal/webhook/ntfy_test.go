@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNtfyTemplate(t *testing.T) {
+	var path string
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:      server.URL + "/",
+		Timeout:  5 * time.Second,
+		Template: TemplateNtfy,
+		Ntfy: NtfyOptions{
+			Topic:    "forwardarr",
+			Priority: "high",
+			Tags:     []string{"electric_plug", "vpn"},
+			Token:    "tk_secret",
+		},
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	if path != "/forwardarr" {
+		t.Errorf("request path = %q, want /forwardarr", path)
+	}
+	if string(body) != "Port changed from 8080 to 9090" {
+		t.Errorf("body = %q, want port change message", body)
+	}
+	expected := map[string]string{
+		"Title":         "Port Change Notification",
+		"Priority":      "high",
+		"Tags":          "electric_plug,vpn",
+		"Authorization": "Bearer tk_secret",
+		"Content-Type":  "text/plain; charset=utf-8",
+	}
+	for name, value := range expected {
+		if got := header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestNtfyTemplate_TopicInURL(t *testing.T) {
+	var path string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		header = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:      server.URL + "/alerts",
+		Timeout:  5 * time.Second,
+		Template: TemplateNtfy,
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	if path != "/alerts" {
+		t.Errorf("request path = %q, want /alerts", path)
+	}
+	for _, name := range []string{"Priority", "Tags", "Authorization"} {
+		if _, ok := header[name]; ok {
+			t.Errorf("%s header present, want absent", name)
+		}
+	}
+}
+
+func TestNtfyOptionsValidate(t *testing.T) {
+	tests := []struct {
+		priority string
+		wantErr  bool
+	}{
+		{"", false},
+		{"1", false},
+		{"5", false},
+		{"urgent", false},
+		{"min", false},
+		{"0", true},
+		{"6", true},
+		{"critical", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			err := NtfyOptions{Priority: tt.priority}.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
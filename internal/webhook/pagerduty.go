@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PagerDutyURL is the PagerDuty Events API v2 endpoint
+const PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyTriggers maps alerting events to the dedup key of the incident they
+// open. Resolving events close the incident opened by their failure event, so
+// repeated failures update a single incident instead of paging again.
+var (
+	pagerDutyTriggers = map[string]string{
+		"sync_error":       "forwardarr-sync_error",
+		"qbit_unreachable": "forwardarr-qbit_unreachable",
+		EventTest:          "forwardarr-test",
+	}
+	pagerDutyResolves = map[string]string{
+		"sync_recovered": "forwardarr-sync_error",
+		"qbit_recovered": "forwardarr-qbit_unreachable",
+	}
+)
+
+// pagerDutySeverities lists the severities accepted by the Events API
+var pagerDutySeverities = map[string]bool{
+	"critical": true,
+	"error":    true,
+	"warning":  true,
+	"info":     true,
+}
+
+// PagerDutyOptions configures the PagerDuty template
+type PagerDutyOptions struct {
+	// RoutingKey is the integration key of the PagerDuty service
+	RoutingKey string
+	// Severity of triggered alerts: critical, error (default), warning or info
+	Severity string
+}
+
+func (o PagerDutyOptions) validate() error {
+	if o.RoutingKey == "" {
+		return fmt.Errorf("pagerduty template requires a routing key")
+	}
+	if o.Severity != "" && !pagerDutySeverities[o.Severity] {
+		return fmt.Errorf("invalid pagerduty severity %q: must be critical, error, warning or info", o.Severity)
+	}
+	return nil
+}
+
+// pagerDutyHandles reports whether the event triggers or resolves an alert.
+// Informational events such as port changes are not sent to PagerDuty.
+func pagerDutyHandles(event string) bool {
+	_, trigger := pagerDutyTriggers[event]
+	_, resolve := pagerDutyResolves[event]
+	return trigger || resolve
+}
+
+// formatPagerDuty formats payload as a PagerDuty Events API v2 event
+func (c *Client) formatPagerDuty(payload Payload) ([]byte, error) {
+	if key, ok := pagerDutyResolves[payload.Event]; ok {
+		return json.Marshal(map[string]interface{}{
+			"routing_key":  c.pagerduty.RoutingKey,
+			"event_action": "resolve",
+			"dedup_key":    key,
+		})
+	}
+
+	key, ok := pagerDutyTriggers[payload.Event]
+	if !ok {
+		return nil, fmt.Errorf("event %q is not sent to pagerduty", payload.Event)
+	}
+
+	severity := c.pagerduty.Severity
+	if severity == "" {
+		severity = "error"
+	}
+	if payload.Event == EventTest {
+		severity = "info"
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"routing_key":  c.pagerduty.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"payload": map[string]interface{}{
+			"summary":        payload.Message,
+			"source":         "forwardarr",
+			"severity":       severity,
+			"timestamp":      payload.Timestamp.Format(time.RFC3339),
+			"component":      "forwardarr",
+			"class":          payload.Event,
+			"custom_details": payload,
+		},
+	})
+}
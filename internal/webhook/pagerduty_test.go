@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyTemplate_Trigger(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:       server.URL,
+		Timeout:   5 * time.Second,
+		Template:  TemplatePagerDuty,
+		PagerDuty: PagerDutyOptions{RoutingKey: "routing-key"},
+	})
+	err := client.Send(t.Context(), Payload{Event: "sync_error", Timestamp: time.Now(), Message: "failed to set port"})
+	if err != nil {
+		t.Fatalf("Send() error = %v, want nil", err)
+	}
+
+	if received["routing_key"] != "routing-key" {
+		t.Errorf("routing_key = %v, want routing-key", received["routing_key"])
+	}
+	if received["event_action"] != "trigger" {
+		t.Errorf("event_action = %v, want trigger", received["event_action"])
+	}
+	if received["dedup_key"] != "forwardarr-sync_error" {
+		t.Errorf("dedup_key = %v, want forwardarr-sync_error", received["dedup_key"])
+	}
+	details, ok := received["payload"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("payload = %v, want object", received["payload"])
+	}
+	if details["summary"] != "failed to set port" {
+		t.Errorf("summary = %v, want error message", details["summary"])
+	}
+	if details["severity"] != "error" {
+		t.Errorf("severity = %v, want default error", details["severity"])
+	}
+}
+
+func TestPagerDutyTemplate_Resolve(t *testing.T) {
+	client := newTestClient(t, Target{
+		Template:  TemplatePagerDuty,
+		PagerDuty: PagerDutyOptions{RoutingKey: "routing-key", Severity: "critical"},
+	})
+
+	body, err := client.formatPagerDuty(Payload{Event: "sync_recovered"})
+	if err != nil {
+		t.Fatalf("formatPagerDuty() error = %v", err)
+	}
+
+	var received map[string]interface{}
+	if err := json.Unmarshal(body, &received); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if received["event_action"] != "resolve" {
+		t.Errorf("event_action = %v, want resolve", received["event_action"])
+	}
+	if received["dedup_key"] != "forwardarr-sync_error" {
+		t.Errorf("dedup_key = %v, want the key of the triggering event", received["dedup_key"])
+	}
+	if _, ok := received["payload"]; ok {
+		t.Error("resolve event contains 'payload' field")
+	}
+}
+
+func TestPagerDutyTemplate_SkipsInformationalEvents(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:       server.URL,
+		Timeout:   5 * time.Second,
+		Template:  TemplatePagerDuty,
+		PagerDuty: PagerDutyOptions{RoutingKey: "routing-key"},
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("server calls = %d, want 0 for port_changed", calls.Load())
+	}
+}
+
+func TestPagerDutyOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PagerDutyOptions
+		wantErr bool
+	}{
+		{name: "valid", opts: PagerDutyOptions{RoutingKey: "key"}},
+		{name: "valid severity", opts: PagerDutyOptions{RoutingKey: "key", Severity: "warning"}},
+		{name: "missing routing key", opts: PagerDutyOptions{}, wantErr: true},
+		{name: "invalid severity", opts: PagerDutyOptions{RoutingKey: "key", Severity: "fatal"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPagerDutyTemplate_TestEvent(t *testing.T) {
+	client := newTestClient(t, Target{
+		Template:  TemplatePagerDuty,
+		PagerDuty: PagerDutyOptions{RoutingKey: "routing-key", Severity: "critical"},
+	})
+
+	body, err := client.formatPagerDuty(newTestPayload())
+	if err != nil {
+		t.Fatalf("formatPagerDuty() error = %v", err)
+	}
+
+	var event struct {
+		DedupKey string `json:"dedup_key"`
+		Payload  struct {
+			Severity string `json:"severity"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if event.DedupKey != "forwardarr-test" {
+		t.Errorf("dedup_key = %q, want forwardarr-test", event.DedupKey)
+	}
+	if event.Payload.Severity != "info" {
+		t.Errorf("severity = %q, want info for test events", event.Payload.Severity)
+	}
+}
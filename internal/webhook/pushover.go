@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PushoverURL is the Pushover message API endpoint
+const PushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Emergency priority notifications are repeated until acknowledged; Pushover
+// requires a retry interval and expiry for them.
+const (
+	pushoverEmergencyPriority = 2
+	pushoverEmergencyRetry    = 60
+	pushoverEmergencyExpire   = 3600
+)
+
+// PushoverOptions configures the Pushover template
+type PushoverOptions struct {
+	// Token is the Pushover application API token
+	Token string
+	// User is the user or group key that receives the notification
+	User string
+	// Priority ranges from -2 (lowest) to 2 (emergency)
+	Priority int
+}
+
+func (o PushoverOptions) validate() error {
+	if o.Token == "" || o.User == "" {
+		return fmt.Errorf("pushover template requires an application token and user key")
+	}
+	if o.Priority < -2 || o.Priority > pushoverEmergencyPriority {
+		return fmt.Errorf("pushover priority %d out of range [-2, 2]", o.Priority)
+	}
+	return nil
+}
+
+// formatPushover formats payload for the Pushover message API
+func (c *Client) formatPushover(payload Payload) ([]byte, error) {
+	pushover := map[string]interface{}{
+		"token":     c.pushover.Token,
+		"user":      c.pushover.User,
+		"title":     payloadTitle(payload),
+		"message":   payload.Message,
+		"priority":  c.pushover.Priority,
+		"timestamp": payload.Timestamp.Unix(),
+	}
+	if c.pushover.Priority == pushoverEmergencyPriority {
+		pushover["retry"] = pushoverEmergencyRetry
+		pushover["expire"] = pushoverEmergencyExpire
+	}
+	return json.Marshal(pushover)
+}
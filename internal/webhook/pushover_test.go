@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPushoverTemplate(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{
+		URL:      server.URL,
+		Timeout:  5 * time.Second,
+		Template: TemplatePushover,
+		Pushover: PushoverOptions{Token: "app-token", User: "user-key", Priority: 1},
+	})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	if received["token"] != "app-token" {
+		t.Errorf("token = %v, want app-token", received["token"])
+	}
+	if received["user"] != "user-key" {
+		t.Errorf("user = %v, want user-key", received["user"])
+	}
+	if received["message"] != "Port changed from 8080 to 9090" {
+		t.Errorf("message = %v, want port change message", received["message"])
+	}
+	if received["title"] == nil {
+		t.Error("Pushover payload missing 'title' field")
+	}
+	if received["priority"] != float64(1) {
+		t.Errorf("priority = %v, want 1", received["priority"])
+	}
+	if _, ok := received["retry"]; ok {
+		t.Error("non-emergency Pushover payload contains 'retry' field")
+	}
+}
+
+func TestPushoverTemplate_EmergencyPriority(t *testing.T) {
+	client := newTestClient(t, Target{
+		Template: TemplatePushover,
+		Pushover: PushoverOptions{Token: "app-token", User: "user-key", Priority: 2},
+	})
+
+	body, err := client.formatPushover(Payload{Message: "Port changed from 1 to 2"})
+	if err != nil {
+		t.Fatalf("formatPushover() error = %v", err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload["retry"] == nil || payload["expire"] == nil {
+		t.Errorf("emergency payload = %v, want retry and expire fields", payload)
+	}
+}
+
+func TestPushoverTemplate_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options PushoverOptions
+	}{
+		{"missing token", PushoverOptions{User: "user-key"}},
+		{"missing user", PushoverOptions{Token: "app-token"}},
+		{"priority too low", PushoverOptions{Token: "app-token", User: "user-key", Priority: -3}},
+		{"priority too high", PushoverOptions{Token: "app-token", User: "user-key", Priority: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(Target{Template: TemplatePushover, Pushover: tt.options}); err == nil {
+				t.Error("NewClient() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxQueuedDeliveries caps the queue file; the oldest deliveries are dropped
+// first once it is exceeded
+const maxQueuedDeliveries = 1000
+
+// queuedDelivery is a failed delivery stored in the queue file
+type queuedDelivery struct {
+	Target   string    `json:"target"`
+	Payload  Payload   `json:"payload"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// Queue persists failed deliveries as JSON lines so they can be retried
+// later, including after a restart
+type Queue struct {
+	path string
+	mu   sync.Mutex
+	// count is the number of entries in the file, or -1 until it is loaded
+	count int
+}
+
+// NewQueue creates a queue stored in the file at path. The file and its
+// directory are created on the first failed delivery.
+func NewQueue(path string) *Queue {
+	return &Queue{path: path, count: -1}
+}
+
+// Add appends a failed delivery to the queue. The file is only rewritten when
+// it is full.
+func (q *Queue) Add(target string, payload Payload) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry := queuedDelivery{Target: target, Payload: payload, QueuedAt: time.Now().UTC()}
+
+	if q.count < 0 {
+		entries, err := q.load()
+		if err != nil {
+			return err
+		}
+		q.count = len(entries)
+	}
+	if q.count >= maxQueuedDeliveries {
+		entries, err := q.load()
+		if err != nil {
+			return err
+		}
+		dropped := len(entries) + 1 - maxQueuedDeliveries
+		slog.Warn("webhook queue full, dropping oldest deliveries", "dropped", dropped)
+		return q.save(append(entries[dropped:], entry))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create webhook queue directory: %w", err)
+	}
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open webhook queue: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write webhook queue: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write webhook queue: %w", err)
+	}
+	q.count++
+	return nil
+}
+
+// Len returns the number of queued deliveries
+func (q *Queue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.load()
+	return len(entries), err
+}
+
+// replay redelivers the queued deliveries of the given senders and keeps the
+// ones that fail again. With all set, senders are all configured targets and
+// deliveries for targets that no longer exist are dropped. The deliveries are
+// taken out of the queue before sending, so that slow targets do not block
+// Add; the failed ones are put back in front of those added meanwhile.
+func (q *Queue) replay(ctx context.Context, senders []Sender, all bool) error {
+	byName := make(map[string]Sender, len(senders))
+	for _, s := range senders {
+		byName[s.Name()] = s
+	}
+
+	pending, err := q.take(func(entry queuedDelivery) bool {
+		_, ok := byName[entry.Target]
+		return ok || all
+	})
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	var remaining []queuedDelivery
+	for _, entry := range pending {
+		sender, ok := byName[entry.Target]
+		if !ok {
+			slog.Warn("dropping queued webhook delivery for unknown target", "webhook", entry.Target, "event", entry.Payload.Event)
+			continue
+		}
+		if err := sender.Send(ctx, entry.Payload); err != nil {
+			if !IsRetryable(err) {
+				slog.Warn("dropping queued webhook delivery after permanent failure", "webhook", entry.Target, "event", entry.Payload.Event, "error", err)
+				continue
+			}
+			slog.Warn("queued webhook delivery failed again", "webhook", entry.Target, "event", entry.Payload.Event, "error", err)
+			remaining = append(remaining, entry)
+			continue
+		}
+		slog.Info("delivered queued webhook notification", "webhook", entry.Target, "event", entry.Payload.Event, "queued_at", entry.QueuedAt)
+	}
+
+	return q.requeue(remaining)
+}
+
+// take removes the queued deliveries matching replay from the queue and
+// returns them
+func (q *Queue) take(replay func(queuedDelivery) bool) ([]queuedDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.load()
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	var taken, kept []queuedDelivery
+	for _, entry := range entries {
+		if replay(entry) {
+			taken = append(taken, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if len(taken) == 0 {
+		return nil, nil
+	}
+	return taken, q.save(kept)
+}
+
+// requeue puts deliveries taken from the queue back in front of the queued
+// ones, dropping the oldest if the queue is full
+func (q *Queue) requeue(entries []queuedDelivery) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued, err := q.load()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, queued...)
+	if dropped := len(entries) - maxQueuedDeliveries; dropped > 0 {
+		slog.Warn("webhook queue full, dropping oldest deliveries", "dropped", dropped)
+		entries = entries[dropped:]
+	}
+	return q.save(entries)
+}
+
+// load reads all queued deliveries. Unreadable lines are skipped so that a
+// truncated write cannot block the rest of the queue.
+func (q *Queue) load() ([]queuedDelivery, error) {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook queue: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []queuedDelivery
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry queuedDelivery
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Warn("skipping invalid webhook queue entry", "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook queue: %w", err)
+	}
+	return entries, nil
+}
+
+// save atomically replaces the queue file with the given deliveries
+func (q *Queue) save(entries []queuedDelivery) error {
+	q.count = -1
+	if len(entries) == 0 {
+		if err := os.Remove(q.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clear webhook queue: %w", err)
+		}
+		q.count = 0
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create webhook queue directory: %w", err)
+	}
+
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write webhook queue: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to write webhook queue: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write webhook queue: %w", err)
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("failed to write webhook queue: %w", err)
+	}
+	q.count = len(entries)
+	return nil
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSender records payloads and fails while err is set
+type fakeSender struct {
+	name  string
+	err   error
+	sent  []Payload
+	calls int
+}
+
+func (s *fakeSender) Name() string { return s.name }
+
+func (s *fakeSender) Send(ctx context.Context, payload Payload) error {
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, payload)
+	return nil
+}
+
+func TestDispatcherQueuesFailedDeliveries(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "data", "queue.jsonl"))
+	down := &fakeSender{name: "down", err: errors.New("connection refused")}
+	up := &fakeSender{name: "up"}
+
+	dispatcher := NewDispatcher(down, up)
+	dispatcher.SetQueue(queue)
+
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err == nil {
+		t.Fatal("SendPortChange() error = nil, want error for failing target")
+	}
+	if n, err := queue.Len(); err != nil || n != 1 {
+		t.Fatalf("queue.Len() = %d, %v, want 1", n, err)
+	}
+
+	// A restarted dispatcher with a recovered target redelivers the queue
+	down.err = nil
+	restarted := NewDispatcher(down, up)
+	restarted.SetQueue(NewQueue(queue.path))
+	if err := restarted.Replay(t.Context()); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if len(down.sent) != 1 || down.sent[0].NewPort != 9090 {
+		t.Errorf("down target received %v, want the queued port change", down.sent)
+	}
+	if len(up.sent) != 1 {
+		t.Errorf("up target received %d payloads, want 1 (not redelivered)", len(up.sent))
+	}
+	if _, err := os.Stat(queue.path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("queue file still exists after successful replay: %v", err)
+	}
+}
+
+func TestDispatcherReplaysAfterSuccessfulDelivery(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	flaky := &fakeSender{name: "flaky", err: errors.New("timeout")}
+
+	dispatcher := NewDispatcher(flaky)
+	dispatcher.SetQueue(queue)
+
+	_ = dispatcher.SendPortChange(t.Context(), 1000, 2000)
+	_ = dispatcher.SendPortChange(t.Context(), 2000, 3000)
+
+	flaky.err = nil
+	if err := dispatcher.SendPortChange(t.Context(), 3000, 4000); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+
+	if len(flaky.sent) != 3 {
+		t.Fatalf("flaky target received %d payloads, want 3", len(flaky.sent))
+	}
+	if flaky.sent[1].NewPort != 2000 || flaky.sent[2].NewPort != 3000 {
+		t.Errorf("queued deliveries replayed out of order: %v", flaky.sent)
+	}
+	if n, _ := queue.Len(); n != 0 {
+		t.Errorf("queue.Len() = %d, want 0", n)
+	}
+}
+
+func TestQueueReplayDropsUnknownTargets(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err := queue.Add("removed", newPortChangePayload(1, 2)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	dispatcher := NewDispatcher(&fakeSender{name: "other"})
+	dispatcher.SetQueue(queue)
+	if err := dispatcher.Replay(t.Context()); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if n, _ := queue.Len(); n != 0 {
+		t.Errorf("queue.Len() = %d, want 0", n)
+	}
+}
+
+// addingSender adds a delivery to the queue while it sends
+type addingSender struct {
+	fakeSender
+	queue *Queue
+}
+
+func (s *addingSender) Send(ctx context.Context, payload Payload) error {
+	if err := s.queue.Add("other", newPortChangePayload(3, 4)); err != nil {
+		return err
+	}
+	return s.fakeSender.Send(ctx, payload)
+}
+
+func TestQueueReplayDoesNotHoldTheLockWhileSending(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err := queue.Add("slow", newPortChangePayload(1, 2)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	sender := &addingSender{fakeSender: fakeSender{name: "slow", err: errors.New("connection refused")}, queue: queue}
+
+	done := make(chan error, 1)
+	go func() { done <- queue.replay(t.Context(), []Sender{sender}, false) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("replay() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay() blocked Add while sending")
+	}
+
+	queue.mu.Lock()
+	entries, err := queue.load()
+	queue.mu.Unlock()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	// The failed delivery is kept in front of the one added meanwhile
+	if len(entries) != 2 || entries[0].Target != "slow" || entries[1].Target != "other" {
+		t.Errorf("entries = %+v, want slow then other", entries)
+	}
+}
+
+func TestQueueSkipsInvalidLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	queue := NewQueue(path)
+	if err := queue.Add("a", newPortChangePayload(1, 2)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open queue: %v", err)
+	}
+	_, _ = f.WriteString("{truncated\n")
+	_ = f.Close()
+
+	if n, err := queue.Len(); err != nil || n != 1 {
+		t.Errorf("queue.Len() = %d, %v, want 1", n, err)
+	}
+}
+
+func TestQueueDropsOldestWhenFull(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	for i := range maxQueuedDeliveries + 5 {
+		if err := queue.Add("a", newPortChangePayload(i, i+1)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	queue.mu.Lock()
+	entries, err := queue.load()
+	queue.mu.Unlock()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(entries) != maxQueuedDeliveries {
+		t.Fatalf("len(entries) = %d, want %d", len(entries), maxQueuedDeliveries)
+	}
+	if entries[0].Payload.OldPort != 5 {
+		t.Errorf("oldest entry OldPort = %d, want 5", entries[0].Payload.OldPort)
+	}
+}
+
+func TestDispatcherDoesNotQueuePermanentFailures(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	rejected := &fakeSender{name: "rejected", err: &StatusError{StatusCode: http.StatusUnauthorized}}
+
+	dispatcher := NewDispatcher(rejected)
+	dispatcher.SetQueue(queue)
+	if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err == nil {
+		t.Fatal("SendPortChange() error = nil, want error")
+	}
+
+	if n, err := queue.Len(); err != nil || n != 0 {
+		t.Errorf("queue.Len() = %d, %v, want 0 queued deliveries", n, err)
+	}
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// deduplicator suppresses notifications that repeat an event with the same
+// ports within window, e.g. when the port source flaps
+type deduplicator struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// duplicate reports whether an equal notification was seen within the window
+// before now, and records the payload otherwise
+func (d *deduplicator) duplicate(payload Payload, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, seen := range d.seen {
+		if now.Sub(seen) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+
+	key := fmt.Sprintf("%s:%d:%d", payload.Event, payload.OldPort, payload.NewPort)
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	if d.seen == nil {
+		d.seen = make(map[string]time.Time)
+	}
+	d.seen[key] = now
+	return false
+}
+
+// rateLimiter allows at most limit notifications per target within any
+// minute
+type rateLimiter struct {
+	limit int
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+// allow reports whether another notification may be sent at now and counts
+// it if so
+func (r *rateLimiter) allow(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recent := r.sent[:0]
+	for _, t := range r.sent {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	r.sent = recent
+
+	if len(r.sent) >= r.limit {
+		return false
+	}
+	r.sent = append(r.sent, now)
+	return true
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := &deduplicator{window: time.Minute}
+	now := time.Now()
+
+	if d.duplicate(newPortChangePayload(8080, 9090), now) {
+		t.Fatal("first notification reported as duplicate")
+	}
+	if !d.duplicate(newPortChangePayload(8080, 9090), now.Add(30*time.Second)) {
+		t.Error("repeated notification within window not reported as duplicate")
+	}
+	if d.duplicate(newPortChangePayload(9090, 8080), now.Add(30*time.Second)) {
+		t.Error("notification with different ports reported as duplicate")
+	}
+	if d.duplicate(newPortChangePayload(8080, 9090), now.Add(time.Minute)) {
+		t.Error("notification after window reported as duplicate")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := &rateLimiter{limit: 2}
+	now := time.Now()
+
+	if !r.allow(now) || !r.allow(now.Add(time.Second)) {
+		t.Fatal("allow() = false within limit")
+	}
+	if r.allow(now.Add(2 * time.Second)) {
+		t.Error("allow() = true above limit")
+	}
+	if !r.allow(now.Add(time.Minute)) {
+		t.Error("allow() = false after the oldest notification left the window")
+	}
+}
+
+func TestDispatcherSuppressesDuplicates(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetDeduplication(time.Minute)
+
+	for range 3 {
+		if err := dispatcher.SendPortChange(t.Context(), 8080, 9090); err != nil {
+			t.Fatalf("SendPortChange() error = %v", err)
+		}
+	}
+	if err := dispatcher.SendPortChange(t.Context(), 9090, 8080); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+
+	if len(sender.sent) != 2 {
+		t.Errorf("target got %d notifications, want 2", len(sender.sent))
+	}
+}
+
+func TestDispatcherRateLimitsPerTarget(t *testing.T) {
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetRateLimit(2)
+
+	for port := range 5 {
+		if err := dispatcher.SendPortChange(t.Context(), 8080, 9000+port); err != nil {
+			t.Fatalf("SendPortChange() error = %v", err)
+		}
+	}
+
+	if len(sender.sent) != 2 {
+		t.Errorf("target got %d notifications, want 2", len(sender.sent))
+	}
+}
+
+func TestDispatcherRateLimitDoesNotReplayQueue(t *testing.T) {
+	queue := NewQueue(filepath.Join(t.TempDir(), "queue.jsonl"))
+	for port := range 3 {
+		if err := queue.Add("discord", newPortChangePayload(8080, 9000+port)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sender := &fakeSender{name: "discord"}
+	dispatcher := NewDispatcher(sender)
+	dispatcher.SetQueue(queue)
+	dispatcher.SetRateLimit(1)
+	dispatcher.limiters[0].allow(time.Now())
+
+	if err := dispatcher.SendPortChange(t.Context(), 9000, 9100); err != nil {
+		t.Fatalf("SendPortChange() error = %v", err)
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("rate limited target got %d notifications, want none", len(sender.sent))
+	}
+	if n, err := queue.Len(); err != nil || n != 3 {
+		t.Errorf("queue.Len() = %d, %v, want the queue kept", n, err)
+	}
+}
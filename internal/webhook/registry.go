@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// SenderFactory creates the sender of a target. It is called once per
+// configured target and should validate the target's options.
+type SenderFactory func(target Target) (Sender, error)
+
+var (
+	registryMu sync.RWMutex
+	// registry maps template names to the factory of their sender. The HTTP
+	// templates share the Client, which formats the request per template.
+	registry = map[Template]SenderFactory{
+		TemplateJSON:      senderFactory(NewClient),
+		TemplateDiscord:   senderFactory(NewClient),
+		TemplateSlack:     senderFactory(NewClient),
+		TemplateGotify:    senderFactory(NewClient),
+		TemplateCustom:    senderFactory(NewClient),
+		TemplatePushover:  senderFactory(NewClient),
+		TemplateNtfy:      senderFactory(NewClient),
+		TemplateMatrix:    senderFactory(NewClient),
+		TemplateApprise:   senderFactory(NewClient),
+		TemplatePagerDuty: senderFactory(NewClient),
+		TemplateEmail:     senderFactory(NewEmailSender),
+		TemplateMQTT:      senderFactory(NewMQTTSender),
+	}
+)
+
+// senderFactory adapts a constructor returning a concrete sender, taking care not
+// to return a nil pointer wrapped in a non-nil Sender on error
+func senderFactory[S Sender](newSender func(Target) (S, error)) SenderFactory {
+	return func(target Target) (Sender, error) {
+		sender, err := newSender(target)
+		if err != nil {
+			return nil, err
+		}
+		return sender, nil
+	}
+}
+
+// register makes a sender available as the given template. Like
+// database/sql.Register, it panics if the name is empty or already
+// registered, or if factory is nil.
+func register(template Template, factory SenderFactory) {
+	if template == "" {
+		panic("webhook: register with empty template name")
+	}
+	if factory == nil {
+		panic("webhook: register factory is nil for template " + string(template))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[template]; exists {
+		panic("webhook: register called twice for template " + string(template))
+	}
+	registry[template] = factory
+}
+
+// Templates returns the names of all registered templates in sorted order
+func Templates() []Template {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// NewSender creates the sender registered for the target's template. An
+// empty or unknown template selects the json template.
+func NewSender(target Target) (Sender, error) {
+	if target.Template == "" {
+		target.Template = TemplateJSON
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[target.Template]
+	registryMu.RUnlock()
+	if !ok {
+		slog.Warn("unknown webhook template, using json", "webhook", target.Name, "template", target.Template, "available", fmt.Sprint(Templates()))
+		target.Template = TemplateJSON
+		factory = registry[TemplateJSON]
+	}
+	return factory(target)
+}
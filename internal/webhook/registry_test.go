@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestNewSender_BuiltinTemplates(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		want   string
+	}{
+		{name: "default", target: Target{}, want: "*webhook.Client"},
+		{name: "discord", target: Target{Template: TemplateDiscord}, want: "*webhook.Client"},
+		{name: "unknown falls back to json", target: Target{Template: "telegram"}, want: "*webhook.Client"},
+		{name: "mqtt", target: Target{Template: TemplateMQTT, URL: "mqtt://broker:1883"}, want: "*webhook.MQTTSender"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, err := NewSender(tt.target)
+			if err != nil {
+				t.Fatalf("NewSender() error = %v", err)
+			}
+			if got := fmt.Sprintf("%T", sender); got != tt.want {
+				t.Errorf("NewSender() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSender_Errors(t *testing.T) {
+	sender, err := NewSender(Target{Template: TemplateDiscord, Discord: DiscordOptions{Color: "blue"}})
+	if err == nil {
+		t.Error("NewSender() error = nil, want validation error")
+	}
+	if sender != nil {
+		t.Errorf("NewSender() = %#v, want nil sender on error", sender)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	const template Template = "test-registry"
+	want := &fakeSender{name: "registered"}
+	register(template, func(target Target) (Sender, error) {
+		return want, nil
+	})
+
+	if !slices.Contains(Templates(), template) {
+		t.Errorf("Templates() = %v, want %s included", Templates(), template)
+	}
+	got, err := NewSender(Target{Template: template})
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("NewSender() = %v, want the registered sender", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("register() did not panic for a duplicate template")
+		}
+	}()
+	register(TemplateDiscord, func(target Target) (Sender, error) { return want, nil })
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody caps how much of an error response body is kept
+const maxErrorBody = 512
+
+// errorHeaders are the response headers that help to diagnose a rejected
+// webhook, such as rate limit details from Discord and Slack
+var errorHeaders = []string{
+	"Retry-After",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset-After",
+	"X-RateLimit-Scope",
+}
+
+// StatusError is returned when a target responds with a non-2xx status
+type StatusError struct {
+	StatusCode int
+	// Body is the start of the response body, truncated to maxErrorBody bytes
+	Body string
+	// Header holds the diagnostic headers present in the response
+	Header map[string]string
+}
+
+// Retryable reports whether the status indicates a temporary problem: a
+// timeout, rate limiting or a server error
+func (e *StatusError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// RetryAfter returns the delay requested in a Retry-After header given in
+// seconds, or 0
+func (e *StatusError) RetryAfter() time.Duration {
+	seconds, err := strconv.Atoi(e.Header["Retry-After"])
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (e *StatusError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "webhook returned non-2xx status: %d", e.StatusCode)
+	if e.Body != "" {
+		fmt.Fprintf(&b, ": %s", e.Body)
+	}
+	for _, name := range errorHeaders {
+		if value, ok := e.Header[name]; ok {
+			fmt.Fprintf(&b, " (%s: %s)", name, value)
+		}
+	}
+	return b.String()
+}
+
+// newStatusError reads the diagnostic parts of a non-2xx response
+func newStatusError(resp *http.Response) *StatusError {
+	statusErr := &StatusError{StatusCode: resp.StatusCode}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+	if err == nil {
+		text := strings.TrimSpace(string(body))
+		if len(body) > maxErrorBody {
+			text = strings.TrimSpace(string(body[:maxErrorBody])) + "..."
+		}
+		statusErr.Body = strings.Join(strings.Fields(text), " ")
+	}
+
+	for _, name := range errorHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if statusErr.Header == nil {
+				statusErr.Header = make(map[string]string)
+			}
+			statusErr.Header[name] = value
+		}
+	}
+	return statusErr
+}
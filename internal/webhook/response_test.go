@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientSend_StatusErrorIncludesBodyAndHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-Unrelated", "ignored")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("{\"message\": \"Invalid Form Body\",\n \"code\": 50035}\n"))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateDiscord})
+	err := client.SendPortChange(t.Context(), 8080, 9090)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("SendPortChange() error = %v, want StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d, want 400", statusErr.StatusCode)
+	}
+	if statusErr.Body != `{"message": "Invalid Form Body", "code": 50035}` {
+		t.Errorf("Body = %q", statusErr.Body)
+	}
+	if _, ok := statusErr.Header["X-Unrelated"]; ok {
+		t.Error("Header contains unrelated header")
+	}
+
+	want := `webhook returned non-2xx status: 400: {"message": "Invalid Form Body", "code": 50035} (Retry-After: 2) (X-RateLimit-Remaining: 0)`
+	if statusErr.Error() != want {
+		t.Errorf("Error() = %q, want %q", statusErr.Error(), want)
+	}
+}
+
+func TestNewStatusError_TruncatesBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(http.StatusInternalServerError)
+	_, _ = rec.WriteString(strings.Repeat("x", 2*maxErrorBody))
+
+	statusErr := newStatusError(rec.Result())
+
+	if want := strings.Repeat("x", maxErrorBody) + "..."; statusErr.Body != want {
+		t.Errorf("Body has length %d, want %d", len(statusErr.Body), len(want))
+	}
+	if statusErr.Header != nil {
+		t.Errorf("Header = %v, want nil", statusErr.Header)
+	}
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// PermanentError marks a delivery failure that retrying cannot fix, such as
+// an invalid template, rejected credentials or an unknown endpoint
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable always reports false
+func (e *PermanentError) Retryable() bool {
+	return false
+}
+
+// IsRetryable reports whether a failed delivery may succeed when retried.
+// Errors that do not classify themselves, such as network errors and
+// timeouts, are retryable.
+func IsRetryable(err error) bool {
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+	return err != nil
+}
+
+// retryAfter returns the delay requested by a rate limited target, or 0
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter()
+	}
+	return 0
+}
+
+// RetryPolicy controls how failed webhook deliveries are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// attempts returns the total number of delivery attempts, never less than one
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the delay to wait after the given failed attempt. The delay
+// doubles with every attempt, is capped at MaxDelay, and is jittered into the
+// upper half of the window so that retries from several instances spread out.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if attempt < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		if delay > time.Duration(1<<62) {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// run calls deliver until it succeeds, fails permanently or the attempts are
+// exhausted, waiting with backoff between attempts. A Retry-After delay
+// requested by the target is honored up to MaxDelay. Canceling ctx stops
+// waiting for the next attempt. The returned error wraps the last failure.
+func (p RetryPolicy) run(ctx context.Context, target string, deliver func() error) error {
+	attempts := p.attempts()
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		lastErr = deliver()
+		if lastErr == nil {
+			return nil
+		}
+		if !IsRetryable(lastErr) {
+			if attempt > 1 {
+				return fmt.Errorf("webhook delivery failed permanently after %d attempts: %w", attempt, lastErr)
+			}
+			return lastErr
+		}
+
+		if attempt < attempts {
+			delay := max(p.backoff(attempt), retryAfter(lastErr))
+			if p.MaxDelay > 0 {
+				delay = min(delay, p.MaxDelay)
+			}
+			slog.Warn("webhook delivery failed, retrying",
+				"webhook", target,
+				"attempt", attempt,
+				"max_attempts", attempts,
+				"retry_delay", delay,
+				"error", lastErr,
+			)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("webhook delivery canceled after %d attempts: %w", attempt, lastErr)
+			}
+		}
+	}
+
+	if attempts > 1 {
+		return fmt.Errorf("webhook delivery failed after %d attempts: %w", attempts, lastErr)
+	}
+	return lastErr
+}
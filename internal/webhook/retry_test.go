@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyAttempts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		want        int
+	}{
+		{"zero means single attempt", 0, 1},
+		{"negative means single attempt", -2, 1},
+		{"explicit attempts", 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RetryPolicy{MaxAttempts: tt.maxAttempts}
+			if got := p.attempts(); got != tt.want {
+				t.Errorf("attempts() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 6, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	tests := []struct {
+		name    string
+		attempt int
+		ceiling time.Duration
+	}{
+		{"attempt1", 1, time.Second},
+		{"attempt2", 2, 2 * time.Second},
+		{"attempt3", 3, 4 * time.Second},
+		{"capped at max delay", 4, 5 * time.Second},
+		{"stays capped", 10, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				got := p.backoff(tt.attempt)
+				if got < tt.ceiling/2 || got > tt.ceiling {
+					t.Fatalf("backoff(%d) = %s, want within [%s, %s]", tt.attempt, got, tt.ceiling/2, tt.ceiling)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoffDisabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+	}{
+		{"zero base delay", RetryPolicy{MaxAttempts: 3}, 1},
+		{"invalid attempt", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.attempt); got != 0 {
+				t.Errorf("backoff(%d) = %s, want 0", tt.attempt, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRun_StopsOnPermanentError(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5}
+	calls := 0
+	err := p.run(t.Context(), "test", func() error {
+		calls++
+		return &StatusError{StatusCode: http.StatusNotFound}
+	})
+
+	if calls != 1 {
+		t.Errorf("deliver called %d times, want 1 for a permanent error", calls)
+	}
+	if IsRetryable(err) {
+		t.Errorf("IsRetryable(%v) = true, want false", err)
+	}
+}
+
+func TestRetryPolicyRun_StopsWhenCanceled(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithCancel(t.Context())
+	calls := 0
+	err := p.run(ctx, "test", func() error {
+		calls++
+		cancel()
+		return &StatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+
+	if calls != 1 {
+		t.Errorf("deliver called %d times, want 1 after cancellation", calls)
+	}
+	if err == nil {
+		t.Error("run() error = nil, want the last failure")
+	}
+}
+
+func TestRetryPolicyRun_HonorsRetryAfter(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 2, MaxDelay: 50 * time.Millisecond}
+	calls := 0
+	start := time.Now()
+	err := p.run(t.Context(), "test", func() error {
+		calls++
+		if calls == 1 {
+			return &StatusError{StatusCode: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "30"}}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("waited %v, want Retry-After capped at MaxDelay (50ms)", elapsed)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "network error", err: errors.New("connection refused"), want: true},
+		{name: "server error", err: &StatusError{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "rate limited", err: &StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "request timeout", err: &StatusError{StatusCode: http.StatusRequestTimeout}, want: true},
+		{name: "unauthorized", err: &StatusError{StatusCode: http.StatusUnauthorized}, want: false},
+		{name: "not found", err: &StatusError{StatusCode: http.StatusNotFound}, want: false},
+		{name: "wrapped permanent", err: fmt.Errorf("webhook %q: %w", "x", &PermanentError{Err: errors.New("bad template")}), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import "fmt"
+
+// Severity ranks how urgent a notification is
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// eventSeverities holds the severity of every event above info. Recoveries
+// share the severity of the failure they resolve, so that a target limited
+// to errors also learns when an error is over.
+var eventSeverities = map[string]Severity{
+	EventVPNDown:         SeverityWarning,
+	EventVPNRecovered:    SeverityWarning,
+	EventSourceFailover:  SeverityWarning,
+	EventSourceUnhealthy: SeverityWarning,
+	EventTargetSuspended: SeverityWarning,
+	EventQbitFirewalled:  SeverityWarning,
+	EventQbitConnectable: SeverityWarning,
+	EventSyncError:       SeverityError,
+	EventSyncRecovered:   SeverityError,
+	EventQbitUnreachable: SeverityError,
+	EventQbitRecovered:   SeverityError,
+}
+
+// eventSeverity returns the severity of an event
+func eventSeverity(event string) Severity {
+	return eventSeverities[event]
+}
+
+// ParseSeverity parses a severity name; an empty name is info
+func ParseSeverity(name string) (Severity, error) {
+	switch name {
+	case "", "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q (expected info, warning or error)", name)
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "info"
+	}
+}
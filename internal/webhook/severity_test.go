@@ -0,0 +1,59 @@
+package webhook
+
+import "testing"
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Severity
+		wantErr bool
+	}{
+		{name: "", want: SeverityInfo},
+		{name: "info", want: SeverityInfo},
+		{name: "warning", want: SeverityWarning},
+		{name: "error", want: SeverityError},
+		{name: "critical", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverity(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventEnabled_MinSeverity(t *testing.T) {
+	tests := []struct {
+		event       string
+		minSeverity Severity
+		want        bool
+	}{
+		{event: EventPortChanged, minSeverity: SeverityInfo, want: true},
+		{event: EventPortChanged, minSeverity: SeverityWarning, want: false},
+		{event: EventVPNDown, minSeverity: SeverityWarning, want: true},
+		{event: EventVPNDown, minSeverity: SeverityError, want: false},
+		{event: EventSyncError, minSeverity: SeverityError, want: true},
+		{event: EventSyncRecovered, minSeverity: SeverityError, want: true},
+		{event: EventTest, minSeverity: SeverityError, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event+"/"+tt.minSeverity.String(), func(t *testing.T) {
+			if got := eventEnabled(nil, tt.minSeverity, tt.event); got != tt.want {
+				t.Errorf("eventEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClient_InvalidMinSeverity(t *testing.T) {
+	if _, err := NewClient(Target{Template: TemplateJSON, MinSeverity: "fatal"}); err == nil {
+		t.Error("NewClient() error = nil, want error for unknown severity")
+	}
+}
@@ -0,0 +1,19 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the request body when
+// a shared secret is configured for the target
+const SignatureHeader = "X-Forwardarr-Signature"
+
+// sign returns the signature of body in the "sha256=<hex digest>" format used
+// by GitHub webhooks, so existing receiver verification code can be reused
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Reference value from GitHub's webhook validation documentation
+	got := sign("It's a Secret to Everybody", []byte("Hello, World!"))
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != want {
+		t.Errorf("sign() = %s, want %s", got, want)
+	}
+}
+
+func TestSendPortChange_SignsPayload(t *testing.T) {
+	const secret = "s3cr3t"
+
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(SignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON, Secret: secret})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+	}
+}
+
+func TestSendPortChange_NoSignatureWithoutSecret(t *testing.T) {
+	var present bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, present = r.Header[SignatureHeader]
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, Target{URL: server.URL, Timeout: 5 * time.Second, Template: TemplateJSON})
+	if err := client.SendPortChange(t.Context(), 8080, 9090); err != nil {
+		t.Fatalf("SendPortChange() error = %v, want nil", err)
+	}
+	if present {
+		t.Errorf("%s header present, want absent", SignatureHeader)
+	}
+}
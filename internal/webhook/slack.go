@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SlackOptions configures the slack template. Channel, Username and
+// IconEmoji are only honored by legacy incoming webhooks and Slack-compatible
+// receivers such as Mattermost or Rocket.Chat.
+type SlackOptions struct {
+	Channel   string
+	Username  string
+	IconEmoji string
+	// Attachments sends the legacy attachments format instead of Block Kit
+	Attachments bool
+}
+
+// formatSlack formats payload for Slack webhook
+func (c *Client) formatSlack(payload Payload) ([]byte, error) {
+	var slack map[string]interface{}
+	if c.slack.Attachments {
+		slack = slackAttachments(payload)
+	} else {
+		slack = slackBlocks(payload)
+	}
+
+	if c.slack.Channel != "" {
+		slack["channel"] = c.slack.Channel
+	}
+	if c.slack.Username != "" {
+		slack["username"] = c.slack.Username
+	}
+	if c.slack.IconEmoji != "" {
+		slack["icon_emoji"] = c.slack.IconEmoji
+	}
+	return json.Marshal(slack)
+}
+
+// slackBlocks builds a Block Kit message
+func slackBlocks(payload Payload) map[string]interface{} {
+	fields := []map[string]string{}
+	for _, f := range payloadFields(payload) {
+		fields = append(fields, map[string]string{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("*%s:*\n%s", f.name, f.value),
+		})
+	}
+	fields = append(fields, map[string]string{
+		"type": "mrkdwn",
+		"text": fmt.Sprintf("*Time:*\n%s", payload.Timestamp.Format(time.RFC3339)),
+	})
+
+	return map[string]interface{}{
+		"text": payload.Message,
+		"blocks": []map[string]interface{}{
+			{
+				"type": "section",
+				"text": map[string]string{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf("*%s*\n%s", payloadTitle(payload), payload.Message),
+				},
+			},
+			{
+				"type":   "section",
+				"fields": fields,
+			},
+		},
+	}
+}
+
+// slackAttachments builds a message in the legacy attachments format for
+// receivers without Block Kit support
+func slackAttachments(payload Payload) map[string]interface{} {
+	fields := []map[string]interface{}{}
+	for _, f := range payloadFields(payload) {
+		fields = append(fields, map[string]interface{}{
+			"title": f.name,
+			"value": f.value,
+			"short": f.name != "Error",
+		})
+	}
+
+	color := "good"
+	if payload.Error != "" {
+		color = "danger"
+	}
+
+	return map[string]interface{}{
+		"text": payload.Message,
+		"attachments": []map[string]interface{}{
+			{
+				"fallback": payload.Message,
+				"color":    color,
+				"title":    payloadTitle(payload),
+				"text":     payload.Message,
+				"fields":   fields,
+				"ts":       payload.Timestamp.Unix(),
+			},
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSlackTemplate_Overrides(t *testing.T) {
+	client := newTestClient(t, Target{
+		Template: TemplateSlack,
+		Slack:    SlackOptions{Channel: "#alerts", Username: "Forwardarr", IconEmoji: ":satellite:"},
+	})
+
+	body, err := client.formatSlack(newPortChangePayload(8080, 9090))
+	if err != nil {
+		t.Fatalf("formatSlack() error = %v", err)
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if msg["channel"] != "#alerts" || msg["username"] != "Forwardarr" || msg["icon_emoji"] != ":satellite:" {
+		t.Errorf("overrides = %v, %v, %v", msg["channel"], msg["username"], msg["icon_emoji"])
+	}
+	if _, ok := msg["blocks"]; !ok {
+		t.Error("message has no blocks, want Block Kit by default")
+	}
+}
+
+func TestSlackTemplate_Attachments(t *testing.T) {
+	client := newTestClient(t, Target{Template: TemplateSlack, Slack: SlackOptions{Attachments: true}})
+
+	body, err := client.formatSlack(newSyncErrorPayload("qbittorrent", errors.New("timeout"), 2))
+	if err != nil {
+		t.Fatalf("formatSlack() error = %v", err)
+	}
+
+	var msg struct {
+		Text        string            `json:"text"`
+		Blocks      []json.RawMessage `json:"blocks"`
+		Attachments []struct {
+			Fallback string `json:"fallback"`
+			Color    string `json:"color"`
+			Title    string `json:"title"`
+			Fields   []struct {
+				Title string `json:"title"`
+				Value string `json:"value"`
+				Short bool   `json:"short"`
+			} `json:"fields"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if len(msg.Blocks) != 0 {
+		t.Error("attachments message contains blocks")
+	}
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("len(attachments) = %d, want 1", len(msg.Attachments))
+	}
+	attachment := msg.Attachments[0]
+	if attachment.Color != "danger" || attachment.Title != "Port Sync Failed" || attachment.Fallback != msg.Text {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	last := attachment.Fields[len(attachment.Fields)-1]
+	if last.Title != "Error" || last.Value != "timeout" || last.Short {
+		t.Errorf("last field = %+v, want long Error field", last)
+	}
+}
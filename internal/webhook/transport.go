@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Connection pool settings of the webhook transports. Several targets often
+// share a host (e.g. multiple Discord webhooks), and a burst of events reaches
+// every target at once, so more idle connections per host are kept than the
+// default of two.
+const (
+	dialTimeout         = 10 * time.Second
+	keepAlive           = 30 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+	idleConnTimeout     = 90 * time.Second
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 10
+)
+
+// sharedTransport is used by all targets without TLS options or an explicit
+// proxy, so that they reuse connections instead of dialing per target
+var sharedTransport = sync.OnceValue(newTransport)
+
+// TLSOptions customizes certificate verification and client authentication
+// for a target, e.g. for self-hosted services signed by a private CA
+type TLSOptions struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile string
+	// CertFile and KeyFile hold a PEM client certificate and its key
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables certificate verification entirely
+	InsecureSkipVerify bool
+}
+
+// config builds the TLS configuration for the options
+func (o TLSOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %q", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// newTransport creates a pooled transport honoring the standard HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables
+func newTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: keepAlive}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		IdleConnTimeout:       idleConnTimeout,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// newHTTPClient creates the HTTP client for a target. Targets with TLS
+// options or an explicit proxy get a transport of their own; all others share
+// one. Without an explicit proxy, the standard proxy environment variables
+// are honored.
+func newHTTPClient(proxy string, tlsOptions TLSOptions) (*http.Client, error) {
+	if proxy == "" && tlsOptions == (TLSOptions{}) {
+		return &http.Client{Transport: sharedTransport()}, nil
+	}
+
+	tlsConfig, err := tlsOptions.config()
+	if err != nil {
+		return nil, err
+	}
+	transport := newTransport()
+	transport.TLSClientConfig = tlsConfig
+
+	if proxy != "" {
+		proxyURL, err := parseProxyURL(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// dialContext opens the TCP connection of the email sender, over
+// TLS if tlsConfig is set
+func dialContext(ctx context.Context, addr string, timeout time.Duration, tlsConfig *tls.Config) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if tlsConfig != nil {
+		return (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// parseProxyURL validates an explicit proxy URL
+func parseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+	}
+	return u, nil
+}
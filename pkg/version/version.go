@@ -3,6 +3,7 @@ package version
 import (
 	"fmt"
 	"runtime"
+	"runtime/debug"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -26,6 +27,58 @@ func init() {
 	info.WithLabelValues(Version, Commit, Date, runtime.Version()).Set(1)
 }
 
+// BuildInfo describes the running build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	Compiler  string `json:"compiler"`
+}
+
+// Build returns the details of the running build. Commit and date fall back
+// to the VCS information recorded by the Go toolchain if they were not set
+// at build time.
+func Build() BuildInfo {
+	build := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Compiler:  runtime.Compiler,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		applyVCSSettings(&build, bi.Settings)
+	}
+	return build
+}
+
+// applyVCSSettings fills in commit and date from the VCS build settings where
+// they are unknown. A commit with local modifications is marked as dirty.
+func applyVCSSettings(build *BuildInfo, settings []debug.BuildSetting) {
+	var revision, modified string
+	for _, setting := range settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.time":
+			if build.Date == "unknown" {
+				build.Date = setting.Value
+			}
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if build.Commit == "unknown" && revision != "" {
+		build.Commit = revision
+		if modified == "true" {
+			build.Commit += "-dirty"
+		}
+	}
+}
+
 func String() string {
 	return fmt.Sprintf("Forwardarr %s (commit: %s, built: %s)", Version, Commit, Date)
 }
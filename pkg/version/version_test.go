@@ -1,6 +1,7 @@
 package version
 
 import (
+	"runtime/debug"
 	"strings"
 	"testing"
 )
@@ -62,3 +63,33 @@ func TestStringContainsForwardarr(t *testing.T) {
 		t.Errorf("String() = %q, should contain 'Forwardarr'", result)
 	}
 }
+
+func TestBuild(t *testing.T) {
+	build := Build()
+	if build.Version != Version {
+		t.Errorf("Build().Version = %q, want %q", build.Version, Version)
+	}
+	if !strings.HasPrefix(build.GoVersion, "go") || !strings.Contains(build.Platform, "/") {
+		t.Errorf("Build() runtime = %q on %q, want the Go version and os/arch", build.GoVersion, build.Platform)
+	}
+}
+
+func TestApplyVCSSettings(t *testing.T) {
+	settings := []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "0123abcd"},
+		{Key: "vcs.time", Value: "2025-01-02T03:04:05Z"},
+		{Key: "vcs.modified", Value: "true"},
+	}
+
+	build := BuildInfo{Commit: "unknown", Date: "unknown"}
+	applyVCSSettings(&build, settings)
+	if build.Commit != "0123abcd-dirty" || build.Date != "2025-01-02T03:04:05Z" {
+		t.Errorf("unknown build = %q built %q, want the VCS details", build.Commit, build.Date)
+	}
+
+	build = BuildInfo{Commit: "abc123", Date: "2024-01-01"}
+	applyVCSSettings(&build, settings)
+	if build.Commit != "abc123" || build.Date != "2024-01-01" {
+		t.Errorf("injected build = %q built %q, want the injected details kept", build.Commit, build.Date)
+	}
+}